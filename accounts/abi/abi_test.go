@@ -1141,6 +1141,9 @@ func TestUnpackRevert(t *testing.T) {
 		{"", "", errors.New("invalid data for unpacking")},
 		{"08c379a1", "", errors.New("invalid data for unpacking")},
 		{"08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000d72657665727420726561736f6e00000000000000000000000000000000000000", "revert reason", nil},
+		{"4e487b710000000000000000000000000000000000000000000000000000000000000000", "generic panic", nil},
+		{"4e487b710000000000000000000000000000000000000000000000000000000000000011", "arithmetic underflow or overflow", nil},
+		{"4e487b7100000000000000000000000000000000000000000000000000000000000000ff", "unknown panic code: 0xff", nil},
 	}
 	for index, c := range cases {
 		t.Run(fmt.Sprintf("case %d", index), func(t *testing.T) {
@@ -1160,3 +1163,72 @@ func TestUnpackRevert(t *testing.T) {
 		})
 	}
 }
+
+// insufficientBalanceError is a hand written binding of the InsufficientBalance
+// error, as abigen would generate it.
+type insufficientBalanceError struct {
+	Available *big.Int
+	Required  *big.Int
+}
+
+func (*insufficientBalanceError) ErrorSig() string { return "InsufficientBalance(uint256,uint256)" }
+func (*insufficientBalanceError) Error() string    { return "InsufficientBalance" }
+
+type unauthorizedError struct{}
+
+func (*unauthorizedError) ErrorSig() string { return "Unauthorized()" }
+func (*unauthorizedError) Error() string    { return "Unauthorized" }
+
+func TestUnpackRevertError(t *testing.T) {
+	t.Parallel()
+
+	const def = `[
+		{"type":"error","name":"InsufficientBalance","inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}]},
+		{"type":"error","name":"Unauthorized","inputs":[]}
+	]`
+	abi, err := JSON(strings.NewReader(def))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(crypto.Keccak256([]byte("InsufficientBalance(uint256,uint256)"))[:4],
+		append(common.LeftPadBytes([]byte{5}, 32), common.LeftPadBytes([]byte{10}, 32)...)...)
+
+	revert, err := abi.UnpackRevertError(data)
+	if err != nil {
+		t.Fatalf("failed to unpack revert: %v", err)
+	}
+	if revert.Def.Name != "InsufficientBalance" {
+		t.Fatalf("wrong error matched: %s", revert.Def.Name)
+	}
+	if want := "execution reverted: InsufficientBalance(5, 10)"; revert.Error() != want {
+		t.Fatalf("error string mismatch: have %q, want %q", revert.Error(), want)
+	}
+	// Convert the revert into the typed errors
+	var wrapped error = fmt.Errorf("call failed: %w", revert)
+
+	var balanceErr *insufficientBalanceError
+	if !errors.As(wrapped, &balanceErr) {
+		t.Fatal("failed to convert revert to typed error")
+	}
+	if balanceErr.Available.Int64() != 5 || balanceErr.Required.Int64() != 10 {
+		t.Fatalf("typed error mismatch: %v %v", balanceErr.Available, balanceErr.Required)
+	}
+	var authErr *unauthorizedError
+	if errors.As(wrapped, &authErr) {
+		t.Fatal("converted revert to the wrong typed error")
+	}
+	// Errors without inputs and undeclared errors
+	revert, err = abi.UnpackRevertError(crypto.Keccak256([]byte("Unauthorized()"))[:4])
+	if err != nil {
+		t.Fatalf("failed to unpack revert: %v", err)
+	}
+	if !errors.As(revert, &authErr) {
+		t.Fatal("failed to convert revert to typed error")
+	}
+	if _, err := abi.UnpackRevertError(crypto.Keccak256([]byte("Unknown()"))[:4]); err == nil {
+		t.Fatal("unpacked undeclared error")
+	}
+	if _, err := abi.UnpackRevertError([]byte{1, 2}); err == nil {
+		t.Fatal("unpacked short revert data")
+	}
+}
@@ -39,6 +39,10 @@ var (
 	// ErrNoCodeAfterDeploy is returned by WaitDeployed if contract creation leaves
 	// an empty contract behind.
 	ErrNoCodeAfterDeploy = errors.New("no contract code after deployment")
+
+	// ErrNoBlockNumber is returned by CallBatch.Execute if no block number is given
+	// and the backend can't report its latest header to pin the calls to.
+	ErrNoBlockNumber = errors.New("no block number to pin batch to and backend does not report headers")
 )
 
 // ContractCaller defines the methods needed to allow operating with a contract on a read
@@ -64,6 +68,16 @@ type PendingContractCaller interface {
 	PendingCallContract(ctx context.Context, call alba.CallMsg) ([]byte, error)
 }
 
+// BatchContractCaller defines the methods needed to execute several contract calls
+// in a single round trip. CallBatch will try to discover this interface and falls
+// back to calling the contracts one by one if the backend doesn't implement it.
+type BatchContractCaller interface {
+	// BatchCallContract executes the calls at the given block, returning the output
+	// and the error of each of them. The returned error is only set if the batch
+	// as a whole failed.
+	BatchCallContract(ctx context.Context, calls []alba.CallMsg, blockNumber *big.Int) ([][]byte, []error, error)
+}
+
 // ContractTransactor defines the methods needed to allow operating with a contract
 // on a write only basis. Besides the transacting method, the remainder are helpers
 // used when the user does not provide some needed values, but rather leaves it up
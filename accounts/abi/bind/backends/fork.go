@@ -0,0 +1,454 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/pictor01/ALBA/common"
+	"github.com/pictor01/ALBA/common/hexutil"
+	"github.com/pictor01/ALBA/consensus/albaash"
+	"github.com/pictor01/ALBA/core"
+	"github.com/pictor01/ALBA/core/rawdb"
+	"github.com/pictor01/ALBA/core/state"
+	"github.com/pictor01/ALBA/core/types"
+	"github.com/pictor01/ALBA/core/vm"
+	"github.com/pictor01/ALBA/crypto"
+	"github.com/pictor01/ALBA/params"
+	"github.com/pictor01/ALBA/rlp"
+	"github.com/pictor01/ALBA/rpc"
+	"github.com/pictor01/ALBA/trie"
+)
+
+// forkRequestTimeout is the time allowance of a single request to the node the
+// simulated chain was forked from.
+const forkRequestTimeout = 30 * time.Second
+
+// forkDeleted marks an item that was deleted on the fork, but still exists in the
+// remote state. It is neither a valid account nor a valid storage slot encoding,
+// so it never leaks out of the fork tries.
+var forkDeleted = []byte{0xc0}
+
+// NewForkedSimulatedBackend creates a new binding backend on top of the state of
+// a remote node at the given block (nil meaning the latest one). Accounts, code
+// and storage are retrieved on first access and cached locally, otherwise the
+// backend behaves like a normal simulated blockchain. Accounts in alloc replace
+// their remote counterparts.
+//
+// The simulated chain starts anew from a genesis block inheriting the time and base
+// fee of the forked block. Its block hashes, numbers and state roots are local.
+// A simulated backend always uses chainID 1337.
+func NewForkedSimulatedBackend(client *rpc.Client, blockNumber *big.Int, alloc core.GenesisAlloc, gasLimit uint64) (*SimulatedBackend, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), forkRequestTimeout)
+	defer cancel()
+
+	var head *types.Header
+	if err := client.CallContext(ctx, &head, "eth_getBlockByNumber", toForkBlockNumArg(blockNumber), false); err != nil {
+		return nil, fmt.Errorf("failed to retrieve fork block: %v", err)
+	}
+	if head == nil {
+		return nil, errBlockDoesNotExist
+	}
+	var (
+		database = rawdb.NewMemoryDatabase()
+		source   = newForkSource(client, head.Number)
+		genesis  = core.Genesis{
+			Config:    params.AllAlbaashProtocolChanges,
+			GasLimit:  gasLimit,
+			Alloc:     alloc,
+			Timestamp: head.Time,
+			BaseFee:   head.BaseFee,
+		}
+	)
+	source.addAccountRoot(genesis.MustCommit(database).Root())
+
+	cacheConfig := &core.CacheConfig{
+		TrieCleanLimit:      256,
+		TrieCleanNoPrefetch: true,
+		TrieDirtyLimit:      256,
+		TrieTimeLimit:       5 * time.Minute,
+		StateDatabase: func(db state.Database) state.Database {
+			return &forkDatabase{Database: db, source: source}
+		},
+	}
+	blockchain, err := core.NewBlockChain(database, cacheConfig, genesis.Config, albaash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	stateDatabase := &forkDatabase{Database: state.NewDatabase(database), source: source}
+	return newSimulatedBackend(database, blockchain, stateDatabase), nil
+}
+
+func toForkBlockNumArg(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	return hexutil.EncodeBig(number)
+}
+
+// forkAccount is an account of the remote state.
+type forkAccount struct {
+	address common.Address
+	hash    common.Hash        // Hash of the address, the owner of the storage trie
+	data    types.StateAccount // Account data with the remote storage root
+}
+
+// forkSource retrieves the remote state at the forked block. The remote state is
+// immutable, so everything retrieved is cached for the lifetime of the backend.
+type forkSource struct {
+	client *rpc.Client
+	number string // Block number argument of the forked block
+
+	lock     sync.Mutex
+	accounts map[common.Address]*forkAccount                // Retrieved accounts, nil if nonexistent
+	storage  map[common.Address]map[common.Hash]common.Hash // Retrieved storage slots
+	code     map[common.Hash][]byte                         // Retrieved contract code
+
+	// Roots of the tries falling back to the remote state. The trie prefetcher
+	// opens tries by root alone, so both kinds need to be recognizable.
+	accountRoots map[common.Hash]struct{}
+	storageRoots map[common.Hash]map[common.Hash]*forkAccount // Root -> owner hash -> owner
+}
+
+func newForkSource(client *rpc.Client, number *big.Int) *forkSource {
+	return &forkSource{
+		client:       client,
+		number:       hexutil.EncodeBig(number),
+		accounts:     make(map[common.Address]*forkAccount),
+		storage:      make(map[common.Address]map[common.Hash]common.Hash),
+		code:         make(map[common.Hash][]byte),
+		accountRoots: make(map[common.Hash]struct{}),
+		storageRoots: make(map[common.Hash]map[common.Hash]*forkAccount),
+	}
+}
+
+// account retrieves an account of the remote state, or nil if it doesn't exist.
+func (s *forkSource) account(addr common.Address) (*forkAccount, error) {
+	s.lock.Lock()
+	account, ok := s.accounts[addr]
+	s.lock.Unlock()
+	if ok {
+		return account, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), forkRequestTimeout)
+	defer cancel()
+
+	var (
+		proof struct {
+			Balance     *hexutil.Big   `json:"balance"`
+			Nonce       hexutil.Uint64 `json:"nonce"`
+			StorageHash common.Hash    `json:"storageHash"`
+		}
+		code  hexutil.Bytes
+		batch = []rpc.BatchElem{
+			{Method: "eth_getProof", Args: []interface{}{addr, []string{}, s.number}, Result: &proof},
+			{Method: "eth_getCode", Args: []interface{}{addr, s.number}, Result: &code},
+		}
+	)
+	if err := s.client.BatchCallContext(ctx, batch); err != nil {
+		return nil, err
+	}
+	for _, elem := range batch {
+		if elem.Error != nil {
+			return nil, fmt.Errorf("failed to retrieve fork account %x: %v", addr, elem.Error)
+		}
+	}
+	balance := (*big.Int)(proof.Balance)
+	if balance == nil {
+		balance = new(big.Int)
+	}
+	root := proof.StorageHash
+	if root == (common.Hash{}) {
+		root = types.EmptyRootHash
+	}
+	if proof.Nonce != 0 || balance.Sign() != 0 || len(code) > 0 || root != types.EmptyRootHash {
+		account = &forkAccount{
+			address: addr,
+			hash:    crypto.Keccak256Hash(addr[:]),
+			data: types.StateAccount{
+				Nonce:    uint64(proof.Nonce),
+				Balance:  balance,
+				Root:     root,
+				CodeHash: crypto.Keccak256(code),
+			},
+		}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.accounts[addr] = account
+	if account != nil {
+		s.code[common.BytesToHash(account.data.CodeHash)] = code
+		if root != types.EmptyRootHash {
+			s.addStorageRootLocked(root, account)
+		}
+	}
+	return account, nil
+}
+
+// slot retrieves a storage slot of a remote account.
+func (s *forkSource) slot(account *forkAccount, key common.Hash) (common.Hash, error) {
+	s.lock.Lock()
+	value, ok := s.storage[account.address][key]
+	s.lock.Unlock()
+	if ok {
+		return value, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), forkRequestTimeout)
+	defer cancel()
+
+	var result hexutil.Bytes
+	if err := s.client.CallContext(ctx, &result, "eth_getStorageAt", account.address, key, s.number); err != nil {
+		return common.Hash{}, fmt.Errorf("failed to retrieve fork storage %x/%x: %v", account.address, key, err)
+	}
+	value = common.BytesToHash(result)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.storage[account.address] == nil {
+		s.storage[account.address] = make(map[common.Hash]common.Hash)
+	}
+	s.storage[account.address][key] = value
+	return value, nil
+}
+
+// contractCode returns the code of a remote account, or nil if it's unknown.
+func (s *forkSource) contractCode(hash common.Hash) []byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.code[hash]
+}
+
+func (s *forkSource) addAccountRoot(root common.Hash) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.accountRoots[root] = struct{}{}
+}
+
+func (s *forkSource) addStorageRoot(root common.Hash, owner *forkAccount) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.addStorageRootLocked(root, owner)
+}
+
+func (s *forkSource) addStorageRootLocked(root common.Hash, owner *forkAccount) {
+	if s.storageRoots[root] == nil {
+		s.storageRoots[root] = make(map[common.Hash]*forkAccount)
+	}
+	s.storageRoots[root][owner.hash] = owner
+}
+
+// forkDatabase is a state database backed by the remote state. The tries opened
+// from it hold the changes made on the fork and fall back to the remote state for
+// anything they don't contain.
+type forkDatabase struct {
+	state.Database
+	source *forkSource
+}
+
+// OpenTrie opens the main account trie at a specific root hash.
+func (db *forkDatabase) OpenTrie(root common.Hash) (state.Trie, error) {
+	db.source.lock.Lock()
+	_, account := db.source.accountRoots[root]
+	owners := db.source.storageRoots[root]
+	db.source.lock.Unlock()
+
+	switch {
+	case account:
+		tr, err := db.Database.OpenTrie(root)
+		if err != nil {
+			return nil, err
+		}
+		return &forkTrie{Trie: tr, source: db.source}, nil
+
+	case len(owners) == 1:
+		// The trie prefetcher opens storage tries without their owner
+		for hash, owner := range owners {
+			return db.openStorageTrie(hash, root, owner)
+		}
+	case len(owners) > 1:
+		return nil, errors.New("ambiguous fork storage root")
+	}
+	return db.Database.OpenTrie(root)
+}
+
+// OpenStorageTrie opens the storage trie of an account.
+func (db *forkDatabase) OpenStorageTrie(addrHash, root common.Hash) (state.Trie, error) {
+	db.source.lock.Lock()
+	owner := db.source.storageRoots[root][addrHash]
+	db.source.lock.Unlock()
+
+	if owner == nil {
+		return db.Database.OpenStorageTrie(addrHash, root)
+	}
+	return db.openStorageTrie(addrHash, root, owner)
+}
+
+func (db *forkDatabase) openStorageTrie(addrHash, root common.Hash, owner *forkAccount) (state.Trie, error) {
+	// The remote storage root stands for a storage without local changes
+	if root == owner.data.Root {
+		root = types.EmptyRootHash
+	}
+	tr, err := db.Database.OpenStorageTrie(addrHash, root)
+	if err != nil {
+		return nil, err
+	}
+	return &forkTrie{Trie: tr, source: db.source, owner: owner}, nil
+}
+
+// CopyTrie returns an independent copy of the given trie.
+func (db *forkDatabase) CopyTrie(t state.Trie) state.Trie {
+	if t, ok := t.(*forkTrie); ok {
+		return &forkTrie{Trie: db.Database.CopyTrie(t.Trie), source: t.source, owner: t.owner}
+	}
+	return db.Database.CopyTrie(t)
+}
+
+// ContractCode retrieves a particular contract's code.
+func (db *forkDatabase) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
+	code, err := db.Database.ContractCode(addrHash, codeHash)
+	if err != nil {
+		if remote := db.source.contractCode(codeHash); remote != nil {
+			return remote, nil
+		}
+	}
+	return code, err
+}
+
+// ContractCodeWithPrefix retrieves a particular contract's code.
+func (db *forkDatabase) ContractCodeWithPrefix(addrHash, codeHash common.Hash) ([]byte, error) {
+	type codeReader interface {
+		ContractCodeWithPrefix(addrHash, codeHash common.Hash) ([]byte, error)
+	}
+	code, err := db.Database.(codeReader).ContractCodeWithPrefix(addrHash, codeHash)
+	if err != nil {
+		if remote := db.source.contractCode(codeHash); remote != nil {
+			return remote, nil
+		}
+	}
+	return code, err
+}
+
+// ContractCodeSize retrieves a particular contracts code's size.
+func (db *forkDatabase) ContractCodeSize(addrHash, codeHash common.Hash) (int, error) {
+	size, err := db.Database.ContractCodeSize(addrHash, codeHash)
+	if err != nil {
+		if remote := db.source.contractCode(codeHash); remote != nil {
+			return len(remote), nil
+		}
+	}
+	return size, err
+}
+
+// forkTrie is an account or storage trie of the fork. Reads of items not stored
+// locally are served from the remote state, deletions of remote items are stored
+// as forkDeleted markers. Hashing, iteration and proofs only cover the local items.
+type forkTrie struct {
+	state.Trie // Local trie holding the changes made on the fork
+
+	source *forkSource
+	owner  *forkAccount // Remote owner of a storage trie, nil for the account trie
+}
+
+// TryGet returns the value for key stored in the trie.
+func (t *forkTrie) TryGet(key []byte) ([]byte, error) {
+	value, err := t.Trie.TryGet(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) > 0 {
+		if bytes.Equal(value, forkDeleted) {
+			return nil, nil
+		}
+		return value, nil
+	}
+	return t.remote(key)
+}
+
+// remote returns the encoded remote value for key, or nil if there is none.
+func (t *forkTrie) remote(key []byte) ([]byte, error) {
+	if t.owner == nil {
+		account, err := t.source.account(common.BytesToAddress(key))
+		if account == nil || err != nil {
+			return nil, err
+		}
+		return rlp.EncodeToBytes(&account.data)
+	}
+	value, err := t.source.slot(t.owner, common.BytesToHash(key))
+	if value == (common.Hash{}) || err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(common.TrimLeftZeroes(value[:]))
+}
+
+// TryUpdate associates key with value in the trie.
+func (t *forkTrie) TryUpdate(key, value []byte) error {
+	if len(value) == 0 {
+		return t.TryDelete(key)
+	}
+	return t.Trie.TryUpdate(key, value)
+}
+
+// TryDelete removes any existing value for key from the trie.
+func (t *forkTrie) TryDelete(key []byte) error {
+	remote, err := t.remote(key)
+	if err != nil {
+		return err
+	}
+	if remote != nil {
+		return t.Trie.TryUpdate(key, forkDeleted)
+	}
+	return t.Trie.TryDelete(key)
+}
+
+// Hash returns the root hash of the trie.
+func (t *forkTrie) Hash() common.Hash {
+	return t.track(t.Trie.Hash())
+}
+
+// CommitNodes writes all nodes to the trie's memory database.
+func (t *forkTrie) CommitNodes(onleaf trie.LeafCallback) (common.Hash, *trie.NodeSet, int, error) {
+	root, nodes, committed, err := t.Trie.CommitNodes(onleaf)
+	if err != nil {
+		return root, nodes, committed, err
+	}
+	return t.track(root), nodes, committed, nil
+}
+
+// track converts the root of the local trie into the root of the fork trie and
+// records it, so the trie can be reopened with the remote fallback.
+func (t *forkTrie) track(root common.Hash) common.Hash {
+	if t.owner == nil {
+		t.source.addAccountRoot(root)
+		return root
+	}
+	if root == types.EmptyRootHash {
+		return t.owner.data.Root
+	}
+	t.source.addStorageRoot(root, t.owner)
+	return root
+}
@@ -0,0 +1,222 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/pictor01/ALBA"
+	"github.com/pictor01/ALBA/common"
+	"github.com/pictor01/ALBA/common/hexutil"
+	"github.com/pictor01/ALBA/core"
+	"github.com/pictor01/ALBA/core/state"
+	"github.com/pictor01/ALBA/core/types"
+	"github.com/pictor01/ALBA/crypto"
+	"github.com/pictor01/ALBA/rpc"
+)
+
+// forkTestCode is the runtime code of a contract returning its storage slot zero
+// if called without data, and otherwise storing the first word of the call data
+// in it.
+var forkTestCode = common.FromHex("3615600c57600035600055005b60005460005260206000f3")
+
+// forkTestAPI serves the state of a simulated chain over RPC, like a remote node
+// would, and counts the requests made.
+type forkTestAPI struct {
+	chain *core.BlockChain
+
+	lock     sync.Mutex
+	requests map[string]int
+}
+
+func (api *forkTestAPI) count(method string) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	api.requests[method]++
+}
+
+func (api *forkTestAPI) requestCount(method string) int {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	return api.requests[method]
+}
+
+func (api *forkTestAPI) header(number rpc.BlockNumber) *types.Header {
+	if number == rpc.LatestBlockNumber {
+		return api.chain.CurrentHeader()
+	}
+	return api.chain.GetHeaderByNumber(uint64(number))
+}
+
+func (api *forkTestAPI) state(number rpc.BlockNumber) (*state.StateDB, error) {
+	header := api.header(number)
+	if header == nil {
+		return nil, errors.New("unknown block")
+	}
+	return api.chain.StateAt(header.Root)
+}
+
+func (api *forkTestAPI) GetBlockByNumber(number rpc.BlockNumber, full bool) (*types.Header, error) {
+	api.count("getBlockByNumber")
+	return api.header(number), nil
+}
+
+func (api *forkTestAPI) GetProof(addr common.Address, keys []string, number rpc.BlockNumber) (map[string]interface{}, error) {
+	api.count("getProof")
+	statedb, err := api.state(number)
+	if err != nil {
+		return nil, err
+	}
+	root := types.EmptyRootHash
+	if tr := statedb.StorageTrie(addr); tr != nil {
+		root = tr.Hash()
+	}
+	return map[string]interface{}{
+		"balance":     (*hexutil.Big)(statedb.GetBalance(addr)),
+		"nonce":       hexutil.Uint64(statedb.GetNonce(addr)),
+		"storageHash": root,
+	}, nil
+}
+
+func (api *forkTestAPI) GetCode(addr common.Address, number rpc.BlockNumber) (hexutil.Bytes, error) {
+	api.count("getCode")
+	statedb, err := api.state(number)
+	if err != nil {
+		return nil, err
+	}
+	return statedb.GetCode(addr), nil
+}
+
+func (api *forkTestAPI) GetStorageAt(addr common.Address, key common.Hash, number rpc.BlockNumber) (hexutil.Bytes, error) {
+	api.count("getStorageAt")
+	statedb, err := api.state(number)
+	if err != nil {
+		return nil, err
+	}
+	return statedb.GetState(addr, key).Bytes(), nil
+}
+
+func TestForkedSimulatedBackend(t *testing.T) {
+	var (
+		testAddr = crypto.PubkeyToAddress(testKey.PublicKey)
+		contract = common.HexToAddress("0xc0de")
+		funded   = common.HexToAddress("0xf00d")
+		bgCtx    = context.Background()
+	)
+	// Create the remote chain and change the contract storage after the fork block
+	remote := NewSimulatedBackend(core.GenesisAlloc{
+		testAddr: {Balance: big.NewInt(10000000000000000)},
+		contract: {Balance: new(big.Int), Code: forkTestCode, Storage: map[common.Hash]common.Hash{{}: common.BigToHash(big.NewInt(42))}},
+	}, 10000000)
+	defer remote.Close()
+
+	sendForkTestTx(t, remote, contract, 7)
+	remote.Commit()
+
+	api := &forkTestAPI{chain: remote.Blockchain(), requests: make(map[string]int)}
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatal(err)
+	}
+	sim, err := NewForkedSimulatedBackend(rpc.DialInProc(server), big.NewInt(0), core.GenesisAlloc{
+		funded: {Balance: big.NewInt(1)},
+	}, 10000000)
+	if err != nil {
+		t.Fatalf("failed to fork: %v", err)
+	}
+	defer sim.Close()
+
+	// The remote state of the fork block is visible
+	if have := callForkTest(t, sim, contract); have != 42 {
+		t.Errorf("storage mismatch: have %d, want %d", have, 42)
+	}
+	if code, err := sim.CodeAt(bgCtx, contract, nil); err != nil || string(code) != string(forkTestCode) {
+		t.Errorf("code mismatch: have %x, want %x (err %v)", code, forkTestCode, err)
+	}
+	if balance, err := sim.BalanceAt(bgCtx, testAddr, nil); err != nil || balance.Cmp(big.NewInt(10000000000000000)) != 0 {
+		t.Errorf("balance mismatch: have %v (err %v)", balance, err)
+	}
+	if balance, err := sim.BalanceAt(bgCtx, funded, nil); err != nil || balance.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("allocated balance mismatch: have %v (err %v)", balance, err)
+	}
+	// Remote state is retrieved once only
+	proofs, slots := api.requestCount("getProof"), api.requestCount("getStorageAt")
+	if have := callForkTest(t, sim, contract); have != 42 {
+		t.Errorf("storage mismatch: have %d, want %d", have, 42)
+	}
+	if api.requestCount("getProof") != proofs || api.requestCount("getStorageAt") != slots {
+		t.Errorf("remote state retrieved repeatedly")
+	}
+	// Changes are made locally, deletions hide the remote values
+	sendForkTestTx(t, sim, contract, 0)
+	sim.Commit()
+	if have := callForkTest(t, sim, contract); have != 0 {
+		t.Errorf("storage mismatch after deletion: have %d, want %d", have, 0)
+	}
+	sendForkTestTx(t, sim, contract, 5)
+	sim.Commit()
+	if have := callForkTest(t, sim, contract); have != 5 {
+		t.Errorf("storage mismatch after update: have %d, want %d", have, 5)
+	}
+	for number, want := range []int64{42, 0, 5} {
+		value, err := sim.StorageAt(bgCtx, contract, common.Hash{}, big.NewInt(int64(number)))
+		if err != nil {
+			t.Fatalf("failed to retrieve storage at block %d: %v", number, err)
+		}
+		if have := new(big.Int).SetBytes(value).Int64(); have != want {
+			t.Errorf("storage mismatch at block %d: have %d, want %d", number, have, want)
+		}
+	}
+	// The remote chain is unaffected
+	if value, _ := remote.StorageAt(bgCtx, contract, common.Hash{}, nil); new(big.Int).SetBytes(value).Int64() != 7 {
+		t.Errorf("remote storage changed: have %x", value)
+	}
+}
+
+func sendForkTestTx(t *testing.T, sim *SimulatedBackend, contract common.Address, value int64) {
+	t.Helper()
+
+	testAddr := crypto.PubkeyToAddress(testKey.PublicKey)
+	head, _ := sim.HeaderByNumber(context.Background(), nil)
+	nonce, err := sim.PendingNonceAt(context.Background(), testAddr)
+	if err != nil {
+		t.Fatalf("failed to retrieve nonce: %v", err)
+	}
+	gasPrice := new(big.Int).Add(head.BaseFee, big.NewInt(1))
+	tx := types.NewTransaction(nonce, contract, new(big.Int), 100000, gasPrice, common.BigToHash(big.NewInt(value)).Bytes())
+	tx, _ = types.SignTx(tx, types.HomesteadSigner{}, testKey)
+	if err := sim.SendTransaction(context.Background(), tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+}
+
+func callForkTest(t *testing.T, sim *SimulatedBackend, contract common.Address) int64 {
+	t.Helper()
+
+	res, err := sim.CallContract(context.Background(), alba.CallMsg{To: &contract}, nil)
+	if err != nil {
+		t.Fatalf("failed to call contract: %v", err)
+	}
+	return new(big.Int).SetBytes(res).Int64()
+}
@@ -60,8 +60,9 @@ var (
 // ChainReader, ChainStateReader, ContractBackend, ContractCaller, ContractFilterer, ContractTransactor,
 // DeployBackend, GasEstimator, GasPricer, LogFilterer, PendingContractCaller, TransactionReader, and TransactionSender
 type SimulatedBackend struct {
-	database   albadb.Database   // In memory database to store our testing data
-	blockchain *core.BlockChain // Ethereum blockchain to handle the consensus
+	database      albadb.Database  // In memory database to store our testing data
+	blockchain    *core.BlockChain // Ethereum blockchain to handle the consensus
+	stateDatabase state.Database   // State database to generate the pending blocks with
 
 	mu           sync.Mutex
 	pendingBlock *types.Block   // Currently pending block that will be imported on request
@@ -80,11 +81,17 @@ func NewSimulatedBackendWithDatabase(database ethdb.Database, alloc core.Genesis
 	genesis.MustCommit(database)
 	blockchain, _ := core.NewBlockChain(database, nil, genesis.Config, albaash.NewFaker(), vm.Config{}, nil, nil)
 
+	return newSimulatedBackend(database, blockchain, state.NewDatabase(database))
+}
+
+// newSimulatedBackend creates a binding backend on top of an initialized chain.
+func newSimulatedBackend(database albadb.Database, blockchain *core.BlockChain, stateDatabase state.Database) *SimulatedBackend {
 	backend := &SimulatedBackend{
-		database:   database,
-		blockchain: blockchain,
-		config:     genesis.Config,
-		events:     filters.NewEventSystem(&filterBackend{database, blockchain}, false),
+		database:      database,
+		blockchain:    blockchain,
+		stateDatabase: stateDatabase,
+		config:        blockchain.Config(),
+		events:        filters.NewEventSystem(&filterBackend{database, blockchain}, false),
 	}
 	backend.rollback(blockchain.CurrentBlock())
 	return backend
@@ -126,7 +133,7 @@ func (b *SimulatedBackend) Rollback() {
 }
 
 func (b *SimulatedBackend) rollback(parent *types.Block) {
-	blocks, _ := core.GenerateChain(b.config, parent, ethash.NewFaker(), b.database, 1, func(int, *core.BlockGen) {})
+	blocks, _ := core.GenerateChainWithStateDatabase(b.config, parent, ethash.NewFaker(), b.stateDatabase, 1, func(int, *core.BlockGen) {})
 
 	b.pendingBlock = blocks[0]
 	b.pendingState, _ = state.New(b.pendingBlock.Root(), b.blockchain.StateCache(), nil)
@@ -433,6 +440,38 @@ func (b *SimulatedBackend) CallContract(ctx context.Context, call ethereum.CallM
 	return res.Return(), res.Err
 }
 
+// BatchCallContract executes several contract calls, all on the state of the
+// current block.
+func (b *SimulatedBackend) BatchCallContract(ctx context.Context, calls []ethereum.CallMsg, blockNumber *big.Int) ([][]byte, []error, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if blockNumber != nil && blockNumber.Cmp(b.blockchain.CurrentBlock().Number()) != 0 {
+		return nil, nil, errBlockNumberUnsupported
+	}
+	var (
+		outputs = make([][]byte, len(calls))
+		errs    = make([]error, len(calls))
+	)
+	for i, call := range calls {
+		// Every call runs on a fresh copy of the state, as in CallContract
+		stateDB, err := b.blockchain.State()
+		if err != nil {
+			return nil, nil, err
+		}
+		res, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), stateDB)
+		switch {
+		case err != nil:
+			errs[i] = err
+		case len(res.Revert()) > 0:
+			errs[i] = newRevertError(res)
+		default:
+			outputs[i], errs[i] = res.Return(), res.Err
+		}
+	}
+	return outputs, errs, nil
+}
+
 // PendingCallContract executes a contract call on the pending state.
 func (b *SimulatedBackend) PendingCallContract(ctx context.Context, call ethereum.CallMsg) ([]byte, error) {
 	b.mu.Lock()
@@ -660,7 +699,7 @@ func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transa
 		panic(fmt.Errorf("invalid transaction nonce: got %d, want %d", tx.Nonce(), nonce))
 	}
 	// Include tx in chain
-	blocks, _ := core.GenerateChain(b.config, block, ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
+	blocks, _ := core.GenerateChainWithStateDatabase(b.config, block, ethash.NewFaker(), b.stateDatabase, 1, func(number int, block *core.BlockGen) {
 		for _, tx := range b.pendingBlock.Transactions() {
 			block.AddTxWithChain(b.blockchain, tx)
 		}
@@ -778,7 +817,7 @@ func (b *SimulatedBackend) AdjustTime(adjustment time.Duration) error {
 		return errors.New("Could not adjust time on non-empty block")
 	}
 
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
+	blocks, _ := core.GenerateChainWithStateDatabase(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.stateDatabase, 1, func(number int, block *core.BlockGen) {
 		block.OffsetTime(int64(adjustment.Seconds()))
 	})
 	stateDB, _ := b.blockchain.State()
@@ -1165,6 +1165,54 @@ func TestCallContractRevert(t *testing.T) {
 	}
 }
 
+func TestBatchCallContract(t *testing.T) {
+	testAddr := crypto.PubkeyToAddress(testKey.PublicKey)
+	sim := simTestBackend(testAddr)
+	defer sim.Close()
+	bgCtx := context.Background()
+
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		t.Fatalf("could not parse abi: %v", err)
+	}
+	contractAuth, _ := bind.NewKeyedTransactorWithChainID(testKey, big.NewInt(1337))
+	addr, _, _, err := bind.DeployContract(contractAuth, parsed, common.FromHex(abiBin), sim)
+	if err != nil {
+		t.Fatalf("could not deploy contract: %v", err)
+	}
+	sim.Commit()
+
+	input, err := parsed.Pack("receive", []byte("X"))
+	if err != nil {
+		t.Fatalf("could not pack receive function on contract: %v", err)
+	}
+	calls := []alba.CallMsg{
+		{From: testAddr, To: &addr, Data: input},
+		{From: testAddr, To: &addr, Data: []byte{0xde, 0xad, 0xbe, 0xef}},
+		{From: testAddr, To: &addr, Data: input},
+	}
+	head := sim.Blockchain().CurrentBlock().Number()
+	outputs, errs, err := sim.BatchCallContract(bgCtx, calls, head)
+	if err != nil {
+		t.Fatalf("could not execute batch: %v", err)
+	}
+	for _, i := range []int{0, 2} {
+		if errs[i] != nil {
+			t.Errorf("call %d failed: %v", i, errs[i])
+		}
+		if !bytes.Equal(outputs[i], expectedReturn) {
+			t.Errorf("call %d result mismatch: have %x, want %x", i, outputs[i], expectedReturn)
+		}
+	}
+	if errs[1] == nil {
+		t.Errorf("call to unknown method did not fail")
+	}
+	// Only the current block is supported
+	if _, _, err := sim.BatchCallContract(bgCtx, calls, new(big.Int).Sub(head, big.NewInt(1))); err != errBlockNumberUnsupported {
+		t.Errorf("error mismatch: have %v, want %v", err, errBlockNumberUnsupported)
+	}
+}
+
 // TestFork check that the chain length after a reorg is correct.
 // Steps:
 //  1. Save the current block which will serve as parent for the fork.
@@ -27,6 +27,7 @@ import (
 	"github.com/pictor01/ALBA"
 	"github.com/pictor01/ALBA/accounts/abi"
 	"github.com/pictor01/ALBA/common"
+	"github.com/pictor01/ALBA/common/hexutil"
 	"github.com/pictor01/ALBA/core/types"
 	"github.com/pictor01/ALBA/crypto"
 	"github.com/pictor01/ALBA/event"
@@ -171,7 +172,10 @@ func (c *BoundContract) Call(opts *CallOpts, results *[]interface{}, method stri
 			return ErrNoPendingState
 		}
 		output, err = pb.PendingCallContract(ctx, msg)
-		if err == nil && len(output) == 0 {
+		if err != nil {
+			return c.unpackRevert(err)
+		}
+		if len(output) == 0 {
 			// Make sure we have a contract to operate on, and bail out otherwise.
 			if code, err = pb.PendingCodeAt(ctx, c.address); err != nil {
 				return err
@@ -182,7 +186,7 @@ func (c *BoundContract) Call(opts *CallOpts, results *[]interface{}, method stri
 	} else {
 		output, err = c.caller.CallContract(ctx, msg, opts.BlockNumber)
 		if err != nil {
-			return err
+			return c.unpackRevert(err)
 		}
 		if len(output) == 0 {
 			// Make sure we have a contract to operate on, and bail out otherwise.
@@ -344,7 +348,33 @@ func (c *BoundContract) estimateGasLimit(opts *TransactOpts, contract *common.Ad
 		Value:     value,
 		Data:      input,
 	}
-	return c.transactor.EstimateGas(ensureContext(opts.Context), msg)
+	gas, err := c.transactor.EstimateGas(ensureContext(opts.Context), msg)
+	if err != nil {
+		return 0, c.unpackRevert(err)
+	}
+	return gas, nil
+}
+
+// unpackRevert converts the error of a reverted call into an abi.RevertError if
+// the revert data matches one of the errors declared in the contract's ABI, so
+// it can be inspected with errors.As. Other errors are returned unchanged.
+func (c *BoundContract) unpackRevert(err error) error {
+	var de interface{ ErrorData() interface{} }
+	if !errors.As(err, &de) {
+		return err
+	}
+	hexdata, ok := de.ErrorData().(string)
+	if !ok {
+		return err
+	}
+	data, derr := hexutil.Decode(hexdata)
+	if derr != nil {
+		return err
+	}
+	if revert, rerr := c.abi.UnpackRevertError(data); rerr == nil {
+		return revert
+	}
+	return err
 }
 
 func (c *BoundContract) getNonce(opts *TransactOpts) (uint64, error) {
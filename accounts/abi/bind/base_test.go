@@ -18,6 +18,7 @@ package bind_test
 
 import (
 	"context"
+	"errors"
 	"math/big"
 	"reflect"
 	"strings"
@@ -146,6 +147,61 @@ func TestPassingBlockNumber(t *testing.T) {
 	}
 }
 
+// revertCaller is a ContractCaller whose calls revert with the given data.
+type revertCaller struct {
+	mockCaller
+	data []byte
+}
+
+// revertError mimics the error of a reverted call returned by RPC backends.
+type revertError struct{ data string }
+
+func (e *revertError) Error() string          { return "execution reverted" }
+func (e *revertError) ErrorCode() int         { return 3 }
+func (e *revertError) ErrorData() interface{} { return e.data }
+
+func (rc *revertCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return nil, &revertError{hexutil.Encode(rc.data)}
+}
+
+type notOwnerError struct {
+	Caller common.Address
+}
+
+func (*notOwnerError) ErrorSig() string { return "NotOwner(address)" }
+func (*notOwnerError) Error() string    { return "NotOwner" }
+
+func TestCallRevertError(t *testing.T) {
+	const def = `[
+		{"type":"function","name":"something","inputs":[],"outputs":[]},
+		{"type":"error","name":"NotOwner","inputs":[{"name":"caller","type":"address"}]}
+	]`
+	parsed, err := abi.JSON(strings.NewReader(def))
+	if err != nil {
+		t.Fatal(err)
+	}
+	caller := common.HexToAddress("0x0102030405060708090a0b0c0d0e0f1011121314")
+	data := append(crypto.Keccak256([]byte("NotOwner(address)"))[:4], common.LeftPadBytes(caller.Bytes(), 32)...)
+
+	// Declared errors are decoded into their typed bindings
+	bc := bind.NewBoundContract(common.Address{}, parsed, &revertCaller{data: data}, nil, nil)
+	err = bc.Call(nil, nil, "something")
+
+	var notOwner *notOwnerError
+	if !errors.As(err, &notOwner) {
+		t.Fatalf("expected NotOwner error, got %v", err)
+	}
+	if notOwner.Caller != caller {
+		t.Fatalf("caller mismatch: have %v, want %v", notOwner.Caller, caller)
+	}
+	// Undeclared errors are returned as they are
+	bc = bind.NewBoundContract(common.Address{}, parsed, &revertCaller{data: []byte{1, 2, 3, 4}}, nil, nil)
+	err = bc.Call(nil, nil, "something")
+	if _, ok := err.(*revertError); !ok {
+		t.Fatalf("expected backend error, got %T", err)
+	}
+}
+
 const hexData = "0x000000000000000000000000376c47978271565f56deb45495afa69e59c16ab200000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000060000000000000000000000000000000000000000000000000000000000000000158"
 
 func TestUnpackIndexedStringTyLogIntoMap(t *testing.T) {
@@ -0,0 +1,234 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/pictor01/ALBA"
+	"github.com/pictor01/ALBA/accounts/abi"
+	"github.com/pictor01/ALBA/common"
+	"github.com/pictor01/ALBA/core/types"
+)
+
+// Multicall3Address is the address the Multicall3 contract is deployed at on
+// most chains, to be used as CallBatch.Multicall.
+var Multicall3Address = common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+// multicall3ABI is the part of the Multicall3 ABI needed to aggregate calls.
+const multicall3ABI = `[{"inputs":[{"components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}],"name":"calls","type":"tuple[]"}],"name":"aggregate3","outputs":[{"components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}],"name":"returnData","type":"tuple[]"}],"stateMutability":"payable","type":"function"}]`
+
+var (
+	multicall3, _ = abi.JSON(strings.NewReader(multicall3ABI))
+
+	errBatchNotExecuted  = errors.New("batch not executed yet")
+	errExecutionReverted = errors.New("execution reverted")
+)
+
+// multicallCall and multicallResult mirror the tuples taken and returned by the
+// aggregate3 method of Multicall3.
+type multicallCall struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type multicallResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// CallBatch collects read-only calls of bound contracts to execute them together
+// in a single round trip, all at the same block. Calls are queued with Add, or
+// with the Batch variants of view methods generated by abigen, and their results
+// become available once the batch is executed.
+type CallBatch struct {
+	// Multicall, if set, is the address of a Multicall3 contract to aggregate the
+	// calls through, executing them in a single call. Note the calls are made on
+	// behalf of the Multicall3 contract then, not CallOpts.From.
+	//
+	// Otherwise the calls are sent as a single JSON-RPC batch if the backend
+	// implements BatchContractCaller, or one by one if it doesn't.
+	Multicall *common.Address
+
+	calls []*BatchCall
+}
+
+// BatchCall is a contract call queued in a CallBatch.
+type BatchCall struct {
+	contract *BoundContract
+	method   string
+	input    []byte
+
+	output []byte
+	err    error
+	done   bool
+}
+
+// Add queues a call of the (constant) contract method with params as input values.
+// The returned BatchCall holds the result once the batch is executed.
+func (b *CallBatch) Add(contract *BoundContract, method string, params ...interface{}) *BatchCall {
+	call := &BatchCall{contract: contract, method: method}
+	if call.input, call.err = contract.abi.Pack(method, params...); call.err != nil {
+		call.done = true
+	}
+	b.calls = append(b.calls, call)
+	return call
+}
+
+// Len returns the number of calls queued in the batch.
+func (b *CallBatch) Len() int {
+	return len(b.calls)
+}
+
+// Execute runs all the calls queued since the last execution through backend.
+// All calls are made at the same block: opts.BlockNumber if set, otherwise the
+// latest block, which requires the backend to report its headers through a
+// HeaderByNumber method (as ContractBackend implementations do). If neither is
+// available, ErrNoBlockNumber is returned. The pending state is not supported.
+//
+// The returned error only reports a failure of the batch as a whole, errors of
+// individual calls are returned by their Result.
+func (b *CallBatch) Execute(opts *CallOpts, backend ContractCaller) error {
+	// Don't crash on a lazy user
+	if opts == nil {
+		opts = new(CallOpts)
+	}
+	if opts.Pending {
+		return ErrNoPendingState
+	}
+	var calls []*BatchCall
+	for _, call := range b.calls {
+		if !call.done {
+			calls = append(calls, call)
+		}
+	}
+	if len(calls) == 0 {
+		return nil
+	}
+	ctx := ensureContext(opts.Context)
+
+	// Pin the block, so that all calls see the same state
+	number := opts.BlockNumber
+	if number == nil {
+		hr, ok := backend.(interface {
+			HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+		})
+		if !ok {
+			return ErrNoBlockNumber
+		}
+		head, err := hr.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return err
+		}
+		number = head.Number
+	}
+	msgs := make([]alba.CallMsg, len(calls))
+	for i, call := range calls {
+		msgs[i] = alba.CallMsg{From: opts.From, To: &call.contract.address, Data: call.input}
+	}
+	if b.Multicall != nil {
+		return b.executeMulticall(ctx, backend, opts.From, calls, msgs, number)
+	}
+	if bc, ok := backend.(BatchContractCaller); ok {
+		outputs, errs, err := bc.BatchCallContract(ctx, msgs, number)
+		if err != nil {
+			return err
+		}
+		if len(outputs) != len(calls) || len(errs) != len(calls) {
+			return fmt.Errorf("batch result count mismatch: have %d/%d, want %d", len(outputs), len(errs), len(calls))
+		}
+		for i, call := range calls {
+			call.finish(outputs[i], errs[i])
+		}
+		return nil
+	}
+	for i, call := range calls {
+		output, err := backend.CallContract(ctx, msgs[i], number)
+		call.finish(output, err)
+	}
+	return nil
+}
+
+// executeMulticall runs the calls as a single call of Multicall3's aggregate3,
+// allowing the individual calls to fail.
+func (b *CallBatch) executeMulticall(ctx context.Context, backend ContractCaller, from common.Address, calls []*BatchCall, msgs []alba.CallMsg, number *big.Int) error {
+	aggregate := make([]multicallCall, len(msgs))
+	for i, msg := range msgs {
+		aggregate[i] = multicallCall{Target: *msg.To, AllowFailure: true, CallData: msg.Data}
+	}
+	input, err := multicall3.Pack("aggregate3", aggregate)
+	if err != nil {
+		return err
+	}
+	output, err := backend.CallContract(ctx, alba.CallMsg{From: from, To: b.Multicall, Data: input}, number)
+	if err != nil {
+		return err
+	}
+	unpacked, err := multicall3.Unpack("aggregate3", output)
+	if err != nil {
+		return err
+	}
+	results := *abi.ConvertType(unpacked[0], new([]multicallResult)).(*[]multicallResult)
+	if len(results) != len(calls) {
+		return fmt.Errorf("multicall result count mismatch: have %d, want %d", len(results), len(calls))
+	}
+	for i, call := range calls {
+		if results[i].Success {
+			call.finish(results[i].ReturnData, nil)
+		} else {
+			call.finish(nil, call.contract.revertDataError(results[i].ReturnData))
+		}
+	}
+	return nil
+}
+
+// finish stores the outcome of the call.
+func (c *BatchCall) finish(output []byte, err error) {
+	c.done = true
+	c.output = output
+	if err != nil {
+		c.err = c.contract.unpackRevert(err)
+	}
+}
+
+// Result returns the unpacked outputs of the call, or the error it failed with.
+func (c *BatchCall) Result() ([]interface{}, error) {
+	if !c.done {
+		return nil, errBatchNotExecuted
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.contract.abi.Unpack(c.method, c.output)
+}
+
+// revertDataError converts raw revert data into an error, decoding it as one of
+// the contract's custom errors or a revert reason where possible.
+func (c *BoundContract) revertDataError(data []byte) error {
+	if revert, err := c.abi.UnpackRevertError(data); err == nil {
+		return revert
+	}
+	if reason, err := abi.UnpackRevert(data); err == nil {
+		return fmt.Errorf("%w: %s", errExecutionReverted, reason)
+	}
+	return errExecutionReverted
+}
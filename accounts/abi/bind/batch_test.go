@@ -0,0 +1,275 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/pictor01/ALBA"
+	"github.com/pictor01/ALBA/accounts/abi"
+	"github.com/pictor01/ALBA/accounts/abi/bind"
+	"github.com/pictor01/ALBA/common"
+	"github.com/pictor01/ALBA/common/hexutil"
+	"github.com/pictor01/ALBA/core/types"
+	"github.com/pictor01/ALBA/crypto"
+)
+
+const batchTokenABI = `[
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"error","name":"NotOwner","inputs":[{"name":"caller","type":"address"}]}
+]`
+
+const batchMulticallABI = `[{"inputs":[{"components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}],"name":"calls","type":"tuple[]"}],"name":"aggregate3","outputs":[{"components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}],"name":"returnData","type":"tuple[]"}],"stateMutability":"payable","type":"function"}]`
+
+var (
+	batchToken, _     = abi.JSON(strings.NewReader(batchTokenABI))
+	batchMulticall, _ = abi.JSON(strings.NewReader(batchMulticallABI))
+	batchBlocked      = common.HexToAddress("0xdead")
+)
+
+// batchBackend is a ContractCaller serving balanceOf calls of any token contract.
+// The balance of an account is its last address byte times the block number,
+// querying the balance of batchBlocked reverts with NotOwner.
+type batchBackend struct {
+	head  *big.Int
+	calls int // number of round trips made
+	pins  []*big.Int
+}
+
+func (b *batchBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (b *batchBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: new(big.Int).Set(b.head)}, nil
+}
+
+func (b *batchBackend) CallContract(ctx context.Context, call alba.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	b.calls++
+	b.pins = append(b.pins, blockNumber)
+	return b.call(call, blockNumber)
+}
+
+func (b *batchBackend) call(call alba.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	args, err := batchToken.Methods["balanceOf"].Inputs.Unpack(call.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	owner := args[0].(common.Address)
+	if owner == batchBlocked {
+		data := append(crypto.Keccak256([]byte("NotOwner(address)"))[:4], common.LeftPadBytes(owner.Bytes(), 32)...)
+		return nil, &revertError{hexutil.Encode(data)}
+	}
+	balance := new(big.Int).Mul(big.NewInt(int64(owner[19])), blockNumber)
+	return batchToken.Methods["balanceOf"].Outputs.Pack(balance)
+}
+
+// batchRPCBackend additionally supports JSON-RPC style batching.
+type batchRPCBackend struct {
+	batchBackend
+}
+
+func (b *batchRPCBackend) BatchCallContract(ctx context.Context, calls []alba.CallMsg, blockNumber *big.Int) ([][]byte, []error, error) {
+	b.calls++
+	b.pins = append(b.pins, blockNumber)
+
+	outputs, errs := make([][]byte, len(calls)), make([]error, len(calls))
+	for i, call := range calls {
+		outputs[i], errs[i] = b.call(call, blockNumber)
+	}
+	return outputs, errs, nil
+}
+
+// multicallBackend serves calls of a Multicall3 contract aggregating balanceOf calls.
+type multicallBackend struct {
+	batchBackend
+	multicall common.Address
+}
+
+func (b *multicallBackend) CallContract(ctx context.Context, call alba.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if *call.To != b.multicall {
+		return nil, errors.New("call bypassed multicall")
+	}
+	b.calls++
+	b.pins = append(b.pins, blockNumber)
+
+	args, err := batchMulticall.Methods["aggregate3"].Inputs.Unpack(call.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	calls := *abi.ConvertType(args[0], new([]struct {
+		Target       common.Address
+		AllowFailure bool
+		CallData     []byte
+	})).(*[]struct {
+		Target       common.Address
+		AllowFailure bool
+		CallData     []byte
+	})
+	type result struct {
+		Success    bool
+		ReturnData []byte
+	}
+	results := make([]result, len(calls))
+	for i, inner := range calls {
+		output, err := b.call(alba.CallMsg{To: &inner.Target, Data: inner.CallData}, blockNumber)
+		if err != nil {
+			data, _ := hexutil.Decode(err.(*revertError).data)
+			results[i] = result{false, data}
+		} else {
+			results[i] = result{true, output}
+		}
+	}
+	return batchMulticall.Methods["aggregate3"].Outputs.Pack(results)
+}
+
+type batchNotOwnerError struct {
+	Caller common.Address
+}
+
+func (*batchNotOwnerError) ErrorSig() string { return "NotOwner(address)" }
+func (*batchNotOwnerError) Error() string    { return "NotOwner" }
+
+func TestCallBatch(t *testing.T) {
+	multicall := common.HexToAddress("0xca11")
+	tests := []struct {
+		name      string
+		backend   bind.ContractCaller
+		multicall *common.Address
+		calls     int
+	}{
+		{name: "sequential", backend: &batchBackend{head: big.NewInt(7)}, calls: 3},
+		{name: "rpc-batch", backend: &batchRPCBackend{batchBackend{head: big.NewInt(7)}}, calls: 1},
+		{name: "multicall", backend: &multicallBackend{batchBackend{head: big.NewInt(7)}, multicall}, multicall: &multicall, calls: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				tokenA = bind.NewBoundContract(common.HexToAddress("0xa"), batchToken, test.backend, nil, nil)
+				tokenB = bind.NewBoundContract(common.HexToAddress("0xb"), batchToken, test.backend, nil, nil)
+				batch  = &bind.CallBatch{Multicall: test.multicall}
+			)
+			first := batch.Add(tokenA, "balanceOf", common.HexToAddress("0x01"))
+			second := batch.Add(tokenB, "balanceOf", common.HexToAddress("0x02"))
+			blocked := batch.Add(tokenB, "balanceOf", batchBlocked)
+			invalid := batch.Add(tokenA, "balanceOf", "not an address")
+
+			if _, err := first.Result(); err == nil {
+				t.Fatal("retrieved result before execution")
+			}
+			if err := batch.Execute(nil, test.backend); err != nil {
+				t.Fatalf("failed to execute batch: %v", err)
+			}
+			// Check the round trips and the pinned block
+			var stats *batchBackend
+			switch backend := test.backend.(type) {
+			case *batchBackend:
+				stats = backend
+			case *batchRPCBackend:
+				stats = &backend.batchBackend
+			case *multicallBackend:
+				stats = &backend.batchBackend
+			}
+			if stats.calls != test.calls {
+				t.Errorf("round trips mismatch: have %d, want %d", stats.calls, test.calls)
+			}
+			for _, pin := range stats.pins {
+				if pin == nil || pin.Cmp(stats.head) != 0 {
+					t.Errorf("call not pinned to head: have %v, want %v", pin, stats.head)
+				}
+			}
+			// Check the individual results
+			for i, call := range []*bind.BatchCall{first, second} {
+				out, err := call.Result()
+				if err != nil {
+					t.Fatalf("call %d failed: %v", i, err)
+				}
+				if want := big.NewInt(int64(7 * (i + 1))); out[0].(*big.Int).Cmp(want) != 0 {
+					t.Errorf("call %d result mismatch: have %v, want %v", i, out[0], want)
+				}
+			}
+			var notOwner *batchNotOwnerError
+			if _, err := blocked.Result(); !errors.As(err, &notOwner) || notOwner.Caller != batchBlocked {
+				t.Errorf("expected NotOwner error, got %v", err)
+			}
+			if _, err := invalid.Result(); err == nil {
+				t.Error("expected packing error")
+			}
+			// Executed calls are not repeated
+			before := stats.calls
+			if err := batch.Execute(nil, test.backend); err != nil {
+				t.Fatalf("failed to re-execute batch: %v", err)
+			}
+			if stats.calls != before {
+				t.Errorf("executed calls repeated")
+			}
+		})
+	}
+}
+
+// headerlessBackend is a ContractCaller which can't report its headers.
+type headerlessBackend struct {
+	backend *batchBackend
+}
+
+func (b *headerlessBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return b.backend.CodeAt(ctx, contract, blockNumber)
+}
+
+func (b *headerlessBackend) CallContract(ctx context.Context, call alba.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return b.backend.CallContract(ctx, call, blockNumber)
+}
+
+// Tests that a batch is only executed if its calls can be pinned to a block.
+func TestCallBatchUnpinned(t *testing.T) {
+	backend := &headerlessBackend{&batchBackend{head: big.NewInt(3)}}
+	token := bind.NewBoundContract(common.HexToAddress("0xa"), batchToken, backend, nil, nil)
+
+	batch := new(bind.CallBatch)
+	call := batch.Add(token, "balanceOf", common.HexToAddress("0x01"))
+	if err := batch.Execute(nil, backend); err != bind.ErrNoBlockNumber {
+		t.Fatalf("error mismatch: have %v, want %v", err, bind.ErrNoBlockNumber)
+	}
+	if backend.backend.calls != 0 {
+		t.Fatalf("unpinned calls made: %d", backend.backend.calls)
+	}
+	// An explicit block number pins the calls without headers
+	if err := batch.Execute(&bind.CallOpts{BlockNumber: big.NewInt(2)}, backend); err != nil {
+		t.Fatalf("failed to execute batch: %v", err)
+	}
+	out, err := call.Result()
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if out[0].(*big.Int).Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("result mismatch: have %v, want %v", out[0], 2)
+	}
+}
+
+func TestCallBatchPending(t *testing.T) {
+	backend := &batchBackend{head: big.NewInt(1)}
+	batch := new(bind.CallBatch)
+	batch.Add(bind.NewBoundContract(common.Address{}, batchToken, backend, nil, nil), "balanceOf", common.Address{})
+
+	if err := batch.Execute(&bind.CallOpts{Pending: true}, backend); err != bind.ErrNoPendingState {
+		t.Fatalf("error mismatch: have %v, want %v", err, bind.ErrNoPendingState)
+	}
+}
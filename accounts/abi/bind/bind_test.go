@@ -368,6 +368,17 @@ var bindTests = []struct {
 			} else if str != "Hi" || num.Cmp(big.NewInt(1)) != 0 {
 				t.Fatalf("Retrieved value mismatch: have %v/%v, want %v/%v", str, num, "Hi", 1)
 			}
+			// Execute the same call batched
+			batch := new(bind.CallBatch)
+			result := getter.BatchGetter(batch)
+			if err := batch.Execute(nil, sim); err != nil {
+				t.Fatalf("Failed to execute call batch: %v", err)
+			}
+			if str, num, _, err := result(); err != nil {
+				t.Fatalf("Failed to retrieve batched result: %v", err)
+			} else if str != "Hi" || num.Cmp(big.NewInt(1)) != 0 {
+				t.Fatalf("Batched value mismatch: have %v/%v, want %v/%v", str, num, "Hi", 1)
+			}
 		`,
 		nil,
 		nil,
@@ -1875,6 +1886,7 @@ var bindTests = []struct {
 		[]string{"0x6080604052348015600f57600080fd5b5060998061001e6000396000f3fe6080604052348015600f57600080fd5b506004361060285760003560e01c8063726c638214602d575b600080fd5b60336035565b005b60405163024876cd60e61b815260016004820152600260248201526003604482015260640160405180910390fdfea264697066735822122093f786a1bc60216540cd999fbb4a6109e0fef20abcff6e9107fb2817ca968f3c64736f6c63430008070033"},
 		[]string{`[{"inputs":[{"internalType":"uint256","name":"","type":"uint256"}],"name":"MyError","type":"error"},{"inputs":[{"internalType":"uint256","name":"","type":"uint256"}],"name":"MyError1","type":"error"},{"inputs":[{"internalType":"uint256","name":"","type":"uint256"},{"internalType":"uint256","name":"","type":"uint256"}],"name":"MyError2","type":"error"},{"inputs":[{"internalType":"uint256","name":"a","type":"uint256"},{"internalType":"uint256","name":"b","type":"uint256"},{"internalType":"uint256","name":"c","type":"uint256"}],"name":"MyError3","type":"error"},{"inputs":[],"name":"Error","outputs":[],"stateMutability":"pure","type":"function"}]`},
 		`
+			"errors"
 			"math/big"
 	
 			"github.com/pictor01/ALBA/accounts/abi/bind"
@@ -1900,11 +1912,21 @@ var bindTests = []struct {
 			if err != nil {
 				t.Error(err)
 			}
-			if err := contract.Error(new(bind.CallOpts)); err == nil {
+			err = contract.Error(new(bind.CallOpts))
+			if err == nil {
 				t.Fatalf("expected contract to throw error")
 			}
-			// TODO (MariusVanDerWijden unpack error using abigen
-			// once that is implemented
+			var myErr *NewErrorsMyError3Error
+			if !errors.As(err, &myErr) {
+				t.Fatalf("expected MyError3, got %v", err)
+			}
+			if myErr.A.Int64() != 1 || myErr.B.Int64() != 2 || myErr.C.Int64() != 3 {
+				t.Fatalf("unexpected error inputs: %v %v %v", myErr.A, myErr.B, myErr.C)
+			}
+			var otherErr *NewErrorsMyError2Error
+			if errors.As(err, &otherErr) {
+				t.Fatalf("MyError3 converted to MyError2")
+			}
 	   `,
 		nil,
 		nil,
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/pictor01/ALBA/common"
@@ -89,3 +90,49 @@ func (e *Error) Unpack(data []byte) (interface{}, error) {
 	}
 	return e.Inputs.Unpack(data[4:])
 }
+
+// BoundError is implemented by Go types representing a custom error, such as the
+// ones generated by abigen. A RevertError of the same signature is converted to
+// them by errors.As.
+type BoundError interface {
+	error
+	ErrorSig() string
+}
+
+// RevertError is a revert raised by one of the custom errors declared in an ABI.
+type RevertError struct {
+	Def  *Error        // Declaration of the error
+	Args []interface{} // Decoded inputs of the error, in declaration order
+	Data []byte        // Raw revert data
+}
+
+// Error implements error, formatting the error along with its inputs.
+func (e *RevertError) Error() string {
+	args := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = fmt.Sprintf("%v", arg)
+	}
+	return fmt.Sprintf("execution reverted: %s(%s)", e.Def.Name, strings.Join(args, ", "))
+}
+
+// As unpacks the error into target if it points to a BoundError implementation
+// with the same signature, allowing errors.As to extract typed errors.
+func (e *RevertError) As(target interface{}) bool {
+	val := reflect.ValueOf(target)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return false
+	}
+	typ := val.Type().Elem()
+	if typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	bound, ok := reflect.New(typ.Elem()).Interface().(BoundError)
+	if !ok || bound.ErrorSig() != e.Def.Sig {
+		return false
+	}
+	if err := e.Def.Inputs.Copy(bound, e.Args); err != nil {
+		return false
+	}
+	val.Elem().Set(reflect.ValueOf(bound))
+	return true
+}
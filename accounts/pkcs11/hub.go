@@ -0,0 +1,203 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pkcs11 implements support for keys held in hardware security modules,
+// accessed through a PKCS#11 (Cryptoki) library.
+//
+// Every token of the library is exposed as a wallet, holding an account for each
+// secp256k1 key pair on it. Keys are discovered through their public key objects,
+// which must share their CKA_ID with the private key. The private keys never leave
+// the token, transactions and data are signed with the CKM_ECDSA mechanism.
+//
+// Signing requires the user to be logged into the token, which is done by opening
+// the wallet with the user PIN (e.g. `personal.openWallet(URL, PIN)`), or on the
+// fly by the passphrase based signing methods, as used by clef.
+package pkcs11
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pictor01/ALBA/accounts"
+	"github.com/pictor01/ALBA/event"
+	"github.com/pictor01/ALBA/log"
+)
+
+// Scheme is the URI prefix for PKCS#11 wallets.
+const Scheme = "pkcs11"
+
+// refreshCycle is the maximum time between wallet refreshes. Cryptoki has no
+// reliable token event notifications, so tokens are polled.
+const refreshCycle = 5 * time.Second
+
+// refreshThrottling is the minimum time between wallet refreshes to avoid thrashing.
+const refreshThrottling = time.Second
+
+// Hub is a accounts.Backend that can find and handle the tokens of a PKCS#11
+// library.
+type Hub struct {
+	module *module // Cryptoki library the tokens are accessed through
+
+	refreshed   time.Time               // Time instance when the list of wallets was last refreshed
+	wallets     map[string]*Wallet      // Mapping from token identifiers to wallet instances
+	updateFeed  event.Feed              // Event feed to notify wallet additions/removals
+	updateScope event.SubscriptionScope // Subscription scope tracking current live listeners
+	updating    bool                    // Whether the event notification loop is running
+
+	stateLock sync.RWMutex // Protects the internals of the hub from racey access
+}
+
+// NewHub loads the PKCS#11 library at the given path and creates a wallet manager
+// for its tokens.
+func NewHub(path string) (*Hub, error) {
+	module, err := loadModule(path)
+	if err != nil {
+		return nil, err
+	}
+	hub := &Hub{
+		module:  module,
+		wallets: make(map[string]*Wallet),
+	}
+	hub.refreshWallets()
+	return hub, nil
+}
+
+// Wallets implements accounts.Backend, returning all the tokens currently
+// present in the slots of the library.
+func (hub *Hub) Wallets() []accounts.Wallet {
+	// Make sure the list of wallets is up to date
+	hub.refreshWallets()
+
+	hub.stateLock.RLock()
+	defer hub.stateLock.RUnlock()
+
+	cpy := make([]accounts.Wallet, 0, len(hub.wallets))
+	for _, wallet := range hub.wallets {
+		cpy = append(cpy, wallet)
+	}
+	sort.Sort(accounts.WalletsByURL(cpy))
+	return cpy
+}
+
+// refreshWallets scans the slots of the library and updates the list of wallets
+// based on the tokens found.
+func (hub *Hub) refreshWallets() {
+	// Don't query the library like crazy if the user fetches wallets in a loop
+	hub.stateLock.RLock()
+	elapsed := time.Since(hub.refreshed)
+	hub.stateLock.RUnlock()
+
+	if elapsed < refreshThrottling {
+		return
+	}
+	slots, err := hub.module.slots()
+	if err != nil {
+		log.Error("Failed to enumerate PKCS#11 slots", "err", err)
+		return
+	}
+	// Transform the current list of wallets into the new one
+	hub.stateLock.Lock()
+
+	events := []accounts.WalletEvent{}
+	seen := make(map[string]struct{})
+
+	for _, slot := range slots {
+		info, err := hub.module.tokenInfo(slot)
+		if err != nil {
+			log.Debug("Failed to query PKCS#11 token", "slot", slot, "err", err)
+			continue
+		}
+		id := info.serial
+		if id == "" {
+			id = fmt.Sprintf("slot%d", slot)
+		}
+		seen[id] = struct{}{}
+
+		// If we already know about this token, refresh its keys, otherwise clean up
+		if wallet, ok := hub.wallets[id]; ok {
+			if wallet.slot == slot {
+				if err := wallet.refresh(); err == nil {
+					continue
+				}
+			}
+			wallet.release()
+			events = append(events, accounts.WalletEvent{Wallet: wallet, Kind: accounts.WalletDropped})
+			delete(hub.wallets, id)
+		}
+		// New token detected, try to open a session with it
+		wallet, err := newWallet(hub, slot, id, info)
+		if err != nil {
+			log.Debug("Failed to open PKCS#11 token", "slot", slot, "label", info.label, "err", err)
+			continue
+		}
+		hub.wallets[id] = wallet
+		events = append(events, accounts.WalletEvent{Wallet: wallet, Kind: accounts.WalletArrived})
+	}
+	// Remove any wallets no longer present
+	for id, wallet := range hub.wallets {
+		if _, ok := seen[id]; !ok {
+			wallet.release()
+			events = append(events, accounts.WalletEvent{Wallet: wallet, Kind: accounts.WalletDropped})
+			delete(hub.wallets, id)
+		}
+	}
+	hub.refreshed = time.Now()
+	hub.stateLock.Unlock()
+
+	for _, event := range events {
+		hub.updateFeed.Send(event)
+	}
+}
+
+// Subscribe implements accounts.Backend, creating an async subscription to
+// receive notifications on the addition or removal of PKCS#11 wallets.
+func (hub *Hub) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	// We need the mutex to reliably start/stop the update loop
+	hub.stateLock.Lock()
+	defer hub.stateLock.Unlock()
+
+	// Subscribe the caller and track the subscriber count
+	sub := hub.updateScope.Track(hub.updateFeed.Subscribe(sink))
+
+	// Subscribers require an active notification loop, start it
+	if !hub.updating {
+		hub.updating = true
+		go hub.updater()
+	}
+	return sub
+}
+
+// updater is responsible for maintaining an up-to-date list of wallets managed
+// by the hub, and for firing wallet addition/removal events.
+func (hub *Hub) updater() {
+	for {
+		time.Sleep(refreshCycle)
+
+		// Run the wallet refresher
+		hub.refreshWallets()
+
+		// If all our subscribers left, stop the updater
+		hub.stateLock.Lock()
+		if hub.updateScope.Count() == 0 {
+			hub.updating = false
+			hub.stateLock.Unlock()
+			return
+		}
+		hub.stateLock.Unlock()
+	}
+}
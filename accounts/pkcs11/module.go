@@ -0,0 +1,481 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build cgo && !windows
+// +build cgo,!windows
+
+package pkcs11
+
+/*
+#cgo linux LDFLAGS: -ldl
+
+#include <dlfcn.h>
+#include <stdlib.h>
+
+// Minimal Cryptoki type definitions, as laid out by the PKCS#11 v2.x headers on
+// platforms without structure packing.
+typedef unsigned long CK_ULONG;
+typedef unsigned char CK_BYTE;
+typedef CK_ULONG CK_RV;
+
+typedef struct { CK_BYTE major; CK_BYTE minor; } CK_VERSION;
+
+typedef struct {
+	CK_ULONG type;
+	void    *pValue;
+	CK_ULONG ulValueLen;
+} CK_ATTRIBUTE;
+
+typedef struct {
+	CK_ULONG mechanism;
+	void    *pParameter;
+	CK_ULONG ulParameterLen;
+} CK_MECHANISM;
+
+typedef struct {
+	CK_BYTE    label[32];
+	CK_BYTE    manufacturerID[32];
+	CK_BYTE    model[16];
+	CK_BYTE    serialNumber[16];
+	CK_ULONG   flags;
+	CK_ULONG   ulMaxSessionCount;
+	CK_ULONG   ulSessionCount;
+	CK_ULONG   ulMaxRwSessionCount;
+	CK_ULONG   ulRwSessionCount;
+	CK_ULONG   ulMaxPinLen;
+	CK_ULONG   ulMinPinLen;
+	CK_ULONG   ulTotalPublicMemory;
+	CK_ULONG   ulFreePublicMemory;
+	CK_ULONG   ulTotalPrivateMemory;
+	CK_ULONG   ulFreePrivateMemory;
+	CK_VERSION hardwareVersion;
+	CK_VERSION firmwareVersion;
+	CK_BYTE    utcTime[16];
+} CK_TOKEN_INFO;
+
+typedef struct {
+	CK_ULONG slotID;
+	CK_ULONG state;
+	CK_ULONG flags;
+	CK_ULONG ulDeviceError;
+} CK_SESSION_INFO;
+
+// CK_FUNCTION_LIST mirrors the Cryptoki function table. Only the entries used
+// by the wallet are typed, the rest are placeholders keeping the layout.
+typedef struct {
+	CK_VERSION version;
+	CK_RV (*C_Initialize)(void *);
+	CK_RV (*C_Finalize)(void *);
+	void *C_GetInfo;
+	void *C_GetFunctionList;
+	CK_RV (*C_GetSlotList)(CK_BYTE, CK_ULONG *, CK_ULONG *);
+	void *C_GetSlotInfo;
+	CK_RV (*C_GetTokenInfo)(CK_ULONG, CK_TOKEN_INFO *);
+	void *C_GetMechanismList;
+	void *C_GetMechanismInfo;
+	void *C_InitToken;
+	void *C_InitPIN;
+	void *C_SetPIN;
+	CK_RV (*C_OpenSession)(CK_ULONG, CK_ULONG, void *, void *, CK_ULONG *);
+	CK_RV (*C_CloseSession)(CK_ULONG);
+	void *C_CloseAllSessions;
+	CK_RV (*C_GetSessionInfo)(CK_ULONG, CK_SESSION_INFO *);
+	void *C_GetOperationState;
+	void *C_SetOperationState;
+	CK_RV (*C_Login)(CK_ULONG, CK_ULONG, CK_BYTE *, CK_ULONG);
+	CK_RV (*C_Logout)(CK_ULONG);
+	void *C_CreateObject;
+	void *C_CopyObject;
+	void *C_DestroyObject;
+	void *C_GetObjectSize;
+	CK_RV (*C_GetAttributeValue)(CK_ULONG, CK_ULONG, CK_ATTRIBUTE *, CK_ULONG);
+	void *C_SetAttributeValue;
+	CK_RV (*C_FindObjectsInit)(CK_ULONG, CK_ATTRIBUTE *, CK_ULONG);
+	CK_RV (*C_FindObjects)(CK_ULONG, CK_ULONG *, CK_ULONG, CK_ULONG *);
+	CK_RV (*C_FindObjectsFinal)(CK_ULONG);
+	void *C_EncryptInit;
+	void *C_Encrypt;
+	void *C_EncryptUpdate;
+	void *C_EncryptFinal;
+	void *C_DecryptInit;
+	void *C_Decrypt;
+	void *C_DecryptUpdate;
+	void *C_DecryptFinal;
+	void *C_DigestInit;
+	void *C_Digest;
+	void *C_DigestUpdate;
+	void *C_DigestKey;
+	void *C_DigestFinal;
+	CK_RV (*C_SignInit)(CK_ULONG, CK_MECHANISM *, CK_ULONG);
+	CK_RV (*C_Sign)(CK_ULONG, CK_BYTE *, CK_ULONG, CK_BYTE *, CK_ULONG *);
+	// The remaining entries are never used, no need to lay them out
+} CK_FUNCTION_LIST;
+
+#define CKF_SERIAL_SESSION 4
+#define CKM_ECDSA          0x1041
+
+// load opens a Cryptoki library and retrieves its function table. Failures to
+// open the library or find the entry point are reported as -1 and -2.
+static CK_RV load(const char *path, void **lib, CK_FUNCTION_LIST **funcs) {
+	*lib = dlopen(path, RTLD_NOW | RTLD_LOCAL);
+	if (*lib == NULL) {
+		return (CK_RV)-1;
+	}
+	CK_RV (*getFunctionList)(CK_FUNCTION_LIST **) = dlsym(*lib, "C_GetFunctionList");
+	if (getFunctionList == NULL) {
+		dlclose(*lib);
+		return (CK_RV)-2;
+	}
+	CK_RV rv = getFunctionList(funcs);
+	if (rv != 0) {
+		dlclose(*lib);
+	}
+	return rv;
+}
+
+static CK_RV initialize(CK_FUNCTION_LIST *f) { return f->C_Initialize(NULL); }
+
+static CK_RV getSlotList(CK_FUNCTION_LIST *f, CK_ULONG *slots, CK_ULONG *count) {
+	return f->C_GetSlotList(1, slots, count);
+}
+static CK_RV getTokenInfo(CK_FUNCTION_LIST *f, CK_ULONG slot, CK_TOKEN_INFO *info) {
+	return f->C_GetTokenInfo(slot, info);
+}
+static CK_RV openSession(CK_FUNCTION_LIST *f, CK_ULONG slot, CK_ULONG *session) {
+	return f->C_OpenSession(slot, CKF_SERIAL_SESSION, NULL, NULL, session);
+}
+static CK_RV closeSession(CK_FUNCTION_LIST *f, CK_ULONG session) {
+	return f->C_CloseSession(session);
+}
+static CK_RV getSessionInfo(CK_FUNCTION_LIST *f, CK_ULONG session, CK_SESSION_INFO *info) {
+	return f->C_GetSessionInfo(session, info);
+}
+static CK_RV login(CK_FUNCTION_LIST *f, CK_ULONG session, CK_ULONG user, CK_BYTE *pin, CK_ULONG pinLen) {
+	return f->C_Login(session, user, pin, pinLen);
+}
+static CK_RV logout(CK_FUNCTION_LIST *f, CK_ULONG session) {
+	return f->C_Logout(session);
+}
+static CK_RV findObjectsInit(CK_FUNCTION_LIST *f, CK_ULONG session, CK_ATTRIBUTE *tmpl, CK_ULONG count) {
+	return f->C_FindObjectsInit(session, tmpl, count);
+}
+static CK_RV findObjects(CK_FUNCTION_LIST *f, CK_ULONG session, CK_ULONG *objects, CK_ULONG max, CK_ULONG *count) {
+	return f->C_FindObjects(session, objects, max, count);
+}
+static CK_RV findObjectsFinal(CK_FUNCTION_LIST *f, CK_ULONG session) {
+	return f->C_FindObjectsFinal(session);
+}
+static CK_RV getAttributeValue(CK_FUNCTION_LIST *f, CK_ULONG session, CK_ULONG object, CK_ATTRIBUTE *tmpl, CK_ULONG count) {
+	return f->C_GetAttributeValue(session, object, tmpl, count);
+}
+static CK_RV signECDSA(CK_FUNCTION_LIST *f, CK_ULONG session, CK_ULONG key, CK_BYTE *data, CK_ULONG dataLen, CK_BYTE *sig, CK_ULONG *sigLen) {
+	CK_MECHANISM mechanism = {CKM_ECDSA, NULL, 0};
+	CK_RV rv = f->C_SignInit(session, &mechanism, key);
+	if (rv != 0) {
+		return rv;
+	}
+	return f->C_Sign(session, data, dataLen, sig, sigLen);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// unavailableInformation is the length reported for attributes which cannot be
+// retrieved (CK_UNAVAILABLE_INFORMATION).
+const unavailableInformation = ^C.CK_ULONG(0)
+
+// attribute is an object attribute, either to match against or as retrieved.
+type attribute struct {
+	typ   uint
+	value []byte
+}
+
+// ulongAttribute creates an attribute holding a CK_ULONG value.
+func ulongAttribute(typ uint, value uint) attribute {
+	buf := make([]byte, C.sizeof_CK_ULONG)
+	*(*C.CK_ULONG)(unsafe.Pointer(&buf[0])) = C.CK_ULONG(value)
+	return attribute{typ: typ, value: buf}
+}
+
+// tokenInfo is the subset of a token's description used by the wallet.
+type tokenInfo struct {
+	label  string
+	model  string
+	serial string
+	flags  uint
+}
+
+// module is a loaded Cryptoki library. The library is initialized without
+// locking callbacks, so all calls into it are serialized.
+type module struct {
+	path  string
+	lib   unsafe.Pointer
+	funcs *C.CK_FUNCTION_LIST
+	lock  sync.Mutex
+}
+
+// loadModule loads and initializes the Cryptoki library at the given path.
+func loadModule(path string) (*module, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	m := &module{path: path}
+	if rv := C.load(cpath, &m.lib, &m.funcs); rv != 0 {
+		switch rv {
+		case ^C.CK_RV(0):
+			return nil, fmt.Errorf("pkcs11: failed to load %s: %s", path, C.GoString(C.dlerror()))
+		case ^C.CK_RV(1):
+			return nil, fmt.Errorf("pkcs11: %s is not a Cryptoki library", path)
+		}
+		return nil, Error(rv)
+	}
+	if rv := C.initialize(m.funcs); rv != 0 && Error(rv) != errCryptokiAlreadyInitialized {
+		C.dlclose(m.lib)
+		return nil, Error(rv)
+	}
+	return m, nil
+}
+
+// slots returns the slots with a token present.
+func (m *module) slots() ([]uint, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var count C.CK_ULONG
+	if rv := C.getSlotList(m.funcs, nil, &count); rv != 0 {
+		return nil, Error(rv)
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	ids := make([]C.CK_ULONG, count)
+	if rv := C.getSlotList(m.funcs, &ids[0], &count); rv != 0 {
+		return nil, Error(rv)
+	}
+	slots := make([]uint, count)
+	for i := range slots {
+		slots[i] = uint(ids[i])
+	}
+	return slots, nil
+}
+
+// tokenInfo retrieves the description of the token in a slot.
+func (m *module) tokenInfo(slot uint) (tokenInfo, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var info C.CK_TOKEN_INFO
+	if rv := C.getTokenInfo(m.funcs, C.CK_ULONG(slot), &info); rv != 0 {
+		return tokenInfo{}, Error(rv)
+	}
+	// Cryptoki strings are blank padded and not zero terminated
+	padded := func(field []C.CK_BYTE) string {
+		return strings.TrimRight(C.GoStringN((*C.char)(unsafe.Pointer(&field[0])), C.int(len(field))), " \x00")
+	}
+	return tokenInfo{
+		label:  padded(info.label[:]),
+		model:  padded(info.model[:]),
+		serial: padded(info.serialNumber[:]),
+		flags:  uint(info.flags),
+	}, nil
+}
+
+// openSession opens a read-only session with the token in a slot.
+func (m *module) openSession(slot uint) (uint, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var session C.CK_ULONG
+	if rv := C.openSession(m.funcs, C.CK_ULONG(slot), &session); rv != 0 {
+		return 0, Error(rv)
+	}
+	return uint(session), nil
+}
+
+// closeSession closes a session opened by openSession.
+func (m *module) closeSession(session uint) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if rv := C.closeSession(m.funcs, C.CK_ULONG(session)); rv != 0 {
+		return Error(rv)
+	}
+	return nil
+}
+
+// sessionState returns the state of a session (CKS_*).
+func (m *module) sessionState(session uint) (uint, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var info C.CK_SESSION_INFO
+	if rv := C.getSessionInfo(m.funcs, C.CK_ULONG(session), &info); rv != 0 {
+		return 0, Error(rv)
+	}
+	return uint(info.state), nil
+}
+
+// login logs the normal user into the token of a session. A nil PIN is used for
+// tokens with a protected authentication path, such as a PIN pad.
+func (m *module) login(session uint, pin []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var cpin *C.CK_BYTE
+	if pin != nil {
+		cpin = (*C.CK_BYTE)(C.CBytes(pin))
+		defer C.free(unsafe.Pointer(cpin))
+	}
+	if rv := C.login(m.funcs, C.CK_ULONG(session), ckuUser, cpin, C.CK_ULONG(len(pin))); rv != 0 {
+		return Error(rv)
+	}
+	return nil
+}
+
+// logout logs the user out of the token of a session.
+func (m *module) logout(session uint) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if rv := C.logout(m.funcs, C.CK_ULONG(session)); rv != 0 {
+		return Error(rv)
+	}
+	return nil
+}
+
+// newTemplate copies a list of attributes into C memory, as pointers to Go memory
+// may not be embedded into values passed to C. The template must be freed.
+func newTemplate(attrs []attribute) *C.CK_ATTRIBUTE {
+	if len(attrs) == 0 {
+		return nil
+	}
+	tmpl := (*[1 << 20]C.CK_ATTRIBUTE)(C.calloc(C.size_t(len(attrs)), C.sizeof_CK_ATTRIBUTE))[:len(attrs):len(attrs)]
+	for i, attr := range attrs {
+		tmpl[i]._type = C.CK_ULONG(attr.typ)
+		if attr.value != nil {
+			tmpl[i].pValue = C.CBytes(attr.value)
+			tmpl[i].ulValueLen = C.CK_ULONG(len(attr.value))
+		}
+	}
+	return &tmpl[0]
+}
+
+// freeTemplate releases a template created by newTemplate, along with the values
+// it points to.
+func freeTemplate(tmpl *C.CK_ATTRIBUTE, n int) {
+	if tmpl == nil {
+		return
+	}
+	for _, attr := range (*[1 << 20]C.CK_ATTRIBUTE)(unsafe.Pointer(tmpl))[:n:n] {
+		C.free(attr.pValue)
+	}
+	C.free(unsafe.Pointer(tmpl))
+}
+
+// findObjects returns the handles of all objects matching the given attributes.
+func (m *module) findObjects(session uint, match []attribute) ([]uint, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	tmpl := newTemplate(match)
+	defer freeTemplate(tmpl, len(match))
+
+	if rv := C.findObjectsInit(m.funcs, C.CK_ULONG(session), tmpl, C.CK_ULONG(len(match))); rv != 0 {
+		return nil, Error(rv)
+	}
+	var (
+		objects []uint
+		batch   [16]C.CK_ULONG
+	)
+	for {
+		var count C.CK_ULONG
+		if rv := C.findObjects(m.funcs, C.CK_ULONG(session), &batch[0], C.CK_ULONG(len(batch)), &count); rv != 0 {
+			C.findObjectsFinal(m.funcs, C.CK_ULONG(session))
+			return nil, Error(rv)
+		}
+		for i := 0; i < int(count); i++ {
+			objects = append(objects, uint(batch[i]))
+		}
+		if count < C.CK_ULONG(len(batch)) {
+			break
+		}
+	}
+	if rv := C.findObjectsFinal(m.funcs, C.CK_ULONG(session)); rv != 0 {
+		return nil, Error(rv)
+	}
+	return objects, nil
+}
+
+// attributes retrieves the requested attributes of an object. Attributes which
+// the object lacks or which are sensitive are returned as nil.
+func (m *module) attributes(session uint, object uint, types ...uint) ([][]byte, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	attrs := make([]attribute, len(types))
+	for i, typ := range types {
+		attrs[i].typ = typ
+	}
+	tmpl := newTemplate(attrs)
+	defer freeTemplate(tmpl, len(attrs))
+
+	// Query the value lengths first, then allocate the buffers and fetch them
+	rv := C.getAttributeValue(m.funcs, C.CK_ULONG(session), C.CK_ULONG(object), tmpl, C.CK_ULONG(len(attrs)))
+	if rv != 0 && Error(rv) != errAttributeSensitive && Error(rv) != errAttributeTypeInvalid {
+		return nil, Error(rv)
+	}
+	list := (*[1 << 20]C.CK_ATTRIBUTE)(unsafe.Pointer(tmpl))[:len(attrs):len(attrs)]
+	for i := range list {
+		if list[i].ulValueLen != unavailableInformation && list[i].ulValueLen > 0 {
+			list[i].pValue = C.malloc(C.size_t(list[i].ulValueLen))
+		}
+	}
+	rv = C.getAttributeValue(m.funcs, C.CK_ULONG(session), C.CK_ULONG(object), tmpl, C.CK_ULONG(len(attrs)))
+	if rv != 0 && Error(rv) != errAttributeSensitive && Error(rv) != errAttributeTypeInvalid {
+		return nil, Error(rv)
+	}
+	values := make([][]byte, len(attrs))
+	for i := range list {
+		if list[i].pValue != nil && list[i].ulValueLen != unavailableInformation {
+			values[i] = C.GoBytes(list[i].pValue, C.int(list[i].ulValueLen))
+		}
+	}
+	return values, nil
+}
+
+// sign signs a digest with an EC private key, returning the raw r || s signature.
+func (m *module) sign(session uint, key uint, digest []byte) ([]byte, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	data := (*C.CK_BYTE)(C.CBytes(digest))
+	defer C.free(unsafe.Pointer(data))
+
+	sig := (*C.CK_BYTE)(C.malloc(256))
+	defer C.free(unsafe.Pointer(sig))
+
+	sigLen := C.CK_ULONG(256)
+	if rv := C.signECDSA(m.funcs, C.CK_ULONG(session), C.CK_ULONG(key), data, C.CK_ULONG(len(digest)), sig, &sigLen); rv != 0 {
+		return nil, Error(rv)
+	}
+	return C.GoBytes(unsafe.Pointer(sig), C.int(sigLen)), nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !cgo || windows
+// +build !cgo windows
+
+// This is the fallback implementation of the Cryptoki bindings, for platforms
+// where libraries cannot be loaded dynamically.
+
+package pkcs11
+
+import "errors"
+
+var errNotSupported = errors.New("pkcs11: not supported on this platform")
+
+// attribute is an object attribute, either to match against or as retrieved.
+type attribute struct {
+	typ   uint
+	value []byte
+}
+
+func ulongAttribute(typ uint, value uint) attribute {
+	return attribute{typ: typ}
+}
+
+// tokenInfo is the subset of a token's description used by the wallet.
+type tokenInfo struct {
+	label  string
+	model  string
+	serial string
+	flags  uint
+}
+
+type module struct{}
+
+func loadModule(path string) (*module, error)             { return nil, errNotSupported }
+func (m *module) slots() ([]uint, error)                  { return nil, errNotSupported }
+func (m *module) tokenInfo(slot uint) (tokenInfo, error)  { return tokenInfo{}, errNotSupported }
+func (m *module) openSession(slot uint) (uint, error)     { return 0, errNotSupported }
+func (m *module) closeSession(session uint) error         { return errNotSupported }
+func (m *module) sessionState(session uint) (uint, error) { return 0, errNotSupported }
+func (m *module) login(session uint, pin []byte) error    { return errNotSupported }
+func (m *module) logout(session uint) error               { return errNotSupported }
+func (m *module) findObjects(session uint, match []attribute) ([]uint, error) {
+	return nil, errNotSupported
+}
+func (m *module) attributes(session uint, object uint, types ...uint) ([][]byte, error) {
+	return nil, errNotSupported
+}
+func (m *module) sign(session uint, key uint, digest []byte) ([]byte, error) {
+	return nil, errNotSupported
+}
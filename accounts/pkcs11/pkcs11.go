@@ -0,0 +1,92 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pkcs11
+
+import "fmt"
+
+// Cryptoki constants used by the wallet, as defined by the PKCS#11 specification.
+const (
+	ckuUser = 1 // CKU_USER
+
+	ckoPublicKey  = 2 // CKO_PUBLIC_KEY
+	ckoPrivateKey = 3 // CKO_PRIVATE_KEY
+	ckkEC         = 3 // CKK_EC
+
+	ckaClass    = 0x000 // CKA_CLASS
+	ckaLabel    = 0x003 // CKA_LABEL
+	ckaKeyType  = 0x100 // CKA_KEY_TYPE
+	ckaID       = 0x102 // CKA_ID
+	ckaECParams = 0x180 // CKA_EC_PARAMS
+	ckaECPoint  = 0x181 // CKA_EC_POINT
+
+	ckfProtectedAuthenticationPath = 1 << 8  // CKF_PROTECTED_AUTHENTICATION_PATH
+	ckfUserPINLocked               = 1 << 18 // CKF_USER_PIN_LOCKED
+
+	cksROUserFunctions = 1 // CKS_RO_USER_FUNCTIONS
+	cksRWUserFunctions = 3 // CKS_RW_USER_FUNCTIONS
+)
+
+// Error is a Cryptoki return value other than CKR_OK.
+type Error uint
+
+// Return values handled by the wallet.
+const (
+	errAttributeSensitive         Error = 0x011 // CKR_ATTRIBUTE_SENSITIVE
+	errAttributeTypeInvalid       Error = 0x012 // CKR_ATTRIBUTE_TYPE_INVALID
+	errPINIncorrect               Error = 0x0a0 // CKR_PIN_INCORRECT
+	errUserAlreadyLoggedIn        Error = 0x100 // CKR_USER_ALREADY_LOGGED_IN
+	errUserNotLoggedIn            Error = 0x101 // CKR_USER_NOT_LOGGED_IN
+	errCryptokiAlreadyInitialized Error = 0x191 // CKR_CRYPTOKI_ALREADY_INITIALIZED
+)
+
+// errorNames contains the names of the return values commonly seen in practice.
+var errorNames = map[Error]string{
+	0x003: "CKR_SLOT_ID_INVALID",
+	0x005: "CKR_GENERAL_ERROR",
+	0x006: "CKR_FUNCTION_FAILED",
+	0x007: "CKR_ARGUMENTS_BAD",
+	0x011: "CKR_ATTRIBUTE_SENSITIVE",
+	0x012: "CKR_ATTRIBUTE_TYPE_INVALID",
+	0x030: "CKR_DEVICE_ERROR",
+	0x032: "CKR_DEVICE_REMOVED",
+	0x054: "CKR_FUNCTION_NOT_SUPPORTED",
+	0x060: "CKR_KEY_HANDLE_INVALID",
+	0x068: "CKR_KEY_FUNCTION_NOT_PERMITTED",
+	0x070: "CKR_MECHANISM_INVALID",
+	0x082: "CKR_OBJECT_HANDLE_INVALID",
+	0x090: "CKR_OPERATION_ACTIVE",
+	0x0a0: "CKR_PIN_INCORRECT",
+	0x0a2: "CKR_PIN_LEN_RANGE",
+	0x0a4: "CKR_PIN_LOCKED",
+	0x0b0: "CKR_SESSION_CLOSED",
+	0x0b3: "CKR_SESSION_HANDLE_INVALID",
+	0x0e0: "CKR_TOKEN_NOT_PRESENT",
+	0x0e1: "CKR_TOKEN_NOT_RECOGNIZED",
+	0x100: "CKR_USER_ALREADY_LOGGED_IN",
+	0x101: "CKR_USER_NOT_LOGGED_IN",
+	0x102: "CKR_USER_PIN_NOT_INITIALIZED",
+	0x150: "CKR_BUFFER_TOO_SMALL",
+	0x190: "CKR_CRYPTOKI_NOT_INITIALIZED",
+	0x191: "CKR_CRYPTOKI_ALREADY_INITIALIZED",
+}
+
+func (e Error) Error() string {
+	if name, ok := errorNames[e]; ok {
+		return "pkcs11: " + name
+	}
+	return fmt.Sprintf("pkcs11: error 0x%x", uint(e))
+}
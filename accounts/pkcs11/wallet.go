@@ -0,0 +1,404 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pkcs11
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/pictor01/ALBA"
+	"github.com/pictor01/ALBA/accounts"
+	"github.com/pictor01/ALBA/common"
+	"github.com/pictor01/ALBA/core/types"
+	"github.com/pictor01/ALBA/crypto"
+	"github.com/pictor01/ALBA/log"
+)
+
+// ErrPINNeeded is returned if opening the wallet or signing requires the user
+// PIN of the token.
+var ErrPINNeeded = errors.New("pkcs11: pin needed")
+
+// ErrPubkeyMismatch is returned if a signature produced by the token does not
+// belong to the public key of the account.
+var ErrPubkeyMismatch = errors.New("pkcs11: signature does not match public key")
+
+// secp256k1Params is the DER encoded object identifier of the secp256k1 curve,
+// as found in the CKA_EC_PARAMS attribute of its keys.
+var secp256k1Params = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x0a}
+
+var (
+	secp256k1N     = crypto.S256().Params().N
+	secp256k1halfN = new(big.Int).Rsh(secp256k1N, 1)
+)
+
+// key is a secp256k1 key pair found on a token.
+type key struct {
+	id     []byte // CKA_ID shared by the public and private key objects
+	pubkey []byte // Uncompressed public key, for matching signatures against
+}
+
+// Wallet represents a PKCS#11 token, holding the accounts of its secp256k1 keys.
+type Wallet struct {
+	hub     *Hub         // Hub the token was found through
+	url     accounts.URL // Textual URL uniquely identifying this token
+	slot    uint         // Slot the token is inserted into
+	info    tokenInfo    // Description of the token
+	session uint         // Session kept open for as long as the token is present
+
+	accounts []accounts.Account     // Accounts of the keys found on the token
+	keys     map[common.Address]key // Keys of the accounts, for signing
+
+	lock sync.Mutex // Lock serializing access to the token
+	log  log.Logger // Contextual logger to tag the token with its id
+}
+
+// newWallet opens a session with the token in a slot and loads its keys.
+func newWallet(hub *Hub, slot uint, id string, info tokenInfo) (*Wallet, error) {
+	session, err := hub.module.openSession(slot)
+	if err != nil {
+		return nil, err
+	}
+	w := &Wallet{
+		hub:     hub,
+		url:     accounts.URL{Scheme: Scheme, Path: id},
+		slot:    slot,
+		info:    info,
+		session: session,
+		log:     log.New("url", accounts.URL{Scheme: Scheme, Path: id}),
+	}
+	if err := w.loadKeys(); err != nil {
+		hub.module.closeSession(session)
+		return nil, err
+	}
+	return w, nil
+}
+
+// URL implements accounts.Wallet, returning the URL of the token.
+func (w *Wallet) URL() accounts.URL {
+	return w.url
+}
+
+// Status implements accounts.Wallet, returning a custom status message from the
+// token.
+func (w *Wallet) Status() (string, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	loggedIn, err := w.loggedIn()
+	if err != nil {
+		return fmt.Sprintf("Failed: %v", err), err
+	}
+	if loggedIn {
+		return "Online", nil
+	}
+	if info, err := w.hub.module.tokenInfo(w.slot); err == nil && info.flags&ckfUserPINLocked != 0 {
+		return "Blocked, user PIN locked", nil
+	}
+	return "Locked, waiting for PIN", nil
+}
+
+// Open implements accounts.Wallet, logging the user into the token with the PIN
+// given as passphrase. Tokens with a protected authentication path (e.g. a PIN
+// pad) may be opened without one.
+func (w *Wallet) Open(passphrase string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	loggedIn, err := w.loggedIn()
+	if err != nil {
+		return err
+	}
+	if loggedIn {
+		return accounts.ErrWalletAlreadyOpen
+	}
+	if err := w.login(passphrase); err != nil {
+		return err
+	}
+	// Public keys may be private objects, only visible after logging in
+	if err := w.loadKeys(); err != nil {
+		w.log.Warn("Failed to reload keys", "err", err)
+	}
+	// Notify anyone listening for wallet events that the token is accessible
+	go w.hub.updateFeed.Send(accounts.WalletEvent{Wallet: w, Kind: accounts.WalletOpened})
+
+	return nil
+}
+
+// Close implements accounts.Wallet, logging the user out of the token.
+func (w *Wallet) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if err := w.hub.module.logout(w.session); err != nil && err != errUserNotLoggedIn {
+		return err
+	}
+	return nil
+}
+
+// Accounts implements accounts.Wallet, returning the accounts of the secp256k1
+// keys on the token.
+func (w *Wallet) Accounts() []accounts.Account {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	cpy := make([]accounts.Account, len(w.accounts))
+	copy(cpy, w.accounts)
+	return cpy
+}
+
+// Contains implements accounts.Wallet, returning whether a particular account is
+// or is not wrapped by this wallet instance.
+func (w *Wallet) Contains(account accounts.Account) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, acc := range w.accounts {
+		if acc.Address == account.Address && (account.URL == (accounts.URL{}) || account.URL == acc.URL) {
+			return true
+		}
+	}
+	return false
+}
+
+// Derive implements accounts.Wallet, but is not supported for PKCS#11 tokens,
+// whose keys are generated on the token instead of derived from a seed.
+func (w *Wallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, accounts.ErrNotSupported
+}
+
+// SelfDerive implements accounts.Wallet, but is a noop for PKCS#11 tokens, whose
+// keys are all discovered on the token.
+func (w *Wallet) SelfDerive(bases []accounts.DerivationPath, chain ethereum.ChainStateReader) {
+}
+
+// SignData implements accounts.Wallet, signing the hash of the given data with
+// the key of the account. The wallet needs to be open.
+func (w *Wallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	return w.signHash(account, "", crypto.Keccak256(data))
+}
+
+// SignDataWithPassphrase implements accounts.Wallet, logging into the token with
+// the given PIN for the duration of the signing if the wallet is not open.
+func (w *Wallet) SignDataWithPassphrase(account accounts.Account, passphrase, mimeType string, data []byte) ([]byte, error) {
+	return w.signHash(account, passphrase, crypto.Keccak256(data))
+}
+
+// SignText implements accounts.Wallet, signing the hash of the given text with
+// the key of the account. The wallet needs to be open.
+func (w *Wallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	return w.signHash(account, "", accounts.TextHash(text))
+}
+
+// SignTextWithPassphrase implements accounts.Wallet, logging into the token with
+// the given PIN for the duration of the signing if the wallet is not open.
+func (w *Wallet) SignTextWithPassphrase(account accounts.Account, passphrase string, text []byte) ([]byte, error) {
+	return w.signHash(account, passphrase, accounts.TextHash(text))
+}
+
+// SignTx implements accounts.Wallet, signing the given transaction with the key
+// of the account. The wallet needs to be open.
+func (w *Wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return w.SignTxWithPassphrase(account, "", tx, chainID)
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, logging into the token with
+// the given PIN for the duration of the signing if the wallet is not open.
+func (w *Wallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	signer := types.LatestSignerForChainID(chainID)
+	hash := signer.Hash(tx)
+	sig, err := w.signHash(account, passphrase, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(signer, sig)
+}
+
+// signHash signs a hash with the key of an account. If the wallet is not open,
+// the user is logged in with the given PIN for the duration of the signing.
+func (w *Wallet) signHash(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	loggedIn, err := w.loggedIn()
+	if err != nil {
+		return nil, err
+	}
+	if !loggedIn {
+		if err := w.login(passphrase); err != nil {
+			return nil, err
+		}
+		defer w.hub.module.logout(w.session)
+	}
+	key, ok := w.keys[account.Address]
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	objects, err := w.hub.module.findObjects(w.session, []attribute{
+		ulongAttribute(ckaClass, ckoPrivateKey),
+		ulongAttribute(ckaKeyType, ckkEC),
+		{typ: ckaID, value: key.id},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("pkcs11: private key of %v not found", account.Address)
+	}
+	sig, err := w.hub.module.sign(w.session, objects[0], hash)
+	if err != nil {
+		return nil, err
+	}
+	return recoverableSignature(hash, sig, key.pubkey)
+}
+
+// loggedIn returns whether the user is logged into the token.
+func (w *Wallet) loggedIn() (bool, error) {
+	state, err := w.hub.module.sessionState(w.session)
+	if err != nil {
+		return false, err
+	}
+	return state == cksROUserFunctions || state == cksRWUserFunctions, nil
+}
+
+// login logs the user into the token.
+func (w *Wallet) login(pin string) error {
+	var secret []byte
+	switch {
+	case pin != "":
+		secret = []byte(pin)
+	case w.info.flags&ckfProtectedAuthenticationPath == 0:
+		return ErrPINNeeded
+	}
+	switch err := w.hub.module.login(w.session, secret); err {
+	case nil, errUserAlreadyLoggedIn:
+		return nil
+	case errPINIncorrect:
+		return accounts.ErrInvalidPassphrase
+	default:
+		return err
+	}
+}
+
+// refresh checks that the token is still accessible and reloads its keys.
+func (w *Wallet) refresh() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.loadKeys()
+}
+
+// release closes the session with the token.
+func (w *Wallet) release() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.hub.module.closeSession(w.session)
+}
+
+// loadKeys enumerates the secp256k1 public keys on the token.
+func (w *Wallet) loadKeys() error {
+	objects, err := w.hub.module.findObjects(w.session, []attribute{
+		ulongAttribute(ckaClass, ckoPublicKey),
+		ulongAttribute(ckaKeyType, ckkEC),
+	})
+	if err != nil {
+		return err
+	}
+	var (
+		accs []accounts.Account
+		keys = make(map[common.Address]key)
+	)
+	for _, object := range objects {
+		values, err := w.hub.module.attributes(w.session, object, ckaID, ckaECParams, ckaECPoint)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(values[1], secp256k1Params) {
+			continue
+		}
+		if len(values[0]) == 0 {
+			w.log.Debug("Skipping key without identifier", "object", object)
+			continue
+		}
+		pubkey, err := parseECPoint(values[2])
+		if err != nil {
+			w.log.Debug("Skipping key with invalid public key", "id", hex.EncodeToString(values[0]), "err", err)
+			continue
+		}
+		address := crypto.PubkeyToAddress(*pubkey)
+		if _, ok := keys[address]; ok {
+			continue
+		}
+		keys[address] = key{id: values[0], pubkey: crypto.FromECDSAPub(pubkey)}
+		accs = append(accs, accounts.Account{
+			Address: address,
+			URL:     accounts.URL{Scheme: w.url.Scheme, Path: fmt.Sprintf("%s/%x", w.url.Path, values[0])},
+		})
+	}
+	sort.Sort(accounts.AccountsByURL(accs))
+	w.accounts, w.keys = accs, keys
+	return nil
+}
+
+// parseECPoint decodes the CKA_EC_POINT attribute of a secp256k1 public key.
+func parseECPoint(point []byte) (*ecdsa.PublicKey, error) {
+	// The point should be wrapped in a DER octet string, but some tokens return
+	// it raw. The uncompressed encoding can't be mistaken for the wrapped one.
+	if len(point) != 65 {
+		var raw []byte
+		rest, err := asn1.Unmarshal(point, &raw)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) > 0 {
+			return nil, errors.New("trailing data after EC point")
+		}
+		point = raw
+	}
+	return crypto.UnmarshalPubkey(point)
+}
+
+// recoverableSignature converts the raw r || s signature produced by the token
+// into the [R || S || V] format used by Ethereum. S is normalized into the lower
+// half of the curve order and V is found by recovering the expected public key.
+func recoverableSignature(hash, sig, pubkey []byte) ([]byte, error) {
+	if len(sig) != 64 {
+		return nil, fmt.Errorf("pkcs11: invalid signature length %d", len(sig))
+	}
+	s := new(big.Int).SetBytes(sig[32:])
+	if s.Cmp(secp256k1halfN) > 0 {
+		s.Sub(secp256k1N, s)
+	}
+	rsv := make([]byte, 65)
+	copy(rsv, sig[:32])
+	s.FillBytes(rsv[32:64])
+
+	for v := byte(0); v < 2; v++ {
+		rsv[64] = v
+		if recovered, err := crypto.Ecrecover(hash, rsv); err == nil && bytes.Equal(recovered, pubkey) {
+			return rsv, nil
+		}
+	}
+	return nil, ErrPubkeyMismatch
+}
@@ -0,0 +1,219 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pkcs11
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pictor01/ALBA/accounts"
+	"github.com/pictor01/ALBA/common"
+	"github.com/pictor01/ALBA/core/types"
+	"github.com/pictor01/ALBA/crypto"
+)
+
+func TestParseECPoint(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	raw := crypto.FromECDSAPub(&key.PublicKey)
+	wrapped, _ := asn1.Marshal(raw)
+
+	for _, point := range [][]byte{raw, wrapped} {
+		pubkey, err := parseECPoint(point)
+		if err != nil {
+			t.Fatalf("failed to parse point %x: %v", point, err)
+		}
+		if !bytes.Equal(crypto.FromECDSAPub(pubkey), raw) {
+			t.Errorf("public key mismatch: have %x, want %x", crypto.FromECDSAPub(pubkey), raw)
+		}
+	}
+	for _, point := range [][]byte{raw[:64], wrapped[:66], append(wrapped, 0x00)} {
+		if _, err := parseECPoint(point); err == nil {
+			t.Errorf("invalid point %x accepted", point)
+		}
+	}
+}
+
+func TestRecoverableSignature(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	pubkey := crypto.FromECDSAPub(&key.PublicKey)
+	hash := crypto.Keccak256([]byte("pkcs11"))
+
+	want, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	// Tokens may produce either of the two valid values of s
+	high := new(big.Int).Sub(secp256k1N, new(big.Int).SetBytes(want[32:64]))
+	malleated := append(common.CopyBytes(want[:32]), common.LeftPadBytes(high.Bytes(), 32)...)
+
+	for _, sig := range [][]byte{want[:64], malleated} {
+		have, err := recoverableSignature(hash, sig, pubkey)
+		if err != nil {
+			t.Fatalf("failed to convert signature: %v", err)
+		}
+		if !bytes.Equal(have, want) {
+			t.Errorf("signature mismatch: have %x, want %x", have, want)
+		}
+	}
+	other, _ := crypto.GenerateKey()
+	if _, err := recoverableSignature(hash, want[:64], crypto.FromECDSAPub(&other.PublicKey)); err != ErrPubkeyMismatch {
+		t.Errorf("signature of other key: have %v, want %v", err, ErrPubkeyMismatch)
+	}
+	if _, err := recoverableSignature(hash, want[:63], pubkey); err == nil {
+		t.Errorf("short signature accepted")
+	}
+}
+
+// softHSMModules are the usual install locations of the SoftHSM library.
+var softHSMModules = []string{
+	"/usr/lib/softhsm/libsofthsm2.so",
+	"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/lib64/pkcs11/libsofthsm2.so",
+	"/usr/local/lib/softhsm/libsofthsm2.so",
+	"/opt/homebrew/lib/softhsm/libsofthsm2.so",
+}
+
+// testModule returns a PKCS#11 library and the PIN of a token on it holding a
+// secp256k1 key. The library is taken from PKCS11_TEST_MODULE and PKCS11_TEST_PIN
+// if set, otherwise a SoftHSM token is set up if its tools are installed.
+func testModule(t *testing.T) (string, string) {
+	if module := os.Getenv("PKCS11_TEST_MODULE"); module != "" {
+		return module, os.Getenv("PKCS11_TEST_PIN")
+	}
+	var module string
+	for _, path := range softHSMModules {
+		if _, err := os.Stat(path); err == nil {
+			module = path
+			break
+		}
+	}
+	if module == "" {
+		t.Skip("SoftHSM not installed")
+	}
+	for _, tool := range []string{"softhsm2-util", "pkcs11-tool"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "softhsm2.conf")
+	if err := os.Mkdir(filepath.Join(dir, "tokens"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(conf, []byte("directories.tokendir = "+filepath.Join(dir, "tokens")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("SOFTHSM2_CONF", conf)
+
+	for _, args := range [][]string{
+		{"softhsm2-util", "--init-token", "--free", "--label", "test", "--pin", "1234", "--so-pin", "123456"},
+		{"pkcs11-tool", "--module", module, "--token-label", "test", "--login", "--pin", "1234",
+			"--keypairgen", "--key-type", "EC:secp256k1", "--id", "01", "--label", "test"},
+	} {
+		if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			t.Fatalf("failed to set up SoftHSM token: %v\n%s", err, out)
+		}
+	}
+	return module, "1234"
+}
+
+func TestWallet(t *testing.T) {
+	module, pin := testModule(t)
+
+	hub, err := NewHub(module)
+	if err != nil {
+		t.Fatalf("failed to load module: %v", err)
+	}
+	var wallet accounts.Wallet
+	for _, w := range hub.Wallets() {
+		if len(w.Accounts()) > 0 {
+			wallet = w
+			break
+		}
+	}
+	if wallet == nil {
+		t.Fatalf("no token with secp256k1 keys found")
+	}
+	account := wallet.Accounts()[0]
+	if !strings.HasPrefix(account.URL.Path, wallet.URL().Path+"/") || !wallet.Contains(account) {
+		t.Fatalf("account %v not contained in wallet %v", account.URL, wallet.URL())
+	}
+	tx := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	chainID := big.NewInt(1337)
+	signer := types.LatestSignerForChainID(chainID)
+
+	// Signing and opening fail without the PIN
+	if _, err := wallet.SignTx(account, tx, chainID); err != ErrPINNeeded {
+		t.Fatalf("signing with locked wallet: have %v, want %v", err, ErrPINNeeded)
+	}
+	if err := wallet.Open(""); err != ErrPINNeeded {
+		t.Fatalf("opening without PIN: have %v, want %v", err, ErrPINNeeded)
+	}
+	if err := wallet.Open(pin + "0"); err != accounts.ErrInvalidPassphrase {
+		t.Fatalf("opening with wrong PIN: have %v, want %v", err, accounts.ErrInvalidPassphrase)
+	}
+	// Signing with the PIN logs in for the duration of the signing only
+	signed, err := wallet.SignTxWithPassphrase(account, pin, tx, chainID)
+	if err != nil {
+		t.Fatalf("failed to sign with PIN: %v", err)
+	}
+	if from, err := types.Sender(signer, signed); err != nil || from != account.Address {
+		t.Fatalf("sender mismatch: have %v (%v), want %v", from, err, account.Address)
+	}
+	if status, _ := wallet.Status(); status != "Locked, waiting for PIN" {
+		t.Fatalf("wallet not locked after signing with PIN: %s", status)
+	}
+	// Opening the wallet allows signing without the PIN
+	if err := wallet.Open(pin); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	if status, _ := wallet.Status(); status != "Online" {
+		t.Fatalf("wallet not online after opening: %s", status)
+	}
+	for i := uint64(0); i < 8; i++ {
+		tx := types.NewTransaction(i, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+		signed, err := wallet.SignTx(account, tx, chainID)
+		if err != nil {
+			t.Fatalf("failed to sign transaction %d: %v", i, err)
+		}
+		if from, err := types.Sender(signer, signed); err != nil || from != account.Address {
+			t.Fatalf("transaction %d sender mismatch: have %v (%v), want %v", i, from, err, account.Address)
+		}
+	}
+	text := []byte("hello pkcs11")
+	sig, err := wallet.SignText(account, text)
+	if err != nil {
+		t.Fatalf("failed to sign text: %v", err)
+	}
+	pubkey, err := crypto.SigToPub(accounts.TextHash(text), sig)
+	if err != nil || crypto.PubkeyToAddress(*pubkey) != account.Address {
+		t.Fatalf("text signer mismatch: have %v, want %v", err, account.Address)
+	}
+	if err := wallet.Close(); err != nil {
+		t.Fatalf("failed to close wallet: %v", err)
+	}
+	if _, err := wallet.SignText(account, text); err != ErrPINNeeded {
+		t.Fatalf("signing with closed wallet: have %v, want %v", err, ErrPINNeeded)
+	}
+}
@@ -52,6 +52,7 @@ import (
 	"github.com/ethereum/go-ethereum/signer/core"
 	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	"github.com/ethereum/go-ethereum/signer/fourbyte"
+	"github.com/ethereum/go-ethereum/signer/policy"
 	"github.com/ethereum/go-ethereum/signer/rules"
 	"github.com/ethereum/go-ethereum/signer/storage"
 	"github.com/mattn/go-colorable"
@@ -125,6 +126,14 @@ var (
 		Name:  "rules",
 		Usage: "Path to the rule file to auto-authorize requests with",
 	}
+	policyFlag = cli.StringFlag{
+		Name:  "policy",
+		Usage: "Path to the spending policy file to check transactions against",
+	}
+	attestPolicyFlag = cli.BoolFlag{
+		Name:  "attest-policy",
+		Usage: "Attest a spending policy file instead of a rule file",
+	}
 	stdiouiFlag = cli.BoolFlag{
 		Name: "stdio-ui",
 		Usage: "Use STDIN/STDOUT as a channel for an external UI. " +
@@ -158,13 +167,14 @@ the rule-engine to work.`,
 			logLevelFlag,
 			configdirFlag,
 			signerSecretFlag,
+			attestPolicyFlag,
 		},
 		Description: `
 The attest command stores the sha256 of the rule.js-file that you want to use for automatic processing of
-incoming requests.
+incoming requests. With --attest-policy, it stores the sha256 of the spending policy file instead.
 
-Whenever you make an edit to the rule file, you need to use attestation to tell
-Clef that the file is 'safe' to execute.`,
+Whenever you make an edit to the rule or policy file, you need to use attestation to tell
+Clef that the file is 'safe' to use.`,
 	}
 	setCredentialCommand = cli.Command{
 		Action:    utils.MigrateFlags(setCredential),
@@ -229,6 +239,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.LightKDFFlag,
 			utils.NoUSBFlag,
 			utils.SmartCardDaemonPathFlag,
+			utils.PKCS11ModuleFlag,
 			utils.HTTPListenAddrFlag,
 			utils.HTTPVirtualHostsFlag,
 			utils.IPCDisabledFlag,
@@ -239,6 +250,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			customDBFlag,
 			auditLogFlag,
 			ruleFlag,
+			policyFlag,
 			stdiouiFlag,
 			testFlag,
 			advancedMode,
@@ -258,6 +270,7 @@ func init() {
 		utils.LightKDFFlag,
 		utils.NoUSBFlag,
 		utils.SmartCardDaemonPathFlag,
+		utils.PKCS11ModuleFlag,
 		utils.HTTPListenAddrFlag,
 		utils.HTTPVirtualHostsFlag,
 		utils.IPCDisabledFlag,
@@ -268,6 +281,7 @@ func init() {
 		customDBFlag,
 		auditLogFlag,
 		ruleFlag,
+		policyFlag,
 		stdiouiFlag,
 		testFlag,
 		advancedMode,
@@ -410,6 +424,11 @@ func attestFile(ctx *cli.Context) error {
 	// Initialize the encrypted storages
 	configStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "config.json"), confKey)
 	val := ctx.Args().First()
+	if ctx.Bool(attestPolicyFlag.Name) {
+		configStorage.Put("policy_sha256", val)
+		log.Info("Policy attestation updated", "sha256", val)
+		return nil
+	}
 	configStorage.Put("ruleset_sha256", val)
 	log.Info("Ruleset attestation updated", "sha256", val)
 	return nil
@@ -486,7 +505,7 @@ func newAccount(c *cli.Context) error {
 		lightKdf                  = c.GlobalBool(utils.LightKDFFlag.Name)
 	)
 	log.Info("Starting clef", "keystore", ksLoc, "light-kdf", lightKdf)
-	am := core.StartClefAccountManager(ksLoc, true, lightKdf, "")
+	am := core.StartClefAccountManager(ksLoc, true, lightKdf, "", "")
 	// This gives is us access to the external API
 	apiImpl := core.NewSignerAPI(am, 0, true, ui, nil, false, pwStorage)
 	// This gives us access to the internal API
@@ -572,11 +591,16 @@ func signer(c *cli.Context) error {
 	log.Info("Loaded 4byte database", "embeds", embeds, "locals", locals, "local", fourByteLocal)
 
 	var (
-		api       core.ExternalAPI
-		pwStorage storage.Storage = &storage.NoStorage{}
+		api          core.ExternalAPI
+		pwStorage    storage.Storage = &storage.NoStorage{}
+		policyEngine *policy.Evaluator
 	)
 	configDir := c.GlobalString(configdirFlag.Name)
 	if stretchedKey, err := readMasterKey(c, ui); err != nil {
+		// A spending policy restricts signing, running without it is not an option
+		if policyFile := c.GlobalString(policyFlag.Name); policyFile != "" {
+			utils.Fatalf("Failed to open master, can't enforce policy %s: %v", policyFile, err)
+		}
 		log.Warn("Failed to open master, rules disabled", "err", err)
 	} else {
 		vaultLocation := filepath.Join(configDir, common.Bytes2Hex(crypto.Keccak256([]byte("vault"), stretchedKey)[:10]))
@@ -585,6 +609,7 @@ func signer(c *cli.Context) error {
 		pwkey := crypto.Keccak256([]byte("credentials"), stretchedKey)
 		jskey := crypto.Keccak256([]byte("jsstorage"), stretchedKey)
 		confkey := crypto.Keccak256([]byte("config"), stretchedKey)
+		policykey := crypto.Keccak256([]byte("policy"), stretchedKey)
 
 		// Initialize the encrypted storages
 		pwStorage = storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "credentials.json"), pwkey)
@@ -614,6 +639,28 @@ func signer(c *cli.Context) error {
 				}
 			}
 		}
+		// Do we have a spending policy? Unlike rules, a policy that can't be
+		// enforced is fatal, signing without the limits it sets is not safe.
+		if policyFile := c.GlobalString(policyFlag.Name); policyFile != "" {
+			policyJSON, err := ioutil.ReadFile(policyFile)
+			if err != nil {
+				utils.Fatalf("Could not load policy file %s: %v", policyFile, err)
+			}
+			shasum := sha256.Sum256(policyJSON)
+			foundShaSum := hex.EncodeToString(shasum[:])
+			storedShasum, _ := configStorage.Get("policy_sha256")
+			if storedShasum != foundShaSum {
+				utils.Fatalf("Policy hash %s not attested, attest it with `clef attest --attest-policy %s`", foundShaSum, foundShaSum)
+			}
+			spendingPolicy, err := policy.Parse(policyJSON)
+			if err != nil {
+				utils.Fatalf("Invalid policy file: %v", err)
+			}
+			policyStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "spending.json"), policykey)
+			policyEngine = policy.NewEvaluator(ui, spendingPolicy, db, policyStorage)
+			ui = policyEngine
+			log.Info("Spending policy configured", "file", policyFile, "accounts", len(spendingPolicy.Accounts))
+		}
 	}
 	var (
 		chainId  = c.GlobalInt64(chainIdFlag.Name)
@@ -622,10 +669,11 @@ func signer(c *cli.Context) error {
 		advanced = c.GlobalBool(advancedMode.Name)
 		nousb    = c.GlobalBool(utils.NoUSBFlag.Name)
 		scpath   = c.GlobalString(utils.SmartCardDaemonPathFlag.Name)
+		hsmlib   = c.GlobalString(utils.PKCS11ModuleFlag.Name)
 	)
 	log.Info("Starting signer", "chainid", chainId, "keystore", ksLoc,
 		"light-kdf", lightKdf, "advanced", advanced)
-	am := core.StartClefAccountManager(ksLoc, nousb, lightKdf, scpath)
+	am := core.StartClefAccountManager(ksLoc, nousb, lightKdf, scpath, hsmlib)
 	apiImpl := core.NewSignerAPI(am, chainId, nousb, ui, db, advanced, pwStorage)
 
 	// Establish the bidirectional communication, by creating a new UI backend and registering
@@ -634,10 +682,14 @@ func signer(c *cli.Context) error {
 	api = apiImpl
 	// Audit logging
 	if logfile := c.GlobalString(auditLogFlag.Name); logfile != "" {
-		api, err = core.NewAuditLogger(logfile, api)
+		auditLogger, err := core.NewAuditLogger(logfile, api)
 		if err != nil {
 			utils.Fatalf(err.Error())
 		}
+		if policyEngine != nil {
+			policyEngine.SetAuditLog(auditLogger)
+		}
+		api = auditLogger
 		log.Info("Audit logs configured", "file", logfile)
 	}
 	// register signer API with server
@@ -661,7 +713,7 @@ func signer(c *cli.Context) error {
 		if err != nil {
 			utils.Fatalf("Could not register API: %w", err)
 		}
-		handler := node.NewHTTPHandlerStack(srv, cors, vhosts)
+		handler := node.NewHTTPHandlerStack(srv, cors, vhosts, nil)
 
 		// set port
 		port := c.Int(rpcPortFlag.Name)
@@ -49,6 +49,7 @@ var (
 		ArgsUsage: "<genesisPath>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.StateSchemeFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -210,6 +211,12 @@ func initGenesis(ctx *cli.Context) error {
 		if err != nil {
 			utils.Fatalf("Failed to open database: %v", err)
 		}
+		// Light clients don't store state, the scheme only matters for full nodes
+		if name == "chaindata" {
+			if _, err := rawdb.ParseStateScheme(ctx.GlobalString(utils.StateSchemeFlag.Name), chaindb); err != nil {
+				utils.Fatalf("Failed to initialise state scheme: %v", err)
+			}
+		}
 		_, hash, err := core.SetupGenesisBlock(chaindb, genesis)
 		if err != nil {
 			utils.Fatalf("Failed to write genesis block: %v", err)
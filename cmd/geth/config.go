@@ -29,9 +29,11 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts/external"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/accounts/pkcs11"
 	"github.com/ethereum/go-ethereum/accounts/scwallet"
 	"github.com/ethereum/go-ethereum/accounts/usbwallet"
 	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/log"
@@ -165,7 +167,7 @@ func makeFullNode(ctx *cli.Context) (*node.Node, ethapi.Backend) {
 
 	// Configure GraphQL if requested
 	if ctx.GlobalIsSet(utils.GraphQLEnabledFlag.Name) {
-		utils.RegisterGraphQLService(stack, backend, cfg.Node)
+		utils.RegisterGraphQLService(stack, backend, cfg.Eth.SyncMode == downloader.LightSync, cfg.Node)
 	}
 	// Add the Ethereum Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
@@ -314,6 +316,14 @@ func setAccountManagerBackends(stack *node.Node) error {
 			am.AddBackend(schub)
 		}
 	}
+	if len(conf.PKCS11Module) > 0 {
+		// Start a hub for the tokens of a hardware security module
+		if hsmhub, err := pkcs11.NewHub(conf.PKCS11Module); err != nil {
+			log.Warn(fmt.Sprintf("Failed to start PKCS#11 hub, disabling: %v", err))
+		} else {
+			am.AddBackend(hsmhub)
+		}
+	}
 
 	return nil
 }
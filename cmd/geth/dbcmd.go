@@ -69,6 +69,7 @@ Remove blockchain and state databases`,
 			dbDumpFreezerIndex,
 			dbImportCmd,
 			dbExportCmd,
+			dbPruneHistoryCmd,
 		},
 	}
 	dbInspectCmd = cli.Command{
@@ -233,6 +234,27 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 		},
 		Description: "Exports the specified chain data to an RLP encoded stream, optionally gzip-compressed.",
 	}
+	dbPruneHistoryCmd = cli.Command{
+		Action:    utils.MigrateFlags(pruneHistory),
+		Name:      "prune-history",
+		Usage:     "Delete ancient block bodies and receipts older than the given number of blocks",
+		ArgsUsage: "<number of recent blocks to keep>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.RopstenFlag,
+			utils.SepoliaFlag,
+			utils.RinkebyFlag,
+			utils.GoerliFlag,
+		},
+		Description: `This command deletes the block bodies and receipts from the ancient store
+which are older than the given number of blocks counted from the current head.
+Headers are retained, so the chain can still be verified and followed. Data is
+deleted in whole freezer files, so slightly more history than requested may be
+retained. The deleted history can only be recovered by resyncing.`,
+	}
 )
 
 func removeDB(ctx *cli.Context) error {
@@ -547,6 +569,41 @@ func freezerInspect(ctx *cli.Context) error {
 	return nil
 }
 
+// pruneHistory deletes the ancient bodies and receipts older than the given
+// number of blocks.
+func pruneHistory(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	keep, err := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number of blocks to keep: %v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	head := rawdb.ReadHeadHeader(db)
+	if head == nil {
+		return errors.New("no head header found")
+	}
+	if head.Number.Uint64() <= keep {
+		log.Info("No history to prune", "head", head.Number, "keep", keep)
+		return nil
+	}
+	threshold := head.Number.Uint64() - keep
+
+	start := time.Now()
+	tail, err := rawdb.TruncateAncientHistory(db, threshold)
+	if err != nil {
+		return err
+	}
+	log.Info("Pruned ancient chain history", "head", head.Number, "threshold", threshold, "tail", tail, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
 // ParseHexOrString tries to hexdecode b, but if the prefix is missing, it instead just returns the raw bytes
 func parseHexOrString(str string) ([]byte, error) {
 	b, err := hexutil.Decode(str)
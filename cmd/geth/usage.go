@@ -37,9 +37,11 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.DataDirFlag,
 			utils.AncientFlag,
 			utils.MinFreeDiskSpaceFlag,
+			utils.DBEngineFlag,
 			utils.KeyStoreDirFlag,
 			utils.USBFlag,
 			utils.SmartCardDaemonPathFlag,
+			utils.PKCS11ModuleFlag,
 			utils.NetworkIdFlag,
 			utils.MainnetFlag,
 			utils.GoerliFlag,
@@ -49,6 +51,8 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.SyncModeFlag,
 			utils.ExitWhenSyncedFlag,
 			utils.GCModeFlag,
+			utils.StateSchemeFlag,
+			utils.StateHistoryFlag,
 			utils.TxLookupLimitFlag,
 			utils.EthStatsURLFlag,
 			utils.IdentityFlag,
@@ -105,6 +109,9 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.TxPoolAccountQueueFlag,
 			utils.TxPoolGlobalQueueFlag,
 			utils.TxPoolLifetimeFlag,
+			utils.TxPoolBlobDatadirFlag,
+			utils.TxPoolBlobDatacapFlag,
+			utils.TxPoolBlobAccountSlotsFlag,
 		},
 	},
 	{
@@ -142,6 +149,10 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.HTTPPathPrefixFlag,
 			utils.HTTPCORSDomainFlag,
 			utils.HTTPVirtualHostsFlag,
+			utils.AuthListenFlag,
+			utils.AuthPortFlag,
+			utils.AuthVirtualHostsFlag,
+			utils.JWTSecretFlag,
 			utils.WSEnabledFlag,
 			utils.WSListenAddrFlag,
 			utils.WSPortFlag,
@@ -155,6 +166,11 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.RPCGlobalEVMTimeoutFlag,
 			utils.RPCGlobalTxFeeCapFlag,
 			utils.AllowUnprotectedTxs,
+			utils.BatchRequestLimit,
+			utils.BatchResponseMaxSize,
+			utils.RPCRateLimitFlag,
+			utils.RPCRateLimitBurstFlag,
+			utils.RPCMethodRateLimitsFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
 			utils.PreloadJSFlag,
@@ -217,6 +233,10 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Name:  "METRICS AND STATS",
 		Flags: metricsFlags,
 	},
+	{
+		Name:  "TRACING",
+		Flags: tracingFlags,
+	},
 	{
 		Name: "ALIASED (deprecated)",
 		Flags: []cli.Flag{
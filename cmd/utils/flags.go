@@ -55,6 +55,7 @@ import (
 	"github.com/ethereum/go-ethereum/graphql"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/les"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -67,6 +68,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	gopsutil "github.com/shirou/gopsutil/mem"
 	"gopkg.in/urfave/cli.v1"
@@ -122,6 +124,10 @@ var (
 		Name:  "datadir.minfreedisk",
 		Usage: "Minimum free disk space in MB, once reached triggers auto shut down (default = --cache.gc converted to MB, 0 = disabled)",
 	}
+	DBEngineFlag = cli.StringFlag{
+		Name:  "db.engine",
+		Usage: "Backing database implementation to use ('leveldb' or 'pebble', default = leveldb or the engine of an existing database)",
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -135,6 +141,10 @@ var (
 		Usage: "Path to the smartcard daemon (pcscd) socket file",
 		Value: pcsclite.PCSCDSockName,
 	}
+	PKCS11ModuleFlag = cli.StringFlag{
+		Name:  "pkcs11.module",
+		Usage: "Path to the PKCS#11 library of a hardware security module holding account keys",
+	}
 	NetworkIdFlag = cli.Uint64Flag{
 		Name:  "networkid",
 		Usage: "Explicitly set network id (integer)(For testnets: use --ropsten, --rinkeby, --goerli instead)",
@@ -223,6 +233,15 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	StateSchemeFlag = cli.StringFlag{
+		Name:  "state.scheme",
+		Usage: `Scheme to use for storing the state trie nodes ("hash" or "path")`,
+	}
+	StateHistoryFlag = cli.Uint64Flag{
+		Name:  "state.history",
+		Usage: "Number of recent blocks to retain state history for in the path scheme (0 = entire chain)",
+		Value: ethconfig.Defaults.StateHistory,
+	}
 	SnapshotFlag = cli.BoolTFlag{
 		Name:  "snapshot",
 		Usage: `Enables snapshot-database mode (default = enable)`,
@@ -388,6 +407,21 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: ethconfig.Defaults.TxPool.Lifetime,
 	}
+	TxPoolBlobDatadirFlag = cli.StringFlag{
+		Name:  "txpool.blobdatadir",
+		Usage: "Data directory to store large-payload transactions in (empty = memory only)",
+		Value: ethconfig.Defaults.TxPool.BlobDatadir,
+	}
+	TxPoolBlobDatacapFlag = cli.Uint64Flag{
+		Name:  "txpool.blobdatacap",
+		Usage: "Maximum total size in bytes of large-payload transactions kept in the pool",
+		Value: ethconfig.Defaults.TxPool.BlobDatacap,
+	}
+	TxPoolBlobAccountSlotsFlag = cli.Uint64Flag{
+		Name:  "txpool.blobaccountslots",
+		Usage: "Maximum number of large-payload transactions permitted per account",
+		Value: ethconfig.Defaults.TxPool.BlobAccountSlots,
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -573,6 +607,25 @@ var (
 		Usage: "HTTP path path prefix on which JSON-RPC is served. Use '/' to serve on all paths.",
 		Value: "",
 	}
+	AuthListenFlag = cli.StringFlag{
+		Name:  "authrpc.addr",
+		Usage: "Listening address for authenticated APIs",
+		Value: node.DefaultConfig.AuthAddr,
+	}
+	AuthPortFlag = cli.IntFlag{
+		Name:  "authrpc.port",
+		Usage: "Listening port for authenticated APIs",
+		Value: node.DefaultConfig.AuthPort,
+	}
+	AuthVirtualHostsFlag = cli.StringFlag{
+		Name:  "authrpc.vhosts",
+		Usage: "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
+		Value: strings.Join(node.DefaultConfig.AuthVirtualHosts, ","),
+	}
+	JWTSecretFlag = cli.StringFlag{
+		Name:  "authrpc.jwtsecret",
+		Usage: "Path to a JWT secret to use for authenticated RPC endpoints",
+	}
 	GraphQLEnabledFlag = cli.BoolFlag{
 		Name:  "graphql",
 		Usage: "Enable GraphQL on the HTTP-RPC server. Note that GraphQL can only be started if an HTTP server is started as well.",
@@ -628,6 +681,28 @@ var (
 		Name:  "rpc.allow-unprotected-txs",
 		Usage: "Allow for unprotected (non EIP155 signed) transactions to be submitted via RPC",
 	}
+	BatchRequestLimit = cli.IntFlag{
+		Name:  "rpc.batch-request-limit",
+		Usage: "Maximum number of requests in a batch (0 = no limit)",
+		Value: node.DefaultConfig.BatchRequestLimit,
+	}
+	BatchResponseMaxSize = cli.IntFlag{
+		Name:  "rpc.batch-response-max-size",
+		Usage: "Maximum number of bytes returned for a batch (0 = no limit)",
+		Value: node.DefaultConfig.BatchResponseMaxSize,
+	}
+	RPCRateLimitFlag = cli.Float64Flag{
+		Name:  "rpc.ratelimit",
+		Usage: "Maximum number of HTTP and WebSocket RPC requests per second from a single IP (0 = no limit)",
+	}
+	RPCRateLimitBurstFlag = cli.IntFlag{
+		Name:  "rpc.ratelimit.burst",
+		Usage: "Maximum number of HTTP and WebSocket RPC requests from a single IP in a burst",
+	}
+	RPCMethodRateLimitsFlag = cli.StringFlag{
+		Name:  "rpc.ratelimit.methods",
+		Usage: "Comma separated per-IP method call limits as method=rate[:burst] (e.g. eth_getLogs=5:10)",
+	}
 
 	// Network Settings
 	MaxPeersFlag = cli.IntFlag{
@@ -660,7 +735,7 @@ var (
 	}
 	NATFlag = cli.StringFlag{
 		Name:  "nat",
-		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>)",
+		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>|stun:<server>)",
 		Value: "any",
 	}
 	NoDiscoverFlag = cli.BoolFlag{
@@ -790,6 +865,22 @@ var (
 		Value: metrics.DefaultConfig.InfluxDBOrganization,
 	}
 
+	// Tracing flags
+	TracingEnabledFlag = cli.BoolFlag{
+		Name:  "tracing",
+		Usage: "Enable export of RPC and block import traces to an OTLP collector",
+	}
+	TracingEndpointFlag = cli.StringFlag{
+		Name:  "tracing.endpoint",
+		Usage: "OTLP/HTTP endpoint of the collector to export traces to",
+		Value: "http://localhost:4318/v1/traces",
+	}
+	TracingSampleRateFlag = cli.Float64Flag{
+		Name:  "tracing.samplerate",
+		Usage: "Fraction of traces to sample, between 0 and 1",
+		Value: 1.0,
+	}
+
 	CatalystFlag = cli.BoolFlag{
 		Name:  "catalyst",
 		Usage: "Catalyst mode (eth2 integration testing)",
@@ -970,9 +1061,64 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(HTTPPathPrefixFlag.Name) {
 		cfg.HTTPPathPrefix = ctx.GlobalString(HTTPPathPrefixFlag.Name)
 	}
+	if ctx.GlobalIsSet(AuthListenFlag.Name) {
+		cfg.AuthAddr = ctx.GlobalString(AuthListenFlag.Name)
+	}
+	if ctx.GlobalIsSet(AuthPortFlag.Name) {
+		cfg.AuthPort = ctx.GlobalInt(AuthPortFlag.Name)
+	}
+	if ctx.GlobalIsSet(AuthVirtualHostsFlag.Name) {
+		cfg.AuthVirtualHosts = SplitAndTrim(ctx.GlobalString(AuthVirtualHostsFlag.Name))
+	}
 	if ctx.GlobalIsSet(AllowUnprotectedTxs.Name) {
 		cfg.AllowUnprotectedTxs = ctx.GlobalBool(AllowUnprotectedTxs.Name)
 	}
+	if ctx.GlobalIsSet(BatchRequestLimit.Name) {
+		cfg.BatchRequestLimit = ctx.GlobalInt(BatchRequestLimit.Name)
+	}
+	if ctx.GlobalIsSet(BatchResponseMaxSize.Name) {
+		cfg.BatchResponseMaxSize = ctx.GlobalInt(BatchResponseMaxSize.Name)
+	}
+	if ctx.GlobalIsSet(RPCRateLimitFlag.Name) {
+		cfg.RPCRateLimit.Rate = ctx.GlobalFloat64(RPCRateLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCRateLimitBurstFlag.Name) {
+		cfg.RPCRateLimit.Burst = ctx.GlobalInt(RPCRateLimitBurstFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCMethodRateLimitsFlag.Name) {
+		limits, err := parseMethodRateLimits(ctx.GlobalString(RPCMethodRateLimitsFlag.Name))
+		if err != nil {
+			Fatalf("Option %s: %v", RPCMethodRateLimitsFlag.Name, err)
+		}
+		cfg.RPCMethodRateLimits = limits
+	}
+}
+
+// parseMethodRateLimits parses a comma separated list of method=rate[:burst]
+// rate limits.
+func parseMethodRateLimits(input string) (map[string]rpc.RateLimit, error) {
+	limits := make(map[string]rpc.RateLimit)
+	for _, entry := range SplitAndTrim(input) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid method limit %q", entry)
+		}
+		var (
+			limit rpc.RateLimit
+			err   error
+		)
+		spec := strings.SplitN(parts[1], ":", 2)
+		if limit.Rate, err = strconv.ParseFloat(spec[0], 64); err != nil || limit.Rate < 0 {
+			return nil, fmt.Errorf("invalid rate in method limit %q", entry)
+		}
+		if len(spec) == 2 {
+			if limit.Burst, err = strconv.Atoi(spec[1]); err != nil || limit.Burst < 0 {
+				return nil, fmt.Errorf("invalid burst in method limit %q", entry)
+			}
+		}
+		limits[parts[0]] = limit
+	}
+	return limits, nil
 }
 
 // setGraphQL creates the GraphQL listener interface string from the set
@@ -1012,6 +1158,14 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setJWTSecret sets the path to the JWT secret used by the authenticated
+// RPC endpoints from the set command line flags.
+func setJWTSecret(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(JWTSecretFlag.Name) {
+		cfg.JWTSecret = ctx.GlobalString(JWTSecretFlag.Name)
+	}
+}
+
 // setIPC creates an IPC path configuration from the set command line flags,
 // returning an empty string if IPC was explicitly disabled, or the set path.
 func setIPC(ctx *cli.Context, cfg *node.Config) {
@@ -1219,9 +1373,18 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setGraphQL(ctx, cfg)
 	setWS(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
+	setJWTSecret(ctx, cfg)
 	setDataDir(ctx, cfg)
 	setSmartCard(ctx, cfg)
 
+	if ctx.GlobalIsSet(DBEngineFlag.Name) {
+		dbEngine := ctx.GlobalString(DBEngineFlag.Name)
+		if dbEngine != "leveldb" && dbEngine != "pebble" {
+			Fatalf("Invalid choice for db.engine '%s', allowed 'leveldb' or 'pebble'", dbEngine)
+		}
+		cfg.DBEngine = dbEngine
+	}
+
 	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
 		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
 	}
@@ -1241,6 +1404,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(USBFlag.Name) {
 		cfg.USB = ctx.GlobalBool(USBFlag.Name)
 	}
+	if ctx.GlobalIsSet(PKCS11ModuleFlag.Name) {
+		cfg.PKCS11Module = ctx.GlobalString(PKCS11ModuleFlag.Name)
+	}
 	if ctx.GlobalIsSet(InsecureUnlockAllowedFlag.Name) {
 		cfg.InsecureUnlockAllowed = ctx.GlobalBool(InsecureUnlockAllowedFlag.Name)
 	}
@@ -1354,6 +1520,15 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolBlobDatadirFlag.Name) {
+		cfg.BlobDatadir = ctx.GlobalString(TxPoolBlobDatadirFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolBlobDatacapFlag.Name) {
+		cfg.BlobDatacap = ctx.GlobalUint64(TxPoolBlobDatacapFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolBlobAccountSlotsFlag.Name) {
+		cfg.BlobAccountSlots = ctx.GlobalUint64(TxPoolBlobAccountSlotsFlag.Name)
+	}
 }
 
 func setEthash(ctx *cli.Context, cfg *ethconfig.Config) {
@@ -1537,6 +1712,16 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(GCModeFlag.Name) {
 		cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 	}
+	if ctx.GlobalIsSet(StateSchemeFlag.Name) {
+		scheme := ctx.GlobalString(StateSchemeFlag.Name)
+		if scheme != rawdb.HashScheme && scheme != rawdb.PathScheme {
+			Fatalf("--%s must be either '%s' or '%s'", StateSchemeFlag.Name, rawdb.HashScheme, rawdb.PathScheme)
+		}
+		cfg.StateScheme = scheme
+	}
+	if ctx.GlobalIsSet(StateHistoryFlag.Name) {
+		cfg.StateHistory = ctx.GlobalUint64(StateHistoryFlag.Name)
+	}
 	if ctx.GlobalIsSet(CacheNoPrefetchFlag.Name) {
 		cfg.NoPrefetch = ctx.GlobalBool(CacheNoPrefetchFlag.Name)
 	}
@@ -1752,8 +1937,8 @@ func RegisterEthStatsService(stack *node.Node, backend ethapi.Backend, url strin
 }
 
 // RegisterGraphQLService is a utility function to construct a new service and register it against a node.
-func RegisterGraphQLService(stack *node.Node, backend ethapi.Backend, cfg node.Config) {
-	if err := graphql.New(stack, backend, cfg.GraphQLCors, cfg.GraphQLVirtualHosts); err != nil {
+func RegisterGraphQLService(stack *node.Node, backend ethapi.Backend, lightMode bool, cfg node.Config) {
+	if err := graphql.New(stack, backend, lightMode, cfg.GraphQLCors, cfg.GraphQLVirtualHosts); err != nil {
 		Fatalf("Failed to register the GraphQL service: %v", err)
 	}
 }
@@ -1817,6 +2002,17 @@ func SetupMetrics(ctx *cli.Context) {
 	}
 }
 
+// SetupTracing starts exporting traces if enabled on the command line.
+func SetupTracing(ctx *cli.Context) {
+	if !ctx.GlobalBool(TracingEnabledFlag.Name) {
+		return
+	}
+	endpoint := ctx.GlobalString(TracingEndpointFlag.Name)
+	if err := tracing.Setup(endpoint, ctx.GlobalFloat64(TracingSampleRateFlag.Name)); err != nil {
+		Fatalf("Failed to set up tracing: %v", err)
+	}
+}
+
 func SplitTagsFlag(tagsFlag string) map[string]string {
 	tags := strings.Split(tagsFlag, ",")
 	tagsMap := map[string]string{}
@@ -293,6 +293,18 @@ func (beacon *Beacon) FinalizeAndAssemble(chain consensus.ChainHeaderReader, hea
 	return types.NewBlock(header, txs, uncles, receipts, trie.NewStackTrie(nil)), nil
 }
 
+// VerifyState implements consensus.StateVerifier, forwarding the check to the
+// eth1 engine for legacy headers if it supports it.
+func (beacon *Beacon) VerifyState(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB) error {
+	if beacon.IsPoSHeader(header) {
+		return nil
+	}
+	if verifier, ok := beacon.ethone.(consensus.StateVerifier); ok {
+		return verifier.VerifyState(chain, header, state)
+	}
+	return nil
+}
+
 // Seal generates a new sealing request for the given input block and pushes
 // the result into the given channel.
 //
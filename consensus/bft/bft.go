@@ -0,0 +1,713 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bft implements a byzantine fault tolerant proof-of-authority consensus
+// engine with immediate finality, modelled after IBFT/QBFT.
+//
+// Every height is decided in rounds. The proposer of a round, picked round-robin
+// from the validator set, sends a PRE-PREPARE with its block. Validators accept
+// it with a PREPARE and, once a quorum of 2F+1 prepares is seen, sign the block
+// hash in a COMMIT. A quorum of commits finalizes the block. If a round doesn't
+// finish in time, validators move on with a ROUND-CHANGE carrying the block they
+// prepared, if any, which the next proposer has to re-propose.
+//
+// The commit seals finalizing a block are stored in its own extra-data, so every
+// block, the chain head included, proves its finality. They are left out of the
+// block hash, so the hash doesn't depend on which quorum committed it.
+package bft
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	checkpointInterval = 1024 // Number of blocks after which to save the vote snapshot to the database
+	inmemorySnapshots  = 128  // Number of recent vote snapshots to keep in memory
+	inmemorySignatures = 4096 // Number of recent block signatures to keep in memory
+)
+
+// BFT proof-of-authority protocol constants.
+var (
+	epochLength    = uint64(30000) // Default number of blocks after which to checkpoint and reset the pending votes
+	requestTimeout = uint64(10000) // Default milliseconds before the first round of a height times out
+
+	extraVanity = types.BFTExtraVanity // Fixed number of extra-data prefix bytes reserved for proposer vanity
+
+	nonceAuthVote = hexutil.MustDecode("0xffffffffffffffff") // Magic nonce number to vote on adding a new validator
+	nonceDropVote = hexutil.MustDecode("0x0000000000000000") // Magic nonce number to vote on removing a validator.
+
+	uncleHash = types.CalcUncleHash(nil) // Always Keccak256(RLP([])) as uncles are meaningless outside of PoW.
+
+	mixDigest = types.BFTDigest // Mix digest marking blocks sealed by the engine
+
+	difficulty = big.NewInt(1) // Block difficulty, the chain with the most finalized blocks wins
+)
+
+// Various error messages to mark blocks invalid. These should be private to
+// prevent engine specific errors from being referenced in the remainder of the
+// codebase, inherently breaking if the engine is swapped out. Please put common
+// error types into the consensus package.
+var (
+	// errUnknownBlock is returned when the list of validators is requested for a
+	// block that is not part of the local blockchain.
+	errUnknownBlock = errors.New("unknown block")
+
+	// errInvalidCheckpointBeneficiary is returned if a checkpoint/epoch transition
+	// block has a beneficiary set to non-zeroes.
+	errInvalidCheckpointBeneficiary = errors.New("beneficiary in checkpoint block non-zero")
+
+	// errInvalidVote is returned if a nonce value is something else that the two
+	// allowed constants of 0x00..0 or 0xff..f.
+	errInvalidVote = errors.New("vote nonce not 0x00..0 or 0xff..f")
+
+	// errInvalidCheckpointVote is returned if a checkpoint/epoch transition block
+	// has a vote nonce set to non-zeroes.
+	errInvalidCheckpointVote = errors.New("vote nonce in checkpoint block non-zero")
+
+	// errMissingVanity is returned if a block's extra-data section is shorter than
+	// 32 bytes, which is required to store the proposer vanity.
+	errMissingVanity = errors.New("extra-data 32 byte vanity prefix missing")
+
+	// errMissingSignature is returned if a block's extra-data section doesn't seem
+	// to contain a 65 byte secp256k1 proposer seal.
+	errMissingSignature = errors.New("extra-data 65 byte proposer seal missing")
+
+	// errMismatchingValidators is returned if a block contains a list of
+	// validators different than the one the local node calculated.
+	errMismatchingValidators = errors.New("mismatching validator list")
+
+	// errInvalidMixDigest is returned if a block's mix digest isn't the BFT marker.
+	errInvalidMixDigest = errors.New("invalid mix digest")
+
+	// errInvalidUncleHash is returned if a block contains an non-empty uncle list.
+	errInvalidUncleHash = errors.New("non empty uncle hash")
+
+	// errInvalidDifficulty is returned if the difficulty of a block is not 1.
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+	// errInvalidTimestamp is returned if the timestamp of a block is lower than
+	// the previous block's timestamp + the minimum block period.
+	errInvalidTimestamp = errors.New("invalid timestamp")
+
+	// errInvalidVotingChain is returned if a validator list is attempted to be
+	// modified via out-of-range or non-contiguous headers.
+	errInvalidVotingChain = errors.New("invalid voting chain")
+
+	// errUnauthorizedValidator is returned if a header is sealed by an entity
+	// outside of the validator set.
+	errUnauthorizedValidator = errors.New("unauthorized validator")
+
+	// errWrongProposer is returned if a block or a proposal is sealed by a
+	// validator other than the proposer of its round.
+	errWrongProposer = errors.New("wrong proposer for round")
+
+	// errInvalidCommitSeal is returned if a commit seal is malformed or wasn't
+	// produced by the validator claiming it.
+	errInvalidCommitSeal = errors.New("invalid commit seal")
+
+	// errUnauthorizedCommit is returned if a commit seal is produced by an entity
+	// outside of the validator set.
+	errUnauthorizedCommit = errors.New("commit seal of unauthorized validator")
+
+	// errDuplicateCommit is returned if a validator's commit seal is included
+	// more than once.
+	errDuplicateCommit = errors.New("duplicate commit seal")
+
+	// errInsufficientCommits is returned if a block isn't finalized by a quorum
+	// of commit seals.
+	errInsufficientCommits = errors.New("insufficient commit seals")
+
+	// errNotStarted is returned when sealing is requested before the consensus
+	// state machine is started.
+	errNotStarted = errors.New("consensus engine not started")
+)
+
+// SignerFn hashes and signs the data to be signed by a backing account.
+type SignerFn func(signer accounts.Account, mimeType string, message []byte) ([]byte, error)
+
+// ecrecover extracts the Ethereum account address of the proposer from a
+// sealed header.
+func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
+	// If the signature's already cached, return that
+	hash := header.Hash()
+	if address, known := sigcache.Get(hash); known {
+		return address.(common.Address), nil
+	}
+	// Retrieve the signature from the header extra-data
+	extra, err := decodeExtra(header)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(extra.Seal) != crypto.SignatureLength {
+		return common.Address{}, errMissingSignature
+	}
+	// Recover the public key and the Ethereum address
+	pubkey, err := crypto.Ecrecover(SealHash(header).Bytes(), extra.Seal)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+
+	sigcache.Add(hash, signer)
+	return signer, nil
+}
+
+// BFT is the byzantine fault tolerant proof-of-authority consensus engine.
+type BFT struct {
+	config *params.BFTConfig // Consensus engine configuration parameters
+	db     ethdb.Database    // Database to store and retrieve snapshot checkpoints
+
+	recents    *lru.ARCCache // Snapshots for recent block to speed up reorgs
+	signatures *lru.ARCCache // Signatures of recent blocks to speed up mining
+
+	proposals map[common.Address]bool // Current list of proposals we are pushing
+
+	signer common.Address // Ethereum address of the signing key
+	signFn SignerFn       // Signer function to authorize hashes with
+	lock   sync.RWMutex   // Protects the signer fields
+
+	machine *machine   // Consensus state machine, nil until started
+	peers   *peerSet   // Peers connected on the consensus sub-protocol
+	seen    *lru.Cache // Hashes of recently relayed network messages
+	runLock sync.Mutex // Protects the state machine lifecycle
+}
+
+// New creates a BFT proof-of-authority consensus engine with the initial
+// validators set to the ones in the genesis extra-data.
+func New(config *params.BFTConfig, db ethdb.Database) *BFT {
+	// Set any missing consensus parameters to their defaults
+	conf := *config
+	if conf.Epoch == 0 {
+		conf.Epoch = epochLength
+	}
+	if conf.RequestTimeout == 0 {
+		conf.RequestTimeout = requestTimeout
+	}
+	// Allocate the snapshot caches and create the engine
+	recents, _ := lru.NewARC(inmemorySnapshots)
+	signatures, _ := lru.NewARC(inmemorySignatures)
+	seen, _ := lru.New(maxKnownMessages)
+
+	return &BFT{
+		config:     &conf,
+		db:         db,
+		recents:    recents,
+		signatures: signatures,
+		proposals:  make(map[common.Address]bool),
+		peers:      newPeerSet(),
+		seen:       seen,
+	}
+}
+
+// Start launches the consensus state machine on top of the given chain. Blocks
+// finalized by the validators are inserted into it.
+func (b *BFT) Start(chain Chain) {
+	b.runLock.Lock()
+	defer b.runLock.Unlock()
+
+	if b.machine != nil {
+		return
+	}
+	b.machine = newMachine(b, chain)
+	b.machine.start()
+}
+
+// Author implements consensus.Engine, returning the Ethereum address recovered
+// from the proposer seal in the header's extra-data section.
+func (b *BFT) Author(header *types.Header) (common.Address, error) {
+	return ecrecover(header, b.signatures)
+}
+
+// VerifyHeader checks whether a header conforms to the consensus rules.
+func (b *BFT) VerifyHeader(chain consensus.ChainHeaderReader, header *types.Header, seal bool) error {
+	return b.verifyHeader(chain, header, nil, true)
+}
+
+// verifyProposal checks whether the header of a proposal conforms to the
+// consensus rules, short of being finalized.
+func (b *BFT) verifyProposal(chain consensus.ChainHeaderReader, header *types.Header) error {
+	return b.verifyHeader(chain, header, nil, false)
+}
+
+// VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers. The
+// method returns a quit channel to abort the operations and a results channel to
+// retrieve the async verifications (the order is that of the input slice).
+func (b *BFT) VerifyHeaders(chain consensus.ChainHeaderReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+
+	go func() {
+		for i, header := range headers {
+			err := b.verifyHeader(chain, header, headers[:i], true)
+
+			select {
+			case <-abort:
+				return
+			case results <- err:
+			}
+		}
+	}()
+	return abort, results
+}
+
+// verifyHeader checks whether a header conforms to the consensus rules. The
+// caller may optionally pass in a batch of parents (ascending order) to avoid
+// looking those up from the database. This is useful for concurrently verifying
+// a batch of new headers. Unless committed is unset, the header also needs to
+// carry the commit seals of a quorum of validators.
+func (b *BFT) verifyHeader(chain consensus.ChainHeaderReader, header *types.Header, parents []*types.Header, committed bool) error {
+	if header.Number == nil {
+		return errUnknownBlock
+	}
+	number := header.Number.Uint64()
+
+	// Don't waste time checking blocks from the future
+	if header.Time > uint64(time.Now().Unix()) {
+		return consensus.ErrFutureBlock
+	}
+	// Checkpoint blocks need to enforce zero beneficiary
+	checkpoint := (number % b.config.Epoch) == 0
+	if checkpoint && header.Coinbase != (common.Address{}) {
+		return errInvalidCheckpointBeneficiary
+	}
+	// Nonces must be 0x00..0 or 0xff..f, zeroes enforced on checkpoints
+	if !bytes.Equal(header.Nonce[:], nonceAuthVote) && !bytes.Equal(header.Nonce[:], nonceDropVote) {
+		return errInvalidVote
+	}
+	if checkpoint && !bytes.Equal(header.Nonce[:], nonceDropVote) {
+		return errInvalidCheckpointVote
+	}
+	// Check that the extra-data contains the vanity, the validators and the seal
+	extra, err := decodeExtra(header)
+	if err != nil {
+		return err
+	}
+	if number > 0 && len(extra.Seal) != crypto.SignatureLength {
+		return errMissingSignature
+	}
+	// Ensure that the mix digest marks the block as BFT sealed
+	if number > 0 && header.MixDigest != mixDigest {
+		return errInvalidMixDigest
+	}
+	// Ensure that the block doesn't contain any uncles which are meaningless in PoA
+	if header.UncleHash != uncleHash {
+		return errInvalidUncleHash
+	}
+	// Ensure that the block's difficulty is meaningful
+	if number > 0 {
+		if header.Difficulty == nil || header.Difficulty.Cmp(difficulty) != 0 {
+			return errInvalidDifficulty
+		}
+	}
+	// Verify that the gas limit is <= 2^63-1
+	cap := uint64(0x7fffffffffffffff)
+	if header.GasLimit > cap {
+		return fmt.Errorf("invalid gasLimit: have %v, max %v", header.GasLimit, cap)
+	}
+	// If all checks passed, validate any special fields for hard forks
+	if err := misc.VerifyForkHashes(chain.Config(), header, false); err != nil {
+		return err
+	}
+	// All basic checks passed, verify cascading fields
+	return b.verifyCascadingFields(chain, header, extra, parents, committed)
+}
+
+// verifyCascadingFields verifies all the header fields that are not standalone,
+// rather depend on a batch of previous headers. The caller may optionally pass
+// in a batch of parents (ascending order) to avoid looking those up from the
+// database. This is useful for concurrently verifying a batch of new headers.
+func (b *BFT) verifyCascadingFields(chain consensus.ChainHeaderReader, header *types.Header, extra *Extra, parents []*types.Header, committed bool) error {
+	// The genesis block is the always valid dead-end
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil
+	}
+	// Ensure that the block's timestamp isn't too close to its parent
+	var parent *types.Header
+	if len(parents) > 0 {
+		parent = parents[len(parents)-1]
+	} else {
+		parent = chain.GetHeader(header.ParentHash, number-1)
+	}
+	if parent == nil || parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
+		return consensus.ErrUnknownAncestor
+	}
+	if parent.Time+b.config.Period > header.Time {
+		return errInvalidTimestamp
+	}
+	// Verify that the gasUsed is <= gasLimit
+	if header.GasUsed > header.GasLimit {
+		return fmt.Errorf("invalid gasUsed: have %d, gasLimit %d", header.GasUsed, header.GasLimit)
+	}
+	if !chain.Config().IsLondon(header.Number) {
+		// Verify BaseFee not present before EIP-1559 fork.
+		if header.BaseFee != nil {
+			return fmt.Errorf("invalid baseFee before fork: have %d, want <nil>", header.BaseFee)
+		}
+		if err := misc.VerifyGaslimit(parent.GasLimit, header.GasLimit); err != nil {
+			return err
+		}
+	} else if err := misc.VerifyEip1559Header(chain.Config(), parent, header); err != nil {
+		// Verify the header's EIP-1559 attributes.
+		return err
+	}
+	// Retrieve the snapshot needed to verify this header and cache it
+	snap, err := b.snapshot(chain, number-1, header.ParentHash, parents)
+	if err != nil {
+		return err
+	}
+	// Every block carries the validator set, verify it against the local one
+	validators := snap.validators()
+	if len(extra.Validators) != len(validators) {
+		return errMismatchingValidators
+	}
+	for i, validator := range validators {
+		if extra.Validators[i] != validator {
+			return errMismatchingValidators
+		}
+	}
+	// All basic checks passed, verify the seal and return
+	return b.verifySeal(snap, header, extra, committed)
+}
+
+// snapshot retrieves the validator snapshot at a given point in time.
+func (b *BFT) snapshot(chain consensus.ChainHeaderReader, number uint64, hash common.Hash, parents []*types.Header) (*Snapshot, error) {
+	// Search for a snapshot in memory or on disk for checkpoints
+	var (
+		headers []*types.Header
+		snap    *Snapshot
+	)
+	for snap == nil {
+		// If an in-memory snapshot was found, use that
+		if s, ok := b.recents.Get(hash); ok {
+			snap = s.(*Snapshot)
+			break
+		}
+		// If an on-disk checkpoint snapshot can be found, use that
+		if number%checkpointInterval == 0 {
+			if s, err := loadSnapshot(b.config, b.signatures, b.db, hash); err == nil {
+				log.Trace("Loaded voting snapshot from disk", "number", number, "hash", hash)
+				snap = s
+				break
+			}
+		}
+		// If we're at the genesis, snapshot the initial state. Alternatively if we're
+		// at a checkpoint block without a parent (light client CHT), or we have piled
+		// up more headers than allowed to be reorged (chain reinit from a freezer),
+		// consider the checkpoint trusted and snapshot it.
+		if number == 0 || (number%b.config.Epoch == 0 && (len(headers) > params.FullImmutabilityThreshold || chain.GetHeaderByNumber(number-1) == nil)) {
+			checkpoint := chain.GetHeaderByNumber(number)
+			if checkpoint != nil {
+				hash := checkpoint.Hash()
+
+				extra, err := decodeExtra(checkpoint)
+				if err != nil {
+					return nil, err
+				}
+				snap = newSnapshot(b.config, b.signatures, number, hash, extra.Validators)
+				if err := snap.store(b.db); err != nil {
+					return nil, err
+				}
+				log.Info("Stored checkpoint snapshot to disk", "number", number, "hash", hash)
+				break
+			}
+		}
+		// No snapshot for this header, gather the header and move backward
+		var header *types.Header
+		if len(parents) > 0 {
+			// If we have explicit parents, pick from there (enforced)
+			header = parents[len(parents)-1]
+			if header.Hash() != hash || header.Number.Uint64() != number {
+				return nil, consensus.ErrUnknownAncestor
+			}
+			parents = parents[:len(parents)-1]
+		} else {
+			// No explicit parents (or no more left), reach out to the database
+			header = chain.GetHeader(hash, number)
+			if header == nil {
+				return nil, consensus.ErrUnknownAncestor
+			}
+		}
+		headers = append(headers, header)
+		number, hash = number-1, header.ParentHash
+	}
+	// Previous snapshot found, apply any pending headers on top of it
+	for i := 0; i < len(headers)/2; i++ {
+		headers[i], headers[len(headers)-1-i] = headers[len(headers)-1-i], headers[i]
+	}
+	snap, err := snap.apply(headers)
+	if err != nil {
+		return nil, err
+	}
+	b.recents.Add(snap.Hash, snap)
+
+	// If we've generated a new checkpoint snapshot, save to disk
+	if snap.Number%checkpointInterval == 0 && len(headers) > 0 {
+		if err = snap.store(b.db); err != nil {
+			return nil, err
+		}
+		log.Trace("Stored voting snapshot to disk", "number", snap.Number, "hash", snap.Hash)
+	}
+	return snap, err
+}
+
+// VerifyUncles implements consensus.Engine, always returning an error for any
+// uncles as this consensus mechanism doesn't permit uncles.
+func (b *BFT) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errors.New("uncles not allowed")
+	}
+	return nil
+}
+
+// verifySeal checks whether the proposer seal contained in the header satisfies
+// the consensus protocol requirements and, if committed is set, whether the block
+// was finalized by a quorum of commit seals.
+func (b *BFT) verifySeal(snap *Snapshot, header *types.Header, extra *Extra, committed bool) error {
+	// Verifying the genesis block is not supported
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+	// Resolve the authorization key and check against the round's proposer
+	signer, err := ecrecover(header, b.signatures)
+	if err != nil {
+		return err
+	}
+	if _, ok := snap.Validators[signer]; !ok {
+		return errUnauthorizedValidator
+	}
+	if signer != proposer(extra.Validators, number, extra.Round) {
+		return errWrongProposer
+	}
+	if !committed {
+		return nil
+	}
+	return verifyCommits(header.Hash(), extra.Commits, extra.Validators)
+}
+
+// Prepare implements consensus.Engine, preparing all the consensus fields of the
+// header for running the transactions on top.
+func (b *BFT) Prepare(chain consensus.ChainHeaderReader, header *types.Header) error {
+	// If the block isn't a checkpoint, cast a random vote (good enough for now)
+	header.Coinbase = common.Address{}
+	header.Nonce = types.BlockNonce{}
+
+	number := header.Number.Uint64()
+	// Assemble the voting snapshot to check which votes make sense
+	snap, err := b.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return err
+	}
+	if number%b.config.Epoch != 0 {
+		b.lock.RLock()
+
+		// Gather all the proposals that make sense voting on
+		addresses := make([]common.Address, 0, len(b.proposals))
+		for address, authorize := range b.proposals {
+			if snap.validVote(address, authorize) {
+				addresses = append(addresses, address)
+			}
+		}
+		// If there's pending proposals, cast a vote on them
+		if len(addresses) > 0 {
+			header.Coinbase = addresses[rand.Intn(len(addresses))]
+			if b.proposals[header.Coinbase] {
+				copy(header.Nonce[:], nonceAuthVote)
+			} else {
+				copy(header.Nonce[:], nonceDropVote)
+			}
+		}
+		b.lock.RUnlock()
+	}
+	// Set the correct difficulty
+	header.Difficulty = new(big.Int).Set(difficulty)
+
+	// Assemble the extra-data with the validators, the seals are added later
+	extra := &Extra{Validators: snap.validators()}
+	header.Extra = extra.Encode(header.Extra)
+
+	// Mix digest marks the block as BFT sealed
+	header.MixDigest = mixDigest
+
+	// Ensure the timestamp has the correct delay
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	header.Time = parent.Time + b.config.Period
+	if header.Time < uint64(time.Now().Unix()) {
+		header.Time = uint64(time.Now().Unix())
+	}
+	return nil
+}
+
+// Finalize implements consensus.Engine, ensuring no uncles are set, nor block
+// rewards given.
+func (b *BFT) Finalize(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header) {
+	// No block rewards in PoA, so the state remains as is and uncles are dropped
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	header.UncleHash = types.CalcUncleHash(nil)
+}
+
+// FinalizeAndAssemble implements consensus.Engine, ensuring no uncles are set,
+// nor block rewards given, and returns the final block.
+func (b *BFT) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	// Finalize block
+	b.Finalize(chain, header, state, txs, uncles)
+
+	// Assemble and return the final block for sealing
+	return types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil)), nil
+}
+
+// Authorize injects a private key into the consensus engine to propose and
+// vote on blocks with.
+func (b *BFT) Authorize(signer common.Address, signFn SignerFn) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.signer = signer
+	b.signFn = signFn
+}
+
+// credentials returns the signing credentials of the local validator.
+func (b *BFT) credentials() (common.Address, SignerFn) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.signer, b.signFn
+}
+
+// Seal implements consensus.Engine, handing the block to the consensus state
+// machine to be proposed once the local validator's turn comes. The results
+// channel is not used: blocks finalized by the validators are inserted into
+// the chain directly.
+func (b *BFT) Seal(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block, stop <-chan struct{}) error {
+	header := block.Header()
+
+	// Sealing the genesis block is not supported
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+	// For 0-period chains, refuse to seal empty blocks (no reward but would spin sealing)
+	if b.config.Period == 0 && len(block.Transactions()) == 0 {
+		return errors.New("sealing paused while waiting for transactions")
+	}
+	// Bail out if we're unauthorized to propose a block
+	signer, _ := b.credentials()
+
+	snap, err := b.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return err
+	}
+	if _, authorized := snap.Validators[signer]; !authorized {
+		return errUnauthorizedValidator
+	}
+	b.runLock.Lock()
+	machine := b.machine
+	b.runLock.Unlock()
+
+	if machine == nil {
+		return errNotStarted
+	}
+	machine.post(block)
+	return nil
+}
+
+// sealProposal signs a locally assembled block as the proposal of the given round.
+func (b *BFT) sealProposal(block *types.Block, round uint64) (*types.Block, error) {
+	signer, signFn := b.credentials()
+	if signFn == nil {
+		return nil, errUnauthorizedValidator
+	}
+	header := block.Header()
+	extra, err := decodeExtra(header)
+	if err != nil {
+		return nil, err
+	}
+	vanity := header.Extra[:extraVanity]
+
+	extra.Round, extra.Seal, extra.Commits = round, nil, nil
+	header.Extra = extra.Encode(vanity)
+
+	sighash, err := signFn(accounts.Account{Address: signer}, accounts.MimetypeBFT, BFTRLP(header))
+	if err != nil {
+		return nil, err
+	}
+	extra.Seal = sighash
+	header.Extra = extra.Encode(vanity)
+
+	return block.WithSeal(header), nil
+}
+
+// CalcDifficulty is the difficulty adjustment algorithm. It returns the difficulty
+// that a new block should have, which is always 1: every block carries a quorum
+// of commit seals, so there's never more than one valid block per height to
+// choose from.
+func (b *BFT) CalcDifficulty(chain consensus.ChainHeaderReader, time uint64, parent *types.Header) *big.Int {
+	return new(big.Int).Set(difficulty)
+}
+
+// SealHash returns the hash of a block prior to it being sealed.
+func (b *BFT) SealHash(header *types.Header) common.Hash {
+	return SealHash(header)
+}
+
+// Close implements consensus.Engine, terminating the consensus state machine.
+func (b *BFT) Close() error {
+	b.runLock.Lock()
+	defer b.runLock.Unlock()
+
+	if b.machine != nil {
+		b.machine.stop()
+		b.machine = nil
+	}
+	return nil
+}
+
+// APIs implements consensus.Engine, returning the user facing RPC API to allow
+// controlling the validator voting.
+func (b *BFT) APIs(chain consensus.ChainHeaderReader) []rpc.API {
+	return []rpc.API{{
+		Namespace: "bft",
+		Version:   "1.0",
+		Service:   &API{chain: chain, bft: b},
+		Public:    false,
+	}}
+}
@@ -0,0 +1,323 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// testNode is a validator of an in-process test network, assembling a block on
+// top of every new head like the miner does.
+type testNode struct {
+	key    *ecdsa.PrivateKey
+	engine *BFT
+	chain  *core.BlockChain
+	quit   chan struct{}
+}
+
+func newTestNode(t *testing.T, genesis *core.Genesis, key *ecdsa.PrivateKey) *testNode {
+	db := rawdb.NewMemoryDatabase()
+	genesis.MustCommit(db)
+
+	engine := New(genesis.Config.BFT, db)
+	chain, err := core.NewBlockChain(db, nil, genesis.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	engine.Authorize(crypto.PubkeyToAddress(key.PublicKey), func(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+		return crypto.Sign(crypto.Keccak256(data), key)
+	})
+	engine.Start(chain)
+
+	n := &testNode{key: key, engine: engine, chain: chain, quit: make(chan struct{})}
+	go n.mine()
+	return n
+}
+
+func (n *testNode) mine() {
+	heads := make(chan core.ChainHeadEvent, 16)
+	sub := n.chain.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	n.propose(n.chain.CurrentBlock().Header())
+	for {
+		select {
+		case ev := <-heads:
+			n.propose(ev.Block.Header())
+		case <-n.quit:
+			return
+		}
+	}
+}
+
+func (n *testNode) propose(parent *types.Header) {
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		BaseFee:    misc.CalcBaseFee(n.chain.Config(), parent),
+	}
+	if err := n.engine.Prepare(n.chain, header); err != nil {
+		return
+	}
+	statedb, err := n.chain.StateAt(parent.Root)
+	if err != nil {
+		return
+	}
+	block, err := n.engine.FinalizeAndAssemble(n.chain, header, statedb, nil, nil, nil)
+	if err != nil {
+		return
+	}
+	n.engine.Seal(n.chain, block, nil, nil)
+}
+
+func (n *testNode) close() {
+	close(n.quit)
+	n.engine.Close()
+	n.chain.Stop()
+}
+
+// newTestNetwork creates a genesis for the given validator keys and starts a
+// fully connected network of the first running ones.
+func newTestNetwork(t *testing.T, keys []*ecdsa.PrivateKey, running int) ([]*testNode, func()) {
+	validators := make([]common.Address, len(keys))
+	for i, key := range keys {
+		validators[i] = crypto.PubkeyToAddress(key.PublicKey)
+	}
+	config := *params.AllCliqueProtocolChanges
+	config.Clique = nil
+	config.BFT = &params.BFTConfig{Period: 1, Epoch: 30000, RequestTimeout: 250}
+
+	genesis := &core.Genesis{
+		Config:    &config,
+		ExtraData: GenesisExtra(validators),
+		GasLimit:  10000000,
+		BaseFee:   big.NewInt(params.InitialBaseFee),
+		Timestamp: uint64(time.Now().Unix()),
+	}
+	nodes := make([]*testNode, running)
+	for i := range nodes {
+		nodes[i] = newTestNode(t, genesis, keys[i])
+	}
+	var pipes []*p2p.MsgPipeRW
+	for i := 0; i < running; i++ {
+		for j := i + 1; j < running; j++ {
+			a, b := p2p.MsgPipe()
+			pipes = append(pipes, a, b)
+
+			go nodes[i].engine.runPeer(p2p.NewPeer(enode.PubkeyToIDV4(&keys[j].PublicKey), "", nil), a)
+			go nodes[j].engine.runPeer(p2p.NewPeer(enode.PubkeyToIDV4(&keys[i].PublicKey), "", nil), b)
+		}
+	}
+	return nodes, func() {
+		for _, pipe := range pipes {
+			pipe.Close()
+		}
+		for _, node := range nodes {
+			node.close()
+		}
+	}
+}
+
+// waitHeight waits until all nodes finalized the given number of blocks and
+// checks that they agree on them.
+func waitHeight(t *testing.T, nodes []*testNode, height uint64) {
+	deadline := time.Now().Add(30 * time.Second)
+	for _, node := range nodes {
+		for node.chain.CurrentBlock().NumberU64() < height {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for height %d, at %d", height, node.chain.CurrentBlock().NumberU64())
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	for number := uint64(1); number <= height; number++ {
+		want := nodes[0].chain.GetHeaderByNumber(number)
+		for i, node := range nodes[1:] {
+			if have := node.chain.GetHeaderByNumber(number); have.Hash() != want.Hash() {
+				t.Fatalf("node %d: block %d mismatch: have %x, want %x", i+1, number, have.Hash(), want.Hash())
+			}
+		}
+	}
+}
+
+func newTestKeys(n int) []*ecdsa.PrivateKey {
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	return keys
+}
+
+// Tests that a network of validators finalizes blocks and that every block,
+// the head included, carries its own commit seals.
+func TestNetworkFinality(t *testing.T) {
+	nodes, stop := newTestNetwork(t, newTestKeys(4), 4)
+	defer stop()
+
+	waitHeight(t, nodes, 3)
+
+	chain := nodes[0].chain
+	for number := uint64(1); number <= chain.CurrentHeader().Number.Uint64(); number++ {
+		header := chain.GetHeaderByNumber(number)
+
+		extra, err := decodeExtra(header)
+		if err != nil {
+			t.Fatalf("block %d: failed to decode extra-data: %v", number, err)
+		}
+		if err := verifyCommits(header.Hash(), extra.Commits, extra.Validators); err != nil {
+			t.Errorf("block %d: invalid commits: %v", number, err)
+		}
+	}
+}
+
+// Tests that the network makes progress through round changes while one of
+// the validators is offline.
+func TestNetworkRoundChange(t *testing.T) {
+	keys := newTestKeys(4)
+	nodes, stop := newTestNetwork(t, keys, 3)
+	defer stop()
+
+	// Every height proposed by the offline validator needs a round change
+	waitHeight(t, nodes, 5)
+
+	var changed bool
+	for number := uint64(1); number <= 5; number++ {
+		extra, err := decodeExtra(nodes[0].chain.GetHeaderByNumber(number))
+		if err != nil {
+			t.Fatalf("block %d: failed to decode extra-data: %v", number, err)
+		}
+		if extra.Round > 0 {
+			changed = true
+		}
+	}
+	if !changed {
+		t.Errorf("no block was proposed after a round change")
+	}
+}
+
+// Tests that the seal hash covers the whole header except the proposer seal and
+// the commit seals.
+func TestSealHash(t *testing.T) {
+	extra := &Extra{Validators: []common.Address{{0x01}, {0x02}}, Round: 1}
+	header := &types.Header{
+		Number:     big.NewInt(1),
+		Difficulty: big.NewInt(1),
+		MixDigest:  mixDigest,
+		Extra:      extra.Encode([]byte("vanity")),
+	}
+	hash := SealHash(header)
+
+	extra.Seal = make([]byte, crypto.SignatureLength)
+	extra.Commits = [][]byte{make([]byte, crypto.SignatureLength)}
+	header.Extra = extra.Encode([]byte("vanity"))
+	if have := SealHash(header); have != hash {
+		t.Errorf("seal hash changed by the seals: have %x, want %x", have, hash)
+	}
+	extra.Round = 2
+	header.Extra = extra.Encode([]byte("vanity"))
+	if have := SealHash(header); have == hash {
+		t.Errorf("seal hash not changed by the round")
+	}
+}
+
+// Tests that the block hash covers the proposer seal, but not the commit seals
+// which are added once the validators agreed on it.
+func TestBlockHash(t *testing.T) {
+	extra := &Extra{Validators: []common.Address{{0x01}, {0x02}}, Round: 1, Seal: make([]byte, crypto.SignatureLength)}
+	header := &types.Header{
+		Number:     big.NewInt(1),
+		Difficulty: big.NewInt(1),
+		MixDigest:  mixDigest,
+		Extra:      extra.Encode([]byte("vanity")),
+	}
+	hash := header.Hash()
+
+	extra.Commits = [][]byte{make([]byte, crypto.SignatureLength)}
+	header.Extra = extra.Encode([]byte("vanity"))
+	if have := header.Hash(); have != hash {
+		t.Errorf("block hash changed by the commit seals: have %x, want %x", have, hash)
+	}
+	extra.Seal[0] = 1
+	header.Extra = extra.Encode([]byte("vanity"))
+	if have := header.Hash(); have == hash {
+		t.Errorf("block hash not changed by the proposer seal")
+	}
+}
+
+// Tests that consensus messages survive the network encoding along with their
+// justifications, and that tampering invalidates the signature.
+func TestMessageEncoding(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	signFn := func(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+		return crypto.Sign(crypto.Keccak256(data), key)
+	}
+	prepare := &message{Code: msgPrepare, Height: 5, Round: 0, Digest: common.Hash{0x01}}
+	if err := prepare.sign(addr, signFn); err != nil {
+		t.Fatalf("failed to sign prepare: %v", err)
+	}
+	rc := &message{Code: msgRoundChange, Height: 5, Round: 1, Digest: common.Hash{0x01}, Justification: []*message{prepare}}
+	if err := rc.sign(addr, signFn); err != nil {
+		t.Fatalf("failed to sign round change: %v", err)
+	}
+	payload, err := rlp.EncodeToBytes(rc)
+	if err != nil {
+		t.Fatalf("failed to encode message: %v", err)
+	}
+	dec, err := decodeMessage(payload)
+	if err != nil {
+		t.Fatalf("failed to decode message: %v", err)
+	}
+	if dec.sender != addr || len(dec.Justification) != 1 || dec.Justification[0].sender != addr {
+		t.Errorf("senders not recovered: have %x", dec.sender)
+	}
+	dec.Round = 2
+	if err := dec.recover(); err == nil && dec.sender == addr {
+		t.Errorf("tampered message still attributed to signer")
+	}
+}
+
+func TestQuorum(t *testing.T) {
+	tests := []struct{ validators, quorum, faulty int }{
+		{1, 1, 0}, {2, 2, 0}, {3, 2, 0}, {4, 3, 1}, {5, 4, 1}, {6, 4, 1}, {7, 5, 2}, {10, 7, 3},
+	}
+	for _, tt := range tests {
+		if have := quorumSize(tt.validators); have != tt.quorum {
+			t.Errorf("validators %d: quorum mismatch: have %d, want %d", tt.validators, have, tt.quorum)
+		}
+		if have := faultTolerance(tt.validators); have != tt.faulty {
+			t.Errorf("validators %d: fault tolerance mismatch: have %d, want %d", tt.validators, have, tt.faulty)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"golang.org/x/crypto/sha3"
+)
+
+// Extra is the consensus specific part of a header's extra-data, stored RLP
+// encoded after the 32 byte vanity prefix. It's defined in the types package,
+// as the commit seals are left out of the block hash.
+type Extra = types.BFTExtra
+
+// errInvalidExtra is returned if the extra-data past the vanity cannot be decoded.
+var errInvalidExtra = errors.New("invalid extra-data consensus section")
+
+// decodeExtra extracts the consensus section of a header's extra-data.
+func decodeExtra(header *types.Header) (*Extra, error) {
+	if len(header.Extra) < extraVanity {
+		return nil, errMissingVanity
+	}
+	extra, err := types.DecodeBFTExtra(header)
+	if err != nil {
+		return nil, errInvalidExtra
+	}
+	return extra, nil
+}
+
+// GenesisExtra returns the extra-data of a genesis block starting the chain with
+// the given set of validators.
+func GenesisExtra(validators []common.Address) []byte {
+	sorted := make([]common.Address, len(validators))
+	copy(sorted, validators)
+	sort.Sort(validatorsAscending(sorted))
+
+	return (&Extra{Validators: sorted}).Encode(nil)
+}
+
+// SealHash returns the hash of a block prior to it being sealed.
+func SealHash(header *types.Header) (hash common.Hash) {
+	hasher := sha3.NewLegacyKeccak256()
+	encodeSigHeader(hasher, header)
+	hasher.(crypto.KeccakState).Read(hash[:])
+	return hash
+}
+
+// BFTRLP returns the rlp bytes which needs to be signed by the proposer of a
+// block. The RLP to sign consists of the entire header with the proposer seal
+// and the commit seals removed from the consensus section of the extra-data.
+func BFTRLP(header *types.Header) []byte {
+	b := new(bytes.Buffer)
+	encodeSigHeader(b, header)
+	return b.Bytes()
+}
+
+func encodeSigHeader(w io.Writer, header *types.Header) {
+	extra := header.Extra
+	if dec, err := decodeExtra(header); err == nil {
+		dec.Seal, dec.Commits = nil, nil
+		extra = dec.Encode(header.Extra[:extraVanity])
+	}
+	enc := []interface{}{
+		header.ParentHash,
+		header.UncleHash,
+		header.Coinbase,
+		header.Root,
+		header.TxHash,
+		header.ReceiptHash,
+		header.Bloom,
+		header.Difficulty,
+		header.Number,
+		header.GasLimit,
+		header.GasUsed,
+		header.Time,
+		extra,
+		header.MixDigest,
+		header.Nonce,
+	}
+	if header.BaseFee != nil {
+		enc = append(enc, header.BaseFee)
+	}
+	if err := rlp.Encode(w, enc); err != nil {
+		panic("can't encode: " + err.Error())
+	}
+}
+
+// commitData returns the data a validator signs to commit to a block.
+func commitData(hash common.Hash) []byte {
+	return append(hash.Bytes(), byte(msgCommit))
+}
+
+// commitSigner recovers the validator that produced a commit seal for a block.
+func commitSigner(hash common.Hash, seal []byte) (common.Address, error) {
+	if len(seal) != crypto.SignatureLength {
+		return common.Address{}, errInvalidCommitSeal
+	}
+	pubkey, err := crypto.SigToPub(crypto.Keccak256(commitData(hash)), seal)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubkey), nil
+}
+
+// verifyCommits checks that the commit seals were produced by a quorum of
+// distinct validators for the given block.
+func verifyCommits(hash common.Hash, seals [][]byte, validators []common.Address) error {
+	members := make(map[common.Address]bool, len(validators))
+	for _, validator := range validators {
+		members[validator] = true
+	}
+	signed := make(map[common.Address]bool)
+	for _, seal := range seals {
+		signer, err := commitSigner(hash, seal)
+		if err != nil {
+			return err
+		}
+		if !members[signer] {
+			return errUnauthorizedCommit
+		}
+		if signed[signer] {
+			return errDuplicateCommit
+		}
+		signed[signer] = true
+	}
+	if len(signed) < quorumSize(len(validators)) {
+		return errInsufficientCommits
+	}
+	return nil
+}
@@ -0,0 +1,781 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	maxFutureMessages = 1024 // Maximum number of messages buffered for upcoming heights
+	maxTimeoutShift   = 10   // Maximum number of times the round timeout is doubled
+)
+
+// Chain is the local blockchain the consensus state machine builds on. Proposals
+// are executed against it before being accepted and finalized blocks are
+// inserted into it.
+type Chain interface {
+	consensus.ChainHeaderReader
+
+	// InsertChain inserts a batch of blocks into the chain.
+	InsertChain(chain types.Blocks) (int, error)
+
+	// SubscribeChainHeadEvent registers a subscription for new chain heads.
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+
+	// Validator returns the block validator of the chain.
+	Validator() core.Validator
+
+	// Processor returns the block processor of the chain.
+	Processor() core.Processor
+
+	// StateAt returns a mutable state based on a particular point in time.
+	StateAt(root common.Hash) (*state.StateDB, error)
+
+	// GetVMConfig returns the virtual machine configuration of the chain.
+	GetVMConfig() *vm.Config
+}
+
+// roundState collects the consensus messages of a single round.
+type roundState struct {
+	preprepare   *message
+	prepares     map[common.Address]*message
+	commits      map[common.Address]*message
+	roundChanges map[common.Address]*message
+}
+
+// inbound is a consensus message received from the network.
+type inbound struct {
+	msg     *message
+	hash    common.Hash
+	payload []byte
+}
+
+// timeoutEvent fires when a round didn't finish in time.
+type timeoutEvent struct{ height, round uint64 }
+
+// proposeEvent fires when the timestamp of a delayed proposal is reached.
+type proposeEvent struct{ height, round uint64 }
+
+// machine is the consensus state machine, deciding one height at a time. All
+// its state is owned by the loop goroutine, everything else posts events.
+type machine struct {
+	engine *BFT
+	chain  Chain
+
+	height     uint64           // Height being decided
+	round      uint64           // Current round of the height
+	parent     *types.Header    // Head of the local chain the height builds on
+	validators []common.Address // Validators of the height, in ascending order
+
+	rounds     map[uint64]*roundState       // Messages of the height, by round
+	blocks     map[common.Hash]*types.Block // Verified proposals of the height
+	candidates map[common.Hash]*types.Block // Blocks prepared by others, announced in round changes
+
+	proposal   *types.Block // Proposal accepted in the current round
+	proposed   bool         // Whether the local validator proposed in the current round
+	commitSent bool         // Whether the local validator committed in the current round
+	committed  bool         // Whether the height has been finalized
+
+	preparedRound uint64       // Latest round in which a quorum prepared a proposal
+	preparedBlock *types.Block // Proposal prepared in that round
+	preparedCert  []*message   // Prepares proving it
+
+	pending *types.Block // Locally assembled block waiting for our turn to propose
+	future  []*inbound   // Messages received for upcoming heights
+	timer   *time.Timer  // Timeout of the current round
+
+	events chan interface{}
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// newMachine creates a consensus state machine on top of the chain.
+func newMachine(engine *BFT, chain Chain) *machine {
+	return &machine{
+		engine: engine,
+		chain:  chain,
+		events: make(chan interface{}, 256),
+		quit:   make(chan struct{}),
+	}
+}
+
+// start launches the event loop.
+func (m *machine) start() {
+	m.wg.Add(1)
+	go m.loop()
+}
+
+// stop terminates the event loop and waits for pending insertions.
+func (m *machine) stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
+// post delivers an event to the loop, dropping it if the machine is stopped.
+func (m *machine) post(ev interface{}) {
+	select {
+	case m.events <- ev:
+	case <-m.quit:
+	}
+}
+
+func (m *machine) loop() {
+	defer m.wg.Done()
+
+	heads := make(chan core.ChainHeadEvent, 16)
+	sub := m.chain.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	m.startHeight(m.chain.CurrentHeader())
+	for {
+		select {
+		case ev := <-heads:
+			if ev.Block.NumberU64() >= m.height {
+				m.startHeight(ev.Block.Header())
+			}
+		case ev := <-m.events:
+			switch ev := ev.(type) {
+			case *types.Block:
+				m.handlePending(ev)
+			case *inbound:
+				m.handleInbound(ev)
+			case *committedBlock:
+				m.handleCommitted(ev)
+			case *message:
+				if ev.Height == m.height {
+					if err := m.handleMessage(ev); err != nil {
+						log.Debug("Failed to handle delayed BFT message", "err", err)
+					}
+				}
+			case timeoutEvent:
+				if ev.height == m.height && ev.round == m.round && !m.committed {
+					log.Debug("BFT round timed out", "height", m.height, "round", m.round)
+					m.changeRound(m.round + 1)
+				}
+			case proposeEvent:
+				if ev.height == m.height && ev.round == m.round {
+					m.tryPropose()
+				}
+			}
+		case <-sub.Err():
+			return
+		case <-m.quit:
+			if m.timer != nil {
+				m.timer.Stop()
+			}
+			return
+		}
+	}
+}
+
+// startHeight resets the state machine to decide the block on top of head.
+func (m *machine) startHeight(head *types.Header) {
+	m.height, m.parent = head.Number.Uint64()+1, head
+
+	m.validators = nil
+	snap, err := m.engine.snapshot(m.chain, head.Number.Uint64(), head.Hash(), nil)
+	if err != nil {
+		log.Error("Failed to retrieve BFT validators", "number", head.Number, "hash", head.Hash(), "err", err)
+	} else {
+		m.validators = snap.validators()
+	}
+	m.rounds = make(map[uint64]*roundState)
+	m.blocks = make(map[common.Hash]*types.Block)
+	m.candidates = make(map[common.Hash]*types.Block)
+	m.committed = false
+	m.preparedRound, m.preparedBlock, m.preparedCert = 0, nil, nil
+
+	if m.pending != nil && m.pending.NumberU64() < m.height {
+		m.pending = nil
+	}
+	m.startRound(0)
+
+	// Replay any messages that arrived ahead of time
+	future := m.future
+	m.future = nil
+	for _, in := range future {
+		switch {
+		case in.msg.Height == m.height:
+			m.handleInbound(in)
+		case in.msg.Height > m.height:
+			m.future = append(m.future, in)
+		}
+	}
+}
+
+// startRound moves the state machine to the given round of the current height.
+func (m *machine) startRound(round uint64) {
+	m.round = round
+	m.proposal, m.proposed, m.commitSent = nil, false, false
+
+	// Schedule the round timeout, doubling it every round. The first round also
+	// waits for the block period.
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	shift := round
+	if shift > maxTimeoutShift {
+		shift = maxTimeoutShift
+	}
+	timeout := time.Duration(m.engine.config.RequestTimeout) * time.Millisecond << shift
+	if round == 0 {
+		if wait := time.Until(time.Unix(int64(m.parent.Time+m.engine.config.Period), 0)); wait > 0 {
+			timeout += wait
+		}
+	}
+	height := m.height
+	m.timer = time.AfterFunc(timeout, func() { m.post(timeoutEvent{height, round}) })
+
+	m.acceptProposal()
+	m.tryPropose()
+}
+
+// changeRound gives up on the current round, moving on to the given one and
+// asking the other validators to do the same.
+func (m *machine) changeRound(round uint64) {
+	m.startRound(round)
+
+	msg := &message{Code: msgRoundChange, Height: m.height, Round: round}
+	if m.preparedBlock != nil {
+		enc, err := rlp.EncodeToBytes(m.preparedBlock)
+		if err != nil {
+			log.Error("Failed to encode prepared block", "err", err)
+			return
+		}
+		msg.Digest, msg.PreparedRound, msg.Block = m.preparedBlock.Hash(), m.preparedRound, enc
+		msg.Justification = m.preparedCert
+	}
+	m.broadcast(msg)
+}
+
+// isValidator reports whether the address is a validator of the current height.
+func (m *machine) isValidator(address common.Address) bool {
+	for _, validator := range m.validators {
+		if validator == address {
+			return true
+		}
+	}
+	return false
+}
+
+// roundState returns the message collection of a round, creating it if needed.
+func (m *machine) roundState(round uint64) *roundState {
+	rs := m.rounds[round]
+	if rs == nil {
+		rs = &roundState{
+			prepares:     make(map[common.Address]*message),
+			commits:      make(map[common.Address]*message),
+			roundChanges: make(map[common.Address]*message),
+		}
+		m.rounds[round] = rs
+	}
+	return rs
+}
+
+// broadcast signs a message of the local validator, relays it to the network
+// and processes it locally. Nodes which aren't validators of the height stay
+// silent.
+func (m *machine) broadcast(msg *message) {
+	signer, signFn := m.engine.credentials()
+	if signFn == nil || !m.isValidator(signer) {
+		return
+	}
+	if err := msg.sign(signer, signFn); err != nil {
+		log.Error("Failed to sign BFT message", "err", err)
+		return
+	}
+	payload, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		log.Error("Failed to encode BFT message", "err", err)
+		return
+	}
+	hash := crypto.Keccak256Hash(payload)
+	m.engine.seen.Add(hash, struct{}{})
+	m.engine.gossip(consensusMsg, hash, payload)
+
+	if err := m.handleMessage(msg); err != nil {
+		log.Error("Failed to handle own BFT message", "code", msg.Code, "err", err)
+	}
+}
+
+// handleInbound processes a message received from the network, relaying it
+// further if it's a valid message of the current height.
+func (m *machine) handleInbound(in *inbound) {
+	switch {
+	case in.msg.Height < m.height:
+		return
+	case in.msg.Height > m.height:
+		if len(m.future) < maxFutureMessages {
+			m.future = append(m.future, in)
+		}
+		return
+	}
+	if !m.isValidator(in.msg.sender) {
+		log.Debug("Dropping BFT message of unauthorized validator", "sender", in.msg.sender)
+		return
+	}
+	if err := m.handleMessage(in.msg); err != nil {
+		log.Debug("Failed to handle BFT message", "code", in.msg.Code, "sender", in.msg.sender, "err", err)
+		return
+	}
+	m.engine.gossip(consensusMsg, in.hash, in.payload)
+}
+
+// handleMessage processes a validated message of the current height.
+func (m *machine) handleMessage(msg *message) error {
+	switch msg.Code {
+	case msgPreprepare:
+		return m.handlePreprepare(msg)
+	case msgPrepare:
+		return m.handlePrepare(msg)
+	case msgCommit:
+		return m.handleCommit(msg)
+	case msgRoundChange:
+		return m.handleRoundChange(msg)
+	default:
+		return errInvalidMessage
+	}
+}
+
+// handlePending stores a block assembled by the local miner, proposing it once
+// the local validator's turn comes.
+func (m *machine) handlePending(block *types.Block) {
+	if block.NumberU64() < m.height {
+		return
+	}
+	m.pending = block
+	m.tryPropose()
+}
+
+// tryPropose sends the proposal of the current round if the local validator is
+// its proposer and everything needed is at hand.
+func (m *machine) tryPropose() {
+	if m.proposed || m.committed {
+		return
+	}
+	signer, _ := m.engine.credentials()
+	if signer != proposer(m.validators, m.height, m.round) {
+		return
+	}
+	var (
+		block         *types.Block
+		justification []*message
+		pending       = m.pending != nil && m.pending.ParentHash() == m.parent.Hash()
+	)
+	if m.round == 0 {
+		if pending {
+			block = m.pending
+		}
+	} else {
+		// Later rounds need a quorum of round changes, and the block prepared in
+		// the most recent round among them must be proposed again
+		rs := m.rounds[m.round]
+		if rs == nil || len(rs.roundChanges) < quorumSize(len(m.validators)) {
+			return
+		}
+		var highest *message
+		for _, rc := range rs.roundChanges {
+			justification = append(justification, rc.stripped())
+			if rc.Digest != (common.Hash{}) && (highest == nil || rc.PreparedRound > highest.PreparedRound) {
+				highest = rc
+			}
+		}
+		switch {
+		case highest != nil:
+			if block = m.blocks[highest.Digest]; block == nil {
+				block = m.candidates[highest.Digest]
+			}
+		case pending:
+			block = m.pending
+		}
+	}
+	if block == nil {
+		return
+	}
+	// Wait for the block's timestamp, other validators would reject it until then
+	if delay := time.Until(time.Unix(int64(block.Time()), 0)); delay > 0 {
+		height, round := m.height, m.round
+		time.AfterFunc(delay, func() { m.post(proposeEvent{height, round}) })
+		return
+	}
+	// Fresh blocks still need the proposer seal of the round
+	if block == m.pending {
+		sealed, err := m.engine.sealProposal(block, m.round)
+		if err != nil {
+			log.Error("Failed to seal BFT proposal", "err", err)
+			return
+		}
+		block = sealed
+	}
+	enc, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		log.Error("Failed to encode BFT proposal", "err", err)
+		return
+	}
+	m.proposed = true
+	log.Debug("Proposing BFT block", "number", m.height, "round", m.round, "hash", block.Hash(), "txs", len(block.Transactions()))
+
+	m.broadcast(&message{
+		Code:          msgPreprepare,
+		Height:        m.height,
+		Round:         m.round,
+		Digest:        block.Hash(),
+		Block:         enc,
+		Justification: justification,
+	})
+}
+
+// handlePreprepare processes a proposal.
+func (m *machine) handlePreprepare(msg *message) error {
+	if msg.Round < m.round || m.committed {
+		return nil
+	}
+	if msg.sender != proposer(m.validators, m.height, msg.Round) {
+		return errWrongProposer
+	}
+	rs := m.roundState(msg.Round)
+	if rs.preprepare != nil {
+		return nil
+	}
+	block, err := msg.block()
+	if err != nil {
+		return err
+	}
+	if block.NumberU64() != m.height || block.ParentHash() != m.parent.Hash() {
+		return errInvalidMessage
+	}
+	if msg.Round > 0 {
+		if err := m.justifyPreprepare(msg); err != nil {
+			return err
+		}
+	}
+	if err := m.verifyBlock(block); err != nil {
+		// Proposals slightly ahead of the local clock are retried in time
+		if err == consensus.ErrFutureBlock {
+			if delay := time.Until(time.Unix(int64(block.Time()), 0)); delay < time.Duration(m.engine.config.RequestTimeout)*time.Millisecond {
+				time.AfterFunc(delay, func() { m.post(msg) })
+				return nil
+			}
+		}
+		return err
+	}
+	rs.preprepare = msg
+	m.blocks[block.Hash()] = block
+
+	// A justified proposal for a later round moves us to that round
+	if msg.Round > m.round {
+		m.startRound(msg.Round)
+	} else {
+		m.acceptProposal()
+	}
+	return nil
+}
+
+// justifyPreprepare checks that a proposal of a later round is backed by a
+// quorum of round changes, and re-proposes the most recently prepared block.
+func (m *machine) justifyPreprepare(msg *message) error {
+	var (
+		senders = make(map[common.Address]bool)
+		highest *message
+	)
+	for _, rc := range msg.Justification {
+		if rc.Code != msgRoundChange || rc.Height != m.height || rc.Round != msg.Round {
+			return errInvalidJustification
+		}
+		if !m.isValidator(rc.sender) || senders[rc.sender] {
+			return errInvalidJustification
+		}
+		senders[rc.sender] = true
+
+		if rc.Digest != (common.Hash{}) {
+			if err := m.verifyPrepared(rc); err != nil {
+				return err
+			}
+			if highest == nil || rc.PreparedRound > highest.PreparedRound {
+				highest = rc
+			}
+		}
+	}
+	if len(senders) < quorumSize(len(m.validators)) {
+		return errInvalidJustification
+	}
+	if highest != nil && highest.Digest != msg.Digest {
+		return errInvalidJustification
+	}
+	return nil
+}
+
+// verifyPrepared checks the prepared certificate carried by a round change.
+func (m *machine) verifyPrepared(rc *message) error {
+	if rc.PreparedRound >= rc.Round {
+		return errInvalidJustification
+	}
+	senders := make(map[common.Address]bool)
+	for _, prepare := range rc.Justification {
+		if prepare.Code != msgPrepare || prepare.Height != m.height || prepare.Round != rc.PreparedRound || prepare.Digest != rc.Digest {
+			return errInvalidJustification
+		}
+		if !m.isValidator(prepare.sender) {
+			return errInvalidJustification
+		}
+		senders[prepare.sender] = true
+	}
+	if len(senders) < quorumSize(len(m.validators)) {
+		return errInvalidJustification
+	}
+	return nil
+}
+
+// verifyBlock fully validates a proposal on top of the local chain.
+func (m *machine) verifyBlock(block *types.Block) error {
+	if _, ok := m.blocks[block.Hash()]; ok {
+		return nil
+	}
+	if err := m.engine.verifyProposal(m.chain, block.Header()); err != nil {
+		return err
+	}
+	if err := m.chain.Validator().ValidateBody(block); err != nil {
+		return err
+	}
+	statedb, err := m.chain.StateAt(m.parent.Root)
+	if err != nil {
+		return err
+	}
+	receipts, _, usedGas, err := m.chain.Processor().Process(block, statedb, *m.chain.GetVMConfig())
+	if err != nil {
+		return err
+	}
+	return m.chain.Validator().ValidateState(block, statedb, receipts, usedGas)
+}
+
+// acceptProposal prepares the proposal of the current round, if one arrived.
+func (m *machine) acceptProposal() {
+	rs := m.rounds[m.round]
+	if rs == nil || rs.preprepare == nil || m.proposal != nil || m.committed {
+		return
+	}
+	m.proposal = m.blocks[rs.preprepare.Digest]
+	m.broadcast(&message{Code: msgPrepare, Height: m.height, Round: m.round, Digest: m.proposal.Hash()})
+
+	m.checkPrepared()
+	m.checkCommitted(m.round)
+}
+
+// handlePrepare processes the acceptance of a proposal.
+func (m *machine) handlePrepare(msg *message) error {
+	if msg.Round < m.round {
+		return nil
+	}
+	m.roundState(msg.Round).prepares[msg.sender] = msg
+	if msg.Round == m.round {
+		m.checkPrepared()
+	}
+	return nil
+}
+
+// checkPrepared commits to the current proposal once a quorum prepared it.
+func (m *machine) checkPrepared() {
+	if m.proposal == nil || m.commitSent || m.committed {
+		return
+	}
+	digest := m.proposal.Hash()
+
+	var cert []*message
+	for _, prepare := range m.rounds[m.round].prepares {
+		if prepare.Digest == digest {
+			cert = append(cert, prepare)
+		}
+	}
+	if len(cert) < quorumSize(len(m.validators)) {
+		return
+	}
+	m.preparedRound, m.preparedBlock, m.preparedCert = m.round, m.proposal, cert
+	m.commitSent = true
+
+	signer, signFn := m.engine.credentials()
+	if signFn == nil || !m.isValidator(signer) {
+		return
+	}
+	seal, err := signFn(accounts.Account{Address: signer}, accounts.MimetypeBFT, commitData(digest))
+	if err != nil {
+		log.Error("Failed to sign BFT commit seal", "err", err)
+		return
+	}
+	m.broadcast(&message{Code: msgCommit, Height: m.height, Round: m.round, Digest: digest, CommitSeal: seal})
+}
+
+// handleCommit processes the commitment of a validator to a proposal.
+func (m *machine) handleCommit(msg *message) error {
+	signer, err := commitSigner(msg.Digest, msg.CommitSeal)
+	if err != nil {
+		return err
+	}
+	if signer != msg.sender {
+		return errInvalidCommitSeal
+	}
+	m.roundState(msg.Round).commits[msg.sender] = msg
+	m.checkCommitted(msg.Round)
+	return nil
+}
+
+// checkCommitted finalizes the height once a quorum committed to a known block
+// in the given round.
+func (m *machine) checkCommitted(round uint64) {
+	rs := m.rounds[round]
+	if rs == nil || m.committed {
+		return
+	}
+	seals := make(map[common.Hash][][]byte)
+	for _, commit := range rs.commits {
+		seals[commit.Digest] = append(seals[commit.Digest], commit.CommitSeal)
+	}
+	for digest, digestSeals := range seals {
+		if len(digestSeals) < quorumSize(len(m.validators)) {
+			continue
+		}
+		if block := m.blocks[digest]; block != nil {
+			finalized, err := withCommits(block, digestSeals)
+			if err != nil {
+				log.Error("Failed to seal committed BFT block", "err", err)
+				return
+			}
+			m.commit(finalized)
+			return
+		}
+	}
+}
+
+// withCommits returns the block with the commit seals finalizing it added to
+// its extra-data, which leaves its hash unchanged.
+func withCommits(block *types.Block, seals [][]byte) (*types.Block, error) {
+	header := block.Header()
+	extra, err := decodeExtra(header)
+	if err != nil {
+		return nil, err
+	}
+	extra.Commits = seals
+	header.Extra = extra.Encode(header.Extra[:extraVanity])
+	return block.WithSeal(header), nil
+}
+
+// commit finalizes a block carrying its commit seals, relaying it and inserting
+// it into the local chain.
+func (m *machine) commit(block *types.Block) {
+	m.committed = true
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	log.Info("Committed BFT block", "number", block.Number(), "hash", block.Hash(), "round", m.round)
+
+	if payload, err := rlp.EncodeToBytes(&committedBlock{Block: block}); err != nil {
+		log.Error("Failed to encode committed block", "err", err)
+	} else {
+		hash := crypto.Keccak256Hash(payload)
+		m.engine.seen.Add(hash, struct{}{})
+		m.engine.gossip(committedMsg, hash, payload)
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		if _, err := m.chain.InsertChain(types.Blocks{block}); err != nil {
+			log.Error("Failed to insert committed BFT block", "number", block.Number(), "hash", block.Hash(), "err", err)
+		}
+	}()
+}
+
+// handleCommitted processes a finalized block relayed by another node, which
+// lets nodes that missed the end of a height catch up.
+func (m *machine) handleCommitted(committed *committedBlock) {
+	block := committed.Block
+	if m.committed || block.NumberU64() != m.height || block.ParentHash() != m.parent.Hash() {
+		return
+	}
+	extra, err := decodeExtra(block.Header())
+	if err != nil {
+		log.Debug("Dropping committed block with invalid extra-data", "number", block.Number(), "hash", block.Hash(), "err", err)
+		return
+	}
+	if err := verifyCommits(block.Hash(), extra.Commits, m.validators); err != nil {
+		log.Debug("Dropping committed block with invalid seals", "number", block.Number(), "hash", block.Hash(), "err", err)
+		return
+	}
+	m.blocks[block.Hash()] = block
+	m.commit(block)
+}
+
+// handleRoundChange processes a validator's request to move to a later round.
+func (m *machine) handleRoundChange(msg *message) error {
+	if msg.Round == 0 {
+		return errInvalidMessage
+	}
+	if msg.Round < m.round {
+		return nil
+	}
+	if msg.Digest != (common.Hash{}) {
+		if err := m.verifyPrepared(msg); err != nil {
+			return err
+		}
+		if len(msg.Block) > 0 {
+			block, err := msg.block()
+			if err != nil {
+				return err
+			}
+			m.candidates[block.Hash()] = block
+		}
+	}
+	m.roundState(msg.Round).roundChanges[msg.sender] = msg
+
+	// If F+1 validators moved past our round, at least one of them is honest:
+	// follow to the earliest of those rounds
+	if msg.Round > m.round {
+		var (
+			senders = make(map[common.Address]bool)
+			target  = msg.Round
+		)
+		for round, rs := range m.rounds {
+			if round <= m.round || len(rs.roundChanges) == 0 {
+				continue
+			}
+			for sender := range rs.roundChanges {
+				senders[sender] = true
+			}
+			if round < target {
+				target = round
+			}
+		}
+		if len(senders) > faultTolerance(len(m.validators)) && !m.committed {
+			m.changeRound(target)
+		}
+		return nil
+	}
+	m.tryPropose()
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Consensus message codes exchanged between the validators of a height.
+const (
+	msgPreprepare  = 0x00 // Proposal of a block by the round's proposer
+	msgPrepare     = 0x01 // Acceptance of a proposal
+	msgCommit      = 0x02 // Commitment to a prepared proposal, carrying a commit seal
+	msgRoundChange = 0x03 // Request to move on to a later round
+)
+
+var (
+	// errInvalidMessage is returned if a consensus message is malformed.
+	errInvalidMessage = errors.New("invalid consensus message")
+
+	// errInvalidJustification is returned if a pre-prepare or round change
+	// doesn't carry a valid quorum certificate for its claims.
+	errInvalidJustification = errors.New("invalid justification")
+)
+
+// message is a signed consensus message of a validator.
+type message struct {
+	Code          uint64
+	Height        uint64
+	Round         uint64
+	Digest        common.Hash // Hash of the proposed block, or the prepared one for round changes
+	PreparedRound uint64      // Round in which the digest was prepared, round changes only
+	CommitSeal    []byte      // Validator signature over the digest, commits only
+	Block         []byte      // RLP encoded block, pre-prepares and prepared round changes only
+	Justification []*message  // Round changes justifying a pre-prepare, prepares justifying a round change
+	Signature     []byte      // Validator signature over the signed fields
+
+	sender common.Address // Validator recovered from the signature
+}
+
+// signedFields returns the RLP encoding of the fields covered by the signature.
+// The block and the justification are not signed: the former is bound through
+// its digest and the latter carries signatures of its own.
+func (m *message) signedFields() []byte {
+	enc, err := rlp.EncodeToBytes([]interface{}{m.Code, m.Height, m.Round, m.Digest, m.PreparedRound, m.CommitSeal})
+	if err != nil {
+		panic("can't encode: " + err.Error())
+	}
+	return enc
+}
+
+// sign signs the message with the given validator credentials.
+func (m *message) sign(signer common.Address, signFn SignerFn) error {
+	sig, err := signFn(accounts.Account{Address: signer}, accounts.MimetypeBFT, m.signedFields())
+	if err != nil {
+		return err
+	}
+	m.Signature, m.sender = sig, signer
+	return nil
+}
+
+// recover resolves the sender of the message and of all its justifications.
+func (m *message) recover() error {
+	if len(m.Signature) != crypto.SignatureLength {
+		return errInvalidMessage
+	}
+	pubkey, err := crypto.SigToPub(crypto.Keccak256(m.signedFields()), m.Signature)
+	if err != nil {
+		return err
+	}
+	m.sender = crypto.PubkeyToAddress(*pubkey)
+
+	for _, just := range m.Justification {
+		if err := just.recover(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// block decodes the block carried by the message and checks it against the digest.
+func (m *message) block() (*types.Block, error) {
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(m.Block, block); err != nil {
+		return nil, err
+	}
+	if block.Hash() != m.Digest {
+		return nil, errInvalidMessage
+	}
+	return block, nil
+}
+
+// stripped returns a copy of the message without the block payload, used to
+// embed round changes into justifications without repeating the block.
+func (m *message) stripped() *message {
+	cpy := *m
+	cpy.Block = nil
+	return &cpy
+}
+
+// decodeMessage parses a consensus message received from the network and
+// resolves its sender.
+func decodeMessage(payload []byte) (*message, error) {
+	m := new(message)
+	if err := rlp.DecodeBytes(payload, m); err != nil {
+		return nil, err
+	}
+	if err := m.recover(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// committedBlock is a finalized block, carrying the commit seals proving it,
+// relayed to validators that missed the end of a height.
+type committedBlock struct {
+	Block *types.Block
+}
@@ -0,0 +1,229 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	protocolName    = "bft"
+	protocolVersion = 1
+	protocolLength  = 2 // Number of implemented message codes
+)
+
+// Sub-protocol message codes
+const (
+	consensusMsg = 0x00 // Signed consensus message of a validator
+	committedMsg = 0x01 // Finalized block carrying its commit seals
+)
+
+const (
+	maxMessageSize   = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
+	maxKnownMessages = 16384            // Maximum message hashes to keep in the known lists (prevent DOS)
+	maxQueuedSends   = 256              // Maximum number of messages queued for sending to a peer
+)
+
+// peer is a remote node running the consensus sub-protocol.
+type peer struct {
+	id    string
+	rw    p2p.MsgReadWriter
+	known *lru.Cache // Hashes of messages known to the peer
+
+	queue chan p2p.Msg  // Messages waiting to be sent
+	term  chan struct{} // Termination channel to stop the sender
+}
+
+// markKnown records a message as known to the peer.
+func (p *peer) markKnown(hash common.Hash) {
+	p.known.Add(hash, struct{}{})
+}
+
+// send queues a message to the peer unless it's known to have it already. The
+// message is dropped if the peer can't keep up.
+func (p *peer) send(code uint64, hash common.Hash, payload []byte) {
+	if p.known.Contains(hash) {
+		return
+	}
+	p.markKnown(hash)
+
+	select {
+	case p.queue <- p2p.Msg{Code: code, Size: uint32(len(payload)), Payload: bytes.NewReader(payload)}:
+	default:
+		log.Debug("Dropping BFT message to slow peer", "peer", p.id)
+	}
+}
+
+// sendLoop writes the queued messages to the peer.
+func (p *peer) sendLoop() {
+	for {
+		select {
+		case msg := <-p.queue:
+			if err := p.rw.WriteMsg(msg); err != nil {
+				return
+			}
+		case <-p.term:
+			return
+		}
+	}
+}
+
+// peerSet is the set of peers connected on the consensus sub-protocol.
+type peerSet struct {
+	peers map[string]*peer
+	lock  sync.RWMutex
+}
+
+// newPeerSet creates a new peer set.
+func newPeerSet() *peerSet {
+	return &peerSet{peers: make(map[string]*peer)}
+}
+
+func (ps *peerSet) register(p *peer) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if _, ok := ps.peers[p.id]; ok {
+		return p2p.DiscAlreadyConnected
+	}
+	ps.peers[p.id] = p
+	return nil
+}
+
+func (ps *peerSet) unregister(id string) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	delete(ps.peers, id)
+}
+
+func (ps *peerSet) all() []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		list = append(list, p)
+	}
+	return list
+}
+
+// Protocols returns the consensus sub-protocol the validators exchange their
+// messages over.
+func (b *BFT) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    protocolName,
+		Version: protocolVersion,
+		Length:  protocolLength,
+		Run:     b.runPeer,
+	}}
+}
+
+// runPeer handles the consensus sub-protocol of a connected peer.
+func (b *BFT) runPeer(p2pPeer *p2p.Peer, rw p2p.MsgReadWriter) error {
+	known, _ := lru.New(maxKnownMessages)
+	p := &peer{
+		id:    p2pPeer.ID().String(),
+		rw:    rw,
+		known: known,
+		queue: make(chan p2p.Msg, maxQueuedSends),
+		term:  make(chan struct{}),
+	}
+	if err := b.peers.register(p); err != nil {
+		return err
+	}
+	defer b.peers.unregister(p.id)
+
+	go p.sendLoop()
+	defer close(p.term)
+
+	for {
+		if err := b.handleMsg(p); err != nil {
+			p2pPeer.Log().Debug("BFT message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMsg reads and processes the next message of a peer.
+func (b *BFT) handleMsg(p *peer) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	defer msg.Discard()
+
+	if msg.Size > maxMessageSize {
+		return fmt.Errorf("message too large: %v > %v", msg.Size, maxMessageSize)
+	}
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+	hash := crypto.Keccak256Hash(payload)
+	p.markKnown(hash)
+
+	// Drop anything relayed already, it was processed before
+	if b.seen.Contains(hash) {
+		return nil
+	}
+	b.seen.Add(hash, struct{}{})
+
+	b.runLock.Lock()
+	machine := b.machine
+	b.runLock.Unlock()
+
+	switch msg.Code {
+	case consensusMsg:
+		m, err := decodeMessage(payload)
+		if err != nil {
+			return fmt.Errorf("invalid consensus message: %v", err)
+		}
+		if machine != nil {
+			machine.post(&inbound{msg: m, hash: hash, payload: payload})
+		}
+	case committedMsg:
+		committed := new(committedBlock)
+		if err := rlp.DecodeBytes(payload, committed); err != nil {
+			return fmt.Errorf("invalid committed block: %v", err)
+		}
+		if machine != nil {
+			machine.post(committed)
+		}
+	default:
+		return fmt.Errorf("invalid message code: %v", msg.Code)
+	}
+	return nil
+}
+
+// gossip relays a message to all peers not known to have it.
+func (b *BFT) gossip(code uint64, hash common.Hash, payload []byte) {
+	for _, p := range b.peers.all() {
+		p.send(code, hash, payload)
+	}
+}
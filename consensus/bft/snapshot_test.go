@@ -0,0 +1,209 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// testerAccountPool is a pool to maintain currently active tester accounts,
+// mapped from textual names used in the tests below to actual Ethereum private
+// keys capable of signing blocks.
+type testerAccountPool struct {
+	accounts map[string]*ecdsa.PrivateKey
+}
+
+func newTesterAccountPool() *testerAccountPool {
+	return &testerAccountPool{
+		accounts: make(map[string]*ecdsa.PrivateKey),
+	}
+}
+
+// address retrieves the Ethereum address of a tester account by label, creating
+// a new account if no previous one exists yet.
+func (ap *testerAccountPool) address(account string) common.Address {
+	// Return the zero account for non-addresses
+	if account == "" {
+		return common.Address{}
+	}
+	// Ensure we have a persistent key for the account
+	if ap.accounts[account] == nil {
+		ap.accounts[account], _ = crypto.GenerateKey()
+	}
+	// Resolve and return the Ethereum address
+	return crypto.PubkeyToAddress(ap.accounts[account].PublicKey)
+}
+
+// sign calculates the proposer seal for the given block and embeds it back into
+// the header.
+func (ap *testerAccountPool) sign(header *types.Header, proposer string) {
+	ap.address(proposer)
+
+	header.Extra = (&Extra{}).Encode(nil)
+	sig, _ := crypto.Sign(SealHash(header).Bytes(), ap.accounts[proposer])
+	header.Extra = (&Extra{Seal: sig}).Encode(nil)
+}
+
+// testerVote represents a single block proposed by a particular account, where
+// the account may or may not have cast a vote.
+type testerVote struct {
+	proposer string
+	voted    string
+	auth     bool
+}
+
+// Tests that validator voting is evaluated correctly for various simple and
+// complex scenarios, as well as that a few special corner cases fail correctly.
+func TestVoting(t *testing.T) {
+	tests := []struct {
+		epoch      uint64
+		validators []string
+		votes      []testerVote
+		results    []string
+		failure    error
+	}{
+		{
+			// Single validator, no votes cast
+			validators: []string{"A"},
+			votes:      []testerVote{{proposer: "A"}},
+			results:    []string{"A"},
+		}, {
+			// Single validator, voting to add two others (only accept first, second needs 2 votes)
+			validators: []string{"A"},
+			votes: []testerVote{
+				{proposer: "A", voted: "B", auth: true},
+				{proposer: "B"},
+				{proposer: "A", voted: "C", auth: true},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// Two validators, voting to add a third needs both
+			validators: []string{"A", "B"},
+			votes: []testerVote{
+				{proposer: "A", voted: "C", auth: true},
+				{proposer: "B", voted: "C", auth: true},
+			},
+			results: []string{"A", "B", "C"},
+		}, {
+			// Three validators, two of them deciding to drop the third
+			validators: []string{"A", "B", "C"},
+			votes: []testerVote{
+				{proposer: "A", voted: "C", auth: false},
+				{proposer: "B", voted: "C", auth: false},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// Repeated votes of a single validator only count once
+			validators: []string{"A", "B"},
+			votes: []testerVote{
+				{proposer: "A", voted: "C", auth: true},
+				{proposer: "A", voted: "C", auth: true},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// Votes are reset at epoch boundaries
+			epoch:      3,
+			validators: []string{"A", "B"},
+			votes: []testerVote{
+				{proposer: "A", voted: "C", auth: true},
+				{proposer: "B"},
+				{proposer: "A"},
+				{proposer: "B", voted: "C", auth: true},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// Blocks proposed by outsiders are rejected
+			validators: []string{"A"},
+			votes:      []testerVote{{proposer: "B"}},
+			failure:    errUnauthorizedValidator,
+		},
+	}
+	for i, tt := range tests {
+		accounts := newTesterAccountPool()
+
+		validators := make([]common.Address, len(tt.validators))
+		for j, validator := range tt.validators {
+			validators[j] = accounts.address(validator)
+		}
+		headers := make([]*types.Header, len(tt.votes))
+		for j, vote := range tt.votes {
+			headers[j] = &types.Header{
+				Number:   big.NewInt(int64(j) + 1),
+				Coinbase: accounts.address(vote.voted),
+			}
+			if vote.auth {
+				copy(headers[j].Nonce[:], nonceAuthVote)
+			}
+			accounts.sign(headers[j], vote.proposer)
+		}
+		config := &params.BFTConfig{Epoch: tt.epoch}
+		if config.Epoch == 0 {
+			config.Epoch = epochLength
+		}
+		sigcache, _ := lru.NewARC(inmemorySignatures)
+		snap, err := newSnapshot(config, sigcache, 0, common.Hash{}, validators).apply(headers)
+		if err != tt.failure {
+			t.Errorf("test %d: failure mismatch: have %v, want %v", i, err, tt.failure)
+		}
+		if err != nil {
+			continue
+		}
+		want := make(map[common.Address]bool)
+		for _, validator := range tt.results {
+			want[accounts.address(validator)] = true
+		}
+		have := snap.validators()
+		if len(have) != len(want) {
+			t.Errorf("test %d: validators mismatch: have %x, want %v", i, have, tt.results)
+			continue
+		}
+		for _, validator := range have {
+			if !want[validator] {
+				t.Errorf("test %d: unexpected validator %x", i, validator)
+			}
+		}
+	}
+}
+
+// Tests that proposers rotate through the sorted validators with the height
+// and the round.
+func TestProposerRotation(t *testing.T) {
+	validators := []common.Address{{0x01}, {0x02}, {0x03}, {0x04}}
+	tests := []struct {
+		number, round uint64
+		proposer      common.Address
+	}{
+		{0, 0, common.Address{0x01}},
+		{1, 0, common.Address{0x02}},
+		{1, 1, common.Address{0x03}},
+		{3, 2, common.Address{0x02}},
+		{4, 0, common.Address{0x01}},
+	}
+	for _, tt := range tests {
+		if have := proposer(validators, tt.number, tt.round); have != tt.proposer {
+			t.Errorf("number %d, round %d: proposer mismatch: have %x, want %x", tt.number, tt.round, have, tt.proposer)
+		}
+	}
+}
@@ -93,6 +93,64 @@ func (api *API) GetSignersAtHash(hash common.Hash) ([]common.Address, error) {
 	return snap.signers(), nil
 }
 
+// SignerSet is the list of authorized signers at a block along with where the
+// list originates from.
+type SignerSet struct {
+	Signers  []common.Address `json:"signers"`
+	Source   string           `json:"source"`             // Either "votes" or "contract"
+	Contract *common.Address  `json:"contract,omitempty"` // Signer contract the list was read from
+	Block    uint64           `json:"block,omitempty"`    // Checkpoint block the list was read at
+}
+
+// newSignerSet assembles the signer set of a snapshot.
+func newSignerSet(snap *Snapshot) *SignerSet {
+	set := &SignerSet{
+		Signers: snap.signers(),
+		Source:  "votes",
+	}
+	if snap.ContractBlock != 0 {
+		set.Source = "contract"
+		set.Contract = snap.config.SignerContract
+		set.Block = snap.ContractBlock
+	}
+	return set
+}
+
+// GetSignerSet retrieves the authorized signers at the specified block along
+// with the origin of the list.
+func (api *API) GetSignerSet(number *rpc.BlockNumber) (*SignerSet, error) {
+	// Retrieve the requested block number (or current if none requested)
+	var header *types.Header
+	if number == nil || *number == rpc.LatestBlockNumber {
+		header = api.chain.CurrentHeader()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
+	}
+	// Ensure we have an actually valid block and return the signers from its snapshot
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	snap, err := api.clique.snapshot(api.chain, header.Number.Uint64(), header.Hash(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return newSignerSet(snap), nil
+}
+
+// GetSignerSetAtHash retrieves the authorized signers at the specified block
+// along with the origin of the list.
+func (api *API) GetSignerSetAtHash(hash common.Hash) (*SignerSet, error) {
+	header := api.chain.GetHeaderByHash(hash)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	snap, err := api.clique.snapshot(api.chain, header.Number.Uint64(), header.Hash(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return newSignerSet(snap), nil
+}
+
 // Proposals returns the current proposals the node tries to uphold and vote on.
 func (api *API) Proposals() map[common.Address]bool {
 	api.clique.lock.RLock()
@@ -106,7 +164,7 @@ func (api *API) Proposals() map[common.Address]bool {
 }
 
 // Propose injects a new authorization proposal that the signer will attempt to
-// push through.
+// push through. Proposals are ignored once the signer contract governs the list.
 func (api *API) Propose(address common.Address, auth bool) {
 	api.clique.lock.Lock()
 	defer api.clique.lock.Unlock()
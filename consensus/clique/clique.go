@@ -90,6 +90,10 @@ var (
 	// has a vote nonce set to non-zeroes.
 	errInvalidCheckpointVote = errors.New("vote nonce in checkpoint block non-zero")
 
+	// errSignerContractVote is returned if a block casts a vote while the signer
+	// list is governed by the signer contract.
+	errSignerContractVote = errors.New("vote cast in signer contract governed block")
+
 	// errMissingVanity is returned if a block's extra-data section is shorter than
 	// 32 bytes, which is required to store the signer vanity.
 	errMissingVanity = errors.New("extra-data 32 byte vanity prefix missing")
@@ -265,6 +269,12 @@ func (c *Clique) verifyHeader(chain consensus.ChainHeaderReader, header *types.H
 	if checkpoint && !bytes.Equal(header.Nonce[:], nonceDropVote) {
 		return errInvalidCheckpointVote
 	}
+	// Header voting is disabled once the signer contract takes over
+	if c.config.IsSignerContract(header.Number) {
+		if header.Coinbase != (common.Address{}) || !bytes.Equal(header.Nonce[:], nonceDropVote) {
+			return errSignerContractVote
+		}
+	}
 	// Check that the extra-data contains both the vanity and signature
 	if len(header.Extra) < extraVanity {
 		return errMissingVanity
@@ -280,6 +290,9 @@ func (c *Clique) verifyHeader(chain consensus.ChainHeaderReader, header *types.H
 	if checkpoint && signersBytes%common.AddressLength != 0 {
 		return errInvalidCheckpointSigners
 	}
+	if contractCheckpoint(c.config, number) && signersBytes == 0 {
+		return errInvalidCheckpointSigners
+	}
 	// Ensure that the mix digest is zero as we don't have fork protection currently
 	if header.MixDigest != (common.Hash{}) {
 		return errInvalidMixDigest
@@ -351,14 +364,22 @@ func (c *Clique) verifyCascadingFields(chain consensus.ChainHeaderReader, header
 	if err != nil {
 		return err
 	}
-	// If the block is a checkpoint block, verify the signer list
+	// If the block is a checkpoint block, verify the signer list. Lists read from
+	// the signer contract can only be checked against the block state, which does
+	// not exist yet when headers are verified, so merely ensure they are ordered
+	// here. Full block imports check them in VerifyState before the block is
+	// written, but header-only chains (light clients, headers below the snap sync
+	// pivot) take them on the word of the checkpoint's signer, the same way they
+	// trust checkpoint lists without a parent.
 	if number%c.config.Epoch == 0 {
-		signers := make([]byte, len(snap.Signers)*common.AddressLength)
-		for i, signer := range snap.signers() {
-			copy(signers[i*common.AddressLength:], signer[:])
-		}
 		extraSuffix := len(header.Extra) - extraSeal
-		if !bytes.Equal(header.Extra[extraVanity:extraSuffix], signers) {
+		if contractCheckpoint(c.config, number) {
+			for i := extraVanity + common.AddressLength; i < extraSuffix; i += common.AddressLength {
+				if bytes.Compare(header.Extra[i-common.AddressLength:i], header.Extra[i:i+common.AddressLength]) >= 0 {
+					return errInvalidCheckpointSigners
+				}
+			}
+		} else if !bytes.Equal(header.Extra[extraVanity:extraSuffix], encodeSigners(snap.signers())) {
 			return errMismatchingCheckpointSigners
 		}
 	}
@@ -396,11 +417,10 @@ func (c *Clique) snapshot(chain consensus.ChainHeaderReader, number uint64, hash
 			if checkpoint != nil {
 				hash := checkpoint.Hash()
 
-				signers := make([]common.Address, (len(checkpoint.Extra)-extraVanity-extraSeal)/common.AddressLength)
-				for i := 0; i < len(signers); i++ {
-					copy(signers[i][:], checkpoint.Extra[extraVanity+i*common.AddressLength:])
+				snap = newSnapshot(c.config, c.signatures, number, hash, decodeSigners(checkpoint.Extra))
+				if contractCheckpoint(c.config, number) {
+					snap.ContractBlock = number
 				}
-				snap = newSnapshot(c.config, c.signatures, number, hash, signers)
 				if err := snap.store(c.db); err != nil {
 					return nil, err
 				}
@@ -508,7 +528,7 @@ func (c *Clique) Prepare(chain consensus.ChainHeaderReader, header *types.Header
 	if err != nil {
 		return err
 	}
-	if number%c.config.Epoch != 0 {
+	if number%c.config.Epoch != 0 && !c.config.IsSignerContract(header.Number) {
 		c.lock.RLock()
 
 		// Gather all the proposals that make sense voting on
@@ -539,9 +559,8 @@ func (c *Clique) Prepare(chain consensus.ChainHeaderReader, header *types.Header
 	header.Extra = header.Extra[:extraVanity]
 
 	if number%c.config.Epoch == 0 {
-		for _, signer := range snap.signers() {
-			header.Extra = append(header.Extra, signer[:]...)
-		}
+		// Contract checkpoints get their final list once the state is known
+		header.Extra = append(header.Extra, encodeSigners(snap.signers())...)
 	}
 	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
 
@@ -574,6 +593,16 @@ func (c *Clique) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *
 	// Finalize block
 	c.Finalize(chain, header, state, txs, uncles)
 
+	// Replace the signer list of contract checkpoints with the one read from the
+	// final state, keeping the current list prepared earlier if that fails
+	if contractCheckpoint(c.config, header.Number.Uint64()) {
+		if signers, ok := checkpointSigners(chain, c.config, header, state); ok {
+			extra := make([]byte, extraVanity, extraVanity+len(signers)*common.AddressLength+extraSeal)
+			copy(extra, header.Extra)
+			extra = append(extra, encodeSigners(signers)...)
+			header.Extra = append(extra, make([]byte, extraSeal)...)
+		}
+	}
 	// Assemble and return the final block for sealing
 	return types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil)), nil
 }
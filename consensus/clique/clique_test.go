@@ -17,7 +17,10 @@
 package clique
 
 import (
+	"bytes"
+	"crypto/ecdsa"
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -125,3 +128,113 @@ func TestSealHash(t *testing.T) {
 		t.Errorf("have %x, want %x", have, want)
 	}
 }
+
+// Tests that once the signer contract takes over, the signer list is read from
+// the contract at checkpoints instead of being voted on, and that checkpoints
+// not matching the contract are rejected.
+func TestSignerContract(t *testing.T) {
+	var (
+		db       = rawdb.NewMemoryDatabase()
+		key1, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _  = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		addr1    = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2    = crypto.PubkeyToAddress(key2.PublicKey)
+		contract = common.HexToAddress("0x00000000000000000000000000000000000c1e9e")
+		config   = *params.AllCliqueProtocolChanges
+	)
+	config.Clique = &params.CliqueConfig{
+		Period:              0,
+		Epoch:               4,
+		SignerContract:      &contract,
+		SignerContractBlock: big.NewInt(1),
+	}
+	engine := New(config.Clique, db)
+	engine.fakeDiff = true
+
+	// The contract returns the addresses stored in slots 1..n, n being in slot 0
+	genspec := &core.Genesis{
+		Config:    &config,
+		ExtraData: make([]byte, extraVanity+common.AddressLength+extraSeal),
+		Alloc: map[common.Address]core.GenesisAccount{
+			contract: {
+				Balance: new(big.Int),
+				Code:    common.FromHex("0x60206000526000548060205260005b8181101560295780600101548160200260400152600101600e565b50602002604001" + "6000f3"),
+				Storage: map[common.Hash]common.Hash{
+					common.BigToHash(big.NewInt(0)): common.BigToHash(big.NewInt(2)),
+					common.BigToHash(big.NewInt(1)): common.BytesToHash(addr2[:]),
+					common.BigToHash(big.NewInt(2)): common.BytesToHash(addr1[:]),
+				},
+			},
+		},
+		BaseFee: big.NewInt(params.InitialBaseFee),
+	}
+	copy(genspec.ExtraData[extraVanity:], addr1[:])
+	genesis := genspec.MustCommit(db)
+
+	// Generate a chain up to the first contract checkpoint and one block beyond,
+	// signed by the signer only authorized through the contract
+	blocks, _ := core.GenerateChain(&config, genesis, engine, db, 5, func(i int, block *core.BlockGen) {
+		block.SetDifficulty(diffInTurn)
+	})
+	seal := func(block *types.Block, parent common.Hash, key *ecdsa.PrivateKey) *types.Block {
+		header := block.Header()
+		header.ParentHash = parent
+		header.Difficulty = diffInTurn
+		if len(header.Extra) < extraVanity+extraSeal {
+			header.Extra = make([]byte, extraVanity+extraSeal)
+		}
+		sig, _ := crypto.Sign(SealHash(header).Bytes(), key)
+		copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+		return block.WithSeal(header)
+	}
+	for i, block := range blocks {
+		parent, key := genesis.Hash(), key1
+		if i > 0 {
+			parent = blocks[i-1].Hash()
+		}
+		if i == 4 {
+			key = key2
+		}
+		blocks[i] = seal(block, parent, key)
+	}
+	signers := []common.Address{addr1, addr2}
+	if bytes.Compare(addr2[:], addr1[:]) < 0 {
+		signers = []common.Address{addr2, addr1}
+	}
+	if have, want := blocks[3].Extra()[extraVanity:len(blocks[3].Extra())-extraSeal], encodeSigners(signers); !bytes.Equal(have, want) {
+		t.Fatalf("checkpoint signers mismatch: have %x, want %x", have, want)
+	}
+	// Import the chain and check the reported signer set
+	chain, _ := core.NewBlockChain(db, nil, &config, engine, vm.Config{}, nil, nil)
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert blocks: %v", err)
+	}
+	api := &API{chain: chain, clique: engine}
+	set, err := api.GetSignerSet(nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve signers: %v", err)
+	}
+	if !reflect.DeepEqual(set.Signers, signers) || set.Source != "contract" || *set.Contract != contract || set.Block != 4 {
+		t.Errorf("signer set mismatch: have %+v, want signers %x from contract at block 4", set, signers)
+	}
+	if set, _ := api.GetSignerSetAtHash(blocks[2].Hash()); set.Source != "votes" || len(set.Signers) != 1 {
+		t.Errorf("pre-checkpoint signer set mismatch: have %+v", set)
+	}
+	// Checkpoints not carrying the contract's list must be rejected
+	header := blocks[3].Header()
+	header.Extra = make([]byte, extraVanity+common.AddressLength+extraSeal)
+	copy(header.Extra[extraVanity:], addr1[:])
+	forged := seal(blocks[3].WithSeal(header), blocks[2].Hash(), key1)
+
+	if _, err := chain.InsertChain(types.Blocks{forged}); err != errMismatchingContractSigners {
+		t.Errorf("forged checkpoint error mismatch: have %v, want %v", err, errMismatchingContractSigners)
+	}
+	// Votes must be rejected after the fork
+	header = blocks[4].Header()
+	header.Coinbase = common.Address{0x01}
+	if err := engine.VerifyHeader(chain, header, false); err != errSignerContractVote {
+		t.Errorf("vote error mismatch: have %v, want %v", err, errSignerContractVote)
+	}
+}
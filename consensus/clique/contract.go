@@ -0,0 +1,162 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// signerContractGas is the gas allowance of the getSigners call.
+const signerContractGas = 10000000
+
+// signerContractABI is the interface the signer contract needs to implement.
+const signerContractABI = `[{"inputs":[],"name":"getSigners","outputs":[{"internalType":"address[]","name":"","type":"address[]"}],"stateMutability":"view","type":"function"}]`
+
+var (
+	signerContract abi.ABI
+
+	// errMismatchingContractSigners is returned if a checkpoint block contains a
+	// list of signers different than the one returned by the signer contract.
+	errMismatchingContractSigners = errors.New("mismatching signer contract list on checkpoint block")
+)
+
+func init() {
+	var err error
+	if signerContract, err = abi.JSON(strings.NewReader(signerContractABI)); err != nil {
+		panic(err)
+	}
+}
+
+// contractCheckpoint returns whether the signer list of the given block is read
+// from the signer contract.
+func contractCheckpoint(config *params.CliqueConfig, number uint64) bool {
+	return number > 0 && number%config.Epoch == 0 && config.IsSignerContract(new(big.Int).SetUint64(number))
+}
+
+// contractSigners calls the signer contract on top of the given state, which is
+// the state after executing the block of header. The returned list is sorted in
+// ascending order and free of duplicates.
+func contractSigners(chain consensus.ChainHeaderReader, config *params.CliqueConfig, header *types.Header, statedb *state.StateDB) ([]common.Address, error) {
+	input, err := signerContract.Pack("getSigners")
+	if err != nil {
+		return nil, err
+	}
+	context := vm.BlockContext{
+		CanTransfer: vm.CanTransfer,
+		Transfer:    vm.Transfer,
+		GetHash:     vm.GetHashFn(header, chain.GetHeader),
+		Coinbase:    header.Coinbase,
+		GasLimit:    header.GasLimit,
+		BlockNumber: new(big.Int).Set(header.Number),
+		Time:        new(big.Int).SetUint64(header.Time),
+		Difficulty:  new(big.Int).Set(header.Difficulty),
+	}
+	if header.BaseFee != nil {
+		context.BaseFee = new(big.Int).Set(header.BaseFee)
+	}
+	// Execute on a copy, the call must not leave any trace in the block state
+	evm := vm.NewEVM(context, vm.TxContext{}, statedb.Copy(), chain.Config(), vm.Config{})
+	output, _, err := evm.StaticCall(vm.AccountRef(common.Address{}), *config.SignerContract, input, signerContractGas)
+	if err != nil {
+		return nil, fmt.Errorf("signer contract call failed: %v", err)
+	}
+	var signers []common.Address
+	if err := signerContract.UnpackIntoInterface(&signers, "getSigners", output); err != nil {
+		return nil, fmt.Errorf("invalid signer contract output: %v", err)
+	}
+	sort.Sort(signersAscending(signers))
+
+	unique := signers[:0]
+	for i, signer := range signers {
+		if i == 0 || signer != signers[i-1] {
+			unique = append(unique, signer)
+		}
+	}
+	return unique, nil
+}
+
+// checkpointSigners returns the signer list read from the signer contract for a
+// contract checkpoint header. If the contract can't be called or returns no
+// signers, false is returned and the current signer list carries over, so that
+// a broken contract can't halt the chain.
+func checkpointSigners(chain consensus.ChainHeaderReader, config *params.CliqueConfig, header *types.Header, statedb *state.StateDB) ([]common.Address, bool) {
+	signers, err := contractSigners(chain, config, header, statedb)
+	if err != nil {
+		log.Warn("Failed to read signers from contract", "number", header.Number, "contract", config.SignerContract, "err", err)
+		return nil, false
+	}
+	if len(signers) == 0 {
+		log.Warn("Signer contract returned no signers", "number", header.Number, "contract", config.SignerContract)
+		return nil, false
+	}
+	return signers, true
+}
+
+// VerifyState implements consensus.StateVerifier, checking that the signer list
+// of a contract checkpoint matches the one returned by the signer contract.
+func (c *Clique) VerifyState(chain consensus.ChainHeaderReader, header *types.Header, statedb *state.StateDB) error {
+	number := header.Number.Uint64()
+	if !contractCheckpoint(c.config, number) {
+		return nil
+	}
+	signers, ok := checkpointSigners(chain, c.config, header, statedb)
+	if !ok {
+		snap, err := c.snapshot(chain, number-1, header.ParentHash, nil)
+		if err != nil {
+			return err
+		}
+		signers = snap.signers()
+	}
+	if !bytes.Equal(header.Extra[extraVanity:len(header.Extra)-extraSeal], encodeSigners(signers)) {
+		return errMismatchingContractSigners
+	}
+	return nil
+}
+
+// encodeSigners concatenates the addresses of a signer list as stored in the
+// extra-data of checkpoint headers.
+func encodeSigners(signers []common.Address) []byte {
+	blob := make([]byte, len(signers)*common.AddressLength)
+	for i, signer := range signers {
+		copy(blob[i*common.AddressLength:], signer[:])
+	}
+	return blob
+}
+
+// decodeSigners extracts the signer list from the extra-data of a checkpoint
+// header.
+func decodeSigners(extra []byte) []common.Address {
+	signers := make([]common.Address, (len(extra)-extraVanity-extraSeal)/common.AddressLength)
+	for i := 0; i < len(signers); i++ {
+		copy(signers[i][:], extra[extraVanity+i*common.AddressLength:])
+	}
+	return signers
+}
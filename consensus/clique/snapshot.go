@@ -57,6 +57,8 @@ type Snapshot struct {
 	Recents map[uint64]common.Address   `json:"recents"` // Set of recent signers for spam protections
 	Votes   []*Vote                     `json:"votes"`   // List of votes cast in chronological order
 	Tally   map[common.Address]Tally    `json:"tally"`   // Current vote tally to avoid recalculating
+
+	ContractBlock uint64 `json:"contractBlock,omitempty"` // Checkpoint the signers were read from the signer contract at (0 = voted)
 }
 
 // signersAscending implements the sort interface to allow sorting a list of addresses
@@ -121,6 +123,8 @@ func (s *Snapshot) copy() *Snapshot {
 		Recents:  make(map[uint64]common.Address),
 		Votes:    make([]*Vote, len(s.Votes)),
 		Tally:    make(map[common.Address]Tally),
+
+		ContractBlock: s.ContractBlock,
 	}
 	for signer := range s.Signers {
 		cpy.Signers[signer] = struct{}{}
@@ -229,6 +233,29 @@ func (s *Snapshot) apply(headers []*types.Header) (*Snapshot, error) {
 		}
 		snap.Recents[number] = signer
 
+		// Once the signer contract takes over, headers carry no votes and the signer
+		// list is replaced wholesale at checkpoints
+		if s.config.IsSignerContract(header.Number) {
+			if len(snap.Votes) > 0 || len(snap.Tally) > 0 {
+				snap.Votes = nil
+				snap.Tally = make(map[common.Address]Tally)
+			}
+			if contractCheckpoint(s.config, number) {
+				snap.Signers = make(map[common.Address]struct{})
+				for _, signer := range decodeSigners(header.Extra) {
+					snap.Signers[signer] = struct{}{}
+				}
+				snap.ContractBlock = number
+
+				// Signer list may have shrunk, delete any leftover recent caches
+				limit := uint64(len(snap.Signers)/2 + 1)
+				for block := range snap.Recents {
+					if block+limit <= number {
+						delete(snap.Recents, block)
+					}
+				}
+			}
+		}
 		// Header authorized, discard any previous votes from the signer
 		for i, vote := range snap.Votes {
 			if vote.Signer == signer && vote.Address == header.Coinbase {
@@ -128,3 +128,11 @@ type PoW interface {
 	// Hashrate returns the current mining hashrate of a PoW consensus engine.
 	Hashrate() float64
 }
+
+// StateVerifier is implemented by consensus engines whose header fields depend
+// on the state produced by executing the block.
+type StateVerifier interface {
+	// VerifyState checks the consensus fields of a header against the state
+	// resulting from the execution of its block.
+	VerifyState(chain ChainHeaderReader, header *types.Header, state *state.StateDB) error
+}
@@ -17,6 +17,7 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -41,6 +42,11 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/trie"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // So we can deterministically seed different blockchains
@@ -3704,3 +3710,96 @@ func TestEIP1559Transition(t *testing.T) {
 		t.Fatalf("sender balance incorrect: expected %d, got %d", expected, actual)
 	}
 }
+
+// Tests that block imports are traced down to the state and trie commits.
+func TestInsertChainTracing(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   GenesisAlloc{address: {Balance: big.NewInt(1000000000000000000)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		gendb   = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 2, func(i int, block *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x01}, big.NewInt(1000), params.TxGas, block.header.BaseFee, nil), signer, key)
+		block.AddTx(tx)
+	})
+	// Import the chain into an archive node, flushing every trie to disk
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.TrieDirtyDisabled = true
+	chain, err := NewBlockChain(db, &cacheConfig, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	ctx, root := otel.Tracer("test").Start(context.Background(), "import")
+	if _, err := chain.InsertChainContext(ctx, blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	root.End()
+
+	// Index the recorded spans and check their hierarchy
+	var (
+		spans    = recorder.Ended()
+		byID     = make(map[trace.SpanID]sdktrace.ReadOnlySpan)
+		byName   = make(map[string][]sdktrace.ReadOnlySpan)
+		parentOf = func(span sdktrace.ReadOnlySpan) string {
+			if parent, ok := byID[span.Parent().SpanID()]; ok {
+				return parent.Name()
+			}
+			return ""
+		}
+	)
+	for _, span := range spans {
+		byID[span.SpanContext().SpanID()] = span
+		byName[span.Name()] = append(byName[span.Name()], span)
+	}
+	for name, want := range map[string]struct {
+		count  int
+		parent string
+	}{
+		"core.insertChain": {1, "import"},
+		"core.insertBlock": {2, "core.insertChain"},
+		"state.Commit":     {2, "core.insertBlock"},
+		"trie.Commit":      {2, "core.insertBlock"},
+	} {
+		if have := len(byName[name]); have != want.count {
+			t.Fatalf("span %q count mismatch: have %d, want %d", name, have, want.count)
+		}
+		for _, span := range byName[name] {
+			if parent := parentOf(span); parent != want.parent {
+				t.Errorf("span %q parent mismatch: have %q, want %q", name, parent, want.parent)
+			}
+		}
+	}
+	for i, span := range byName["core.insertBlock"] {
+		attrs := make(map[attribute.Key]attribute.Value)
+		for _, attr := range span.Attributes() {
+			attrs[attr.Key] = attr.Value
+		}
+		if have := attrs["block.number"].AsInt64(); have != int64(i+1) {
+			t.Errorf("block %d: number mismatch: have %d", i, have)
+		}
+		if have, want := attrs["block.gas.used"].AsInt64(), int64(blocks[i].GasUsed()); have != want {
+			t.Errorf("block %d: gas used mismatch: have %d, want %d", i, have, want)
+		}
+		for _, key := range []attribute.Key{"block.gas.limit", "trie.clean.hits", "trie.clean.misses", "snapshot.clean.hits", "snapshot.clean.misses"} {
+			if _, ok := attrs[key]; !ok {
+				t.Errorf("block %d: missing attribute %q", i, key)
+			}
+		}
+	}
+}
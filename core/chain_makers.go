@@ -218,6 +218,16 @@ func (b *BlockGen) OffsetTime(seconds int64) {
 // values. Inserting them into BlockChain requires use of FakePow or
 // a similar non-validating proof of work implementation.
 func GenerateChain(config *params.ChainConfig, parent *types.Block, engine consensus.Engine, db ethdb.Database, n int, gen func(int, *BlockGen)) ([]*types.Block, []types.Receipts) {
+	return generateChain(config, parent, engine, func() state.Database { return state.NewDatabase(db) }, n, gen)
+}
+
+// GenerateChainWithStateDatabase is like GenerateChain, but reads and writes the
+// state of the blocks through the given state database.
+func GenerateChainWithStateDatabase(config *params.ChainConfig, parent *types.Block, engine consensus.Engine, statedb state.Database, n int, gen func(int, *BlockGen)) ([]*types.Block, []types.Receipts) {
+	return generateChain(config, parent, engine, func() state.Database { return statedb }, n, gen)
+}
+
+func generateChain(config *params.ChainConfig, parent *types.Block, engine consensus.Engine, database func() state.Database, n int, gen func(int, *BlockGen)) ([]*types.Block, []types.Receipts) {
 	if config == nil {
 		config = params.TestChainConfig
 	}
@@ -272,7 +282,7 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 		return nil, nil
 	}
 	for i := 0; i < n; i++ {
-		statedb, err := state.New(parent.Root(), state.NewDatabase(db), nil)
+		statedb, err := state.New(parent.Root(), database(), nil)
 		if err != nil {
 			panic(err)
 		}
@@ -178,9 +178,11 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 		return genesis.Config, block.Hash(), nil
 	}
 	// We have the genesis block in database(perhaps in ancient database)
-	// but the corresponding state is missing.
+	// but the corresponding state is missing. The path-based scheme only
+	// persists the latest state, so it's expected to be gone there.
 	header := rawdb.ReadHeader(db, stored, 0)
-	if _, err := state.New(header.Root, state.NewDatabaseWithConfig(db, nil), nil); err != nil {
+	statedb := state.NewDatabaseWithConfig(db, nil)
+	if _, err := state.New(header.Root, statedb, nil); err != nil && statedb.TrieDB().Scheme() == rawdb.HashScheme {
 		if genesis == nil {
 			genesis = DefaultGenesisBlock()
 		}
@@ -768,6 +768,28 @@ func writeAncientBlock(op albadb.AncientWriteOp, block *types.Block, header *typ
 	return nil
 }
 
+// TruncateAncientHistory deletes the block bodies and receipts below the given
+// number from the ancient store, retaining the headers, hashes and total
+// difficulties. As the freezer deletes data in whole files, some history below
+// the threshold may be retained. The returned number is the first block for
+// which both bodies and receipts are still available.
+func TruncateAncientHistory(db albadb.AncientStore, number uint64) (uint64, error) {
+	var tail uint64
+	for _, kind := range []string{freezerBodiesTable, freezerReceiptTable} {
+		if err := db.TruncateAncientTail(kind, number); err != nil {
+			return 0, err
+		}
+		kindTail, err := db.AncientTail(kind)
+		if err != nil {
+			return 0, err
+		}
+		if kindTail > tail {
+			tail = kindTail
+		}
+	}
+	return tail, nil
+}
+
 // DeleteBlock removes all block data associated with a hash.
 func DeleteBlock(db albadb.KeyValueWriter, hash common.Hash, number uint64) {
 	DeleteReceipts(db, hash, number)
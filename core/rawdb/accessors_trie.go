@@ -0,0 +1,197 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pictor01/ALBA/albadb"
+	"github.com/pictor01/ALBA/common"
+	"github.com/pictor01/ALBA/log"
+)
+
+const (
+	// HashScheme is the legacy state scheme, in which trie nodes are stored
+	// in the database keyed by their hash. Nodes are never overwritten, and
+	// stale state can only be removed by the offline pruner.
+	HashScheme = "hash"
+
+	// PathScheme is the state scheme in which trie nodes are stored in the
+	// database keyed by their owner and path. Nodes are overwritten in place,
+	// so only the latest state is persisted, and older states are reachable
+	// for a limited depth via reverse diffs.
+	PathScheme = "path"
+)
+
+// ReadAccountTrieNode retrieves the account trie node at the given path.
+func ReadAccountTrieNode(db albadb.KeyValueReader, path []byte) []byte {
+	data, _ := db.Get(accountTrieNodeKey(path))
+	return data
+}
+
+// WriteAccountTrieNode writes the provided account trie node into the database.
+func WriteAccountTrieNode(db albadb.KeyValueWriter, path []byte, node []byte) {
+	if err := db.Put(accountTrieNodeKey(path), node); err != nil {
+		log.Crit("Failed to store account trie node", "err", err)
+	}
+}
+
+// DeleteAccountTrieNode deletes the account trie node at the given path.
+func DeleteAccountTrieNode(db albadb.KeyValueWriter, path []byte) {
+	if err := db.Delete(accountTrieNodeKey(path)); err != nil {
+		log.Crit("Failed to delete account trie node", "err", err)
+	}
+}
+
+// ReadStorageTrieNode retrieves the storage trie node of the given account at
+// the given path.
+func ReadStorageTrieNode(db albadb.KeyValueReader, accountHash common.Hash, path []byte) []byte {
+	data, _ := db.Get(storageTrieNodeKey(accountHash, path))
+	return data
+}
+
+// WriteStorageTrieNode writes the provided storage trie node into the database.
+func WriteStorageTrieNode(db albadb.KeyValueWriter, accountHash common.Hash, path []byte, node []byte) {
+	if err := db.Put(storageTrieNodeKey(accountHash, path), node); err != nil {
+		log.Crit("Failed to store storage trie node", "err", err)
+	}
+}
+
+// ReadStorageTrieNodes retrieves all the storage trie nodes of the given
+// account, keyed by their path.
+func ReadStorageTrieNodes(db albadb.Iteratee, accountHash common.Hash) map[string][]byte {
+	prefix := storageTrieNodeKey(accountHash, nil)
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	nodes := make(map[string][]byte)
+	for it.Next() {
+		path := it.Key()[len(prefix):]
+		nodes[string(path)] = common.CopyBytes(it.Value())
+	}
+	return nodes
+}
+
+// DeleteStorageTrieNode deletes the storage trie node of the given account at
+// the given path.
+func DeleteStorageTrieNode(db albadb.KeyValueWriter, accountHash common.Hash, path []byte) {
+	if err := db.Delete(storageTrieNodeKey(accountHash, path)); err != nil {
+		log.Crit("Failed to delete storage trie node", "err", err)
+	}
+}
+
+// ReadStateID retrieves the id of the persisted state with the given root.
+func ReadStateID(db albadb.KeyValueReader, root common.Hash) *uint64 {
+	data, _ := db.Get(stateIDKey(root))
+	if len(data) != 8 {
+		return nil
+	}
+	id := binary.BigEndian.Uint64(data)
+	return &id
+}
+
+// WriteStateID stores the root->id mapping of a persisted state.
+func WriteStateID(db albadb.KeyValueWriter, root common.Hash, id uint64) {
+	if err := db.Put(stateIDKey(root), encodeBlockNumber(id)); err != nil {
+		log.Crit("Failed to store state id", "err", err)
+	}
+}
+
+// DeleteStateID deletes the root->id mapping of a persisted state.
+func DeleteStateID(db albadb.KeyValueWriter, root common.Hash) {
+	if err := db.Delete(stateIDKey(root)); err != nil {
+		log.Crit("Failed to delete state id", "err", err)
+	}
+}
+
+// ReadPersistentStateID retrieves the id of the latest persisted state.
+func ReadPersistentStateID(db albadb.KeyValueReader) uint64 {
+	data, _ := db.Get(persistentStateIDKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WritePersistentStateID stores the id of the latest persisted state.
+func WritePersistentStateID(db albadb.KeyValueWriter, id uint64) {
+	if err := db.Put(persistentStateIDKey, encodeBlockNumber(id)); err != nil {
+		log.Crit("Failed to store the persistent state id", "err", err)
+	}
+}
+
+// ReadReverseDiff retrieves the RLP encoded reverse diff which reverts the
+// persisted state with the given id to its parent.
+func ReadReverseDiff(db albadb.KeyValueReader, id uint64) []byte {
+	data, _ := db.Get(reverseDiffKey(id))
+	return data
+}
+
+// WriteReverseDiff stores the RLP encoded reverse diff of the given state id.
+func WriteReverseDiff(db albadb.KeyValueWriter, id uint64, blob []byte) {
+	if err := db.Put(reverseDiffKey(id), blob); err != nil {
+		log.Crit("Failed to store reverse diff", "err", err)
+	}
+}
+
+// DeleteReverseDiff deletes the reverse diff of the given state id.
+func DeleteReverseDiff(db albadb.KeyValueWriter, id uint64) {
+	if err := db.Delete(reverseDiffKey(id)); err != nil {
+		log.Crit("Failed to delete reverse diff", "err", err)
+	}
+}
+
+// ReadStateScheme retrieves the state scheme the database was initialised
+// with, or an empty string if it was never recorded.
+func ReadStateScheme(db albadb.KeyValueReader) string {
+	data, _ := db.Get(stateSchemeKey)
+	return string(data)
+}
+
+// WriteStateScheme stores the state scheme the database is initialised with.
+func WriteStateScheme(db albadb.KeyValueWriter, scheme string) {
+	if err := db.Put(stateSchemeKey, []byte(scheme)); err != nil {
+		log.Crit("Failed to store the state scheme", "err", err)
+	}
+}
+
+// ParseStateScheme checks the provided state scheme against the one the
+// database was initialised with and returns the scheme to use. An empty scheme
+// means the stored one, or the hash scheme for a fresh database. The choice is
+// recorded in fresh databases, since the two schemes can't be mixed.
+func ParseStateScheme(provided string, db albadb.Database) (string, error) {
+	if provided != "" && provided != HashScheme && provided != PathScheme {
+		return "", fmt.Errorf("unknown state scheme %q", provided)
+	}
+	stored := ReadStateScheme(db)
+	if stored == "" && ReadCanonicalHash(db, 0) != (common.Hash{}) {
+		// Databases initialised before the scheme was recorded are hash based
+		stored = HashScheme
+	}
+	if stored == "" {
+		if provided == "" {
+			provided = HashScheme
+		}
+		WriteStateScheme(db, provided)
+		return provided, nil
+	}
+	if provided != "" && provided != stored {
+		return "", fmt.Errorf("incompatible state scheme, stored: %s, provided: %s", stored, provided)
+	}
+	return stored, nil
+}
@@ -15,3 +15,66 @@
 // along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
 
 package rawdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tests that the database engine of an existing datadir is detected and that
+// opening it with a different engine is rejected.
+func TestOpenDatabaseEngine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rawdb-engine-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if typ := PreexistingDatabase(dir); typ != "" {
+		t.Fatalf("empty directory reported as %q database", typ)
+	}
+	// Create a fresh database with the default engine
+	db, err := Open(OpenOptions{Directory: dir, Cache: 16, Handles: 16})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	db.Close()
+
+	if typ := PreexistingDatabase(dir); typ != dbLeveldb {
+		t.Fatalf("database type mismatch: have %q, want %q", typ, dbLeveldb)
+	}
+	if engine, err := ioutil.ReadFile(filepath.Join(dir, dbEngineFile)); err != nil || string(engine) != dbLeveldb+"\n" {
+		t.Fatalf("database engine not recorded: %q, %v", engine, err)
+	}
+	// Reopening with the same (explicit or implicit) engine should work
+	for _, typ := range []string{"", dbLeveldb} {
+		db, err := Open(OpenOptions{Type: typ, Directory: dir, Cache: 16, Handles: 16})
+		if err != nil {
+			t.Fatalf("failed to reopen database with engine %q: %v", typ, err)
+		}
+		db.Close()
+	}
+	// Reopening with a different engine should fail
+	if _, err := Open(OpenOptions{Type: dbPebble, Directory: dir, Cache: 16, Handles: 16}); err == nil {
+		t.Fatal("opened leveldb database with the pebble engine")
+	}
+	// The recorded engine is authoritative, databases without one are leveldb
+	if err := ioutil.WriteFile(filepath.Join(dir, dbEngineFile), []byte(dbPebble), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(OpenOptions{Type: dbLeveldb, Directory: dir, Cache: 16, Handles: 16}); err == nil {
+		t.Fatal("opened pebble database with the leveldb engine")
+	}
+	if err := os.Remove(filepath.Join(dir, dbEngineFile)); err != nil {
+		t.Fatal(err)
+	}
+	if typ := PreexistingDatabase(dir); typ != dbLeveldb {
+		t.Fatalf("legacy database type mismatch: have %q, want %q", typ, dbLeveldb)
+	}
+	// Unknown engines should be rejected
+	if _, err := Open(OpenOptions{Type: "rocksdb", Directory: dir + "-unknown", Cache: 16, Handles: 16}); err == nil {
+		t.Fatal("opened database with an unknown engine")
+	}
+}
@@ -232,6 +232,15 @@ func (f *freezer) AncientSize(kind string) (uint64, error) {
 	return 0, errUnknownTable
 }
 
+// AncientTail returns the number of the first item retained in the specified
+// category, i.e. the number of items deleted from its tail.
+func (f *freezer) AncientTail(kind string) (uint64, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.tail(), nil
+	}
+	return 0, errUnknownTable
+}
+
 // ReadAncients runs the given read operation while ensuring that no writes take place
 // on the underlying freezer.
 func (f *freezer) ReadAncients(fn func(ethdb.AncientReader) error) (err error) {
@@ -285,6 +294,13 @@ func (f *freezer) TruncateAncients(items uint64) error {
 	if atomic.LoadUint64(&f.frozen) <= items {
 		return nil
 	}
+	// Reject the truncation before touching any table if it would cut into the
+	// pruned tail of one, otherwise the tables would end up with different heads
+	for _, table := range f.tables {
+		if items < table.tail() {
+			return errTruncationBelowTail
+		}
+	}
 	for _, table := range f.tables {
 		if err := table.truncate(items); err != nil {
 			return err
@@ -294,6 +310,26 @@ func (f *freezer) TruncateAncients(items uint64) error {
 	return nil
 }
 
+// TruncateAncientTail discards any data of the specified category below the
+// provided threshold number. Data is deleted in whole data files, so the
+// effective tail may be lower than the threshold.
+func (f *freezer) TruncateAncientTail(kind string, items uint64) error {
+	if f.readonly {
+		return errReadOnly
+	}
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	table := f.tables[kind]
+	if table == nil {
+		return errUnknownTable
+	}
+	if frozen := atomic.LoadUint64(&f.frozen); items > frozen {
+		items = frozen
+	}
+	return table.truncateTail(items)
+}
+
 // Sync flushes all data tables to disk.
 func (f *freezer) Sync() error {
 	var errs []error
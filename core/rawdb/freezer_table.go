@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"sync"
@@ -44,6 +45,10 @@ var (
 
 	// errNotSupported is returned if the database doesn't support the required operation.
 	errNotSupported = errors.New("this operation is not supported")
+
+	// errTruncationBelowTail is returned if the user attempts to truncate the head
+	// of a freezer table below the items already deleted from its tail.
+	errTruncationBelowTail = errors.New("truncation below tail")
 )
 
 // indexEntry contains the number/id of the file that the data resides in, aswell as the
@@ -106,7 +111,8 @@ type freezerTable struct {
 	index  *os.File            // File descriptor for the indexEntry file of the table
 
 	// In the case that old items are deleted (from the tail), we use itemOffset
-	// to count how many historic items have gone missing.
+	// to count how many historic items have gone missing. It is persisted in the
+	// first index entry and is accessed atomically.
 	itemOffset uint32 // Offset (number of discarded items)
 
 	headBytes  int64         // Number of bytes written to the head file
@@ -301,6 +307,9 @@ func (t *freezerTable) repair() error {
 			contentExp = int64(lastIndex.offset)
 		}
 	}
+	// Remove any data files left behind by an interrupted tail truncation
+	t.removeFilesBefore(t.tailId)
+
 	// Ensure all reparation changes have been written to disk
 	if err := t.index.Sync(); err != nil {
 		return err
@@ -349,6 +358,11 @@ func (t *freezerTable) truncate(items uint64) error {
 	if existing <= items {
 		return nil
 	}
+	// Items deleted from the tail can't be brought back
+	tail := uint64(atomic.LoadUint32(&t.itemOffset))
+	if items < tail {
+		return errTruncationBelowTail
+	}
 	// We need to truncate, save the old size for metrics tracking
 	oldSize, err := t.sizeNolock()
 	if err != nil {
@@ -360,16 +374,21 @@ func (t *freezerTable) truncate(items uint64) error {
 		log = t.logger.Warn // Only loud warn if we delete multiple items
 	}
 	log("Truncating freezer table", "items", existing, "limit", items)
-	if err := truncateFreezerFile(t.index, int64(items+1)*indexEntrySize); err != nil {
+	if err := truncateFreezerFile(t.index, int64(items-tail+1)*indexEntrySize); err != nil {
 		return err
 	}
 	// Calculate the new expected size of the data file and truncate it
 	buffer := make([]byte, indexEntrySize)
-	if _, err := t.index.ReadAt(buffer, int64(items*indexEntrySize)); err != nil {
+	if _, err := t.index.ReadAt(buffer, int64((items-tail)*indexEntrySize)); err != nil {
 		return err
 	}
 	var expected indexEntry
 	expected.unmarshalBinary(buffer)
+	if items == tail {
+		// The first index entry carries the tail position, not a data offset.
+		// An empty table starts writing at the beginning of the tail file.
+		expected = indexEntry{filenum: expected.filenum, offset: 0}
+	}
 
 	// We might need to truncate back to older files
 	if expected.filenum != t.headId {
@@ -403,6 +422,141 @@ func (t *freezerTable) truncate(items uint64) error {
 	return nil
 }
 
+// truncateTail discards any data below the provided threshold number. Data is
+// only ever removed in whole data files, so items up to the start of the file
+// containing the threshold item are deleted and the rest are retained. The new
+// tail is persisted in the first index entry, so the numbering of the remaining
+// items is unaffected.
+func (t *freezerTable) truncateTail(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// If the tail is already beyond the requested threshold, don't do anything
+	tail := uint64(atomic.LoadUint32(&t.itemOffset))
+	if items <= tail {
+		return nil
+	}
+	if existing := atomic.LoadUint64(&t.items); items > existing {
+		items = existing
+	}
+	// Locate the data file which holds the threshold item. If the threshold
+	// is the item count, all files apart from the head can go.
+	buffer := make([]byte, indexEntrySize)
+	newTailId := t.headId
+	if items < atomic.LoadUint64(&t.items) {
+		if _, err := t.index.ReadAt(buffer, int64((items-tail+1)*indexEntrySize)); err != nil {
+			return err
+		}
+		var entry indexEntry
+		entry.unmarshalBinary(buffer)
+		newTailId = entry.filenum
+	}
+	if newTailId == t.tailId {
+		return nil // Threshold item is in the current tail file, nothing to delete
+	}
+	// Find the first item stored in the new tail file. Its index entry becomes
+	// the first data entry of the rewritten index.
+	stat, err := t.index.Stat()
+	if err != nil {
+		return err
+	}
+	var (
+		entries = uint64(stat.Size() / indexEntrySize)
+		first   = uint64(1)
+	)
+	for ; first < entries; first++ {
+		if _, err := t.index.ReadAt(buffer, int64(first*indexEntrySize)); err != nil {
+			return err
+		}
+		var entry indexEntry
+		entry.unmarshalBinary(buffer)
+		if entry.filenum >= newTailId {
+			break
+		}
+	}
+	newOffset := tail + first - 1
+	if newOffset > math.MaxUint32 {
+		return fmt.Errorf("tail offset %d exceeds index capacity", newOffset)
+	}
+	// We need to truncate, save the old size for metrics tracking
+	oldSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	t.logger.Info("Truncating freezer table tail", "items", atomic.LoadUint64(&t.items), "tail", tail, "newtail", newOffset, "files", newTailId-t.tailId)
+
+	// Write the new index into a temporary file and move it in place. The data
+	// files are only deleted afterwards, so a crash leaves either the old or
+	// the new index intact; leftover files are cleaned up by repair.
+	rest := make([]byte, (entries-first)*indexEntrySize)
+	if _, err := t.index.ReadAt(rest, int64(first*indexEntrySize)); err != nil {
+		return err
+	}
+	head := indexEntry{filenum: newTailId, offset: uint32(newOffset)}
+	blob := head.append(make([]byte, 0, indexEntrySize+len(rest)))
+	blob = append(blob, rest...)
+
+	idxName := t.index.Name()
+	tmpName := idxName + ".tmp"
+	tmp, err := openFreezerFileTruncated(tmpName)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(blob); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	tmp.Close()
+	if err := t.index.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, idxName); err != nil {
+		return err
+	}
+	if t.index, err = openFreezerFileForAppend(idxName); err != nil {
+		return err
+	}
+	// Index swapped, drop the obsolete data files and update the counters
+	t.removeFilesBefore(newTailId)
+	t.tailId = newTailId
+	atomic.StoreUint32(&t.itemOffset, uint32(newOffset))
+
+	// Retrieve the new size and update the total size counter
+	newSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	t.sizeGauge.Dec(int64(oldSize - newSize))
+	return nil
+}
+
+// removeFilesBefore closes and deletes all data files with a lower number than
+// the given one. It assumes that the caller holds the write lock.
+func (t *freezerTable) removeFilesBefore(num uint32) {
+	for fnum := num; fnum > 0; fnum-- {
+		name := t.fileName(fnum - 1)
+		if f, exist := t.files[fnum-1]; exist {
+			delete(t.files, fnum-1)
+			f.Close()
+		}
+		if err := os.Remove(filepath.Join(t.path, name)); err != nil {
+			break // Reached the files already deleted by a previous truncation
+		}
+	}
+}
+
+// tail returns the number of the first item retained in the freezer table,
+// or in other words, the number of items deleted from its tail.
+func (t *freezerTable) tail() uint64 {
+	return uint64(atomic.LoadUint32(&t.itemOffset))
+}
+
 // Close closes all opened files.
 func (t *freezerTable) Close() error {
 	t.lock.Lock()
@@ -431,13 +585,7 @@ func (t *freezerTable) Close() error {
 func (t *freezerTable) openFile(num uint32, opener func(string) (*os.File, error)) (f *os.File, err error) {
 	var exist bool
 	if f, exist = t.files[num]; !exist {
-		var name string
-		if t.noCompression {
-			name = fmt.Sprintf("%s.%04d.rdat", t.name, num)
-		} else {
-			name = fmt.Sprintf("%s.%04d.cdat", t.name, num)
-		}
-		f, err = opener(filepath.Join(t.path, name))
+		f, err = opener(filepath.Join(t.path, t.fileName(num)))
 		if err != nil {
 			return nil, err
 		}
@@ -446,6 +594,14 @@ func (t *freezerTable) openFile(num uint32, opener func(string) (*os.File, error
 	return f, err
 }
 
+// fileName returns the name of the data file with the given number.
+func (t *freezerTable) fileName(num uint32) string {
+	if t.noCompression {
+		return fmt.Sprintf("%s.%04d.rdat", t.name, num)
+	}
+	return fmt.Sprintf("%s.%04d.cdat", t.name, num)
+}
+
 // releaseFile closes a file, and removes it from the open file cache.
 // Assumes that the caller holds the write lock
 func (t *freezerTable) releaseFile(num uint32) {
@@ -568,7 +724,7 @@ func (t *freezerTable) retrieveItems(start, count, maxBytes uint64) ([]byte, []i
 	itemCount := atomic.LoadUint64(&t.items) // max number
 	// Ensure the start is written, not deleted from the tail, and that the
 	// caller actually wants something
-	if itemCount <= start || uint64(t.itemOffset) > start || count == 0 {
+	if itemCount <= start || t.tail() > start || count == 0 {
 		return nil, nil, errOutOfBounds
 	}
 	if start+count > itemCount {
@@ -651,7 +807,7 @@ func (t *freezerTable) retrieveItems(start, count, maxBytes uint64) ([]byte, []i
 // has returns an indicator whether the specified number data
 // exists in the freezer table.
 func (t *freezerTable) has(number uint64) bool {
-	return atomic.LoadUint64(&t.items) > number
+	return atomic.LoadUint64(&t.items) > number && t.tail() <= number
 }
 
 // size returns the total data size in the freezer table.
@@ -398,6 +398,113 @@ func TestFreezerTruncate(t *testing.T) {
 	}
 }
 
+func TestFreezerTruncateTail(t *testing.T) {
+	t.Parallel()
+	rm, wm, sg := metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge()
+	fname := fmt.Sprintf("truncate-tail-%d", rand.Uint64())
+
+	// Fill table
+	{
+		f, err := newTable(os.TempDir(), fname, rm, wm, sg, 50, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Write 15 bytes 30 times, 3 items per file
+		writeChunks(t, f, 30, 15)
+		f.Close()
+	}
+	// Reopen, truncate the tail into the third file
+	{
+		f, err := newTable(os.TempDir(), fname, rm, wm, sg, 50, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.truncateTail(7); err != nil {
+			t.Fatal(err)
+		}
+		// Items are deleted per file, so the tail is the first item of the
+		// file containing item 7.
+		if tail := f.tail(); tail != 6 {
+			t.Fatalf("expected tail %d, got %d", 6, tail)
+		}
+		if f.items != 30 {
+			t.Fatalf("expected %d items, got %d", 30, f.items)
+		}
+		if f.has(5) || !f.has(6) {
+			t.Fatal("wrong item availability around the tail")
+		}
+		checkRetrieveError(t, f, map[uint64]error{
+			0: errOutOfBounds,
+			5: errOutOfBounds,
+		})
+		checkRetrieve(t, f, map[uint64][]byte{
+			6:  getChunk(15, 6),
+			7:  getChunk(15, 7),
+			29: getChunk(15, 29),
+		})
+		// A lower threshold is a noop
+		if err := f.truncateTail(3); err != nil {
+			t.Fatal(err)
+		}
+		if tail := f.tail(); tail != 6 {
+			t.Fatalf("expected tail %d, got %d", 6, tail)
+		}
+		f.Close()
+	}
+	// Reopen, check that the tail persisted and the old files are gone
+	{
+		f, err := newTable(os.TempDir(), fname, rm, wm, sg, 50, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		if tail := f.tail(); tail != 6 {
+			t.Fatalf("expected tail %d after reopen, got %d", 6, tail)
+		}
+		for _, num := range []uint32{0, 1} {
+			if _, err := os.Stat(filepath.Join(os.TempDir(), f.fileName(num))); !os.IsNotExist(err) {
+				t.Fatalf("data file %d not deleted: %v", num, err)
+			}
+		}
+		checkRetrieve(t, f, map[uint64][]byte{
+			6:  getChunk(15, 6),
+			29: getChunk(15, 29),
+		})
+		// Truncating the head keeps working on top of the tail
+		if err := f.truncate(10); err != nil {
+			t.Fatal(err)
+		}
+		checkRetrieve(t, f, map[uint64][]byte{
+			9: getChunk(15, 9),
+		})
+		checkRetrieveError(t, f, map[uint64]error{
+			10: errOutOfBounds,
+		})
+		if err := f.truncate(5); err != errTruncationBelowTail {
+			t.Fatalf("expected truncation below tail to fail, got %v", err)
+		}
+		// Appending continues at the head
+		batch := f.newBatch()
+		require.NoError(t, batch.AppendRaw(10, getChunk(15, 0xaa)))
+		require.NoError(t, batch.commit())
+		checkRetrieve(t, f, map[uint64][]byte{
+			10: getChunk(15, 0xaa),
+		})
+		// Truncating beyond the head deletes everything but the head file
+		if err := f.truncateTail(100); err != nil {
+			t.Fatal(err)
+		}
+		if tail := f.tail(); tail != 9 {
+			t.Fatalf("expected tail %d, got %d", 9, tail)
+		}
+		checkRetrieve(t, f, map[uint64][]byte{
+			9:  getChunk(15, 9),
+			10: getChunk(15, 0xaa),
+		})
+	}
+}
+
 // TestFreezerRepairFirstFile tests a head file with the very first item only half-written.
 // That will rewind the index, and _should_ truncate the head file
 func TestFreezerRepairFirstFile(t *testing.T) {
@@ -253,6 +253,69 @@ func TestFreezerConcurrentModifyTruncate(t *testing.T) {
 	}
 }
 
+func TestFreezerTruncateAncientTail(t *testing.T) {
+	t.Parallel()
+
+	tables := map[string]bool{"kept": true, "pruned": true}
+	f, dir := newFreezerForTesting(t, tables)
+	defer os.RemoveAll(dir)
+	defer f.Close()
+
+	// Commit 100 items of 256 bytes, 8 items per data file.
+	_, err := f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i := 0; i < 100; i++ {
+			if err := op.AppendRaw("kept", uint64(i), getChunk(256, i)); err != nil {
+				return err
+			}
+			if err := op.AppendRaw("pruned", uint64(i), getChunk(256, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal("ModifyAncients failed:", err)
+	}
+	if err := f.TruncateAncientTail("unknown", 50); err != errUnknownTable {
+		t.Fatalf("wrong error for unknown table: %v", err)
+	}
+	if err := f.TruncateAncientTail("pruned", 50); err != nil {
+		t.Fatal("TruncateAncientTail failed:", err)
+	}
+	tail, err := f.AncientTail("pruned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tail != 48 {
+		t.Fatalf("wrong tail: have %d, want %d", tail, 48)
+	}
+	if tail, _ := f.AncientTail("kept"); tail != 0 {
+		t.Fatalf("unpruned table has tail %d", tail)
+	}
+	checkAncientCount(t, f, "kept", 100)
+	checkAncientCount(t, f, "pruned", 100)
+	if ok, _ := f.HasAncient("pruned", 47); ok {
+		t.Fatal("pruned item still reported")
+	}
+	if ok, _ := f.HasAncient("kept", 47); !ok {
+		t.Fatal("retained item missing")
+	}
+	if _, err := f.Ancient("pruned", 47); err != errOutOfBounds {
+		t.Fatalf("wrong error for pruned item: %v", err)
+	}
+	// Rewinding the head below the tail must be rejected
+	if err := f.TruncateAncients(40); err != errTruncationBelowTail {
+		t.Fatalf("wrong error for truncation below tail: %v", err)
+	}
+	checkAncientCount(t, f, "kept", 100)
+	checkAncientCount(t, f, "pruned", 100)
+	if err := f.TruncateAncients(60); err != nil {
+		t.Fatal(err)
+	}
+	checkAncientCount(t, f, "kept", 60)
+	checkAncientCount(t, f, "pruned", 60)
+}
+
 func newFreezerForTesting(t *testing.T, tables map[string]bool) (*freezer, string) {
 	t.Helper()
 
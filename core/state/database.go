@@ -88,9 +88,11 @@ type Trie interface {
 	// can be used even if the trie doesn't have one.
 	Hash() common.Hash
 
-	// Commit writes all nodes to the trie's memory database, tracking the internal
-	// and external (for account tries) references.
-	Commit(onleaf trie.LeafCallback) (common.Hash, int, error)
+	// CommitNodes writes all nodes to the trie's memory database, tracking the
+	// internal and external (for account tries) references. In the path-based
+	// scheme, the nodes are returned as a set instead, to be sealed into the state
+	// by trie.Database.Update.
+	CommitNodes(onleaf trie.LeafCallback) (common.Hash, *trie.NodeSet, int, error)
 
 	// NodeIterator returns an iterator that returns nodes of the trie. Iteration
 	// starts at the key after the given start key.
@@ -115,8 +117,16 @@ func NewDatabase(db ethdb.Database) Database {
 
 // NewDatabaseWithConfig creates a backing store for state. The returned database
 // is safe for concurrent use and retains a lot of collapsed RLP trie nodes in a
-// large memory cache.
+// large memory cache. Without a config, the storage scheme of the trie nodes is
+// the one recorded in the database. A given config is used as is, so ephemeral
+// databases which must not write layers over the persisted state of the path
+// scheme get the hash-based scheme unless they ask for another.
 func NewDatabaseWithConfig(db ethdb.Database, config *trie.Config) Database {
+	if config == nil {
+		if scheme := rawdb.ReadStateScheme(db); scheme != "" {
+			config = &trie.Config{Preimages: true, Scheme: scheme}
+		}
+	}
 	csc, _ := lru.New(codeSizeCacheSize)
 	return &cachingDB{
 		db:            trie.NewDatabaseWithConfig(db, config),
@@ -142,7 +152,7 @@ func (db *cachingDB) OpenTrie(root common.Hash) (Trie, error) {
 
 // OpenStorageTrie opens the storage trie of an account.
 func (db *cachingDB) OpenStorageTrie(addrHash, root common.Hash) (Trie, error) {
-	tr, err := trie.NewSecure(root, db.db)
+	tr, err := trie.NewSecureWithOwner(addrHash, root, db.db)
 	if err != nil {
 		return nil, err
 	}
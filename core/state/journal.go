@@ -90,8 +90,9 @@ type (
 		account *common.Address
 	}
 	resetObjectChange struct {
-		prev         *stateObject
-		prevdestruct bool
+		prev              *stateObject
+		prevdestruct      bool
+		prevstatedestruct bool
 	}
 	suicideChange struct {
 		account     *common.Address
@@ -154,6 +155,9 @@ func (ch resetObjectChange) revert(s *StateDB) {
 	if !ch.prevdestruct && s.snap != nil {
 		delete(s.snapDestructs, ch.prev.addrHash)
 	}
+	if !ch.prevstatedestruct {
+		delete(s.stateDestructs, ch.prev.addrHash)
+	}
 }
 
 func (ch resetObjectChange) dirtied() *common.Address {
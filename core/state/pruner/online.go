@@ -0,0 +1,577 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	onlineMarkedMeter      = metrics.NewRegisteredMeter("state/pruner/online/marked", nil)
+	onlineDeletedMeter     = metrics.NewRegisteredMeter("state/pruner/online/deleted", nil)
+	onlineDeletedSizeMeter = metrics.NewRegisteredMeter("state/pruner/online/deleted/size", nil)
+	onlineProgressGauge    = metrics.NewRegisteredGauge("state/pruner/online/progress", nil)
+
+	// errPruningAborted is returned if an online pruning run is stopped midway.
+	errPruningAborted = errors.New("state pruning aborted")
+)
+
+// Stages of an online pruning run.
+const (
+	stageMarking  = "marking"
+	stageSweeping = "sweeping"
+)
+
+// OnlineConfig contains the settings of the online pruner.
+type OnlineConfig struct {
+	BloomSize  uint64        // Megabytes of memory allocated to the bloom filter of the live state
+	BatchSize  int           // Number of stale trie nodes deleted in a batch
+	BatchDelay time.Duration // Pause between consecutive batches, limiting the load on the database
+}
+
+// DefaultOnlineConfig contains the default settings of the online pruner.
+var DefaultOnlineConfig = OnlineConfig{
+	BloomSize:  2048,
+	BatchSize:  10000,
+	BatchDelay: 100 * time.Millisecond,
+}
+
+// OnlineStatus is the progress report of an online pruning run.
+type OnlineStatus struct {
+	Running  bool               `json:"running"`
+	Stage    string             `json:"stage,omitempty"`
+	Root     common.Hash        `json:"root"`
+	Marked   uint64             `json:"marked"`
+	Deleted  uint64             `json:"deleted"`
+	Size     common.StorageSize `json:"size"`
+	Progress float64            `json:"progress"`
+	Started  time.Time          `json:"started"`
+	Elapsed  string             `json:"elapsed"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// OnlinePruner is a background tool to prune the stale state while the node is
+// running and importing blocks. It works in two stages:
+//
+//   - marking: the trie nodes of the live state are recorded in a bloom filter,
+//     regenerating the state of the snapshot disk layer and walking the paths
+//     modified by each diff layer on top
+//   - sweeping: the database is iterated, deleting all the trie nodes missing
+//     from the bloom filter in rate limited batches
+//
+// Every trie node flushed by the trie database from the start of the run is
+// marked as live before being written, so the new states committed concurrently
+// are never touched. Deletions and marks are serialized, preventing a flushed
+// node from being deleted right after being checked.
+//
+// The state of the snapshot disk layer is persisted in full while marking, as
+// it's the oldest state retained. After an unclean shutdown the chain is rewound
+// to it at worst.
+type OnlinePruner struct {
+	marked  uint64 // Number of live entries marked in the current run (atomic)
+	deleted uint64 // Number of stale trie nodes deleted in the current run (atomic)
+
+	db       ethdb.Database
+	triedb   *trie.Database
+	snaptree *snapshot.Tree
+	config   OnlineConfig
+
+	lock sync.RWMutex // Lock serializing deletions with the marks of flushed nodes
+
+	status     OnlineStatus // Progress of the current or last run
+	statusLock sync.Mutex
+	quit       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewOnlinePruner creates an online pruner operating on the state of the given
+// trie database, using the snapshot tree to track the live state.
+func NewOnlinePruner(db ethdb.Database, triedb *trie.Database, snaptree *snapshot.Tree, config OnlineConfig) (*OnlinePruner, error) {
+	if triedb.Scheme() == rawdb.PathScheme {
+		return nil, errors.New("state pruning is not needed by the path-based state scheme")
+	}
+	if snaptree == nil {
+		return nil, errors.New("state pruning requires snapshots")
+	}
+	if config.BloomSize < 256 {
+		log.Warn("Sanitizing bloomfilter size", "provided(MB)", config.BloomSize, "updated(MB)", 256)
+		config.BloomSize = 256
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultOnlineConfig.BatchSize
+	}
+	return &OnlinePruner{
+		db:       db,
+		triedb:   triedb,
+		snaptree: snaptree,
+		config:   config,
+	}, nil
+}
+
+// Start launches a pruning run in the background, if none is running yet.
+func (p *OnlinePruner) Start() error {
+	p.statusLock.Lock()
+	defer p.statusLock.Unlock()
+
+	if p.status.Running {
+		return errors.New("state pruning already running")
+	}
+	bloom, err := newStateBloomWithSize(p.config.BloomSize)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint64(&p.marked, 0)
+	atomic.StoreUint64(&p.deleted, 0)
+	onlineProgressGauge.Update(0)
+
+	p.status = OnlineStatus{Running: true, Stage: stageMarking, Started: time.Now()}
+	p.quit = make(chan struct{})
+
+	// Any trie node flushed from now on belongs to a live state, mark it before
+	// it hits the disk. The hook is installed before returning, so the states
+	// committed right after starting are covered too.
+	p.triedb.SetFlushHook(func(hash common.Hash) {
+		p.lock.RLock()
+		defer p.lock.RUnlock()
+
+		bloom.Put(hash.Bytes(), nil)
+		atomic.AddUint64(&p.marked, 1)
+		onlineMarkedMeter.Mark(1)
+	})
+
+	p.wg.Add(1)
+	go p.run(bloom, p.quit)
+	return nil
+}
+
+// Stop interrupts the running pruning run, if any, and waits for it to exit.
+func (p *OnlinePruner) Stop() {
+	p.statusLock.Lock()
+	if p.status.Running && p.quit != nil {
+		close(p.quit)
+		p.quit = nil
+	}
+	p.statusLock.Unlock()
+
+	p.wg.Wait()
+}
+
+// Status returns the progress of the current pruning run, or the outcome of the
+// last one.
+func (p *OnlinePruner) Status() OnlineStatus {
+	p.statusLock.Lock()
+	defer p.statusLock.Unlock()
+
+	status := p.status
+	status.Marked = atomic.LoadUint64(&p.marked)
+	status.Deleted = atomic.LoadUint64(&p.deleted)
+	if !status.Started.IsZero() && status.Running {
+		status.Elapsed = common.PrettyDuration(time.Since(status.Started)).String()
+	}
+	return status
+}
+
+// run executes a pruning run, marking the live state and sweeping the stale.
+func (p *OnlinePruner) run(bloom *stateBloom, quit chan struct{}) {
+	defer p.wg.Done()
+
+	start := time.Now()
+
+	err := p.mark(bloom, quit)
+	if err == nil {
+		p.statusLock.Lock()
+		p.status.Stage = stageSweeping
+		p.statusLock.Unlock()
+
+		err = p.sweep(bloom, quit)
+	}
+	// Drop the hook before reporting the run finished, so it can't remove the
+	// hook of the next run
+	p.triedb.SetFlushHook(nil)
+
+	p.statusLock.Lock()
+	p.status.Running, p.status.Stage = false, ""
+	p.status.Elapsed = common.PrettyDuration(time.Since(start)).String()
+	if err != nil {
+		p.status.Error = err.Error()
+	}
+	p.statusLock.Unlock()
+
+	if err != nil {
+		log.Error("Online state pruning failed", "err", err)
+		return
+	}
+	log.Info("Online state pruning successful", "deleted", atomic.LoadUint64(&p.deleted), "size", p.Status().Size,
+		"elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// mark records all the entries of the live state in the bloom filter. The disk
+// layer of the snapshot is held in the meantime, preventing it from moving on.
+// If the diffs piling up on top exceed the memory allowance of the hold, the
+// disk layer moves regardless and marking fails with a stale snapshot error.
+func (p *OnlinePruner) mark(bloom *stateBloom, quit chan struct{}) error {
+	if err := p.snaptree.HoldDisk(); err != nil {
+		return err
+	}
+	defer p.snaptree.ReleaseDisk()
+
+	root := p.snaptree.DiskRoot()
+	if root == (common.Hash{}) {
+		return errors.New("snapshot disk layer is missing")
+	}
+	p.statusLock.Lock()
+	p.status.Root = root
+	p.statusLock.Unlock()
+
+	// Mark the recent states first, as their tries are gradually dereferenced
+	// from the trie database while the chain progresses.
+	log.Info("Marking recent state for pruning", "base", root)
+	if err := p.markDiffs(bloom, root, quit); err != nil {
+		return err
+	}
+	// Regenerate the state of the disk layer, persisting the nodes which are
+	// missing from the database along the way.
+	log.Info("Marking persisted state for pruning", "root", root)
+	writer := &liveWriter{pruner: p, bloom: bloom, db: p.db, batch: p.db.NewBatch()}
+	if err := snapshot.GenerateTrieWithAbort(p.snaptree, root, p.db, writer, quit); err != nil {
+		select {
+		case <-quit:
+			return errPruningAborted
+		default:
+			return err
+		}
+	}
+	if err := writer.flush(); err != nil {
+		return err
+	}
+	// Keep the genesis state around, same as the offline pruner
+	return extractGenesis(p.db, &liveWriter{pruner: p, bloom: bloom})
+}
+
+// markDiffs marks the states of the snapshot diff layers on top of the given
+// disk layer root. The state of a layer consists of the nodes of its parent and
+// the ones on the paths it modifies, or right next to them (e.g. a shortened
+// sibling after a split), so proving the modified keys of each layer upwards
+// from the disk layer covers them all. Layers whose state isn't available anymore
+// hand down their modifications to their children.
+func (p *OnlinePruner) markDiffs(bloom *stateBloom, root common.Hash, quit chan struct{}) error {
+	children := make(map[common.Hash][]*snapshot.StateDiff)
+	for _, diff := range p.snaptree.Diffs() {
+		children[diff.Parent] = append(children[diff.Parent], diff)
+	}
+	type task struct {
+		diff    *snapshot.StateDiff
+		pending []*snapshot.StateDiff // Modifications of the unavailable ancestors
+	}
+	var queue []task
+	for _, diff := range children[root] {
+		queue = append(queue, task{diff: diff})
+	}
+	writer := &liveWriter{pruner: p, bloom: bloom, children: true}
+	for len(queue) > 0 {
+		select {
+		case <-quit:
+			return errPruningAborted
+		default:
+		}
+		t := queue[0]
+		queue = queue[1:]
+
+		diffs := append(append([]*snapshot.StateDiff{}, t.pending...), t.diff)
+		available, err := p.markPaths(writer, t.diff.Root, diffs)
+		if err != nil {
+			return err
+		}
+		var pending []*snapshot.StateDiff
+		if !available {
+			pending = diffs
+		}
+		for _, child := range children[t.diff.Root] {
+			queue = append(queue, task{diff: child, pending: pending})
+		}
+	}
+	return nil
+}
+
+// markPaths marks the nodes on the paths of the keys modified by the given diffs
+// in the state with the given root, reporting whether the state is available.
+func (p *OnlinePruner) markPaths(writer *liveWriter, root common.Hash, diffs []*snapshot.StateDiff) (bool, error) {
+	accTrie, err := trie.New(root, p.triedb)
+	if err != nil {
+		if _, ok := err.(*trie.MissingNodeError); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, diff := range diffs {
+		for _, hash := range diff.Accounts {
+			if err := accTrie.Prove(hash.Bytes(), 0, writer); err != nil {
+				return false, fmt.Errorf("state %x changed during marking: %v", root, err)
+			}
+			blob, err := accTrie.TryGet(hash.Bytes())
+			if err != nil {
+				return false, fmt.Errorf("state %x changed during marking: %v", root, err)
+			}
+			if len(blob) == 0 {
+				continue // Account deleted
+			}
+			var acc types.StateAccount
+			if err := rlp.DecodeBytes(blob, &acc); err != nil {
+				return false, err
+			}
+			if !bytes.Equal(acc.CodeHash, emptyCode) {
+				writer.Put(acc.CodeHash, nil)
+			}
+			slots := diff.Storage[hash]
+			if len(slots) == 0 || acc.Root == emptyRoot {
+				continue
+			}
+			stTrie, err := trie.New(acc.Root, p.triedb)
+			if err != nil {
+				return false, fmt.Errorf("state %x changed during marking: %v", root, err)
+			}
+			for _, slot := range slots {
+				if err := stTrie.Prove(slot.Bytes(), 0, writer); err != nil {
+					return false, fmt.Errorf("state %x changed during marking: %v", root, err)
+				}
+			}
+		}
+	}
+	return true, nil
+}
+
+// sweep deletes all the trie nodes in the database which are not marked as live,
+// in rate limited batches.
+func (p *OnlinePruner) sweep(bloom *stateBloom, quit chan struct{}) error {
+	type candidate struct {
+		key  []byte
+		size int
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		batch  []candidate
+		iter   = p.db.NewIterator(nil, nil)
+	)
+	defer func() { iter.Release() }()
+
+	// flush deletes the accumulated batch of stale nodes, skipping the ones which
+	// were flushed in the meantime.
+	flush := func() error {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		var (
+			dbatch = p.db.NewBatch()
+			count  int
+			size   common.StorageSize
+		)
+		for _, c := range batch {
+			if ok, _ := bloom.Contain(c.key); ok {
+				continue
+			}
+			dbatch.Delete(c.key)
+			count++
+			size += common.StorageSize(c.size)
+		}
+		if err := dbatch.Write(); err != nil {
+			return err
+		}
+		atomic.AddUint64(&p.deleted, uint64(count))
+		onlineDeletedMeter.Mark(int64(count))
+		onlineDeletedSizeMeter.Mark(int64(size))
+
+		p.statusLock.Lock()
+		p.status.Size += size
+		p.statusLock.Unlock()
+		return nil
+	}
+	for iter.Next() {
+		// Only ever delete trie nodes, leaving any other entry alone
+		key := iter.Key()
+		if !isTrieNode(key, iter.Value()) {
+			continue
+		}
+		if ok, _ := bloom.Contain(key); ok {
+			continue
+		}
+		batch = append(batch, candidate{key: common.CopyBytes(key), size: len(key) + len(iter.Value())})
+		if len(batch) < p.config.BatchSize {
+			continue
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		progress := float64(binary.BigEndian.Uint64(key[:8])) / math.MaxUint64
+		onlineProgressGauge.Update(int64(progress * 1000))
+
+		p.statusLock.Lock()
+		p.status.Progress = progress
+		p.statusLock.Unlock()
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Pruning state data online", "nodes", atomic.LoadUint64(&p.deleted), "progress", fmt.Sprintf("%.2f%%", progress*100),
+				"elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		// Recreate the iterator after every batch in order to allow the underlying
+		// compactor to delete the entries.
+		iter.Release()
+		iter = p.db.NewIterator(nil, key)
+		batch = batch[:0]
+
+		select {
+		case <-quit:
+			return errPruningAborted
+		case <-time.After(p.config.BatchDelay):
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	onlineProgressGauge.Update(1000)
+
+	p.statusLock.Lock()
+	p.status.Progress = 1
+	p.statusLock.Unlock()
+	return nil
+}
+
+// isTrieNode reports whether a database entry is a trie node of the hash-based
+// scheme: keyed by the hash of its value, which is the encoding of a short node
+// (two items) or a full node (seventeen items).
+func isTrieNode(key, value []byte) bool {
+	if len(key) != common.HashLength {
+		return false
+	}
+	elems, _, err := rlp.SplitList(value)
+	if err != nil {
+		return false
+	}
+	if count, err := rlp.CountValues(elems); err != nil || (count != 2 && count != 17) {
+		return false
+	}
+	return bytes.Equal(crypto.Keccak256(value), key)
+}
+
+// liveWriter is a database writer marking the keys of the written state entries
+// as live in the bloom filter. If a database is set, the trie nodes missing from
+// it are persisted too. It's safe for concurrent use.
+type liveWriter struct {
+	pruner   *OnlinePruner
+	bloom    *stateBloom
+	children bool // Whether the hashed children of the written trie nodes are marked too
+	db       ethdb.Database
+	batch    ethdb.Batch
+	lock     sync.Mutex
+}
+
+// Put implements the KeyValueWriter interface, marking the key as live.
+func (w *liveWriter) Put(key []byte, value []byte) error {
+	if err := w.mark(key); err != nil {
+		return err
+	}
+	if w.children && len(key) == common.HashLength {
+		// Anything hash sized referenced by the node is taken as a child, marking
+		// a few extra entries is harmless.
+		if elems, _, err := rlp.SplitList(value); err == nil {
+			for len(elems) > 0 {
+				kind, content, rest, err := rlp.Split(elems)
+				if err != nil {
+					break
+				}
+				if kind == rlp.String && len(content) == common.HashLength {
+					w.mark(content)
+				}
+				elems = rest
+			}
+		}
+	}
+
+	if w.db == nil || len(key) != common.HashLength {
+		return nil
+	}
+	if ok, _ := w.db.Has(key); ok {
+		return nil
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if err := w.batch.Put(key, value); err != nil {
+		return err
+	}
+	if w.batch.ValueSize() >= ethdb.IdealBatchSize {
+		if err := w.batch.Write(); err != nil {
+			return err
+		}
+		w.batch.Reset()
+	}
+	return nil
+}
+
+// mark records the key as live in the bloom filter. Contract codes are marked
+// under their plain hash too, which is how legacy databases store them.
+func (w *liveWriter) mark(key []byte) error {
+	if err := w.bloom.Put(key, nil); err != nil {
+		return err
+	}
+	if ok, hash := rawdb.IsCodeKey(key); ok {
+		if err := w.bloom.Put(hash, nil); err != nil {
+			return err
+		}
+	}
+	atomic.AddUint64(&w.pruner.marked, 1)
+	onlineMarkedMeter.Mark(1)
+	return nil
+}
+
+// Delete implements the KeyValueWriter interface, but it's not supported.
+func (w *liveWriter) Delete(key []byte) error { panic("not supported") }
+
+// flush persists the accumulated trie nodes missing from the database.
+func (w *liveWriter) flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.batch == nil || w.batch.ValueSize() == 0 {
+		return nil
+	}
+	return w.batch.Write()
+}
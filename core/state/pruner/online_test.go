@@ -0,0 +1,345 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// onlineTester is a hash based database holding a genesis state, a stale state
+// and the live state on top of it, tracked by a snapshot tree.
+type onlineTester struct {
+	db     ethdb.Database
+	sdb    state.Database
+	snaps  *snapshot.Tree
+	head   common.Hash   // Root of the most recently committed state
+	live   []common.Hash // Roots of the states which must survive pruning
+	stale  map[common.Hash]struct{}
+	others map[string][]byte // Database entries which must survive pruning untouched
+}
+
+func newOnlineTester(t *testing.T) *onlineTester {
+	db := rawdb.NewMemoryDatabase()
+	tester := &onlineTester{
+		db:  db,
+		sdb: state.NewDatabase(db),
+	}
+	// Create the genesis state, which is kept around regardless
+	genesis := tester.commit(t, common.Hash{}, func(statedb *state.StateDB) {
+		for i := 0; i < 10; i++ {
+			statedb.SetBalance(common.BigToAddress(big.NewInt(int64(i))), big.NewInt(1))
+		}
+	})
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0), Root: genesis})
+	rawdb.WriteBlock(db, block)
+	rawdb.WriteCanonicalHash(db, block.Hash(), 0)
+
+	// Create a state on top, becoming stale once all its accounts are modified
+	deadCode := []byte{0x60, 0x01, 0x60, 0x00, 0x55}
+	old := tester.commit(t, genesis, func(statedb *state.StateDB) {
+		for i := 10; i < 200; i++ {
+			addr := common.BigToAddress(big.NewInt(int64(i)))
+			statedb.SetBalance(addr, big.NewInt(int64(i)))
+			statedb.SetState(addr, common.BigToHash(big.NewInt(int64(i))), common.Hash{0x01})
+		}
+		statedb.SetCode(common.Address{0xde, 0xad}, deadCode)
+	})
+	// Create the live state, which ends up in the snapshot disk layer
+	snaps, err := snapshot.New(db, tester.sdb.TrieDB(), 16, old, false, true, false)
+	if err != nil {
+		t.Fatalf("Failed to create snapshot tree: %v", err)
+	}
+	tester.snaps = snaps
+
+	live := tester.commit(t, old, func(statedb *state.StateDB) {
+		for i := 10; i < 200; i++ {
+			addr := common.BigToAddress(big.NewInt(int64(i)))
+			statedb.SetBalance(addr, big.NewInt(int64(1000+i)))
+			statedb.SetState(addr, common.BigToHash(big.NewInt(int64(i))), common.Hash{0x02})
+		}
+		statedb.Suicide(common.Address{0xde, 0xad})
+		statedb.SetCode(common.Address{0xbe, 0xef}, []byte{0x60, 0x02})
+	})
+	if err := snaps.Cap(live, 0); err != nil {
+		t.Fatalf("Failed to flatten snapshot: %v", err)
+	}
+	if root := snaps.DiskRoot(); root != live {
+		t.Fatalf("Snapshot disk root mismatch: have %x, want %x", root, live)
+	}
+	tester.live = []common.Hash{genesis, live}
+
+	// Store a few entries which look like trie nodes at first glance, they must
+	// be left alone too.
+	legacyCode := []byte{0x60, 0x03}
+	db.Put(crypto.Keccak256(legacyCode), legacyCode)
+	fakeNode, _ := rlp.EncodeToBytes([][]byte{{0x01}, {0x02}})
+	db.Put(crypto.Keccak256([]byte("fake")), fakeNode)
+	db.Put(crypto.Keccak256([]byte("junk")), []byte("junk"))
+
+	// Stale nodes are the ones unreachable from the live states, anything else
+	// must survive pruning.
+	tester.stale = stateNodes(t, db, old)
+	for _, root := range tester.live {
+		for hash := range stateNodes(t, db, root) {
+			delete(tester.stale, hash)
+		}
+	}
+	if len(tester.stale) == 0 {
+		t.Fatal("No stale trie nodes created")
+	}
+	tester.others = make(map[string][]byte)
+	it := db.NewIterator(nil, nil)
+	for it.Next() {
+		if _, ok := tester.stale[common.BytesToHash(it.Key())]; ok && len(it.Key()) == common.HashLength {
+			continue
+		}
+		tester.others[string(it.Key())] = common.CopyBytes(it.Value())
+	}
+	it.Release()
+	return tester
+}
+
+// commit applies the modifications on top of the given state, commits it and
+// flushes it into the database, tracking it as the new head.
+func (tester *onlineTester) commit(t *testing.T, parent common.Hash, modify func(*state.StateDB)) common.Hash {
+	statedb, err := state.New(parent, tester.sdb, tester.snaps)
+	if err != nil {
+		t.Fatalf("Failed to open state %x: %v", parent, err)
+	}
+	modify(statedb)
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatalf("Failed to commit state: %v", err)
+	}
+	if err := tester.sdb.TrieDB().Commit(root, false, nil); err != nil {
+		t.Fatalf("Failed to flush state %x: %v", root, err)
+	}
+	tester.head = root
+	return root
+}
+
+// commitLive commits a new state on top of the head, modifying both new and
+// existing accounts, and tracks it as live.
+func (tester *onlineTester) commitLive(t *testing.T, n int) {
+	tester.commit(t, tester.head, func(statedb *state.StateDB) {
+		for i := 0; i < 10; i++ {
+			addr := common.BigToAddress(big.NewInt(int64(1000 + 10*n + i)))
+			statedb.SetBalance(addr, big.NewInt(1))
+			statedb.SetState(addr, common.Hash{0x01}, common.Hash{byte(n + 1)})
+		}
+		addr := common.BigToAddress(big.NewInt(int64(10 + n%190)))
+		statedb.SetState(addr, common.BigToHash(big.NewInt(int64(n))), common.Hash{0x03})
+	})
+	tester.live = append(tester.live, tester.head)
+}
+
+// verify checks that all the stale trie nodes are deleted from the database,
+// while all the live states and the other entries are intact.
+func (tester *onlineTester) verify(t *testing.T) {
+	for hash := range tester.stale {
+		if ok, _ := tester.db.Has(hash.Bytes()); ok {
+			t.Errorf("Stale trie node %x not deleted", hash)
+		}
+	}
+	for key, value := range tester.others {
+		have, err := tester.db.Get([]byte(key))
+		if err != nil {
+			t.Errorf("Entry %x deleted", key)
+		} else if !bytes.Equal(have, value) {
+			t.Errorf("Entry %x modified: have %x, want %x", key, have, value)
+		}
+	}
+	for _, root := range tester.live {
+		stateNodes(t, tester.db, root)
+	}
+}
+
+// stateNodes iterates the state with the given root straight from the disk,
+// returning the hashes of all its trie nodes.
+func stateNodes(t *testing.T, db ethdb.Database, root common.Hash) map[common.Hash]struct{} {
+	triedb := trie.NewDatabase(db)
+	accTrie, err := trie.New(root, triedb)
+	if err != nil {
+		t.Fatalf("Failed to open state %x: %v", root, err)
+	}
+	nodes := make(map[common.Hash]struct{})
+	it := accTrie.NodeIterator(nil)
+	for it.Next(true) {
+		if it.Hash() != (common.Hash{}) {
+			nodes[it.Hash()] = struct{}{}
+		}
+		if !it.Leaf() {
+			continue
+		}
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(it.LeafBlob(), &acc); err != nil {
+			t.Fatalf("Failed to decode account: %v", err)
+		}
+		if acc.Root == emptyRoot {
+			continue
+		}
+		stTrie, err := trie.New(acc.Root, triedb)
+		if err != nil {
+			t.Fatalf("Failed to open storage of state %x: %v", root, err)
+		}
+		stIt := stTrie.NodeIterator(nil)
+		for stIt.Next(true) {
+			if stIt.Hash() != (common.Hash{}) {
+				nodes[stIt.Hash()] = struct{}{}
+			}
+		}
+		if stIt.Error() != nil {
+			t.Fatalf("Failed to iterate storage of state %x: %v", root, stIt.Error())
+		}
+	}
+	if it.Error() != nil {
+		t.Fatalf("Failed to iterate state %x: %v", root, it.Error())
+	}
+	return nodes
+}
+
+func newTestOnlinePruner(t *testing.T, tester *onlineTester, config OnlineConfig) *OnlinePruner {
+	p, err := NewOnlinePruner(tester.db, tester.sdb.TrieDB(), tester.snaps, config)
+	if err != nil {
+		t.Fatalf("Failed to create pruner: %v", err)
+	}
+	p.config.BloomSize = 16 // Plenty for the test, spare the allocation
+	return p
+}
+
+// waitStatus polls the status of the pruner until the condition is met.
+func waitStatus(t *testing.T, p *OnlinePruner, cond func(OnlineStatus) bool) OnlineStatus {
+	for start := time.Now(); time.Since(start) < 30*time.Second; time.Sleep(time.Millisecond) {
+		if status := p.Status(); cond(status) {
+			return status
+		}
+	}
+	t.Fatalf("Pruner status timeout: %+v", p.Status())
+	return OnlineStatus{}
+}
+
+// Tests that online pruning deletes the stale trie nodes only, keeping the live
+// states, including the ones committed and flushed while pruning, and every
+// other database entry.
+func TestOnlinePruning(t *testing.T) {
+	var (
+		tester = newOnlineTester(t)
+		p      = newTestOnlinePruner(t, tester, OnlineConfig{BatchSize: 16, BatchDelay: 10 * time.Millisecond})
+	)
+	if err := p.Start(); err != nil {
+		t.Fatalf("Failed to start pruning: %v", err)
+	}
+	if err := p.Start(); err == nil {
+		t.Fatal("Pruning started twice")
+	}
+	// Commit states concurrently, both while marking and while sweeping
+	tester.commitLive(t, 0)
+	waitStatus(t, p, func(status OnlineStatus) bool { return status.Stage == stageSweeping })
+
+	var sweeping int
+	for n := 1; p.Status().Running; n++ {
+		tester.commitLive(t, n)
+		if p.Status().Stage == stageSweeping {
+			sweeping++
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if sweeping == 0 {
+		t.Fatal("No state committed while sweeping")
+	}
+	status := p.Status()
+	if status.Error != "" {
+		t.Fatalf("Pruning failed: %v", status.Error)
+	}
+	if status.Stage != "" || status.Progress != 1 {
+		t.Errorf("Unexpected final stage: stage %q, progress %v", status.Stage, status.Progress)
+	}
+	if status.Root != tester.live[1] {
+		t.Errorf("Pruned state root mismatch: have %x, want %x", status.Root, tester.live[1])
+	}
+	if status.Deleted != uint64(len(tester.stale)) {
+		t.Errorf("Deleted node count mismatch: have %d, want %d", status.Deleted, len(tester.stale))
+	}
+	if status.Marked == 0 || status.Size == 0 {
+		t.Errorf("Missing progress report: marked %d, size %v", status.Marked, status.Size)
+	}
+	tester.verify(t)
+
+	// The flush hook is removed after finishing
+	tester.commitLive(t, len(tester.live))
+	if marked := p.Status().Marked; marked != status.Marked {
+		t.Errorf("Nodes marked after finishing: have %d, want %d", marked, status.Marked)
+	}
+}
+
+// Tests that online pruning can be aborted midway and restarted later on.
+func TestOnlinePruningAbort(t *testing.T) {
+	var (
+		tester = newOnlineTester(t)
+		p      = newTestOnlinePruner(t, tester, OnlineConfig{BatchSize: 1, BatchDelay: time.Hour})
+	)
+	// Aborting without a run is a noop
+	p.Stop()
+
+	if err := p.Start(); err != nil {
+		t.Fatalf("Failed to start pruning: %v", err)
+	}
+	tester.commitLive(t, 0)
+	waitStatus(t, p, func(status OnlineStatus) bool { return status.Deleted > 0 })
+	p.Stop()
+
+	status := p.Status()
+	if status.Running || status.Stage != "" {
+		t.Fatalf("Pruning still running after abort: %+v", status)
+	}
+	if status.Error != errPruningAborted.Error() {
+		t.Fatalf("Abort error mismatch: have %q, want %q", status.Error, errPruningAborted)
+	}
+	if status.Deleted != 1 || status.Progress == 1 {
+		t.Fatalf("Unexpected progress after abort: deleted %d, progress %v", status.Deleted, status.Progress)
+	}
+	// Nothing live is lost by aborting, and a restart finishes the job
+	for _, root := range tester.live {
+		stateNodes(t, tester.db, root)
+	}
+	p.config.BatchDelay = 0
+	if err := p.Start(); err != nil {
+		t.Fatalf("Failed to restart pruning: %v", err)
+	}
+	tester.commitLive(t, 1)
+	status = waitStatus(t, p, func(status OnlineStatus) bool { return !status.Running })
+	if status.Error != "" {
+		t.Fatalf("Pruning failed: %v", status.Error)
+	}
+	if status.Deleted != uint64(len(tester.stale)-1) {
+		t.Errorf("Deleted node count mismatch: have %d, want %d", status.Deleted, len(tester.stale)-1)
+	}
+	tester.verify(t)
+}
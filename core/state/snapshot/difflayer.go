@@ -41,6 +41,11 @@ var (
 	// understanding all the implications.
 	aggregatorMemoryLimit = uint64(4 * 1024 * 1024)
 
+	// heldMemoryLimit is the maximum size the bottom-most diff layer may grow to
+	// while the disk layer is held. Beyond it, the hold is overridden and the
+	// layer is flushed into the disk layer anyway.
+	heldMemoryLimit = uint64(256 * 1024 * 1024)
+
 	// aggregatorItemLimit is an approximate number of items that will end up
 	// in the agregator layer before it's flushed out to disk. A plain account
 	// weighs around 14B (+hash), a storage slot 32B (+hash), a deleted slot
@@ -69,6 +69,11 @@ var (
 	snapshotBloomIndexTimer = metrics.NewRegisteredResettingTimer("state/snapshot/bloom/index", nil)
 	snapshotBloomErrorGauge = metrics.NewRegisteredGaugeFloat64("state/snapshot/bloom/error", nil)
 
+	// cleanHits and cleanMisses count the lookups served by the clean caches of
+	// the disk layers, independently of whether metrics collection is enabled.
+	cleanHits   uint64
+	cleanMisses uint64
+
 	snapshotBloomAccountTrueHitMeter  = metrics.NewRegisteredMeter("state/snapshot/bloom/account/truehit", nil)
 	snapshotBloomAccountFalseHitMeter = metrics.NewRegisteredMeter("state/snapshot/bloom/account/falsehit", nil)
 	snapshotBloomAccountMissMeter     = metrics.NewRegisteredMeter("state/snapshot/bloom/account/miss", nil)
@@ -162,6 +167,7 @@ type Tree struct {
 	triedb *trie.Database           // In-memory cache to access the trie through
 	cache  int                      // Megabytes permitted to use for read caches
 	layers map[common.Hash]snapshot // Collection of all known layers
+	held   int                      // Number of holders preventing diffs from being persisted
 	lock   sync.RWMutex
 
 	// Test hooks
@@ -482,7 +488,14 @@ func (t *Tree) cap(diff *diffLayer, layers int) *diskLayer {
 			t.onFlatten()
 		}
 		diff.parent = flattened
-		if flattened.memory < aggregatorMemoryLimit {
+		if t.held > 0 {
+			// The disk layer is held, keep accumulating unless the memory allowance
+			// is exhausted. No generator can be running, it's refused by HoldDisk.
+			if flattened.memory < heldMemoryLimit {
+				return nil
+			}
+			log.Warn("Flushing held snapshot disk layer", "memory", common.StorageSize(flattened.memory), "holders", t.held)
+		} else if flattened.memory < aggregatorMemoryLimit {
 			// Accumulator layer is smaller than the limit, so we can abort, unless
 			// there's a snapshot being generated currently. In that case, the trie
 			// will move from underneath the generator so we **must** merge all the
@@ -724,6 +737,10 @@ func (t *Tree) Rebuild(root common.Hash) {
 			panic(fmt.Sprintf("unknown layer type: %T", layer))
 		}
 	}
+	// Drop any holds on the disk layer, it's stale now and the new one needs to
+	// be generated.
+	t.held = 0
+
 	// Start generating a new snapshot from scratch on a background thread. The
 	// generator will run a wiper first if there's not one running right now.
 	log.Info("Rebuilding state snapshot")
@@ -836,6 +853,91 @@ func (t *Tree) generating() (bool, error) {
 	return layer.genMarker != nil, nil
 }
 
+// HoldDisk prevents the diff layers from being persisted into the disk layer
+// until released, keeping it usable for long running iterations. Meanwhile the
+// bottom-most diff layer keeps accumulating the flattened diffs, up to
+// heldMemoryLimit. Past that, the layer is persisted regardless and iterators
+// over the held disk layer fail with ErrSnapshotStale.
+//
+// A disk layer which is still being generated can't be held, as the generator
+// needs the diffs merged in to keep up with the trie.
+func (t *Tree) HoldDisk() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	layer := t.disklayer()
+	if layer == nil {
+		return errors.New("disk layer is missing")
+	}
+	layer.lock.RLock()
+	generating := layer.genMarker != nil
+	layer.lock.RUnlock()
+
+	if generating {
+		return ErrNotConstructed
+	}
+	t.held++
+	return nil
+}
+
+// ReleaseDisk releases a hold previously placed on the disk layer. The diffs
+// accumulated in the meantime are persisted on the next cap.
+func (t *Tree) ReleaseDisk() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.held > 0 {
+		t.held--
+	}
+}
+
+// StateDiff is the set of accounts and storage slots modified by a diff layer
+// on top of its parent.
+type StateDiff struct {
+	Root     common.Hash                   // Root hash of the diff layer
+	Parent   common.Hash                   // Root hash of the parent layer
+	Accounts []common.Hash                 // Hashes of the modified or destructed accounts
+	Storage  map[common.Hash][]common.Hash // Hashes of the modified slots, grouped by account
+}
+
+// Diffs returns the modifications made by each diff layer in the tree, in no
+// particular order.
+func (t *Tree) Diffs() []*StateDiff {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	var diffs []*StateDiff
+	for _, snap := range t.layers {
+		layer, ok := snap.(*diffLayer)
+		if !ok {
+			continue
+		}
+		layer.lock.RLock()
+		diff := &StateDiff{
+			Root:    layer.root,
+			Parent:  layer.parent.Root(),
+			Storage: make(map[common.Hash][]common.Hash),
+		}
+		for hash := range layer.destructSet {
+			if _, ok := layer.accountData[hash]; !ok {
+				diff.Accounts = append(diff.Accounts, hash)
+			}
+		}
+		for hash := range layer.accountData {
+			diff.Accounts = append(diff.Accounts, hash)
+		}
+		for hash, slots := range layer.storageData {
+			for slot := range slots {
+				diff.Storage[hash] = append(diff.Storage[hash], slot)
+			}
+		}
+		layer.lock.RUnlock()
+
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
 // diskRoot is a external helper function to return the disk layer root.
 func (t *Tree) DiskRoot() common.Hash {
 	t.lock.Lock()
@@ -843,3 +945,10 @@ func (t *Tree) DiskRoot() common.Hash {
 
 	return t.diskRoot()
 }
+
+// CleanCacheStats returns the number of account and storage lookups served by
+// the clean caches of the disk layers, and the number of lookups which had to
+// go to disk, since the process started.
+func CleanCacheStats() (hits uint64, misses uint64) {
+	return atomic.LoadUint64(&cleanHits), atomic.LoadUint64(&cleanMisses)
+}
@@ -484,3 +484,137 @@ func TestReadStateDuringFlattening(t *testing.T) {
 		t.Fatal("Unexpected blocker")
 	}
 }
+
+// Tests that a held disk layer is not modified by capping, with the diffs being
+// accumulated in the bottom-most diff layer until it's released.
+func TestHoldDiskLayer(t *testing.T) {
+	// Create an empty base layer and a snapshot tree out of it
+	base := &diskLayer{
+		diskdb: rawdb.NewMemoryDatabase(),
+		root:   common.HexToHash("0x01"),
+		cache:  fastcache.New(1024 * 500),
+	}
+	snaps := &Tree{
+		layers: map[common.Hash]snapshot{
+			base.root: base,
+		},
+	}
+	ref := snaps.Snapshot(base.root)
+
+	defer func(memcap uint64) { aggregatorMemoryLimit = memcap }(aggregatorMemoryLimit)
+	aggregatorMemoryLimit = 0
+
+	// Stack a few diffs on top of the held disk layer, capping after each
+	if err := snaps.HoldDisk(); err != nil {
+		t.Fatalf("failed to hold disk layer: %v", err)
+	}
+	parent := base.root
+	for i := 2; i < 6; i++ {
+		root := common.BigToHash(big.NewInt(int64(i)))
+		storage := randomStorageSet([]string{"0xa1"}, [][]string{{fmt.Sprintf("0x%x", i)}}, nil)
+		if err := snaps.Update(root, parent, nil, randomAccountSet("0xa1", fmt.Sprintf("0xb%x", i)), storage); err != nil {
+			t.Fatalf("failed to create diff layer %d: %v", i, err)
+		}
+		if err := snaps.Cap(root, 1); err != nil {
+			t.Fatalf("failed to cap diff layer %d: %v", i, err)
+		}
+		parent = root
+	}
+	if acc, err := ref.Account(common.HexToHash("0xa1")); err != nil || acc != nil {
+		t.Fatalf("held disk layer modified: %v (err: %v)", acc, err)
+	}
+	if root := snaps.DiskRoot(); root != base.root {
+		t.Fatalf("disk root mismatch: have %x, want %x", root, base.root)
+	}
+	// Ensure the accumulated modifications are reported by the diffs
+	var bottom *StateDiff
+	for _, diff := range snaps.Diffs() {
+		if diff.Parent == base.root {
+			bottom = diff
+		}
+	}
+	if bottom == nil {
+		t.Fatalf("bottom-most diff missing")
+	}
+	if len(bottom.Accounts) != 4 {
+		t.Errorf("accumulated account count mismatch: have %d, want %d", len(bottom.Accounts), 4)
+	}
+	if slots := bottom.Storage[common.HexToHash("0xa1")]; len(slots) != 3 {
+		t.Errorf("accumulated slot count mismatch: have %d, want %d", len(slots), 3)
+	}
+	// Release the disk layer and ensure the diffs are persisted on the next cap
+	snaps.ReleaseDisk()
+	root := common.BigToHash(big.NewInt(6))
+	if err := snaps.Update(root, parent, nil, randomAccountSet("0xa1"), nil); err != nil {
+		t.Fatalf("failed to create diff layer: %v", err)
+	}
+	if err := snaps.Cap(root, 1); err != nil {
+		t.Fatalf("failed to cap diff layer: %v", err)
+	}
+	if _, err := ref.Account(common.HexToHash("0xa1")); err != ErrSnapshotStale {
+		t.Fatalf("released disk layer not persisted: %v", err)
+	}
+}
+
+// Tests that a held disk layer is persisted regardless once the accumulated diffs
+// exceed the memory allowance of the hold.
+func TestHoldDiskLayerMemoryLimit(t *testing.T) {
+	// Create an empty base layer and a snapshot tree out of it
+	base := &diskLayer{
+		diskdb: rawdb.NewMemoryDatabase(),
+		root:   common.HexToHash("0x01"),
+		cache:  fastcache.New(1024 * 500),
+	}
+	snaps := &Tree{
+		layers: map[common.Hash]snapshot{
+			base.root: base,
+		},
+	}
+	ref := snaps.Snapshot(base.root)
+
+	defer func(memcap uint64) { aggregatorMemoryLimit = memcap }(aggregatorMemoryLimit)
+	aggregatorMemoryLimit = 0
+	defer func(memcap uint64) { heldMemoryLimit = memcap }(heldMemoryLimit)
+	heldMemoryLimit = 0
+
+	if err := snaps.HoldDisk(); err != nil {
+		t.Fatalf("failed to hold disk layer: %v", err)
+	}
+	defer snaps.ReleaseDisk()
+
+	parent := base.root
+	for i := 2; i < 4; i++ {
+		root := common.BigToHash(big.NewInt(int64(i)))
+		if err := snaps.Update(root, parent, nil, randomAccountSet("0xa1"), nil); err != nil {
+			t.Fatalf("failed to create diff layer %d: %v", i, err)
+		}
+		if err := snaps.Cap(root, 1); err != nil {
+			t.Fatalf("failed to cap diff layer %d: %v", i, err)
+		}
+		parent = root
+	}
+	if _, err := ref.Account(common.HexToHash("0xa1")); err != ErrSnapshotStale {
+		t.Fatalf("oversized held disk layer not persisted: %v", err)
+	}
+}
+
+// Tests that a disk layer under generation can't be held.
+func TestHoldDiskLayerGenerating(t *testing.T) {
+	base := &diskLayer{
+		diskdb:    rawdb.NewMemoryDatabase(),
+		root:      common.HexToHash("0x01"),
+		cache:     fastcache.New(1024 * 500),
+		genMarker: []byte{},
+	}
+	snaps := &Tree{
+		layers: map[common.Hash]snapshot{
+			base.root: base,
+		},
+	}
+	if err := snaps.HoldDisk(); err != ErrNotConstructed {
+		t.Fatalf("hold error mismatch: have %v, want %v", err, ErrNotConstructed)
+	}
+	if snaps.held != 0 {
+		t.Fatalf("refused hold recorded")
+	}
+}
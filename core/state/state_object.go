@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 var emptyCodeHash = crypto.Keccak256(nil)
@@ -389,24 +390,25 @@ func (s *stateObject) updateRoot(db Database) {
 }
 
 // CommitTrie the storage trie of the object to db.
-// This updates the trie root.
-func (s *stateObject) CommitTrie(db Database) (int, error) {
+// This updates the trie root. The committed nodes are returned in the path-based
+// trie scheme.
+func (s *stateObject) CommitTrie(db Database) (*trie.NodeSet, int, error) {
 	// If nothing changed, don't bother with hashing anything
 	if s.updateTrie(db) == nil {
-		return 0, nil
+		return nil, 0, nil
 	}
 	if s.dbErr != nil {
-		return 0, s.dbErr
+		return nil, 0, s.dbErr
 	}
 	// Track the amount of time wasted on committing the storage trie
 	if metrics.EnabledExpensive {
 		defer func(start time.Time) { s.db.StorageCommits += time.Since(start) }(time.Now())
 	}
-	root, committed, err := s.trie.Commit(nil)
+	root, nodes, committed, err := s.trie.CommitNodes(nil)
 	if err == nil {
 		s.data.Root = root
 	}
-	return committed, err
+	return nodes, committed, err
 }
 
 // AddBalance adds amount to s's balance.
@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -915,3 +916,80 @@ func TestStateDBAccessList(t *testing.T) {
 		t.Fatalf("expected empty, got %d", got)
 	}
 }
+
+// Tests that a state database opened without a config follows the scheme the
+// disk was initialised with, while configured ones, like the ephemeral databases
+// of the tracers, keep the one they ask for.
+func TestDatabaseStateScheme(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	rawdb.WriteStateScheme(db, rawdb.PathScheme)
+
+	if scheme := NewDatabaseWithConfig(db, nil).TrieDB().Scheme(); scheme != rawdb.PathScheme {
+		t.Fatalf("default scheme mismatch: have %s, want %s", scheme, rawdb.PathScheme)
+	}
+	if scheme := NewDatabaseWithConfig(db, &trie.Config{Cache: 16}).TrieDB().Scheme(); scheme != rawdb.HashScheme {
+		t.Fatalf("configured scheme mismatch: have %s, want %s", scheme, rawdb.HashScheme)
+	}
+}
+
+// Tests that concurrent state commits in the path-based scheme each seal their
+// own trie nodes, so that persisting one of the states leaves it complete.
+func TestPathSchemeConcurrentCommits(t *testing.T) {
+	sdb := NewDatabaseWithConfig(rawdb.NewMemoryDatabase(), &trie.Config{Scheme: rawdb.PathScheme})
+	base, _ := New(common.Hash{}, sdb, nil)
+	for i := byte(0); i < 16; i++ {
+		base.SetBalance(common.Address{i}, big.NewInt(1))
+		base.SetState(common.Address{i}, common.Hash{i}, common.Hash{i})
+	}
+	parent, err := base.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit base state: %v", err)
+	}
+	var (
+		roots = make([]common.Hash, 4)
+		wg    sync.WaitGroup
+	)
+	for i := range roots {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			state, _ := New(parent, sdb, nil)
+			for j := byte(0); j < 16; j++ {
+				state.SetBalance(common.Address{j}, big.NewInt(int64(i+2)))
+				state.SetState(common.Address{j}, common.Hash{j}, common.Hash{byte(i + 1), j})
+			}
+			root, err := state.Commit(false)
+			if err != nil {
+				t.Errorf("state %d: failed to commit: %v", i, err)
+			}
+			roots[i] = root
+		}(i)
+	}
+	wg.Wait()
+
+	check := func(i int) {
+		state, err := New(roots[i], sdb, nil)
+		if err != nil {
+			t.Fatalf("state %d: failed to open: %v", i, err)
+		}
+		for j := byte(0); j < 16; j++ {
+			if balance := state.GetBalance(common.Address{j}); balance.Int64() != int64(i+2) {
+				t.Fatalf("state %d: balance mismatch of account %d: have %v, want %d", i, j, balance, i+2)
+			}
+			if val := state.GetState(common.Address{j}, common.Hash{j}); val != (common.Hash{byte(i + 1), j}) {
+				t.Fatalf("state %d: slot mismatch of account %d: have %x", i, j, val)
+			}
+		}
+		if err := state.Error(); err != nil {
+			t.Fatalf("state %d: failed to read: %v", i, err)
+		}
+	}
+	for i := range roots {
+		check(i)
+	}
+	if err := sdb.TrieDB().Commit(roots[0], false, nil); err != nil {
+		t.Fatalf("failed to persist state: %v", err)
+	}
+	check(0)
+}
@@ -0,0 +1,845 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"container/heap"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// blobTxMaxSize is the maximum size a single large-payload transaction can have.
+// Transactions up to txMaxSize are handled by the legacy pool, anything above it
+// and up to this limit by the blob pool.
+const blobTxMaxSize = 8 * txMaxSize // 1MB
+
+// ErrAccountLimitExceeded is returned if a transaction would exceed the number
+// of transactions permitted for a single account.
+var ErrAccountLimitExceeded = errors.New("account limit exceeded")
+
+var (
+	blobDatasizeGauge = metrics.NewRegisteredGauge("blobpool/datasize", nil)
+	blobCountGauge    = metrics.NewRegisteredGauge("blobpool/count", nil)
+
+	blobEvictionMeter = metrics.NewRegisteredMeter("blobpool/eviction", nil) // Dropped due to the datacap
+	blobDroppedMeter  = metrics.NewRegisteredMeter("blobpool/dropped", nil)  // Dropped due to chain updates
+)
+
+// blobTxMeta is the subset of a large-payload transaction kept in memory. The
+// full transaction is only retrieved from the disk store when needed.
+type blobTxMeta struct {
+	hash  common.Hash
+	nonce uint64
+	size  uint64
+	cost  *big.Int
+
+	gasFeeCap *big.Int
+	gasTipCap *big.Int
+}
+
+// newBlobTxMeta extracts the metadata tracked in memory from a transaction.
+func newBlobTxMeta(tx *types.Transaction) *blobTxMeta {
+	return &blobTxMeta{
+		hash:      tx.Hash(),
+		nonce:     tx.Nonce(),
+		size:      uint64(tx.Size()),
+		cost:      tx.Cost(),
+		gasFeeCap: tx.GasFeeCap(),
+		gasTipCap: tx.GasTipCap(),
+	}
+}
+
+// effectiveTip returns the miner tip of the transaction given a base fee.
+func (meta *blobTxMeta) effectiveTip(baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return meta.gasTipCap
+	}
+	tip := new(big.Int).Sub(meta.gasFeeCap, baseFee)
+	if tip.Cmp(meta.gasTipCap) > 0 {
+		tip = meta.gasTipCap
+	}
+	return tip
+}
+
+// blobEvictHeap is a min-heap of accounts ordered by the lowest fee cap of their
+// transactions. Since a cheap transaction blocks all subsequent ones from being
+// included, the fee cap of the cheapest transaction defines the account's worth.
+type blobEvictHeap struct {
+	index map[common.Address][]*blobTxMeta // Reference to the pool's account index
+	addrs []common.Address                 // Heap of accounts
+	slots map[common.Address]int           // Position of each account in the heap
+}
+
+func newBlobEvictHeap(index map[common.Address][]*blobTxMeta) *blobEvictHeap {
+	return &blobEvictHeap{
+		index: index,
+		slots: make(map[common.Address]int),
+	}
+}
+
+// minFeeCap returns the lowest fee cap among the transactions of an account.
+func (h *blobEvictHeap) minFeeCap(addr common.Address) *big.Int {
+	var fee *big.Int
+	for _, meta := range h.index[addr] {
+		if fee == nil || meta.gasFeeCap.Cmp(fee) < 0 {
+			fee = meta.gasFeeCap
+		}
+	}
+	return fee
+}
+
+func (h *blobEvictHeap) Len() int { return len(h.addrs) }
+
+func (h *blobEvictHeap) Less(i, j int) bool {
+	return h.minFeeCap(h.addrs[i]).Cmp(h.minFeeCap(h.addrs[j])) < 0
+}
+
+func (h *blobEvictHeap) Swap(i, j int) {
+	h.addrs[i], h.addrs[j] = h.addrs[j], h.addrs[i]
+	h.slots[h.addrs[i]], h.slots[h.addrs[j]] = i, j
+}
+
+func (h *blobEvictHeap) Push(x interface{}) {
+	addr := x.(common.Address)
+	h.slots[addr] = len(h.addrs)
+	h.addrs = append(h.addrs, addr)
+}
+
+func (h *blobEvictHeap) Pop() interface{} {
+	addr := h.addrs[len(h.addrs)-1]
+	h.addrs = h.addrs[:len(h.addrs)-1]
+	delete(h.slots, addr)
+	return addr
+}
+
+// BlobPool is the transaction pool dedicated to large-payload transactions. As
+// these are expensive to keep in memory and to propagate, the pool keeps only a
+// small index in memory and stores the transactions themselves on disk. It has
+// its own size limits and evicts the cheapest accounts when full.
+//
+// The pool doesn't track non-executable transactions: every transaction has to
+// directly follow either the sender's state nonce or a pooled transaction.
+type BlobPool struct {
+	config      TxPoolConfig
+	chainconfig *params.ChainConfig
+	chain       blockChain
+	signer      types.Signer
+	store       ethdb.KeyValueStore // Persistent store of the pooled transactions
+
+	gasPrice *big.Int       // Minimum gas tip to enforce for acceptance into the pool
+	head     *types.Header  // Current head of the blockchain
+	state    *state.StateDB // Current state in the blockchain head
+	baseFee  *big.Int       // Base fee of the next pending block, nil before London
+	maxGas   uint64         // Current gas limit for transaction caps
+	istanbul bool           // Fork indicator whether we are in the istanbul stage.
+	eip2718  bool           // Fork indicator whether we are using EIP-2718 type transactions.
+	eip1559  bool           // Fork indicator whether we are using EIP-1559 type transactions.
+
+	index    map[common.Address][]*blobTxMeta // Pooled transactions per account, sorted by nonce
+	lookup   map[common.Hash]common.Address   // Sender of each pooled transaction
+	evict    *blobEvictHeap                   // Accounts ordered by their fee caps for eviction
+	datasize uint64                           // Total size of the pooled transactions
+	loaded   bool                             // Whether the persisted transactions were loaded
+
+	txFeed       event.Feed
+	scope        event.SubscriptionScope
+	chainHeadCh  chan ChainHeadEvent
+	chainHeadSub event.Subscription
+	wg           sync.WaitGroup
+
+	lock sync.RWMutex
+}
+
+// NewBlobPool creates a new pool for large-payload transactions, loading any
+// transactions persisted in its data directory.
+func NewBlobPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain blockChain) *BlobPool {
+	// Sanitize the input to ensure no vulnerable gas prices are set
+	config = (&config).sanitize()
+
+	pool := &BlobPool{
+		config:      config,
+		chainconfig: chainconfig,
+		chain:       chain,
+		signer:      types.LatestSigner(chainconfig),
+		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
+		index:       make(map[common.Address][]*blobTxMeta),
+		lookup:      make(map[common.Hash]common.Address),
+		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
+	}
+	pool.evict = newBlobEvictHeap(pool.index)
+
+	// Open the transaction store, falling back to memory if it's unavailable
+	if config.BlobDatadir != "" {
+		store, err := rawdb.NewLevelDBDatabase(config.BlobDatadir, 16, 16, "txpool/blob", false)
+		if err != nil {
+			log.Error("Failed to open blob pool store", "dir", config.BlobDatadir, "err", err)
+		} else {
+			pool.store = store
+		}
+	}
+	if pool.store == nil {
+		pool.store = memorydb.New()
+	}
+	if _, ok := pool.reset(chain.CurrentBlock().Header()); ok {
+		pool.load()
+	} else {
+		// Without the head state nothing can be validated. Start from an empty
+		// state and load the persisted transactions once a usable head arrives.
+		pool.state, _ = state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	}
+
+	// Subscribe events from blockchain and start the main event loop.
+	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
+	pool.wg.Add(1)
+	go pool.loop()
+
+	return pool
+}
+
+// load reads all the transactions from the disk store and indexes the ones still
+// valid against the current state, deleting the rest.
+func (pool *BlobPool) load() {
+	pool.loaded = true
+
+	var (
+		txs   = make(map[common.Address]types.Transactions)
+		drops [][]byte
+	)
+	it := pool.store.NewIterator(nil, nil)
+	for it.Next() {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(it.Value()); err != nil {
+			log.Error("Failed to decode pooled blob transaction", "hash", common.BytesToHash(it.Key()), "err", err)
+			drops = append(drops, common.CopyBytes(it.Key()))
+			continue
+		}
+		from, err := types.Sender(pool.signer, tx)
+		if err != nil {
+			drops = append(drops, common.CopyBytes(it.Key()))
+			continue
+		}
+		txs[from] = append(txs[from], tx)
+	}
+	it.Release()
+
+	for _, key := range drops {
+		pool.store.Delete(key)
+	}
+	for addr, list := range txs {
+		sort.Sort(types.TxByNonce(list))
+		for i, tx := range list {
+			// A crash during a replacement might leave both transactions behind
+			if i > 0 && tx.Nonce() == list[i-1].Nonce() {
+				pool.store.Delete(tx.Hash().Bytes())
+				continue
+			}
+			pool.index[addr] = append(pool.index[addr], newBlobTxMeta(tx))
+			pool.lookup[tx.Hash()] = addr
+			pool.datasize += uint64(tx.Size())
+		}
+		pool.recheck(addr)
+	}
+	for pool.datasize > pool.config.BlobDatacap {
+		pool.evictOne()
+	}
+	pool.updateMetrics()
+
+	if len(pool.lookup) > 0 {
+		log.Info("Loaded blob pool transactions", "count", len(pool.lookup), "size", common.StorageSize(pool.datasize))
+	}
+}
+
+// loop is the blob pool's main event loop, reacting to new chain heads.
+func (pool *BlobPool) loop() {
+	defer pool.wg.Done()
+
+	for {
+		select {
+		case ev := <-pool.chainHeadCh:
+			if ev.Block != nil {
+				var readded []*types.Transaction
+
+				pool.lock.Lock()
+				if reinject, ok := pool.reset(ev.Block.Header()); ok {
+					if !pool.loaded {
+						pool.load()
+					}
+					inserted := pool.reinject(reinject)
+					for addr := range pool.index {
+						pool.recheck(addr)
+					}
+					for pool.datasize > pool.config.BlobDatacap {
+						pool.evictOne()
+					}
+					for _, tx := range inserted {
+						if _, ok := pool.lookup[tx.Hash()]; ok {
+							readded = append(readded, tx)
+						}
+					}
+					pool.updateMetrics()
+				}
+				pool.lock.Unlock()
+
+				if len(readded) > 0 {
+					pool.txFeed.Send(NewTxsEvent{readded})
+				}
+			}
+
+		// System shutdown.
+		case <-pool.chainHeadSub.Err():
+			return
+		}
+	}
+}
+
+// reset updates the pool's view of the chain to the given head, returning the
+// large-payload transactions of the blocks dropped by a reorg. If the state of
+// the head is unavailable, the pool is left untouched and false is returned.
+func (pool *BlobPool) reset(head *types.Header) (types.Transactions, bool) {
+	statedb, err := pool.chain.StateAt(head.Root)
+	if err != nil {
+		log.Error("Failed to reset blob pool state", "err", err)
+		return nil, false
+	}
+	var reinject types.Transactions
+	if pool.head != nil && pool.head.Hash() != head.ParentHash {
+		// A failed walk only loses the dropped transactions, the pool still
+		// needs to move on to the new head.
+		txs, _ := reorgedTxs(pool.chain, pool.head, head)
+		for _, tx := range txs {
+			if pool.Filter(tx) {
+				reinject = append(reinject, tx)
+			}
+		}
+	}
+	pool.head = head
+	pool.state = statedb
+	pool.maxGas = head.GasLimit
+
+	// Update all fork indicator by next pending block number.
+	next := new(big.Int).Add(head.Number, big.NewInt(1))
+	pool.istanbul = pool.chainconfig.IsIstanbul(next)
+	pool.eip2718 = pool.chainconfig.IsBerlin(next)
+	pool.eip1559 = pool.chainconfig.IsLondon(next)
+
+	pool.baseFee = nil
+	if pool.eip1559 && (head.BaseFee != nil || !pool.chainconfig.IsLondon(head.Number)) {
+		pool.baseFee = misc.CalcBaseFee(pool.chainconfig, head)
+	}
+	return reinject, true
+}
+
+// reinject inserts transactions dropped from the chain by a reorg back into the
+// index, in nonce order with the pooled transactions of their senders. Pooled
+// transactions win over reinjected ones with the same nonce. The accounts need
+// to be rechecked afterwards, as the inserted transactions are only validated
+// statelessly. The transactions inserted are returned.
+func (pool *BlobPool) reinject(txs types.Transactions) types.Transactions {
+	var inserted types.Transactions
+	for _, tx := range txs {
+		if _, ok := pool.lookup[tx.Hash()]; ok {
+			continue
+		}
+		from, err := pool.validateTx(tx, false)
+		if err != nil {
+			continue
+		}
+		list, meta := pool.index[from], newBlobTxMeta(tx)
+		pos := sort.Search(len(list), func(i int) bool { return list[i].nonce >= meta.nonce })
+		if pos < len(list) && list[pos].nonce == meta.nonce {
+			continue
+		}
+		blob, err := tx.MarshalBinary()
+		if err != nil {
+			continue
+		}
+		if err := pool.store.Put(meta.hash.Bytes(), blob); err != nil {
+			log.Error("Failed to store blob transaction", "hash", meta.hash, "err", err)
+			continue
+		}
+		list = append(list, nil)
+		copy(list[pos+1:], list[pos:])
+		list[pos] = meta
+		pool.index[from] = list
+
+		pool.lookup[meta.hash] = from
+		pool.datasize += meta.size
+		pool.fix(from)
+
+		inserted = append(inserted, tx)
+	}
+	return inserted
+}
+
+// recheck validates the transactions of an account against the current state,
+// dropping the ones included in the chain and the ones which can't be executed
+// anymore (nonce gap or insufficient funds).
+func (pool *BlobPool) recheck(addr common.Address) {
+	list := pool.index[addr]
+	nonce := pool.state.GetNonce(addr)
+
+	// Drop all the transactions already included
+	var included int
+	for included < len(list) && list[included].nonce < nonce {
+		included++
+	}
+	if included > 0 {
+		for _, meta := range list[:included] {
+			pool.forget(meta)
+		}
+		list = append(list[:0:0], list[included:]...)
+		pool.index[addr] = list
+	}
+	// Drop everything after the first transaction which can't be executed
+	var (
+		balance = pool.state.GetBalance(addr)
+		spent   = new(big.Int)
+		keep    int
+	)
+	for keep < len(list) {
+		spent.Add(spent, list[keep].cost)
+		if list[keep].nonce != nonce+uint64(keep) || spent.Cmp(balance) > 0 {
+			break
+		}
+		keep++
+	}
+	if keep < len(list) {
+		blobDroppedMeter.Mark(int64(len(list) - keep))
+		pool.dropFrom(addr, keep)
+		return
+	}
+	pool.fix(addr)
+}
+
+// forget removes a transaction from the lookup and the disk store, without
+// touching the account index.
+func (pool *BlobPool) forget(meta *blobTxMeta) {
+	delete(pool.lookup, meta.hash)
+	pool.datasize -= meta.size
+	if err := pool.store.Delete(meta.hash.Bytes()); err != nil {
+		log.Error("Failed to delete blob pool transaction", "hash", meta.hash, "err", err)
+	}
+}
+
+// dropFrom removes all the transactions of an account starting at the given
+// position in its list.
+func (pool *BlobPool) dropFrom(addr common.Address, from int) {
+	list := pool.index[addr]
+	for _, meta := range list[from:] {
+		pool.forget(meta)
+	}
+	pool.index[addr] = list[:from]
+	pool.fix(addr)
+}
+
+// fix updates the eviction order of an account after its transactions changed,
+// removing it altogether if it doesn't have any transactions left.
+func (pool *BlobPool) fix(addr common.Address) {
+	slot, tracked := pool.evict.slots[addr]
+	switch {
+	case len(pool.index[addr]) == 0:
+		delete(pool.index, addr)
+		if tracked {
+			heap.Remove(pool.evict, slot)
+		}
+	case tracked:
+		heap.Fix(pool.evict, slot)
+	default:
+		heap.Push(pool.evict, addr)
+	}
+}
+
+// evictOne drops the last transaction of the cheapest account, returning its
+// hash.
+func (pool *BlobPool) evictOne() common.Hash {
+	addr := pool.evict.addrs[0]
+	list := pool.index[addr]
+	hash := list[len(list)-1].hash
+
+	pool.dropFrom(addr, len(list)-1)
+	blobEvictionMeter.Mark(1)
+	return hash
+}
+
+// updateMetrics reports the current size of the pool.
+func (pool *BlobPool) updateMetrics() {
+	blobDatasizeGauge.Update(int64(pool.datasize))
+	blobCountGauge.Update(int64(len(pool.lookup)))
+}
+
+// Stop terminates the blob pool.
+func (pool *BlobPool) Stop() {
+	// Unsubscribe all subscriptions registered from txpool
+	pool.scope.Close()
+
+	// Unsubscribe subscriptions registered from blockchain
+	pool.chainHeadSub.Unsubscribe()
+	pool.wg.Wait()
+
+	if err := pool.store.Close(); err != nil {
+		log.Error("Failed to close blob pool store", "err", err)
+	}
+	log.Info("Blob pool stopped")
+}
+
+// Filter returns whether the given transaction can be consumed by the blob pool,
+// namely whether it is too large to be handled by the legacy pool.
+func (pool *BlobPool) Filter(tx *types.Transaction) bool {
+	return uint64(tx.Size()) > txMaxSize
+}
+
+// HasAccount returns whether the pool tracks any transaction from the account.
+func (pool *BlobPool) HasAccount(addr common.Address) bool {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	return len(pool.index[addr]) > 0
+}
+
+// Has returns an indicator whether the pool has a transaction cached with the
+// given hash.
+func (pool *BlobPool) Has(hash common.Hash) bool {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	_, ok := pool.lookup[hash]
+	return ok
+}
+
+// Get returns a transaction if it is contained in the pool and nil otherwise.
+func (pool *BlobPool) Get(hash common.Hash) *types.Transaction {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	if _, ok := pool.lookup[hash]; !ok {
+		return nil
+	}
+	return pool.read(hash)
+}
+
+// read retrieves a transaction from the disk store.
+func (pool *BlobPool) read(hash common.Hash) *types.Transaction {
+	blob, err := pool.store.Get(hash.Bytes())
+	if err != nil {
+		log.Error("Blob pool transaction missing from store", "hash", hash, "err", err)
+		return nil
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(blob); err != nil {
+		log.Error("Failed to decode blob pool transaction", "hash", hash, "err", err)
+		return nil
+	}
+	return tx
+}
+
+// readAll retrieves a list of transactions from the disk store, stopping at the
+// first one that can't be read.
+func (pool *BlobPool) readAll(list []*blobTxMeta) types.Transactions {
+	txs := make(types.Transactions, 0, len(list))
+	for _, meta := range list {
+		tx := pool.read(meta.hash)
+		if tx == nil {
+			break
+		}
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+// Add enqueues a batch of transactions into the pool if they are valid. Since
+// the pool doesn't reorganize asynchronously, sync has no effect.
+func (pool *BlobPool) Add(txs []*types.Transaction, local bool, sync bool) []error {
+	var (
+		errs = make([]error, len(txs))
+		adds = make([]*types.Transaction, 0, len(txs))
+	)
+	pool.lock.Lock()
+	for i, tx := range txs {
+		if errs[i] = pool.add(tx, local); errs[i] == nil {
+			adds = append(adds, tx)
+		}
+	}
+	pool.updateMetrics()
+	pool.lock.Unlock()
+
+	if len(adds) > 0 {
+		pool.txFeed.Send(NewTxsEvent{adds})
+	}
+	return errs
+}
+
+// add validates a transaction and inserts it into the pool, replacing any known
+// transaction with the same nonce if the new one is sufficiently more expensive.
+func (pool *BlobPool) add(tx *types.Transaction, local bool) error {
+	hash := tx.Hash()
+	if _, ok := pool.lookup[hash]; ok {
+		log.Trace("Discarding already known blob transaction", "hash", hash)
+		return ErrAlreadyKnown
+	}
+	from, err := pool.validateTx(tx, local)
+	if err != nil {
+		log.Trace("Discarding invalid blob transaction", "hash", hash, "err", err)
+		return err
+	}
+	var (
+		list  = pool.index[from]
+		first = pool.state.GetNonce(from)
+		next  = first + uint64(len(list))
+		meta  = newBlobTxMeta(tx)
+	)
+	if tx.Nonce() > next {
+		return ErrNonceTooHigh
+	}
+	// Make sure the sender can pay for all its transactions, including the new one
+	var (
+		offset = int(tx.Nonce() - first)
+		spent  = new(big.Int).Set(meta.cost)
+	)
+	for i, old := range list {
+		if i != offset {
+			spent.Add(spent, old.cost)
+		}
+	}
+	if pool.state.GetBalance(from).Cmp(spent) < 0 {
+		return ErrInsufficientFunds
+	}
+	if offset < len(list) {
+		// Replacing an existing transaction, ensure the price bump is met
+		old := list[offset]
+		if meta.gasFeeCap.Cmp(old.gasFeeCap) <= 0 || meta.gasTipCap.Cmp(old.gasTipCap) <= 0 {
+			return ErrReplaceUnderpriced
+		}
+		feeCap := new(big.Int).Mul(old.gasFeeCap, big.NewInt(100+int64(pool.config.PriceBump)))
+		feeCap.Div(feeCap, big.NewInt(100))
+		tipCap := new(big.Int).Mul(old.gasTipCap, big.NewInt(100+int64(pool.config.PriceBump)))
+		tipCap.Div(tipCap, big.NewInt(100))
+
+		if meta.gasFeeCap.Cmp(feeCap) < 0 || meta.gasTipCap.Cmp(tipCap) < 0 {
+			return ErrReplaceUnderpriced
+		}
+	} else if uint64(len(list)) >= pool.config.BlobAccountSlots {
+		return ErrAccountLimitExceeded
+	}
+	// Transaction accepted, persist it and update the index
+	blob, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := pool.store.Put(hash.Bytes(), blob); err != nil {
+		log.Error("Failed to store blob transaction", "hash", hash, "err", err)
+		return err
+	}
+	// Swap the replaced transaction out of the index, but keep it in the store
+	// until the new one is known to survive the eviction below.
+	var replaced *blobTxMeta
+	if offset < len(list) {
+		replaced = list[offset]
+		delete(pool.lookup, replaced.hash)
+		pool.datasize -= replaced.size
+		list[offset] = meta
+	} else {
+		pool.index[from] = append(list, meta)
+	}
+	pool.lookup[hash] = from
+	pool.datasize += meta.size
+	pool.fix(from)
+
+	// If the pool is over its capacity, evict the cheapest transactions. If the
+	// new transaction itself gets evicted, it was not good enough to be pooled
+	// and the transaction it replaced, if any, is restored.
+	for pool.datasize > pool.config.BlobDatacap {
+		if pool.evictOne() == hash {
+			if replaced != nil {
+				pool.index[from] = append(pool.index[from], replaced)
+				pool.lookup[replaced.hash] = from
+				pool.datasize += replaced.size
+				pool.fix(from)
+			}
+			return ErrUnderpriced
+		}
+	}
+	if replaced != nil {
+		if err := pool.store.Delete(replaced.hash.Bytes()); err != nil {
+			log.Error("Failed to delete blob pool transaction", "hash", replaced.hash, "err", err)
+		}
+	}
+	return nil
+}
+
+// validateTx checks whether a transaction is valid according to the consensus
+// rules and adheres to the limits of the blob pool.
+func (pool *BlobPool) validateTx(tx *types.Transaction, local bool) (common.Address, error) {
+	opts := &txValidationOptions{
+		eip2718: pool.eip2718,
+		eip1559: pool.eip1559,
+		maxSize: blobTxMaxSize,
+		maxGas:  pool.maxGas,
+		minTip:  pool.gasPrice,
+		baseFee: pool.baseFee,
+	}
+	from, err := validateTxBasics(tx, pool.signer, opts, local)
+	if err != nil {
+		return common.Address{}, err
+	}
+	// Ensure the transaction adheres to nonce ordering
+	if pool.state.GetNonce(from) > tx.Nonce() {
+		return common.Address{}, ErrNonceTooLow
+	}
+	// Ensure the transaction has more gas than the basic tx fee.
+	intrGas, err := IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, true, pool.istanbul)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if tx.Gas() < intrGas {
+		return common.Address{}, ErrIntrinsicGas
+	}
+	return from, nil
+}
+
+// Pending retrieves all the transactions in the pool, grouped by origin account
+// and sorted by nonce.
+//
+// The enforceTips parameter can be used to do an extra filtering on the pending
+// transactions and only return those whose **effective** tip is large enough in
+// the next pending execution environment.
+func (pool *BlobPool) Pending(enforceTips bool) map[common.Address]types.Transactions {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	pending := make(map[common.Address]types.Transactions)
+	for addr, list := range pool.index {
+		if enforceTips {
+			for i, meta := range list {
+				if meta.effectiveTip(pool.baseFee).Cmp(pool.gasPrice) < 0 {
+					list = list[:i]
+					break
+				}
+			}
+		}
+		if txs := pool.readAll(list); len(txs) > 0 {
+			pending[addr] = txs
+		}
+	}
+	return pending
+}
+
+// SubscribeNewTxsEvent registers a subscription of NewTxsEvent and starts
+// sending event to the given channel.
+func (pool *BlobPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscription {
+	return pool.scope.Track(pool.txFeed.Subscribe(ch))
+}
+
+// Nonce returns the next nonce of an account, with all the pooled transactions
+// already applied on top.
+func (pool *BlobPool) Nonce(addr common.Address) uint64 {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	return pool.state.GetNonce(addr) + uint64(len(pool.index[addr]))
+}
+
+// Stats retrieves the current pool stats. As the blob pool doesn't track non-
+// executable transactions, all of them are reported as pending.
+func (pool *BlobPool) Stats() (int, int) {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	return len(pool.lookup), 0
+}
+
+// Content retrieves the data content of the pool, returning all the transactions
+// grouped by account and sorted by nonce.
+func (pool *BlobPool) Content() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
+	return pool.Pending(false), make(map[common.Address]types.Transactions)
+}
+
+// ContentFrom retrieves the transactions of the given account, sorted by nonce.
+func (pool *BlobPool) ContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	if list := pool.index[addr]; len(list) > 0 {
+		return pool.readAll(list), nil
+	}
+	return nil, nil
+}
+
+// Locals retrieves the accounts currently considered local by the pool. Local
+// transactions are exempt from the minimum price when added, but aren't tracked
+// afterwards, so this is always empty.
+func (pool *BlobPool) Locals() []common.Address {
+	return nil
+}
+
+// Status returns the status (unknown/pending) of a batch of transactions
+// identified by their hashes.
+func (pool *BlobPool) Status(hashes []common.Hash) []TxStatus {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	status := make([]TxStatus, len(hashes))
+	for i, hash := range hashes {
+		if _, ok := pool.lookup[hash]; ok {
+			status[i] = TxStatusPending
+		}
+	}
+	return status
+}
+
+// GasPrice returns the current gas price enforced by the pool.
+func (pool *BlobPool) GasPrice() *big.Int {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	return new(big.Int).Set(pool.gasPrice)
+}
+
+// SetGasPrice updates the minimum price required by the pool for a new
+// transaction, and drops all transactions below this threshold.
+func (pool *BlobPool) SetGasPrice(price *big.Int) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	old := pool.gasPrice
+	pool.gasPrice = price
+	if price.Cmp(old) > 0 {
+		for addr, list := range pool.index {
+			for i, meta := range list {
+				if meta.gasTipCap.Cmp(price) < 0 {
+					pool.dropFrom(addr, i)
+					break
+				}
+			}
+		}
+		pool.updateMetrics()
+	}
+	log.Info("Blob pool price threshold updated", "price", price)
+}
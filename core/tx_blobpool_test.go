@@ -0,0 +1,461 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// blobTxSize is the payload size of the large transactions used in the tests,
+// making them too big for the legacy pool.
+const blobTxSize = 200 * 1024
+
+// blobTxGas is the gas limit used by the large transactions in the tests.
+const blobTxGas = 4000000
+
+// setupBlobPool creates a blob pool with the given data directory and capacity
+// on top of an empty state.
+func setupBlobPool(datadir string, datacap uint64) (*BlobPool, *testBlockChain) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{10000000, statedb, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.BlobDatadir = datadir
+	config.BlobDatacap = datacap
+
+	return NewBlobPool(config, params.TestChainConfig, blockchain), blockchain
+}
+
+// fundedKey generates a new account and credits it with enough funds to pay for
+// a number of large transactions.
+func fundedKey(statedb *state.StateDB) *ecdsa.PrivateKey {
+	key, _ := crypto.GenerateKey()
+	statedb.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000000))
+	return key
+}
+
+// Tests that large transactions are accepted by the blob pool, ordered by nonce
+// and that invalid ones are rejected.
+func TestBlobPoolAdd(t *testing.T) {
+	t.Parallel()
+
+	pool, blockchain := setupBlobPool("", DefaultTxPoolConfig.BlobDatacap)
+	defer pool.Stop()
+
+	key := fundedKey(blockchain.statedb)
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	if pool.Filter(pricedTransaction(0, 100000, big.NewInt(1), key)) {
+		t.Fatalf("small transaction accepted by blob pool filter")
+	}
+	tx0 := pricedDataTransaction(0, blobTxGas, big.NewInt(1), key, blobTxSize)
+	if !pool.Filter(tx0) {
+		t.Fatalf("large transaction rejected by blob pool filter")
+	}
+	tests := []struct {
+		tx  *types.Transaction
+		err error
+	}{
+		{tx0, nil},
+		{tx0, ErrAlreadyKnown},
+		{pricedDataTransaction(2, blobTxGas, big.NewInt(1), key, blobTxSize), ErrNonceTooHigh},
+		{pricedDataTransaction(1, blobTxGas, big.NewInt(1), key, blobTxSize), nil},
+		{pricedDataTransaction(1, blobTxGas, big.NewInt(1), key, blobTxSize), ErrReplaceUnderpriced},
+		{pricedDataTransaction(1, blobTxGas, big.NewInt(2), key, blobTxSize), nil},
+		{pricedDataTransaction(2, blobTxGas, big.NewInt(1), key, blobTxMaxSize), ErrOversizedData},
+		{pricedDataTransaction(2, 21000, big.NewInt(1), key, blobTxSize), ErrIntrinsicGas},
+	}
+	for i, tt := range tests {
+		if err := pool.Add([]*types.Transaction{tt.tx}, false, true)[0]; err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatch: have %d, want %d", pending, 2)
+	}
+	if nonce := pool.Nonce(addr); nonce != 2 {
+		t.Fatalf("nonce mismatch: have %d, want %d", nonce, 2)
+	}
+	pending := pool.Pending(false)[addr]
+	if len(pending) != 2 || pending[0].Hash() != tx0.Hash() || pending[1].GasPrice().Cmp(big.NewInt(2)) != 0 {
+		t.Fatalf("pending transactions mismatch: %v", pending)
+	}
+	if tx := pool.Get(tx0.Hash()); tx == nil || tx.Hash() != tx0.Hash() {
+		t.Fatalf("failed to retrieve pooled transaction")
+	}
+}
+
+// Tests that the blob pool evicts the cheapest transactions when going over its
+// data capacity.
+func TestBlobPoolEviction(t *testing.T) {
+	t.Parallel()
+
+	// Create a pool which fits 5 transactions at most
+	pool, blockchain := setupBlobPool("", 5*blobTxSize+blobTxSize/2)
+	defer pool.Stop()
+
+	txs := make([]*types.Transaction, 5)
+	for i := range txs {
+		txs[i] = pricedDataTransaction(0, blobTxGas, big.NewInt(int64(i+2)), fundedKey(blockchain.statedb), blobTxSize)
+	}
+	for i, err := range pool.Add(txs, false, true) {
+		if err != nil {
+			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	// Adding a cheaper transaction should be rejected, a more expensive one
+	// should evict the cheapest account
+	cheap := pricedDataTransaction(0, blobTxGas, big.NewInt(1), fundedKey(blockchain.statedb), blobTxSize)
+	if err := pool.Add([]*types.Transaction{cheap}, false, true)[0]; err != ErrUnderpriced {
+		t.Fatalf("cheap transaction error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	pricey := pricedDataTransaction(0, blobTxGas, big.NewInt(10), fundedKey(blockchain.statedb), blobTxSize)
+	if err := pool.Add([]*types.Transaction{pricey}, false, true)[0]; err != nil {
+		t.Fatalf("failed to add expensive transaction: %v", err)
+	}
+	if pool.Has(txs[0].Hash()) || pool.Has(cheap.Hash()) {
+		t.Fatalf("cheapest transaction not evicted")
+	}
+	for i, tx := range append(txs[1:], pricey) {
+		if !pool.Has(tx.Hash()) {
+			t.Fatalf("tx %d: transaction missing from pool", i)
+		}
+	}
+	if pool.datasize > pool.config.BlobDatacap {
+		t.Fatalf("pool over capacity: have %d, cap %d", pool.datasize, pool.config.BlobDatacap)
+	}
+}
+
+// Tests that the blob pool reloads its transactions from disk on restart, and
+// drops the ones which became stale in the meantime.
+func TestBlobPoolPersistence(t *testing.T) {
+	t.Parallel()
+
+	datadir, err := ioutil.TempDir("", "blobpool-")
+	if err != nil {
+		t.Fatalf("failed to create temporary datadir: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	pool, blockchain := setupBlobPool(datadir, DefaultTxPoolConfig.BlobDatacap)
+
+	key := fundedKey(blockchain.statedb)
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	txs := make([]*types.Transaction, 3)
+	for i := range txs {
+		txs[i] = pricedDataTransaction(uint64(i), blobTxGas, big.NewInt(1), key, blobTxSize)
+	}
+	for i, err := range pool.Add(txs, false, true) {
+		if err != nil {
+			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	pool.Stop()
+
+	// Include the first transaction and restart the pool
+	blockchain.statedb.SetNonce(addr, 1)
+
+	config := testTxPoolConfig
+	config.BlobDatadir = datadir
+	pool = NewBlobPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	if pool.Has(txs[0].Hash()) {
+		t.Fatalf("included transaction reloaded")
+	}
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatch: have %d, want %d", pending, 2)
+	}
+	if nonce := pool.Nonce(addr); nonce != 3 {
+		t.Fatalf("nonce mismatch: have %d, want %d", nonce, 3)
+	}
+}
+
+// Tests that the transaction pool dispatches transactions to the right subpool
+// and that accounts are not split between subpools.
+func TestTxPoolSubpoolDispatch(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{10000000, statedb, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.BlobDatadir = ""
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	var (
+		small = fundedKey(statedb)
+		large = fundedKey(statedb)
+	)
+	txs := []*types.Transaction{
+		pricedTransaction(0, 100000, big.NewInt(1), small),
+		pricedDataTransaction(0, blobTxGas, big.NewInt(1), large, blobTxSize),
+		pricedDataTransaction(1, blobTxGas, big.NewInt(1), small, blobTxSize),
+		pricedTransaction(1, 100000, big.NewInt(1), large),
+	}
+	errs := pool.AddRemotesSync(txs)
+	for i, want := range []error{nil, nil, ErrAccountReserved, ErrAccountReserved} {
+		if errs[i] != want {
+			t.Errorf("tx %d: error mismatch: have %v, want %v", i, errs[i], want)
+		}
+	}
+	pending := pool.Pending(false)
+	if len(pending) != 2 {
+		t.Fatalf("pending accounts mismatch: have %d, want %d", len(pending), 2)
+	}
+	for i, tx := range txs[:2] {
+		if got := pool.Get(tx.Hash()); got == nil {
+			t.Errorf("tx %d: transaction missing from pool", i)
+		}
+	}
+	if status := pool.Status([]common.Hash{txs[0].Hash(), txs[1].Hash(), txs[2].Hash()}); status[0] != TxStatusPending || status[1] != TxStatusPending || status[2] != TxStatusUnknown {
+		t.Fatalf("status mismatch: %v", status)
+	}
+	if nonce := pool.Nonce(crypto.PubkeyToAddress(large.PublicKey)); nonce != 1 {
+		t.Fatalf("nonce mismatch: have %d, want %d", nonce, 1)
+	}
+}
+
+// Tests that a replacement evicted right away due to the data capacity doesn't
+// take the transaction it replaced with it.
+func TestBlobPoolReplacementEviction(t *testing.T) {
+	t.Parallel()
+
+	// Create a pool which fits 5 regular transactions at most
+	pool, blockchain := setupBlobPool("", 5*blobTxSize+blobTxSize/2)
+	defer pool.Stop()
+
+	cheap := fundedKey(blockchain.statedb)
+	old := pricedDataTransaction(0, blobTxGas, big.NewInt(2), cheap, blobTxSize)
+
+	txs := []*types.Transaction{old}
+	for i := 0; i < 4; i++ {
+		txs = append(txs, pricedDataTransaction(0, blobTxGas, big.NewInt(int64(10+i)), fundedKey(blockchain.statedb), blobTxSize))
+	}
+	for i, err := range pool.Add(txs, false, true) {
+		if err != nil {
+			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	// Replace the cheapest transaction with a larger one, overflowing the pool
+	// and getting evicted itself
+	replacement := pricedDataTransaction(0, 2*blobTxGas, big.NewInt(3), cheap, 2*blobTxSize)
+	if err := pool.Add([]*types.Transaction{replacement}, false, true)[0]; err != ErrUnderpriced {
+		t.Fatalf("replacement error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	if pool.Has(replacement.Hash()) {
+		t.Fatalf("evicted replacement still pooled")
+	}
+	if tx := pool.Get(old.Hash()); tx == nil || tx.Hash() != old.Hash() {
+		t.Fatalf("replaced transaction not restored")
+	}
+	if nonce := pool.Nonce(crypto.PubkeyToAddress(cheap.PublicKey)); nonce != 1 {
+		t.Fatalf("nonce mismatch: have %d, want %d", nonce, 1)
+	}
+	var want uint64
+	for _, tx := range txs {
+		want += uint64(tx.Size())
+	}
+	if pool.datasize != want {
+		t.Fatalf("datasize mismatch: have %d, want %d", pool.datasize, want)
+	}
+}
+
+// blobTestChain is a blockchain for the blob pool tests, serving a fixed set of
+// blocks and a state per state root.
+type blobTestChain struct {
+	*testBlockChain
+
+	lock   sync.Mutex
+	head   *types.Block
+	blocks map[common.Hash]*types.Block
+	states map[common.Hash]*state.StateDB
+}
+
+func newBlobTestChain(genesis *types.Block, statedb *state.StateDB) *blobTestChain {
+	return &blobTestChain{
+		testBlockChain: &testBlockChain{10000000, statedb, new(event.Feed)},
+		head:           genesis,
+		blocks:         map[common.Hash]*types.Block{genesis.Hash(): genesis},
+		states:         map[common.Hash]*state.StateDB{genesis.Root(): statedb},
+	}
+}
+
+func (bc *blobTestChain) CurrentBlock() *types.Block {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	return bc.head
+}
+
+func (bc *blobTestChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	return bc.blocks[hash]
+}
+
+func (bc *blobTestChain) StateAt(root common.Hash) (*state.StateDB, error) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	if statedb := bc.states[root]; statedb != nil {
+		return statedb, nil
+	}
+	return nil, errors.New("state unavailable")
+}
+
+// setHead adds a block on top of parent with the given state and announces it
+// as the new head.
+func (bc *blobTestChain) setHead(parent *types.Block, root common.Hash, statedb *state.StateDB, txs types.Transactions) *types.Block {
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   parent.GasLimit(),
+		Root:       root,
+	}
+	block := types.NewBlock(header, txs, nil, nil, trie.NewStackTrie(nil))
+
+	bc.lock.Lock()
+	bc.head = block
+	bc.blocks[block.Hash()] = block
+	if statedb != nil {
+		bc.states[root] = statedb
+	}
+	bc.lock.Unlock()
+
+	bc.chainHeadFeed.Send(ChainHeadEvent{Block: block})
+	return block
+}
+
+// waitBlobPool waits until the blob pool satisfies a condition, failing the test
+// if it doesn't in time.
+func waitBlobPool(t *testing.T, pool *BlobPool, cond func() bool) {
+	t.Helper()
+
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		pool.lock.RLock()
+		done := cond()
+		pool.lock.RUnlock()
+
+		if done {
+			return
+		}
+	}
+	t.Fatalf("blob pool condition not met in time")
+}
+
+// Tests that the blob pool copes with the state of the head being unavailable,
+// keeping its persisted transactions until a usable head arrives.
+func TestBlobPoolStateUnavailable(t *testing.T) {
+	t.Parallel()
+
+	datadir, err := ioutil.TempDir("", "blobpool-")
+	if err != nil {
+		t.Fatalf("failed to create temporary datadir: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	pool, blockchain := setupBlobPool(datadir, DefaultTxPoolConfig.BlobDatacap)
+
+	key := fundedKey(blockchain.statedb)
+	tx := pricedDataTransaction(0, blobTxGas, big.NewInt(1), key, blobTxSize)
+	if err := pool.Add([]*types.Transaction{tx}, false, true)[0]; err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	pool.Stop()
+
+	// Restart the pool on top of a head without state
+	genesis := types.NewBlock(&types.Header{Number: common.Big0, GasLimit: 10000000, Root: common.Hash{0x01}}, nil, nil, nil, trie.NewStackTrie(nil))
+	chain := newBlobTestChain(genesis, blockchain.statedb)
+	delete(chain.states, genesis.Root())
+
+	config := testTxPoolConfig
+	config.BlobDatadir = datadir
+	pool = NewBlobPool(config, params.TestChainConfig, chain)
+	defer pool.Stop()
+
+	if pool.Has(tx.Hash()) {
+		t.Fatalf("transaction loaded without state")
+	}
+	if err := pool.Add([]*types.Transaction{tx}, false, true)[0]; err == nil {
+		t.Fatalf("transaction accepted without state")
+	}
+	// Announce a head with state and ensure the persisted transaction is loaded
+	chain.setHead(genesis, common.Hash{0x02}, blockchain.statedb, nil)
+	waitBlobPool(t, pool, func() bool { return pool.lookup[tx.Hash()] != (common.Address{}) })
+}
+
+// Tests that large transactions dropped from the chain by a reorg are put back
+// into the blob pool.
+func TestBlobPoolReorg(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	key := fundedKey(statedb)
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	genesis := types.NewBlock(&types.Header{Number: common.Big0, GasLimit: 10000000, Root: common.Hash{0x01}}, nil, nil, nil, trie.NewStackTrie(nil))
+	chain := newBlobTestChain(genesis, statedb)
+
+	config := testTxPoolConfig
+	config.BlobDatadir = ""
+	pool := NewBlobPool(config, params.TestChainConfig, chain)
+	defer pool.Stop()
+
+	txs := []*types.Transaction{
+		pricedDataTransaction(0, blobTxGas, big.NewInt(1), key, blobTxSize),
+		pricedDataTransaction(1, blobTxGas, big.NewInt(1), key, blobTxSize),
+	}
+	for i, err := range pool.Add(txs, false, true) {
+		if err != nil {
+			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	// Include the first transaction in a block
+	included := statedb.Copy()
+	included.SetNonce(addr, 1)
+	chain.setHead(genesis, common.Hash{0x02}, included, types.Transactions{txs[0]})
+	waitBlobPool(t, pool, func() bool { return len(pool.lookup) == 1 })
+
+	// Reorg the block out and ensure the transaction returns to the pool
+	chain.setHead(genesis, common.Hash{0x03}, statedb.Copy(), nil)
+	waitBlobPool(t, pool, func() bool { return len(pool.lookup) == 2 })
+
+	pending := pool.Pending(false)[addr]
+	if len(pending) != 2 || pending[0].Hash() != txs[0].Hash() || pending[1].Hash() != txs[1].Hash() {
+		t.Fatalf("pending transactions mismatch: %v", pending)
+	}
+}
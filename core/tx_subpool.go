@@ -0,0 +1,548 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ErrAccountReserved is returned if a transaction is attempted to be added into
+// a subpool while its sender has transactions tracked by a different subpool.
+var ErrAccountReserved = errors.New("account reserved by another subpool")
+
+// SubPool represents a specialized transaction pool that lives on its own (e.g.
+// the pool of large-payload transactions). Independent of how many specialized
+// pools there are, they need to be updated in lockstep and assembled into one
+// coherent view for block production. This interface defines the methods that
+// allow the TxPool to manage its subpools.
+//
+// An account may only have transactions in a single subpool at any one time, so
+// that nonces are never split across pools.
+type SubPool interface {
+	// Filter is a selector used to decide whether a transaction would be added
+	// to this particular subpool.
+	Filter(tx *types.Transaction) bool
+
+	// HasAccount returns whether the subpool tracks any transaction from the
+	// given account.
+	HasAccount(addr common.Address) bool
+
+	// Has returns an indicator whether the subpool has a transaction cached with
+	// the given hash.
+	Has(hash common.Hash) bool
+
+	// Get returns a transaction if it is contained in the subpool and nil otherwise.
+	Get(hash common.Hash) *types.Transaction
+
+	// Add enqueues a batch of transactions into the subpool if they are valid. If
+	// sync is set, the method only returns after the subpool internals have been
+	// updated and the related events fired.
+	Add(txs []*types.Transaction, local bool, sync bool) []error
+
+	// Pending retrieves all currently processable transactions, grouped by origin
+	// account and sorted by nonce.
+	Pending(enforceTips bool) map[common.Address]types.Transactions
+
+	// SubscribeNewTxsEvent subscribes to new transaction events.
+	SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscription
+
+	// Nonce returns the next nonce of an account, with all transactions executable
+	// by the subpool already applied on top.
+	Nonce(addr common.Address) uint64
+
+	// Stats retrieves the current subpool stats, namely the number of pending and
+	// the number of queued (non-executable) transactions.
+	Stats() (int, int)
+
+	// Content retrieves the data content of the subpool, returning all the pending
+	// as well as queued transactions, grouped by account and sorted by nonce.
+	Content() (map[common.Address]types.Transactions, map[common.Address]types.Transactions)
+
+	// ContentFrom retrieves the data content of the subpool, returning the pending
+	// as well as queued transactions of this address, grouped by nonce.
+	ContentFrom(addr common.Address) (types.Transactions, types.Transactions)
+
+	// Locals retrieves the accounts currently considered local by the subpool.
+	Locals() []common.Address
+
+	// Status returns the known status (unknown/pending/queued) of a batch of
+	// transactions identified by their hashes.
+	Status(hashes []common.Hash) []TxStatus
+
+	// GasPrice returns the current gas price enforced by the subpool.
+	GasPrice() *big.Int
+
+	// SetGasPrice updates the minimum price required by the subpool for a new
+	// transaction, dropping the ones below this threshold.
+	SetGasPrice(price *big.Int)
+
+	// Stop terminates the subpool.
+	Stop()
+}
+
+// TxPool is an aggregator for various transaction specific pools, collectively
+// tracking all the transactions deemed interesting by the node. Transactions
+// enter the pool when they are received from the network or submitted locally.
+// They exit the pool when they are included in the blockchain or evicted due to
+// resource constraints.
+type TxPool struct {
+	subpools []SubPool
+	signer   types.Signer
+
+	lock sync.Mutex // Serializes the account reservation checks with insertions
+}
+
+// NewTxPool creates a new transaction pool to gather, sort and filter inbound
+// transactions from the network. Regular transactions are kept in the legacy
+// pool, large-payload ones in a separate, disk backed blob pool.
+func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain blockChain) *TxPool {
+	return NewTxPoolWithSubpools(chainconfig,
+		NewLegacyPool(config, chainconfig, chain),
+		NewBlobPool(config, chainconfig, chain),
+	)
+}
+
+// NewTxPoolWithSubpools creates a transaction pool aggregating the given
+// subpools. Transactions are dispatched to the first subpool accepting them.
+func NewTxPoolWithSubpools(chainconfig *params.ChainConfig, subpools ...SubPool) *TxPool {
+	return &TxPool{
+		subpools: subpools,
+		signer:   types.LatestSigner(chainconfig),
+	}
+}
+
+// Stop terminates the transaction pool and all its subpools.
+func (p *TxPool) Stop() {
+	for _, subpool := range p.subpools {
+		subpool.Stop()
+	}
+}
+
+// SubscribeNewTxsEvent registers a subscription of NewTxsEvent and starts
+// sending the events of all subpools to the given channel.
+func (p *TxPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscription {
+	subs := make([]event.Subscription, len(p.subpools))
+	for i, subpool := range p.subpools {
+		subs[i] = subpool.SubscribeNewTxsEvent(ch)
+	}
+	return joinSubscriptions(subs)
+}
+
+// GasPrice returns the current gas price enforced by the transaction pool.
+func (p *TxPool) GasPrice() *big.Int {
+	return p.subpools[0].GasPrice()
+}
+
+// SetGasPrice updates the minimum price required by the subpools for a new
+// transaction, and drops all transactions below this threshold.
+func (p *TxPool) SetGasPrice(price *big.Int) {
+	for _, subpool := range p.subpools {
+		subpool.SetGasPrice(price)
+	}
+}
+
+// Nonce returns the next nonce of an account, with all transactions executable
+// by the pool already applied on top.
+func (p *TxPool) Nonce(addr common.Address) uint64 {
+	// Since an account is only ever tracked by one subpool, the highest nonce
+	// is the one of the subpool tracking it (or the state nonce otherwise).
+	var nonce uint64
+	for _, subpool := range p.subpools {
+		if next := subpool.Nonce(addr); nonce < next {
+			nonce = next
+		}
+	}
+	return nonce
+}
+
+// Stats retrieves the current pool stats, namely the number of pending and the
+// number of queued (non-executable) transactions.
+func (p *TxPool) Stats() (int, int) {
+	var pending, queued int
+	for _, subpool := range p.subpools {
+		subpending, subqueued := subpool.Stats()
+		pending += subpending
+		queued += subqueued
+	}
+	return pending, queued
+}
+
+// Content retrieves the data content of the transaction pool, returning all the
+// pending as well as queued transactions, grouped by account and sorted by nonce.
+func (p *TxPool) Content() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
+	var (
+		pending = make(map[common.Address]types.Transactions)
+		queued  = make(map[common.Address]types.Transactions)
+	)
+	for _, subpool := range p.subpools {
+		subpending, subqueued := subpool.Content()
+		for addr, txs := range subpending {
+			pending[addr] = txs
+		}
+		for addr, txs := range subqueued {
+			queued[addr] = txs
+		}
+	}
+	return pending, queued
+}
+
+// ContentFrom retrieves the data content of the transaction pool, returning the
+// pending as well as queued transactions of this address, grouped by nonce.
+func (p *TxPool) ContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	for _, subpool := range p.subpools {
+		pending, queued := subpool.ContentFrom(addr)
+		if len(pending) > 0 || len(queued) > 0 {
+			return pending, queued
+		}
+	}
+	return nil, nil
+}
+
+// Pending retrieves all currently processable transactions of all subpools,
+// grouped by origin account and sorted by nonce. The returned transaction set
+// is a copy and can be freely modified by calling code.
+func (p *TxPool) Pending(enforceTips bool) map[common.Address]types.Transactions {
+	pending := make(map[common.Address]types.Transactions)
+	for _, subpool := range p.subpools {
+		for addr, txs := range subpool.Pending(enforceTips) {
+			pending[addr] = txs
+		}
+	}
+	return pending
+}
+
+// Locals retrieves the accounts currently considered local by the pool.
+func (p *TxPool) Locals() []common.Address {
+	var (
+		locals []common.Address
+		seen   = make(map[common.Address]struct{})
+	)
+	for _, subpool := range p.subpools {
+		for _, addr := range subpool.Locals() {
+			if _, ok := seen[addr]; !ok {
+				seen[addr] = struct{}{}
+				locals = append(locals, addr)
+			}
+		}
+	}
+	return locals
+}
+
+// AddLocals enqueues a batch of transactions into the pool if they are valid, marking the
+// senders as a local ones, ensuring they go around the local pricing constraints.
+//
+// This method is used to add transactions from the RPC API and performs synchronous pool
+// reorganization and event propagation.
+func (p *TxPool) AddLocals(txs []*types.Transaction) []error {
+	return p.add(txs, true, true)
+}
+
+// AddLocal enqueues a single local transaction into the pool if it is valid. This is
+// a convenience wrapper around AddLocals.
+func (p *TxPool) AddLocal(tx *types.Transaction) error {
+	errs := p.AddLocals([]*types.Transaction{tx})
+	return errs[0]
+}
+
+// AddRemotes enqueues a batch of transactions into the pool if they are valid. If the
+// senders are not among the locally tracked ones, full pricing constraints will apply.
+//
+// This method is used to add transactions from the p2p network and does not wait for pool
+// reorganization and internal event propagation.
+func (p *TxPool) AddRemotes(txs []*types.Transaction) []error {
+	return p.add(txs, false, false)
+}
+
+// This is like AddRemotes, but waits for pool reorganization. Tests use this method.
+func (p *TxPool) AddRemotesSync(txs []*types.Transaction) []error {
+	return p.add(txs, false, true)
+}
+
+// AddRemote enqueues a single transaction into the pool if it is valid. This is a convenience
+// wrapper around AddRemotes.
+//
+// Deprecated: use AddRemotes
+func (p *TxPool) AddRemote(tx *types.Transaction) error {
+	errs := p.AddRemotes([]*types.Transaction{tx})
+	return errs[0]
+}
+
+// add splits a batch of transactions between the subpools and enqueues them. An
+// account may only have transactions in one subpool, so transactions from a
+// sender tracked by a different subpool are rejected.
+func (p *TxPool) add(txs []*types.Transaction, local, sync bool) []error {
+	var (
+		errs    = make([]error, len(txs))
+		split   = make([]int, len(txs))
+		batches = make([][]*types.Transaction, len(p.subpools))
+		owners  = make(map[common.Address]int)
+	)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for i, tx := range txs {
+		split[i] = -1
+
+		owner := -1
+		for j, subpool := range p.subpools {
+			if subpool.Filter(tx) {
+				owner = j
+				break
+			}
+		}
+		if owner == -1 {
+			errs[i] = ErrTxTypeNotSupported
+			continue
+		}
+		from, err := types.Sender(p.signer, tx)
+		if err != nil {
+			errs[i] = ErrInvalidSender
+			continue
+		}
+		// Make sure the sender is not tracked by any other subpool, either in the
+		// pools themselves or by an earlier transaction of this batch
+		if prev, ok := owners[from]; ok && prev != owner {
+			errs[i] = ErrAccountReserved
+			continue
+		}
+		reserved := false
+		for j, subpool := range p.subpools {
+			if j != owner && subpool.HasAccount(from) {
+				reserved = true
+				break
+			}
+		}
+		if reserved {
+			errs[i] = ErrAccountReserved
+			continue
+		}
+		owners[from] = owner
+		split[i] = owner
+		batches[owner] = append(batches[owner], tx)
+	}
+	results := make([][]error, len(p.subpools))
+	for i, subpool := range p.subpools {
+		if len(batches[i]) > 0 {
+			results[i] = subpool.Add(batches[i], local, sync)
+		}
+	}
+	for i := range txs {
+		if split[i] == -1 {
+			continue
+		}
+		errs[i], results[split[i]] = results[split[i]][0], results[split[i]][1:]
+	}
+	return errs
+}
+
+// Status returns the status (unknown/pending/queued) of a batch of transactions
+// identified by their hashes.
+func (p *TxPool) Status(hashes []common.Hash) []TxStatus {
+	status := make([]TxStatus, len(hashes))
+	for _, subpool := range p.subpools {
+		for i, stat := range subpool.Status(hashes) {
+			if stat != TxStatusUnknown {
+				status[i] = stat
+			}
+		}
+	}
+	return status
+}
+
+// Get returns a transaction if it is contained in any of the subpools and nil
+// otherwise.
+func (p *TxPool) Get(hash common.Hash) *types.Transaction {
+	for _, subpool := range p.subpools {
+		if tx := subpool.Get(hash); tx != nil {
+			return tx
+		}
+	}
+	return nil
+}
+
+// Has returns an indicator whether any of the subpools has a transaction cached
+// with the given hash.
+func (p *TxPool) Has(hash common.Hash) bool {
+	for _, subpool := range p.subpools {
+		if subpool.Has(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// txValidationOptions define the differences in the stateless validation rules
+// of the various subpools.
+type txValidationOptions struct {
+	eip2718 bool // Whether EIP-2718 type transactions are accepted
+	eip1559 bool // Whether EIP-1559 type transactions are accepted
+
+	maxSize uint64   // Maximum size of a transaction accepted by the subpool
+	maxGas  uint64   // Current gas limit for transaction caps
+	minTip  *big.Int // Minimum gas tip required for non-local transactions
+	baseFee *big.Int // Base fee of the next pending block, nil before London
+}
+
+// validateTxBasics checks whether a transaction is valid according to the
+// consensus rules and adheres to the subpool's price and size limits, without
+// looking at the sender's state. The sender of the transaction is returned.
+func validateTxBasics(tx *types.Transaction, signer types.Signer, opts *txValidationOptions, local bool) (common.Address, error) {
+	// Accept only legacy transactions until EIP-2718/2930 activates.
+	if !opts.eip2718 && tx.Type() != types.LegacyTxType {
+		return common.Address{}, ErrTxTypeNotSupported
+	}
+	// Reject dynamic fee transactions until EIP-1559 activates.
+	if !opts.eip1559 && tx.Type() == types.DynamicFeeTxType {
+		return common.Address{}, ErrTxTypeNotSupported
+	}
+	// Reject transactions over defined size to prevent DOS attacks
+	if uint64(tx.Size()) > opts.maxSize {
+		return common.Address{}, ErrOversizedData
+	}
+	// Transactions can't be negative. This may never happen using RLP decoded
+	// transactions but may occur if you create a transaction using the RPC.
+	if tx.Value().Sign() < 0 {
+		return common.Address{}, ErrNegativeValue
+	}
+	// Ensure the transaction doesn't exceed the current block limit gas.
+	if opts.maxGas < tx.Gas() {
+		return common.Address{}, ErrGasLimit
+	}
+	// Sanity check for extremely large numbers
+	if tx.GasFeeCap().BitLen() > 256 {
+		return common.Address{}, ErrFeeCapVeryHigh
+	}
+	if tx.GasTipCap().BitLen() > 256 {
+		return common.Address{}, ErrTipVeryHigh
+	}
+	// Ensure gasFeeCap is greater than or equal to gasTipCap.
+	if tx.GasFeeCapIntCmp(tx.GasTipCap()) < 0 {
+		return common.Address{}, ErrTipAboveFeeCap
+	}
+	// Make sure the transaction is signed properly.
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return common.Address{}, ErrInvalidSender
+	}
+	// Drop non-local transactions under our own minimal accepted gas price or tip.
+	if !local && tx.EffectiveGasTipIntCmp(opts.minTip, opts.baseFee) < 0 {
+		return common.Address{}, ErrUnderpriced
+	}
+	return from, nil
+}
+
+// reorgedTxs returns the transactions of the blocks dropped from the canonical
+// chain by switching from oldHead to newHead, which are not included by the new
+// chain. False is returned if the chain can't be followed and the pool reset
+// should be skipped.
+func reorgedTxs(chain blockChain, oldHead, newHead *types.Header) (types.Transactions, bool) {
+	// If the reorg is too deep, avoid doing it (will happen during fast sync)
+	oldNum := oldHead.Number.Uint64()
+	newNum := newHead.Number.Uint64()
+
+	if depth := uint64(math.Abs(float64(oldNum) - float64(newNum))); depth > 64 {
+		log.Debug("Skipping deep transaction reorg", "depth", depth)
+		return nil, true
+	}
+	// Reorg seems shallow enough to pull in all transactions into memory
+	var discarded, included types.Transactions
+	var (
+		rem = chain.GetBlock(oldHead.Hash(), oldHead.Number.Uint64())
+		add = chain.GetBlock(newHead.Hash(), newHead.Number.Uint64())
+	)
+	if rem == nil {
+		// This can happen if a setHead is performed, where we simply discard the old
+		// head from the chain.
+		// If that is the case, we don't have the lost transactions any more, and
+		// there's nothing to add
+		if newNum >= oldNum {
+			// If we reorged to a same or higher number, then it's not a case of setHead
+			log.Warn("Transaction pool reset with missing oldhead",
+				"old", oldHead.Hash(), "oldnum", oldNum, "new", newHead.Hash(), "newnum", newNum)
+			return nil, false
+		}
+		// If the reorg ended up on a lower number, it's indicative of setHead being the cause
+		log.Debug("Skipping transaction reset caused by setHead",
+			"old", oldHead.Hash(), "oldnum", oldNum, "new", newHead.Hash(), "newnum", newNum)
+		// We still need to update the current state s.th. the lost transactions can be readded by the user
+		return nil, true
+	}
+	for rem.NumberU64() > add.NumberU64() {
+		discarded = append(discarded, rem.Transactions()...)
+		if rem = chain.GetBlock(rem.ParentHash(), rem.NumberU64()-1); rem == nil {
+			log.Error("Unrooted old chain seen by tx pool", "block", oldHead.Number, "hash", oldHead.Hash())
+			return nil, false
+		}
+	}
+	for add.NumberU64() > rem.NumberU64() {
+		included = append(included, add.Transactions()...)
+		if add = chain.GetBlock(add.ParentHash(), add.NumberU64()-1); add == nil {
+			log.Error("Unrooted new chain seen by tx pool", "block", newHead.Number, "hash", newHead.Hash())
+			return nil, false
+		}
+	}
+	for rem.Hash() != add.Hash() {
+		discarded = append(discarded, rem.Transactions()...)
+		if rem = chain.GetBlock(rem.ParentHash(), rem.NumberU64()-1); rem == nil {
+			log.Error("Unrooted old chain seen by tx pool", "block", oldHead.Number, "hash", oldHead.Hash())
+			return nil, false
+		}
+		included = append(included, add.Transactions()...)
+		if add = chain.GetBlock(add.ParentHash(), add.NumberU64()-1); add == nil {
+			log.Error("Unrooted new chain seen by tx pool", "block", newHead.Number, "hash", newHead.Hash())
+			return nil, false
+		}
+	}
+	return types.TxDifference(discarded, included), true
+}
+
+// joinSubscriptions joins multiple subscriptions into one, which is terminated
+// when unsubscribed or when any of the underlying subscriptions fails.
+func joinSubscriptions(subs []event.Subscription) event.Subscription {
+	return event.NewSubscription(func(unsubbed <-chan struct{}) error {
+		defer func() {
+			for _, sub := range subs {
+				sub.Unsubscribe()
+			}
+		}()
+		errc := make(chan error, len(subs))
+		for _, sub := range subs {
+			go func(sub event.Subscription) {
+				select {
+				case err := <-sub.Err():
+					errc <- err
+				case <-unsubbed:
+				}
+			}(sub)
+		}
+		select {
+		case err := <-errc:
+			return err
+		case <-unsubbed:
+			return nil
+		}
+	})
+}
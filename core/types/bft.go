@@ -0,0 +1,86 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// BFTExtraVanity is the fixed number of extra-data prefix bytes reserved for the
+// proposer vanity in blocks sealed by the BFT consensus engine.
+const BFTExtraVanity = 32
+
+// BFTDigest is the mix digest marking blocks sealed by the BFT consensus engine
+// ("practical byzantine fault tolerance").
+var BFTDigest = common.HexToHash("0x63746963616c2062797a616e74696e65206661756c7420746f6c6572616e6365")
+
+// errInvalidBFTExtra is returned if the extra-data of a header is not a valid
+// BFT consensus section.
+var errInvalidBFTExtra = errors.New("invalid BFT extra-data")
+
+// BFTExtra is the consensus specific part of the extra-data of blocks sealed by
+// the BFT engine, stored RLP encoded after the vanity prefix.
+//
+// The commit seals are only added once the validators agreed on the block, so
+// they are left out of the block hash, which is what the validators sign.
+type BFTExtra struct {
+	Validators []common.Address // Validators of the block, in ascending order
+	Round      uint64           // Consensus round in which the block was proposed
+	Seal       []byte           // Signature of the proposer over the seal hash
+	Commits    [][]byte         // Commit seals of the validators that finalized the block
+}
+
+// DecodeBFTExtra extracts the consensus section of a header's extra-data.
+func DecodeBFTExtra(header *Header) (*BFTExtra, error) {
+	if len(header.Extra) < BFTExtraVanity {
+		return nil, errInvalidBFTExtra
+	}
+	extra := new(BFTExtra)
+	if err := rlp.DecodeBytes(header.Extra[BFTExtraVanity:], extra); err != nil {
+		return nil, errInvalidBFTExtra
+	}
+	return extra, nil
+}
+
+// Encode assembles the full extra-data field from the vanity and the consensus
+// section.
+func (e *BFTExtra) Encode(vanity []byte) []byte {
+	enc, err := rlp.EncodeToBytes(e)
+	if err != nil {
+		panic("can't encode: " + err.Error())
+	}
+	extra := make([]byte, BFTExtraVanity, BFTExtraVanity+len(enc))
+	copy(extra, vanity)
+	return append(extra, enc...)
+}
+
+// bftHash returns the hash of a header sealed by the BFT engine, which excludes
+// the commit seals. Headers with a malformed consensus section are hashed as is.
+func bftHash(h *Header) common.Hash {
+	extra, err := DecodeBFTExtra(h)
+	if err != nil || len(extra.Commits) == 0 {
+		return rlpHash(h)
+	}
+	extra.Commits = nil
+
+	cpy := *h
+	cpy.Extra = extra.Encode(h.Extra[:BFTExtraVanity])
+	return rlpHash(&cpy)
+}
@@ -106,8 +106,11 @@ type headerMarshaling struct {
 }
 
 // Hash returns the block hash of the header, which is simply the keccak256 hash of its
-// RLP encoding.
+// RLP encoding. The commit seals of blocks sealed by the BFT engine are left out.
 func (h *Header) Hash() common.Hash {
+	if h.MixDigest == BFTDigest {
+		return bftHash(h)
+	}
 	return rlpHash(h)
 }
 
@@ -33,6 +33,7 @@ import (
 	"github.com/pictor01/ALBA/core"
 	"github.com/pictor01/ALBA/core/rawdb"
 	"github.com/pictor01/ALBA/core/state"
+	"github.com/pictor01/ALBA/core/state/pruner"
 	"github.com/pictor01/ALBA/core/types"
 	"github.com/pictor01/ALBA/internal/albaapi"
 	"github.com/pictor01/ALBA/log"
@@ -607,3 +608,35 @@ func (api *PrivateDebugAPI) GetAccessibleState(from, to rpc.BlockNumber) (uint64
 	}
 	return 0, fmt.Errorf("No state found")
 }
+
+// PruneState starts pruning the stale state in the background, while the node
+// keeps importing blocks. The progress is reported by PruneStateStatus.
+func (api *PrivateDebugAPI) PruneState() error {
+	if api.alba.pruner == nil {
+		return errors.New("online state pruning requires snapshots and the hash-based state scheme")
+	}
+	if !api.alba.Synced() {
+		return errors.New("state pruning is not allowed while syncing")
+	}
+	return api.alba.pruner.Start()
+}
+
+// PruneStateStatus returns the progress of the running online state pruning,
+// or the outcome of the last one.
+func (api *PrivateDebugAPI) PruneStateStatus() (*pruner.OnlineStatus, error) {
+	if api.alba.pruner == nil {
+		return nil, errors.New("online state pruning requires snapshots and the hash-based state scheme")
+	}
+	status := api.alba.pruner.Status()
+	return &status, nil
+}
+
+// AbortPruneState interrupts the running online state pruning. The deleted
+// state is not restored, pruning can be restarted any time.
+func (api *PrivateDebugAPI) AbortPruneState() error {
+	if api.alba.pruner == nil {
+		return errors.New("online state pruning requires snapshots and the hash-based state scheme")
+	}
+	api.alba.pruner.Stop()
+	return nil
+}
@@ -23,11 +23,15 @@ import (
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/pictor01/ALBA/common"
 	"github.com/pictor01/ALBA/core/rawdb"
 	"github.com/pictor01/ALBA/core/state"
+	"github.com/pictor01/ALBA/core/state/pruner"
+	"github.com/pictor01/ALBA/core/state/snapshot"
+	"github.com/pictor01/ALBA/core/types"
 	"github.com/pictor01/ALBA/crypto"
 )
 
@@ -218,3 +222,83 @@ func TestStorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+func TestPruneState(t *testing.T) {
+	t.Parallel()
+
+	// Create a genesis state and two more on top, the middle one being stale
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		statedb = state.NewDatabase(db)
+		roots   []common.Hash
+		root    common.Hash
+	)
+	for i := 0; i < 3; i++ {
+		state, _ := state.New(root, statedb, nil)
+		state.SetBalance(common.Address{0x01}, big.NewInt(int64(i+1)))
+		root, _ = state.Commit(true)
+		if err := statedb.TrieDB().Commit(root, false, nil); err != nil {
+			t.Fatalf("failed to flush state %d: %v", i, err)
+		}
+		roots = append(roots, root)
+		if i == 0 {
+			genesis := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0), Root: root})
+			rawdb.WriteBlock(db, genesis)
+			rawdb.WriteCanonicalHash(db, genesis.Hash(), 0)
+		}
+	}
+	snaps, err := snapshot.New(db, statedb.TrieDB(), 16, root, false, true, false)
+	if err != nil {
+		t.Fatalf("failed to create snapshot tree: %v", err)
+	}
+	// Pruning is refused if it's not supported
+	api := NewPrivateDebugAPI(&Alba{handler: new(handler)})
+	if err := api.PruneState(); err == nil {
+		t.Fatal("pruning started without a pruner")
+	}
+	if _, err := api.PruneStateStatus(); err == nil {
+		t.Fatal("pruning status reported without a pruner")
+	}
+	if err := api.AbortPruneState(); err == nil {
+		t.Fatal("pruning aborted without a pruner")
+	}
+	// Pruning is refused while syncing. A never ending batch delay keeps the run
+	// alive until aborted.
+	p, err := pruner.NewOnlinePruner(db, statedb.TrieDB(), snaps, pruner.OnlineConfig{BatchSize: 1, BatchDelay: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create pruner: %v", err)
+	}
+	alba := &Alba{handler: new(handler), pruner: p}
+	api = NewPrivateDebugAPI(alba)
+	if err := api.PruneState(); err == nil {
+		t.Fatal("pruning started while syncing")
+	}
+	if status, err := api.PruneStateStatus(); err != nil || status.Running || !status.Started.IsZero() {
+		t.Fatalf("unexpected status before pruning: %+v, %v", status, err)
+	}
+	alba.SetSynced()
+	if err := api.PruneState(); err != nil {
+		t.Fatalf("failed to start pruning: %v", err)
+	}
+	if err := api.PruneState(); err == nil {
+		t.Fatal("pruning started twice")
+	}
+	status, err := api.PruneStateStatus()
+	if err != nil || !status.Running || status.Started.IsZero() {
+		t.Fatalf("unexpected status while pruning: %+v, %v", status, err)
+	}
+	// Aborting stops the run, reporting it in the status
+	if err := api.AbortPruneState(); err != nil {
+		t.Fatalf("failed to abort pruning: %v", err)
+	}
+	status, err = api.PruneStateStatus()
+	if err != nil || status.Running || status.Stage != "" || status.Error != "state pruning aborted" {
+		t.Fatalf("unexpected status after abort: %+v, %v", status, err)
+	}
+	// The live states are left intact
+	for _, root := range []common.Hash{roots[0], roots[2]} {
+		if _, err := state.New(root, state.NewDatabase(db), nil); err != nil {
+			t.Fatalf("live state %x lost: %v", root, err)
+		}
+	}
+}
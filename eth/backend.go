@@ -31,6 +31,7 @@ import (
 	"github.com/pictor01/ALBA/common/hexutil"
 	"github.com/pictor01/ALBA/consensus"
 	"github.com/pictor01/ALBA/consensus/beacon"
+	"github.com/pictor01/ALBA/consensus/bft"
 	"github.com/pictor01/ALBA/consensus/clique"
 	"github.com/pictor01/ALBA/core"
 	"github.com/pictor01/ALBA/core/bloombits"
@@ -85,6 +86,8 @@ type Alba struct {
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
 	closeBloomHandler chan struct{}
 
+	pruner *pruner.OnlinePruner // Background state pruner, nil if not supported
+
 	APIBackend *AlbaAPIBackend
 
 	miner     *miner.Miner
@@ -133,6 +136,22 @@ func New(stack *node.Node, config *albaconfig.Config) (*Alba, error) {
 	if err != nil {
 		return nil, err
 	}
+	// The state scheme must be settled before the genesis state is written
+	scheme, err := rawdb.ParseStateScheme(config.StateScheme, chainDb)
+	if err != nil {
+		return nil, err
+	}
+	if scheme == rawdb.PathScheme {
+		// The path-based scheme persists a single state, it can't keep an archive
+		// and can't be filled by snap sync, which delivers nodes by hash.
+		if config.NoPruning {
+			return nil, errors.New("archive mode is not supported by the path-based state scheme")
+		}
+		if config.SyncMode == downloader.SnapSync {
+			log.Warn("Snap sync is not supported by the path-based state scheme, switching to full sync")
+			config.SyncMode = downloader.FullSync
+		}
+	}
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.OverrideArrowGlacier, config.OverrideTerminalTotalDifficulty)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
@@ -190,6 +209,8 @@ func New(stack *node.Node, config *albaconfig.Config) (*Alba, error) {
 			TrieTimeLimit:       config.TrieTimeout,
 			SnapshotLimit:       config.SnapshotCache,
 			Preimages:           config.Preimages,
+			StateScheme:         scheme,
+			StateHistory:        config.StateHistory,
 		}
 	)
 	alba.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, alba.engine, vmConfig, alba.shouldPreserve, &config.TxLookupLimit)
@@ -202,11 +223,26 @@ func New(stack *node.Node, config *albaconfig.Config) (*Alba, error) {
 		alba.blockchain.SetHead(compat.RewindTo)
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
+	// Online state pruning is only meaningful for hash based, non-archive nodes
+	if snaps := alba.blockchain.Snapshots(); snaps != nil && scheme == rawdb.HashScheme && !config.NoPruning {
+		alba.pruner, err = pruner.NewOnlinePruner(chainDb, alba.blockchain.StateCache().TrieDB(), snaps, pruner.DefaultOnlineConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
 	alba.bloomIndexer.Start(alba.blockchain)
 
+	// BFT validators decide blocks among themselves, start the state machine
+	if engine := alba.bftEngine(); engine != nil {
+		engine.Start(alba.blockchain)
+	}
+
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.BlobDatadir != "" {
+		config.TxPool.BlobDatadir = stack.ResolvePath(config.TxPool.BlobDatadir)
+	}
 	alba.txPool = core.NewTxPool(config.TxPool, chainConfig, alba.blockchain)
 
 	// Permit the downloader to use the trie cache allowance during fast sync
@@ -487,6 +523,14 @@ func (s *Alba) StartMining(threads int) error {
 			}
 			cli.Authorize(eb, wallet.SignData)
 		}
+		if engine := s.bftEngine(); engine != nil {
+			wallet, err := s.accountManager.Find(accounts.Account{Address: eb})
+			if wallet == nil || err != nil {
+				log.Error("Albabase account unavailable locally", "err", err)
+				return fmt.Errorf("validator missing: %v", err)
+			}
+			engine.Authorize(eb, wallet.SignData)
+		}
 		// If mining is started, we can disable the transaction rejection mechanism
 		// introduced to speed sync times.
 		atomic.StoreUint32(&s.handler.acceptTxs, 1)
@@ -538,9 +582,25 @@ func (s *Alba) Protocols() []p2p.Protocol {
 	if s.config.SnapshotCache > 0 {
 		protos = append(protos, snap.MakeProtocols((*snapHandler)(s.handler), s.snapDialCandidates)...)
 	}
+	if engine := s.bftEngine(); engine != nil {
+		protos = append(protos, engine.Protocols()...)
+	}
 	return protos
 }
 
+// bftEngine returns the BFT consensus engine sealing the chain, or nil if the
+// chain uses a different one.
+func (s *Alba) bftEngine() *bft.BFT {
+	engine := s.engine
+	if b, ok := engine.(*beacon.Beacon); ok {
+		engine = b.InnerEngine()
+	}
+	if b, ok := engine.(*bft.BFT); ok {
+		return b
+	}
+	return nil
+}
+
 // Start implements node.Lifecycle, starting all internal goroutines needed by the
 // Alba protocol implementation.
 func (s *Alba) Start() error {
@@ -575,6 +635,9 @@ func (s *Alba) Stop() error {
 	close(s.closeBloomHandler)
 	s.txPool.Stop()
 	s.miner.Close()
+	if s.pruner != nil {
+		s.pruner.Stop()
+	}
 	s.blockchain.Stop()
 	s.engine.Close()
 	rawdb.PopUncleanShutdownMarker(s.chainDb)
@@ -28,6 +28,7 @@ import (
 	"github.com/pictor01/ALBA/common"
 	"github.com/pictor01/ALBA/consensus"
 	"github.com/pictor01/ALBA/consensus/beacon"
+	"github.com/pictor01/ALBA/consensus/bft"
 	"github.com/pictor01/ALBA/consensus/clique"
 	"github.com/pictor01/ALBA/consensus/albaash"
 	"github.com/pictor01/ALBA/core"
@@ -83,6 +84,7 @@ var Defaults = Config{
 	TrieDirtyCache:          256,
 	TrieTimeout:             60 * time.Minute,
 	SnapshotCache:           102,
+	StateHistory:            90000,
 	Miner: miner.Config{
 		GasCeil:  8000000,
 		GasPrice: big.NewInt(params.GWei),
@@ -168,6 +170,8 @@ type Config struct {
 	TrieTimeout             time.Duration
 	SnapshotCache           int
 	Preimages               bool
+	StateScheme             string `toml:",omitempty"` // Scheme used to store the state trie nodes (hash or path)
+	StateHistory            uint64 `toml:",omitempty"` // Number of recent blocks to retain state history for in the path scheme
 
 	// Mining options
 	Miner miner.Config
@@ -216,6 +220,8 @@ func CreateConsensusEngine(stack *node.Node, chainConfig *params.ChainConfig, co
 	var engine consensus.Engine
 	if chainConfig.Clique != nil {
 		engine = clique.New(chainConfig.Clique, db)
+	} else if chainConfig.BFT != nil {
+		engine = bft.New(chainConfig.BFT, db)
 	} else {
 		switch config.PowMode {
 		case albaash.ModeFake:
@@ -48,6 +48,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		TrieTimeout                     time.Duration
 		SnapshotCache                   int
 		Preimages                       bool
+		StateScheme                     string `toml:",omitempty"`
+		StateHistory                    uint64 `toml:",omitempty"`
 		Miner                           miner.Config
 		Albaash                          albaash.Config
 		TxPool                          core.TxPoolConfig
@@ -93,6 +95,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TrieTimeout = c.TrieTimeout
 	enc.SnapshotCache = c.SnapshotCache
 	enc.Preimages = c.Preimages
+	enc.StateScheme = c.StateScheme
+	enc.StateHistory = c.StateHistory
 	enc.Miner = c.Miner
 	enc.Albaash = c.Albaash
 	enc.TxPool = c.TxPool
@@ -142,6 +146,8 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		TrieTimeout                     *time.Duration
 		SnapshotCache                   *int
 		Preimages                       *bool
+		StateScheme                     *string `toml:",omitempty"`
+		StateHistory                    *uint64 `toml:",omitempty"`
 		Miner                           *miner.Config
 		Albaash                          *albaash.Config
 		TxPool                          *core.TxPoolConfig
@@ -250,6 +256,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.Preimages != nil {
 		c.Preimages = *dec.Preimages
 	}
+	if dec.StateScheme != nil {
+		c.StateScheme = *dec.StateScheme
+	}
+	if dec.StateHistory != nil {
+		c.StateHistory = *dec.StateHistory
+	}
 	if dec.Miner != nil {
 		c.Miner = *dec.Miner
 	}
@@ -45,6 +45,11 @@ const (
 	// txChanSize is the size of channel listening to NewTxsEvent.
 	// The number is referenced from the size of tx pool.
 	txChanSize = 4096
+
+	// txMaxBroadcastSize is the max size of a transaction that will be broadcast.
+	// Larger transactions (e.g. the ones in the blob pool) are only announced and
+	// need to be fetched by the peers.
+	txMaxBroadcastSize = 128 * 1024
 )
 
 var (
@@ -600,6 +605,9 @@ func (h *handler) BroadcastTransactions(txs types.Transactions) {
 		peers := h.peers.peersWithoutTransaction(tx.Hash())
 		// Send the tx unconditionally to a subset of our peers
 		numDirect := int(math.Sqrt(float64(len(peers))))
+		if tx.Size() > txMaxBroadcastSize {
+			numDirect = 0
+		}
 		for _, peer := range peers[:numDirect] {
 			txset[peer] = append(txset[peer], tx.Hash())
 		}
@@ -204,14 +204,9 @@ func handleMessage(backend Backend, peer *Peer) error {
 
 	// Track the amount of time it takes to serve the request and run the handler
 	if metrics.Enabled {
-		h := fmt.Sprintf("%s/%s/%d/%#02x", p2p.HandleHistName, ProtocolName, peer.Version(), msg.Code)
+		h := p2p.HandleHistogram.With(p2p.MsgLabels(ProtocolName, peer.Version(), msg.Code)...)
 		defer func(start time.Time) {
-			sampler := func() metrics.Sample {
-				return metrics.ResettingSample(
-					metrics.NewExpDecaySample(1028, 0.015),
-				)
-			}
-			metrics.GetOrRegisterHistogramLazy(h, nil, sampler).Update(time.Since(start).Microseconds())
+			h.Observe(time.Since(start).Seconds())
 		}(time.Now())
 	}
 	if handler := handlers[msg.Code]; handler != nil {
@@ -76,6 +76,7 @@ func newTestBackendWithGenerator(blocks int, generator func(int, *core.BlockGen)
 	}
 	txconfig := core.DefaultTxPoolConfig
 	txconfig.Journal = "" // Don't litter the disk with test journals
+	txconfig.BlobDatadir = ""
 
 	return &testBackend{
 		db:     db,
@@ -23,6 +23,7 @@ import (
 
 	"github.com/pictor01/ALBA/common"
 	"github.com/pictor01/ALBA/core"
+	"github.com/pictor01/ALBA/core/rawdb"
 	"github.com/pictor01/ALBA/core/state"
 	"github.com/pictor01/ALBA/core/types"
 	"github.com/pictor01/ALBA/core/vm"
@@ -63,7 +64,8 @@ func (eth *Ethereum) StateAtBlock(block *types.Block, reexec uint64, base *state
 		if preferDisk {
 			// Create an ephemeral trie.Database for isolating the live one. Otherwise
 			// the internal junks created by tracing will be persisted into the disk.
-			database = state.NewDatabaseWithConfig(alba.chainDb, &trie.Config{Cache: 16})
+			// It is hash based, path-based states are only served by the live one.
+			database = state.NewDatabaseWithConfig(alba.chainDb, &trie.Config{Cache: 16, Scheme: rawdb.HashScheme})
 			if statedb, err = state.New(block.Root(), database, nil); err == nil {
 				log.Info("Found disk backend for state trie", "root", block.Root(), "number", block.Number())
 				return statedb, nil
@@ -78,7 +80,8 @@ func (eth *Ethereum) StateAtBlock(block *types.Block, reexec uint64, base *state
 
 		// Create an ephemeral trie.Database for isolating the live one. Otherwise
 		// the internal junks created by tracing will be persisted into the disk.
-		database = state.NewDatabaseWithConfig(eth.chainDb, &trie.Config{Cache: 16})
+		// It is hash based, path-based states are only served by the live one.
+		database = state.NewDatabaseWithConfig(eth.chainDb, &trie.Config{Cache: 16, Scheme: rawdb.HashScheme})
 
 		// If we didn't check the dirty database, do check the clean one, otherwise
 		// we would rewind past a persisted block (specific corner case is chain
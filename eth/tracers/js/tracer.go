@@ -423,8 +423,9 @@ type jsTracer struct {
 
 // New instantiates a new tracer instance. code specifies a Javascript snippet,
 // which must evaluate to an expression returning an object with 'step', 'fault'
-// and 'result' functions.
-func newJsTracer(code string, ctx *tracers2.Context) (tracers2.Tracer, error) {
+// and 'result' functions. If the object also has a 'setup' function, it will be
+// invoked with the decoded cfg before tracing starts.
+func newJsTracer(code string, ctx *tracers2.Context, cfg json.RawMessage) (tracers2.Tracer, error) {
 	if c, ok := assetTracers[code]; ok {
 		code = c
 	}
@@ -633,6 +634,27 @@ func newJsTracer(code string, ctx *tracers2.Context) (tracers2.Tracer, error) {
 	tracer.dbWrapper.pushObject(tracer.vm)
 	tracer.vm.PutPropString(tracer.stateObject, "db")
 
+	// Pass the tracer config to the setup function, if one is defined
+	hasSetup := tracer.vm.GetPropString(tracer.tracerObject, "setup")
+	tracer.vm.Pop()
+
+	if hasSetup {
+		if len(cfg) == 0 {
+			cfg = json.RawMessage("{}")
+		}
+		if !json.Valid(cfg) {
+			return nil, errors.New("invalid tracer config")
+		}
+		tracer.vm.PushString("setup")
+		tracer.vm.PushString(string(cfg))
+		tracer.vm.JsonDecode(-1)
+		code := tracer.vm.PcallProp(tracer.tracerObject, 1)
+		defer tracer.vm.Pop()
+
+		if code != 0 {
+			return nil, wrapError("setup", errors.New(tracer.vm.SafeToString(-1)))
+		}
+	}
 	return tracer, nil
 }
 
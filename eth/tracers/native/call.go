@@ -25,6 +25,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/pictor01/ALBA/accounts/abi"
 	"github.com/pictor01/ALBA/common"
 	"github.com/pictor01/ALBA/core/vm"
 	"github.com/pictor01/ALBA/alba/tracers"
@@ -34,33 +35,59 @@ func init() {
 	register("callTracer", newCallTracer)
 }
 
+type callLog struct {
+	Address  string   `json:"address"`
+	Topics   []string `json:"topics"`
+	Data     string   `json:"data"`
+	Position string   `json:"position"` // Number of subcalls made by the frame before the log was emitted
+}
+
 type callFrame struct {
-	Type    string      `json:"type"`
-	From    string      `json:"from"`
-	To      string      `json:"to,omitempty"`
-	Value   string      `json:"value,omitempty"`
-	Gas     string      `json:"gas"`
-	GasUsed string      `json:"gasUsed"`
-	Input   string      `json:"input"`
-	Output  string      `json:"output,omitempty"`
-	Error   string      `json:"error,omitempty"`
-	Calls   []callFrame `json:"calls,omitempty"`
+	Type         string      `json:"type"`
+	From         string      `json:"from"`
+	To           string      `json:"to,omitempty"`
+	Value        string      `json:"value,omitempty"`
+	Gas          string      `json:"gas"`
+	GasUsed      string      `json:"gasUsed"`
+	Input        string      `json:"input"`
+	Output       string      `json:"output,omitempty"`
+	Error        string      `json:"error,omitempty"`
+	RevertReason string      `json:"revertReason,omitempty"`
+	Calls        []callFrame `json:"calls,omitempty"`
+	Logs         []callLog   `json:"logs,omitempty"`
+}
+
+// failed returns whether the execution of the frame ended with an error.
+func (f *callFrame) failed() bool {
+	return len(f.Error) > 0
 }
 
 type callTracer struct {
 	env       *vm.EVM
 	callstack []callFrame
+	config    callTracerConfig
 	interrupt uint32 // Atomic flag to signal execution interruption
 	reason    error  // Textual reason for the interruption
 }
 
+type callTracerConfig struct {
+	OnlyTopCall bool `json:"onlyTopCall"` // If true, call tracer won't collect any subcalls
+	WithLog     bool `json:"withLog"`     // If true, call tracer will collect event logs
+}
+
 // newCallTracer returns a native go tracer which tracks
 // call frames of a tx, and implements vm.EVMLogger.
-func newCallTracer() tracers.Tracer {
+func newCallTracer(ctx *tracers.Context, cfg json.RawMessage) (tracers.Tracer, error) {
+	var config callTracerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, err
+		}
+	}
 	// First callframe contains tx context info
 	// and is populated on start and end.
-	t := &callTracer{callstack: make([]callFrame, 1)}
-	return t
+	t := &callTracer{callstack: make([]callFrame, 1), config: config}
+	return t, nil
 }
 
 // CaptureStart implements the EVMLogger interface to initialize the tracing operation.
@@ -86,6 +113,7 @@ func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration,
 		t.callstack[0].Error = err.Error()
 		if err.Error() == "execution reverted" && len(output) > 0 {
 			t.callstack[0].Output = bytesToHex(output)
+			t.callstack[0].RevertReason = revertReason(output)
 		}
 	} else {
 		t.callstack[0].Output = bytesToHex(output)
@@ -94,6 +122,40 @@ func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration,
 
 // CaptureState implements the EVMLogger interface to trace a single step of VM execution.
 func (t *callTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	// Only logs need to be captured via opcode processing
+	if err != nil || !t.config.WithLog {
+		return
+	}
+	// Avoid processing nested calls when only caring about top call
+	if t.config.OnlyTopCall && depth > 1 {
+		return
+	}
+	// Skip if tracing was interrupted
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		return
+	}
+	switch op {
+	case vm.LOG0, vm.LOG1, vm.LOG2, vm.LOG3, vm.LOG4:
+		var (
+			size  = int(op - vm.LOG0)
+			stack = scope.Stack.Data()
+		)
+		// Don't modify the stack, the memory was already expanded to fit the data
+		mStart, mSize := stack[len(stack)-1], stack[len(stack)-2]
+		topics := make([]string, size)
+		for i := 0; i < size; i++ {
+			topic := stack[len(stack)-2-(i+1)]
+			topics[i] = common.Hash(topic.Bytes32()).Hex()
+		}
+		frame := &t.callstack[len(t.callstack)-1]
+		log := callLog{
+			Address:  addrToHex(scope.Contract.Address()),
+			Topics:   topics,
+			Data:     bytesToHex(scope.Memory.GetCopy(int64(mStart.Uint64()), int64(mSize.Uint64()))),
+			Position: uintToHex(uint64(len(frame.Calls))),
+		}
+		frame.Logs = append(frame.Logs, log)
+	}
 }
 
 // CaptureFault implements the EVMLogger interface to trace an execution fault.
@@ -102,6 +164,9 @@ func (t *callTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, _ *
 
 // CaptureEnter is called when EVM enters a new scope (via call, create or selfdestruct).
 func (t *callTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if t.config.OnlyTopCall {
+		return
+	}
 	// Skip if tracing was interrupted
 	if atomic.LoadUint32(&t.interrupt) > 0 {
 		t.env.Cancel()
@@ -122,6 +187,9 @@ func (t *callTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.
 // CaptureExit is called when EVM exits a scope, even if the scope didn't
 // execute any code.
 func (t *callTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	if t.config.OnlyTopCall {
+		return
+	}
 	size := len(t.callstack)
 	if size <= 1 {
 		return
@@ -139,6 +207,9 @@ func (t *callTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
 		if call.Type == "CREATE" || call.Type == "CREATE2" {
 			call.To = ""
 		}
+		if err == vm.ErrExecutionReverted {
+			call.RevertReason = revertReason(output)
+		}
 	}
 	t.callstack[size-1].Calls = append(t.callstack[size-1].Calls, call)
 }
@@ -149,6 +220,9 @@ func (t *callTracer) GetResult() (json.RawMessage, error) {
 	if len(t.callstack) != 1 {
 		return nil, errors.New("incorrect number of top-level calls")
 	}
+	// Logs emitted by reverted frames never made it into the state
+	clearFailedLogs(&t.callstack[0], false)
+
 	res, err := json.Marshal(t.callstack[0])
 	if err != nil {
 		return nil, err
@@ -162,6 +236,28 @@ func (t *callTracer) Stop(err error) {
 	atomic.StoreUint32(&t.interrupt, 1)
 }
 
+// clearFailedLogs clears the logs of a callframe and all its children
+// in case of execution failure.
+func clearFailedLogs(cf *callFrame, parentFailed bool) {
+	failed := cf.failed() || parentFailed
+	if failed {
+		cf.Logs = nil
+	}
+	for i := range cf.Calls {
+		clearFailedLogs(&cf.Calls[i], failed)
+	}
+}
+
+// revertReason decodes the Error(string) or Panic(uint256) payload returned by
+// a reverted frame, or returns an empty string if it's not a known encoding.
+func revertReason(output []byte) string {
+	reason, err := abi.UnpackRevert(output)
+	if err != nil {
+		return ""
+	}
+	return reason
+}
+
 func bytesToHex(s []byte) string {
 	return "0x" + common.Bytes2Hex(s)
 }
@@ -0,0 +1,319 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/pictor01/ALBA/alba/tracers"
+	"github.com/pictor01/ALBA/common"
+	"github.com/pictor01/ALBA/common/hexutil"
+	"github.com/pictor01/ALBA/core"
+	"github.com/pictor01/ALBA/core/vm"
+	"github.com/pictor01/ALBA/crypto"
+)
+
+func init() {
+	register("prestateTracer", newPrestateTracer)
+}
+
+type state = map[common.Address]*account
+
+type account struct {
+	Balance *big.Int
+	Code    []byte
+	Nonce   uint64
+	Storage map[common.Hash]common.Hash
+}
+
+// exists returns whether the account had any state before the transaction.
+func (a *account) exists() bool {
+	return a.Nonce > 0 || len(a.Code) > 0 || len(a.Storage) > 0 || (a.Balance != nil && a.Balance.Sign() != 0)
+}
+
+// MarshalJSON implements json.Marshaler, encoding the account fields as hex and
+// omitting the empty ones.
+func (a *account) MarshalJSON() ([]byte, error) {
+	type account struct {
+		Balance *hexutil.Big                `json:"balance,omitempty"`
+		Code    hexutil.Bytes               `json:"code,omitempty"`
+		Nonce   uint64                      `json:"nonce,omitempty"`
+		Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+	}
+	return json.Marshal(&account{
+		Balance: (*hexutil.Big)(a.Balance),
+		Code:    a.Code,
+		Nonce:   a.Nonce,
+		Storage: a.Storage,
+	})
+}
+
+type prestateTracer struct {
+	env       *vm.EVM
+	pre       state
+	post      state
+	create    bool
+	to        common.Address
+	config    prestateTracerConfig
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+	created   map[common.Address]bool
+	processed bool // Whether the post-execution state was already collected
+}
+
+type prestateTracerConfig struct {
+	DiffMode bool `json:"diffMode"` // If true, this tracer will return state modifications
+}
+
+// newPrestateTracer returns a native go tracer which collects the state
+// accessed by a tx, and implements vm.EVMLogger. In diff mode it reports
+// both the pre- and post-state of the modified accounts only.
+func newPrestateTracer(ctx *tracers.Context, cfg json.RawMessage) (tracers.Tracer, error) {
+	var config prestateTracerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, err
+		}
+	}
+	return &prestateTracer{
+		pre:     state{},
+		post:    state{},
+		config:  config,
+		created: make(map[common.Address]bool),
+	}, nil
+}
+
+// CaptureStart implements the EVMLogger interface to initialize the tracing operation.
+func (t *prestateTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+	t.create = create
+	t.to = to
+
+	t.lookupAccount(from)
+	t.lookupAccount(to)
+	t.lookupAccount(env.Context.Coinbase)
+
+	// The recipient balance includes the value transferred.
+	t.pre[to].Balance = new(big.Int).Sub(t.pre[to].Balance, value)
+
+	// The sender balance is after reducing the value and the purchased gas,
+	// re-add them to get the pre-tx balance. The access list cost is not part
+	// of the intrinsic gas computed here, same as in the legacy tracer.
+	rules := env.ChainConfig().Rules(env.Context.BlockNumber)
+	intrinsicGas, err := core.IntrinsicGas(input, nil, create, rules.IsHomestead, rules.IsIstanbul)
+	if err != nil {
+		return
+	}
+	gasLimit := new(big.Int).SetUint64(gas + intrinsicGas)
+	fromBal := new(big.Int).Set(t.pre[from].Balance)
+	fromBal.Add(fromBal, new(big.Int).Add(value, new(big.Int).Mul(env.TxContext.GasPrice, gasLimit)))
+	t.pre[from].Balance = fromBal
+	t.pre[from].Nonce--
+
+	if create {
+		// The contract nonce was already bumped by the creation, any existing
+		// account at the address must have had a zero nonce to not collide.
+		t.pre[to].Nonce = 0
+		if t.config.DiffMode {
+			t.created[to] = true
+		}
+	}
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) {
+}
+
+// CaptureState implements the EVMLogger interface to trace a single step of VM execution.
+func (t *prestateTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if err != nil {
+		return
+	}
+	// Skip if tracing was interrupted
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		t.env.Cancel()
+		return
+	}
+	stack := scope.Stack.Data()
+	stackLen := len(stack)
+	caller := scope.Contract.Address()
+
+	switch {
+	case stackLen >= 1 && (op == vm.SLOAD || op == vm.SSTORE):
+		slot := common.Hash(stack[stackLen-1].Bytes32())
+		t.lookupStorage(caller, slot)
+	case stackLen >= 1 && (op == vm.EXTCODECOPY || op == vm.EXTCODEHASH || op == vm.EXTCODESIZE || op == vm.BALANCE || op == vm.SELFDESTRUCT):
+		addr := common.Address(stack[stackLen-1].Bytes20())
+		t.lookupAccount(addr)
+	case stackLen >= 5 && (op == vm.DELEGATECALL || op == vm.CALL || op == vm.STATICCALL || op == vm.CALLCODE):
+		addr := common.Address(stack[stackLen-2].Bytes20())
+		t.lookupAccount(addr)
+	case op == vm.CREATE:
+		addr := crypto.CreateAddress(caller, t.env.StateDB.GetNonce(caller))
+		t.lookupAccount(addr)
+		t.created[addr] = true
+	case stackLen >= 4 && op == vm.CREATE2:
+		offset, size := stack[stackLen-2], stack[stackLen-3]
+		init := scope.Memory.GetCopy(int64(offset.Uint64()), int64(size.Uint64()))
+		salt := stack[stackLen-4]
+		addr := crypto.CreateAddress2(caller, salt.Bytes32(), crypto.Keccak256(init))
+		t.lookupAccount(addr)
+		t.created[addr] = true
+	}
+}
+
+// CaptureFault implements the EVMLogger interface to trace an execution fault.
+func (t *prestateTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, _ *vm.ScopeContext, depth int, err error) {
+}
+
+// CaptureEnter is called when EVM enters a new scope (via call, create or selfdestruct).
+func (t *prestateTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+}
+
+// CaptureExit is called when EVM exits a scope, even if the scope didn't
+// execute any code.
+func (t *prestateTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+}
+
+// GetResult returns the json-encoded pre-state of all accessed accounts, or in
+// diff mode the pre- and post-state of the modified ones, and any error arising
+// from the encoding or forceful termination (via `Stop`).
+func (t *prestateTracer) GetResult() (json.RawMessage, error) {
+	// The post-state is only final once the whole message was applied (gas
+	// refunded, fees paid), so it's collected here instead of in CaptureEnd.
+	if !t.processed && t.env != nil {
+		t.processed = true
+		if t.config.DiffMode {
+			t.processDiffState()
+		} else if t.create {
+			// Exclude the created contract, any existing state would have caused
+			// the transaction to be rejected as invalid in the first place.
+			delete(t.pre, t.to)
+		}
+	}
+	var (
+		res []byte
+		err error
+	)
+	if t.config.DiffMode {
+		res, err = json.Marshal(struct {
+			Post state `json:"post"`
+			Pre  state `json:"pre"`
+		}{t.post, t.pre})
+	} else {
+		res, err = json.Marshal(t.pre)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(res), t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *prestateTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
+
+// processDiffState strips all the accounts and storage slots which were not
+// modified by the transaction from the pre-state and assembles the post-state
+// of the remaining ones.
+func (t *prestateTracer) processDiffState() {
+	for addr, state := range t.pre {
+		// Deleted accounts are kept in the pre-state but have no post-state.
+		// Self-destructs of reverted frames are rolled back by the state, so
+		// only the ones that took effect are seen here.
+		if t.env.StateDB.HasSuicided(addr) {
+			continue
+		}
+		var (
+			modified    bool
+			postAccount = &account{Storage: make(map[common.Hash]common.Hash)}
+			newBalance  = t.env.StateDB.GetBalance(addr)
+			newNonce    = t.env.StateDB.GetNonce(addr)
+			newCode     = t.env.StateDB.GetCode(addr)
+		)
+		if newBalance.Cmp(state.Balance) != 0 {
+			modified = true
+			postAccount.Balance = newBalance
+		}
+		if newNonce != state.Nonce {
+			modified = true
+			postAccount.Nonce = newNonce
+		}
+		if !bytes.Equal(newCode, state.Code) {
+			modified = true
+			postAccount.Code = newCode
+		}
+		for key, val := range state.Storage {
+			// Empty slots are not part of the pre-state
+			if val == (common.Hash{}) {
+				delete(state.Storage, key)
+			}
+			newVal := t.env.StateDB.GetState(addr, key)
+			if val == newVal {
+				// Omit unchanged slots
+				delete(state.Storage, key)
+			} else {
+				modified = true
+				if newVal != (common.Hash{}) {
+					postAccount.Storage[key] = newVal
+				}
+			}
+		}
+		if modified {
+			t.post[addr] = postAccount
+		} else {
+			// Unmodified accounts are not part of the diff
+			delete(t.pre, addr)
+		}
+	}
+	// Created contracts had no pre-state, unless the address was already in use
+	for addr := range t.created {
+		if account := t.pre[addr]; account != nil && !account.exists() {
+			delete(t.pre, addr)
+		}
+	}
+}
+
+// lookupAccount fetches details of an account and adds it to the prestate
+// if it doesn't exist there.
+func (t *prestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.pre[addr]; ok {
+		return
+	}
+	t.pre[addr] = &account{
+		Balance: new(big.Int).Set(t.env.StateDB.GetBalance(addr)),
+		Nonce:   t.env.StateDB.GetNonce(addr),
+		Code:    t.env.StateDB.GetCode(addr),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+}
+
+// lookupStorage fetches the requested storage slot and adds
+// it to the prestate of the given contract.
+func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	t.lookupAccount(addr)
+	if _, ok := t.pre[addr].Storage[key]; ok {
+		return
+	}
+	t.pre[addr].Storage[key] = t.env.StateDB.GetState(addr, key)
+}
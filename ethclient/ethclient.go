@@ -186,6 +186,54 @@ func (ec *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.H
 	return head, err
 }
 
+// UncleByBlockHashAndIndex returns the header of the uncle at index in the given block.
+func (ec *Client) UncleByBlockHashAndIndex(ctx context.Context, blockHash common.Hash, index uint) (*types.Header, error) {
+	var head *types.Header
+	err := ec.c.CallContext(ctx, &head, "eth_getUncleByBlockHashAndIndex", blockHash, hexutil.Uint(index))
+	if err == nil && head == nil {
+		err = ethereum.NotFound
+	}
+	return head, err
+}
+
+// UncleByBlockNumberAndIndex returns the header of the uncle at index in the given block
+// of the current canonical chain. If number is nil, the latest known block is used.
+func (ec *Client) UncleByBlockNumberAndIndex(ctx context.Context, number *big.Int, index uint) (*types.Header, error) {
+	var head *types.Header
+	err := ec.c.CallContext(ctx, &head, "eth_getUncleByBlockNumberAndIndex", toBlockNumArg(number), hexutil.Uint(index))
+	if err == nil && head == nil {
+		err = ethereum.NotFound
+	}
+	return head, err
+}
+
+// UncleCount returns the number of uncles in the given block.
+func (ec *Client) UncleCount(ctx context.Context, blockHash common.Hash) (uint, error) {
+	var num *hexutil.Uint
+	err := ec.c.CallContext(ctx, &num, "eth_getUncleCountByBlockHash", blockHash)
+	if err == nil && num == nil {
+		err = ethereum.NotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return uint(*num), nil
+}
+
+// UncleCountByNumber returns the number of uncles in the given block of the current
+// canonical chain. If number is nil, the latest known block is used.
+func (ec *Client) UncleCountByNumber(ctx context.Context, number *big.Int) (uint, error) {
+	var num *hexutil.Uint
+	err := ec.c.CallContext(ctx, &num, "eth_getUncleCountByBlockNumber", toBlockNumArg(number))
+	if err == nil && num == nil {
+		err = ethereum.NotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return uint(*num), nil
+}
+
 type rpcTransaction struct {
 	tx *types.Transaction
 	txExtraInfo
@@ -273,6 +321,67 @@ func (ec *Client) TransactionInBlock(ctx context.Context, blockHash common.Hash,
 	return json.tx, err
 }
 
+// TransactionCountByNumber returns the total number of transactions in the given block
+// of the current canonical chain. If number is nil, the latest known block is used.
+func (ec *Client) TransactionCountByNumber(ctx context.Context, number *big.Int) (uint, error) {
+	var num hexutil.Uint
+	err := ec.c.CallContext(ctx, &num, "eth_getBlockTransactionCountByNumber", toBlockNumArg(number))
+	return uint(num), err
+}
+
+// TransactionInBlockByNumber returns a single transaction at index in the given block
+// of the current canonical chain. If number is nil, the latest known block is used.
+func (ec *Client) TransactionInBlockByNumber(ctx context.Context, number *big.Int, index uint) (*types.Transaction, error) {
+	var json *rpcTransaction
+	err := ec.c.CallContext(ctx, &json, "eth_getTransactionByBlockNumberAndIndex", toBlockNumArg(number), hexutil.Uint64(index))
+	if err != nil {
+		return nil, err
+	}
+	if json == nil {
+		return nil, ethereum.NotFound
+	} else if _, r, _ := json.tx.RawSignatureValues(); r == nil {
+		return nil, fmt.Errorf("server returned transaction without signature")
+	}
+	if json.From != nil && json.BlockHash != nil {
+		setSenderFromServer(json.tx, *json.From, *json.BlockHash)
+	}
+	return json.tx, err
+}
+
+// RawTransactionByHash returns the canonical encoding of the transaction with the
+// given hash.
+func (ec *Client) RawTransactionByHash(ctx context.Context, hash common.Hash) ([]byte, error) {
+	var raw hexutil.Bytes
+	err := ec.c.CallContext(ctx, &raw, "eth_getRawTransactionByHash", hash)
+	if err == nil && len(raw) == 0 {
+		err = ethereum.NotFound
+	}
+	return raw, err
+}
+
+// RawTransactionInBlock returns the canonical encoding of the transaction at index in
+// the given block.
+func (ec *Client) RawTransactionInBlock(ctx context.Context, blockHash common.Hash, index uint) ([]byte, error) {
+	var raw hexutil.Bytes
+	err := ec.c.CallContext(ctx, &raw, "eth_getRawTransactionByBlockHashAndIndex", blockHash, hexutil.Uint(index))
+	if err == nil && len(raw) == 0 {
+		err = ethereum.NotFound
+	}
+	return raw, err
+}
+
+// RawTransactionInBlockByNumber returns the canonical encoding of the transaction at
+// index in the given block of the current canonical chain. If number is nil, the latest
+// known block is used.
+func (ec *Client) RawTransactionInBlockByNumber(ctx context.Context, number *big.Int, index uint) ([]byte, error) {
+	var raw hexutil.Bytes
+	err := ec.c.CallContext(ctx, &raw, "eth_getRawTransactionByBlockNumberAndIndex", toBlockNumArg(number), hexutil.Uint(index))
+	if err == nil && len(raw) == 0 {
+		err = ethereum.NotFound
+	}
+	return raw, err
+}
+
 // TransactionReceipt returns the receipt of a transaction by transaction hash.
 // Note that the receipt is not available for pending transactions.
 func (ec *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
@@ -286,6 +395,17 @@ func (ec *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*
 	return r, err
 }
 
+// BlockReceipts returns the receipts of all transactions in the block identified
+// by blockNrOrHash.
+func (ec *Client) BlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]*types.Receipt, error) {
+	var r []*types.Receipt
+	err := ec.c.CallContext(ctx, &r, "eth_getBlockReceipts", blockNrOrHash)
+	if err == nil && r == nil {
+		return nil, ethereum.NotFound
+	}
+	return r, err
+}
+
 // SyncProgress retrieves the current progress of the sync algorithm. If there's
 // no sync currently running, it returns nil.
 func (ec *Client) SyncProgress(ctx context.Context) (*alba.SyncProgress, error) {
@@ -380,6 +500,67 @@ func (ec *Client) SubscribeFilterLogs(ctx context.Context, q alba.FilterQuery, c
 	return ec.c.AlbaSubscribe(ctx, ch, "logs", arg)
 }
 
+// NewFilter installs a log filter on the node. Logs matching the query are polled
+// with FilterLogChanges, or retrieved all at once with FilterLogsByID. The node
+// drops filters which are not polled for a while.
+func (ec *Client) NewFilter(ctx context.Context, q ethereum.FilterQuery) (rpc.ID, error) {
+	arg, err := toFilterArg(q)
+	if err != nil {
+		return "", err
+	}
+	var id rpc.ID
+	err = ec.c.CallContext(ctx, &id, "eth_newFilter", arg)
+	return id, err
+}
+
+// NewBlockFilter installs a filter on the node for the hashes of new blocks,
+// which are polled with FilterHashChanges.
+func (ec *Client) NewBlockFilter(ctx context.Context) (rpc.ID, error) {
+	var id rpc.ID
+	err := ec.c.CallContext(ctx, &id, "eth_newBlockFilter")
+	return id, err
+}
+
+// NewPendingTransactionFilter installs a filter on the node for the hashes of
+// transactions entering the pending state, which are polled with
+// FilterHashChanges.
+func (ec *Client) NewPendingTransactionFilter(ctx context.Context) (rpc.ID, error) {
+	var id rpc.ID
+	err := ec.c.CallContext(ctx, &id, "eth_newPendingTransactionFilter")
+	return id, err
+}
+
+// FilterHashChanges returns the hashes collected by a block or pending
+// transaction filter since it was last polled.
+func (ec *Client) FilterHashChanges(ctx context.Context, id rpc.ID) ([]common.Hash, error) {
+	var result []common.Hash
+	err := ec.c.CallContext(ctx, &result, "eth_getFilterChanges", id)
+	return result, err
+}
+
+// FilterLogChanges returns the logs matched by a log filter since it was last
+// polled.
+func (ec *Client) FilterLogChanges(ctx context.Context, id rpc.ID) ([]types.Log, error) {
+	var result []types.Log
+	err := ec.c.CallContext(ctx, &result, "eth_getFilterChanges", id)
+	return result, err
+}
+
+// FilterLogsByID returns all logs matching the query of an installed log filter.
+func (ec *Client) FilterLogsByID(ctx context.Context, id rpc.ID) ([]types.Log, error) {
+	var result []types.Log
+	err := ec.c.CallContext(ctx, &result, "eth_getFilterLogs", id)
+	return result, err
+}
+
+// UninstallFilter removes a filter from the node. It reports whether the filter
+// was installed.
+func (ec *Client) UninstallFilter(ctx context.Context, id rpc.ID) (bool, error) {
+	var result bool
+	err := ec.c.CallContext(ctx, &result, "eth_uninstallFilter", id)
+	return result, err
+}
+
 func toFilterArg(q alba.FilterQuery) (interface{}, error) {
 	arg := map[string]interface{}{
 		"address": q.Addresses,
@@ -439,6 +620,20 @@ func (ec *Client) PendingTransactionCount(ctx context.Context) (uint, error) {
 	return uint(num), err
 }
 
+// PendingTransactions returns the transactions in the pending pool which are sent by
+// one of the accounts managed by the node.
+func (ec *Client) PendingTransactions(ctx context.Context) ([]*types.Transaction, error) {
+	var result []*rpcTransaction
+	if err := ec.c.CallContext(ctx, &result, "eth_pendingTransactions"); err != nil {
+		return nil, err
+	}
+	txs := make([]*types.Transaction, len(result))
+	for i, tx := range result {
+		txs[i] = tx.tx
+	}
+	return txs, nil
+}
+
 // Contract Calling
 
 // CallContract executes a message call transaction, which is directly executed in the VM
@@ -467,6 +662,45 @@ func (ec *Client) PendingCallContract(ctx context.Context, msg alba.CallMsg) ([]
 	return hex, nil
 }
 
+// batchCallLimit is the maximum number of calls BatchCallContract sends in one
+// JSON-RPC batch. It matches the default batch item limit of the RPC server.
+const batchCallLimit = 1000
+
+// BatchCallContract executes several message calls in JSON-RPC batches, all at
+// the given block. Calls beyond batchCallLimit are split over several batches.
+// It returns the output and the error of each call, the returned error is only
+// set if a batch as a whole failed.
+func (ec *Client) BatchCallContract(ctx context.Context, msgs []ethereum.CallMsg, blockNumber *big.Int) ([][]byte, []error, error) {
+	var (
+		hexes = make([]hexutil.Bytes, len(msgs))
+		reqs  = make([]rpc.BatchElem, len(msgs))
+	)
+	for i, msg := range msgs {
+		reqs[i] = rpc.BatchElem{
+			Method: "eth_call",
+			Args:   []interface{}{toCallArg(msg), toBlockNumArg(blockNumber)},
+			Result: &hexes[i],
+		}
+	}
+	for start := 0; start < len(reqs); start += batchCallLimit {
+		end := start + batchCallLimit
+		if end > len(reqs) {
+			end = len(reqs)
+		}
+		if err := ec.c.BatchCallContext(ctx, reqs[start:end]); err != nil {
+			return nil, nil, err
+		}
+	}
+	var (
+		outputs = make([][]byte, len(msgs))
+		errs    = make([]error, len(msgs))
+	)
+	for i := range reqs {
+		outputs[i], errs[i] = hexes[i], reqs[i].Error
+	}
+	return outputs, errs, nil
+}
+
 // SuggestGasPrice retrieves the currently suggested gas price to allow a timely
 // execution of a transaction.
 func (ec *Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
@@ -487,6 +721,40 @@ func (ec *Client) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
 	return (*big.Int)(&hex), nil
 }
 
+type feeHistoryResultMarshaling struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// FeeHistory retrieves the fee market history of the blockCount blocks up to and
+// including lastBlock, along with the given percentiles of the effective priority
+// fees paid in each block. If lastBlock is nil, the latest known block is used.
+func (ec *Client) FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error) {
+	var res feeHistoryResultMarshaling
+	if err := ec.c.CallContext(ctx, &res, "eth_feeHistory", hexutil.Uint(blockCount), toBlockNumArg(lastBlock), rewardPercentiles); err != nil {
+		return nil, err
+	}
+	reward := make([][]*big.Int, len(res.Reward))
+	for i, r := range res.Reward {
+		reward[i] = make([]*big.Int, len(r))
+		for j, r := range r {
+			reward[i][j] = (*big.Int)(r)
+		}
+	}
+	baseFee := make([]*big.Int, len(res.BaseFee))
+	for i, b := range res.BaseFee {
+		baseFee[i] = (*big.Int)(b)
+	}
+	return &ethereum.FeeHistory{
+		OldestBlock:  (*big.Int)(res.OldestBlock),
+		Reward:       reward,
+		BaseFee:      baseFee,
+		GasUsedRatio: res.GasUsedRatio,
+	}, nil
+}
+
 // EstimateGas tries to estimate the gas needed to execute a specific transaction based on
 // the current pending state of the backend blockchain. There is no guarantee that this is
 // the true gas limit requirement as other transactions may be added or removed by miners,
@@ -512,6 +780,71 @@ func (ec *Client) SendTransaction(ctx context.Context, tx *types.Transaction) er
 	return ec.c.CallContext(ctx, nil, "alba_sendRawTransaction", hexutil.Encode(data))
 }
 
+// Node Accounts
+//
+// The methods below rely on the accounts managed by the remote node. They fail if the
+// node doesn't hold the key of the sender, or if the account is locked.
+
+// Accounts returns the addresses of the accounts managed by the node.
+func (ec *Client) Accounts(ctx context.Context) ([]common.Address, error) {
+	var result []common.Address
+	err := ec.c.CallContext(ctx, &result, "eth_accounts")
+	return result, err
+}
+
+// Sign calculates an ECDSA signature of data with the given account:
+// sign(keccak256("\x19Ethereum Signed Message:\n" + len(data) + data)).
+func (ec *Client) Sign(ctx context.Context, account common.Address, data []byte) ([]byte, error) {
+	var result hexutil.Bytes
+	err := ec.c.CallContext(ctx, &result, "eth_sign", account, hexutil.Bytes(data))
+	return result, err
+}
+
+// FillTransaction fills in the defaults of the transaction described by msg, such as
+// the nonce, gas limit and fees, and returns it unsigned.
+func (ec *Client) FillTransaction(ctx context.Context, msg ethereum.CallMsg) (*types.Transaction, error) {
+	var result signTransactionResult
+	if err := ec.c.CallContext(ctx, &result, "eth_fillTransaction", toSendTxArg(msg)); err != nil {
+		return nil, err
+	}
+	return result.Tx, nil
+}
+
+// SignTransaction signs the transaction described by msg with the account of the
+// sender, without submitting it. The gas limit and fees must be set in msg.
+func (ec *Client) SignTransaction(ctx context.Context, msg ethereum.CallMsg, nonce uint64) (*types.Transaction, error) {
+	var result signTransactionResult
+	if err := ec.c.CallContext(ctx, &result, "eth_signTransaction", toTransactionArg(msg, nonce)); err != nil {
+		return nil, err
+	}
+	return result.Tx, nil
+}
+
+// SendUnsignedTransaction fills in the defaults of the transaction described by msg,
+// signs it with the account of the sender and injects it into the pending pool.
+func (ec *Client) SendUnsignedTransaction(ctx context.Context, msg ethereum.CallMsg) (common.Hash, error) {
+	var hash common.Hash
+	err := ec.c.CallContext(ctx, &hash, "eth_sendTransaction", toSendTxArg(msg))
+	return hash, err
+}
+
+// Resend replaces the pending transaction described by msg and nonce with a copy
+// using the given gas price and gas limit. A zero gas limit keeps the original one.
+func (ec *Client) Resend(ctx context.Context, msg ethereum.CallMsg, nonce uint64, gasPrice *big.Int, gasLimit uint64) (common.Hash, error) {
+	var gas *hexutil.Uint64
+	if gasLimit != 0 {
+		gas = (*hexutil.Uint64)(&gasLimit)
+	}
+	var hash common.Hash
+	err := ec.c.CallContext(ctx, &hash, "eth_resend", toTransactionArg(msg, nonce), (*hexutil.Big)(gasPrice), gas)
+	return hash, err
+}
+
+type signTransactionResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
 func toBlockNumArg(number *big.Int) string {
 	if number == nil {
 		return "latest"
@@ -542,3 +875,41 @@ func toCallArg(msg ethereum.CallMsg) interface{} {
 	}
 	return arg
 }
+
+// toSendTxArg converts msg into the transaction arguments of the node account
+// methods and simulated calls. Unlike toCallArg, it carries the dynamic fee
+// fields and access list, which become part of the transaction the node signs.
+func toSendTxArg(msg ethereum.CallMsg) map[string]interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["data"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+	if msg.GasFeeCap != nil {
+		arg["maxFeePerGas"] = (*hexutil.Big)(msg.GasFeeCap)
+	}
+	if msg.GasTipCap != nil {
+		arg["maxPriorityFeePerGas"] = (*hexutil.Big)(msg.GasTipCap)
+	}
+	if msg.AccessList != nil {
+		arg["accessList"] = msg.AccessList
+	}
+	return arg
+}
+
+func toTransactionArg(msg ethereum.CallMsg, nonce uint64) interface{} {
+	arg := toSendTxArg(msg)
+	arg["nonce"] = hexutil.Uint64(nonce)
+	return arg
+}
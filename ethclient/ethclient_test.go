@@ -28,6 +28,7 @@ import (
 
 	"github.com/pictor01/ALBA"
 	"github.com/pictor01/ALBA/common"
+	"github.com/pictor01/ALBA/common/hexutil"
 	"github.com/pictor01/ALBA/consensus/albaash"
 	"github.com/pictor01/ALBA/core"
 	"github.com/pictor01/ALBA/core/rawdb"
@@ -291,6 +292,27 @@ func TestEthClient(t *testing.T) {
 		"TransactionSender": {
 			func(t *testing.T) { testTransactionSender(t, client) },
 		},
+		"BlockReceipts": {
+			func(t *testing.T) { testBlockReceipts(t, chain, client) },
+		},
+		"FeeHistory": {
+			func(t *testing.T) { testFeeHistory(t, client) },
+		},
+		"Uncles": {
+			func(t *testing.T) { testUncles(t, chain, client) },
+		},
+		"RawTransactions": {
+			func(t *testing.T) { testRawTransactions(t, chain, client) },
+		},
+		"NodeAccounts": {
+			func(t *testing.T) { testNodeAccounts(t, client) },
+		},
+		"Filters": {
+			func(t *testing.T) { testFilters(t, client) },
+		},
+		"Simulate": {
+			func(t *testing.T) { testSimulate(t, client) },
+		},
 	}
 
 	t.Parallel()
@@ -534,6 +556,99 @@ func testCallContract(t *testing.T, client *rpc.Client) {
 	}
 }
 
+func testFilters(t *testing.T, client *rpc.Client) {
+	ec := NewClient(client)
+	ctx := context.Background()
+
+	blockID, err := ec.NewBlockFilter(ctx)
+	if err != nil {
+		t.Fatalf("NewBlockFilter: %v", err)
+	}
+	pendingID, err := ec.NewPendingTransactionFilter(ctx)
+	if err != nil {
+		t.Fatalf("NewPendingTransactionFilter: %v", err)
+	}
+	logID, err := ec.NewFilter(ctx, ethereum.FilterQuery{FromBlock: big.NewInt(0)})
+	if err != nil {
+		t.Fatalf("NewFilter: %v", err)
+	}
+	if _, err := ec.FilterHashChanges(ctx, blockID); err != nil {
+		t.Fatalf("FilterHashChanges(block): %v", err)
+	}
+	if _, err := ec.FilterHashChanges(ctx, pendingID); err != nil {
+		t.Fatalf("FilterHashChanges(pending): %v", err)
+	}
+	// The test chain only contains plain transfers, so there are no logs.
+	if logs, err := ec.FilterLogChanges(ctx, logID); err != nil {
+		t.Fatalf("FilterLogChanges: %v", err)
+	} else if len(logs) != 0 {
+		t.Fatalf("FilterLogChanges: unexpected logs %v", logs)
+	}
+	if logs, err := ec.FilterLogsByID(ctx, logID); err != nil {
+		t.Fatalf("FilterLogsByID: %v", err)
+	} else if len(logs) != 0 {
+		t.Fatalf("FilterLogsByID: unexpected logs %v", logs)
+	}
+	for _, id := range []rpc.ID{blockID, pendingID, logID} {
+		if ok, err := ec.UninstallFilter(ctx, id); err != nil || !ok {
+			t.Fatalf("UninstallFilter(%s) = %v, %v; want true", id, ok, err)
+		}
+		if ok, err := ec.UninstallFilter(ctx, id); err != nil || ok {
+			t.Fatalf("second UninstallFilter(%s) = %v, %v; want false", id, ok, err)
+		}
+	}
+	if _, err := ec.FilterHashChanges(ctx, blockID); err == nil {
+		t.Fatal("FilterHashChanges succeeded on an uninstalled filter")
+	}
+}
+
+func testSimulate(t *testing.T, client *rpc.Client) {
+	ec := NewClient(client)
+
+	var (
+		to       = common.Address{2}
+		contract = common.Address{3}
+		parent   = rpc.BlockNumberOrHashWithNumber(2)
+	)
+	blocks := []SimulateBlock{{
+		StateOverrides: map[common.Address]SimulateAccountOverride{
+			// PUSH1 42 PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+			contract: {Code: common.FromHex("602a60005260206000f3")},
+		},
+		Calls: []ethereum.CallMsg{
+			{From: testAddr, To: &to, Value: big.NewInt(1)},
+			{From: testAddr, To: &contract},
+		},
+	}}
+	results, err := ec.Simulate(context.Background(), blocks, &parent)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("unexpected number of blocks: %d", len(results))
+	}
+	block := results[0]
+	if block.Number != 3 {
+		t.Fatalf("unexpected block number: %d", block.Number)
+	}
+	if len(block.Calls) != 2 {
+		t.Fatalf("unexpected number of calls: %d", len(block.Calls))
+	}
+	if call := block.Calls[0]; call.Status != 1 || call.GasUsed != 21000 || call.Error != "" {
+		t.Fatalf("unexpected transfer result: %+v", call)
+	}
+	call := block.Calls[1]
+	if call.Status != 1 || call.Error != "" {
+		t.Fatalf("unexpected contract call result: %+v", call)
+	}
+	if want := common.LeftPadBytes([]byte{42}, 32); !bytes.Equal(call.ReturnData, want) {
+		t.Fatalf("unexpected return data: %x, want %x", call.ReturnData, want)
+	}
+	if block.GasUsed != block.Calls[0].GasUsed+call.GasUsed {
+		t.Fatalf("unexpected block gas used: %d", block.GasUsed)
+	}
+}
+
 func testAtFunctions(t *testing.T, client *rpc.Client) {
 	ec := NewClient(client)
 
@@ -639,6 +754,201 @@ func testTransactionSender(t *testing.T, client *rpc.Client) {
 	}
 }
 
+func testBlockReceipts(t *testing.T, chain []*types.Block, client *rpc.Client) {
+	ec := NewClient(client)
+	ctx := context.Background()
+
+	// Block #2 contains both test transactions, by number and by hash.
+	for _, blockNrOrHash := range []rpc.BlockNumberOrHash{
+		rpc.BlockNumberOrHashWithNumber(2),
+		rpc.BlockNumberOrHashWithHash(chain[2].Hash(), false),
+	} {
+		receipts, err := ec.BlockReceipts(ctx, blockNrOrHash)
+		if err != nil {
+			t.Fatalf("can't get receipts of %v: %v", blockNrOrHash.String(), err)
+		}
+		if len(receipts) != 2 {
+			t.Fatalf("wrong number of receipts: have %d, want %d", len(receipts), 2)
+		}
+		for i, tx := range []*types.Transaction{testTx1, testTx2} {
+			receipt := receipts[i]
+			if receipt.TxHash != tx.Hash() {
+				t.Errorf("receipt %d: wrong tx hash %v, want %v", i, receipt.TxHash, tx.Hash())
+			}
+			if receipt.BlockHash != chain[2].Hash() {
+				t.Errorf("receipt %d: wrong block hash %v, want %v", i, receipt.BlockHash, chain[2].Hash())
+			}
+			if receipt.TransactionIndex != uint(i) {
+				t.Errorf("receipt %d: wrong index %d", i, receipt.TransactionIndex)
+			}
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				t.Errorf("receipt %d: wrong status %d", i, receipt.Status)
+			}
+			if receipt.CumulativeGasUsed != uint64(i+1)*params.TxGas {
+				t.Errorf("receipt %d: wrong cumulative gas %d", i, receipt.CumulativeGasUsed)
+			}
+		}
+	}
+	// Blocks without transactions return an empty list.
+	receipts, err := ec.BlockReceipts(ctx, rpc.BlockNumberOrHashWithNumber(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(receipts) != 0 {
+		t.Fatalf("unexpected receipts in empty block: %d", len(receipts))
+	}
+	// Unknown blocks are reported as not found.
+	if _, err := ec.BlockReceipts(ctx, rpc.BlockNumberOrHashWithNumber(10)); err != ethereum.NotFound {
+		t.Fatalf("wrong error for missing block: %v", err)
+	}
+}
+
+func testFeeHistory(t *testing.T, client *rpc.Client) {
+	ec := NewClient(client)
+
+	history, err := ec.FeeHistory(context.Background(), 1, big.NewInt(2), []float64{50})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if history.OldestBlock.Cmp(big.NewInt(2)) != 0 {
+		t.Fatalf("unexpected oldest block: %v", history.OldestBlock)
+	}
+	if len(history.Reward) != 1 || len(history.Reward[0]) != 1 {
+		t.Fatalf("unexpected rewards: %v", history.Reward)
+	}
+	// The base fees include the one of the block after the last one
+	if len(history.BaseFee) != 2 {
+		t.Fatalf("unexpected base fees: %v", history.BaseFee)
+	}
+	if history.BaseFee[0].Cmp(big.NewInt(params.InitialBaseFee)) >= 0 {
+		t.Fatalf("unexpected base fee of block 2: %v", history.BaseFee[0])
+	}
+	if len(history.GasUsedRatio) != 1 || history.GasUsedRatio[0] == 0 {
+		t.Fatalf("unexpected gas used ratios: %v", history.GasUsedRatio)
+	}
+}
+
+func testUncles(t *testing.T, chain []*types.Block, client *rpc.Client) {
+	ec := NewClient(client)
+	ctx := context.Background()
+
+	// The test chain doesn't contain any uncles
+	count, err := ec.UncleCount(ctx, chain[2].Hash())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("unexpected uncle count: %d", count)
+	}
+	count, err = ec.UncleCountByNumber(ctx, big.NewInt(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("unexpected uncle count: %d", count)
+	}
+	if _, err := ec.UncleCount(ctx, common.Hash{1}); err != ethereum.NotFound {
+		t.Fatalf("wrong error for missing block: %v", err)
+	}
+	if _, err := ec.UncleByBlockHashAndIndex(ctx, chain[2].Hash(), 0); err != ethereum.NotFound {
+		t.Fatalf("wrong error for missing uncle: %v", err)
+	}
+	if _, err := ec.UncleByBlockNumberAndIndex(ctx, big.NewInt(2), 0); err != ethereum.NotFound {
+		t.Fatalf("wrong error for missing uncle: %v", err)
+	}
+}
+
+func testRawTransactions(t *testing.T, chain []*types.Block, client *rpc.Client) {
+	ec := NewClient(client)
+	ctx := context.Background()
+
+	want, err := testTx2.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ec.RawTransactionByHash(ctx, testTx2.Hash())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(raw, want) {
+		t.Fatalf("wrong raw transaction by hash: %x", raw)
+	}
+	raw, err = ec.RawTransactionInBlock(ctx, chain[2].Hash(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(raw, want) {
+		t.Fatalf("wrong raw transaction in block: %x", raw)
+	}
+	raw, err = ec.RawTransactionInBlockByNumber(ctx, big.NewInt(2), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(raw, want) {
+		t.Fatalf("wrong raw transaction in block by number: %x", raw)
+	}
+	if _, err := ec.RawTransactionByHash(ctx, common.Hash{1}); err != ethereum.NotFound {
+		t.Fatalf("wrong error for missing transaction: %v", err)
+	}
+	// Decoded transactions by block number
+	count, err := ec.TransactionCountByNumber(ctx, big.NewInt(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("unexpected transaction count: %d", count)
+	}
+	tx, err := ec.TransactionInBlockByNumber(ctx, big.NewInt(2), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tx.Hash() != testTx2.Hash() {
+		t.Fatalf("wrong tx hash %v, want %v", tx.Hash(), testTx2.Hash())
+	}
+}
+
+func testNodeAccounts(t *testing.T, client *rpc.Client) {
+	ec := NewClient(client)
+	ctx := context.Background()
+
+	// The test node doesn't manage any accounts
+	accounts, err := ec.Accounts(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(accounts) != 0 {
+		t.Fatalf("unexpected accounts: %v", accounts)
+	}
+	pending, err := ec.PendingTransactions(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("unexpected pending transactions: %v", pending)
+	}
+	// Filling in a transaction doesn't need the key of the sender
+	tx, err := ec.FillTransaction(ctx, ethereum.CallMsg{
+		From:      testAddr,
+		To:        &common.Address{2},
+		Value:     big.NewInt(1),
+		GasFeeCap: big.NewInt(3 * params.InitialBaseFee),
+		GasTipCap: big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tx.Gas() != params.TxGas || tx.Type() != types.DynamicFeeTxType {
+		t.Fatalf("unexpected filled transaction: gas %d, type %d", tx.Gas(), tx.Type())
+	}
+	if tx.GasFeeCap().Cmp(big.NewInt(3*params.InitialBaseFee)) != 0 || tx.GasTipCap().Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("unexpected filled transaction fees: cap %v, tip %v", tx.GasFeeCap(), tx.GasTipCap())
+	}
+	// Signing needs the key of the sender
+	if _, err := ec.SendUnsignedTransaction(ctx, ethereum.CallMsg{From: testAddr, To: &common.Address{2}}); err == nil {
+		t.Fatal("expected error sending a transaction of an unknown account")
+	}
+}
+
 func sendTransaction(ec *Client) error {
 	chainID, err := ec.ChainID(context.Background())
 	if err != nil {
@@ -662,3 +972,44 @@ func sendTransaction(ec *Client) error {
 	}
 	return ec.SendTransaction(context.Background(), tx)
 }
+
+// batchCallService echoes the input data of eth_call requests.
+type batchCallService struct{}
+
+func (s *batchCallService) Call(args map[string]interface{}, block string) (hexutil.Bytes, error) {
+	data, _ := args["data"].(string)
+	return hexutil.Decode(data)
+}
+
+func TestBatchCallContractLimit(t *testing.T) {
+	server := rpc.NewServer()
+	defer server.Stop()
+
+	server.SetBatchLimits(batchCallLimit, 0)
+	if err := server.RegisterName("eth", new(batchCallService)); err != nil {
+		t.Fatal(err)
+	}
+	ec := NewClient(rpc.DialInProc(server))
+	defer ec.Close()
+
+	// Exceed the server's batch limit, the calls must be split over batches
+	msgs := make([]ethereum.CallMsg, 2*batchCallLimit+1)
+	for i := range msgs {
+		msgs[i] = ethereum.CallMsg{To: &common.Address{1}, Data: []byte{0x01, byte(i >> 8), byte(i)}}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	outputs, errs, err := ec.BatchCallContract(ctx, msgs, nil)
+	if err != nil {
+		t.Fatalf("batch failed: %v", err)
+	}
+	for i := range msgs {
+		if errs[i] != nil {
+			t.Fatalf("call %d failed: %v", i, errs[i])
+		}
+		if !bytes.Equal(outputs[i], msgs[i].Data) {
+			t.Fatalf("call %d output mismatch: have %x, want %x", i, outputs[i], msgs[i].Data)
+		}
+	}
+}
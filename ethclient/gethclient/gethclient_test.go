@@ -31,6 +31,8 @@ import (
 	"github.com/pictor01/ALBA/crypto"
 	"github.com/pictor01/ALBA/alba"
 	"github.com/pictor01/ALBA/alba/albaconfig"
+	"github.com/pictor01/ALBA/alba/tracers"
+	_ "github.com/pictor01/ALBA/alba/tracers/native"
 	"github.com/pictor01/ALBA/albaclient"
 	"github.com/pictor01/ALBA/node"
 	"github.com/pictor01/ALBA/params"
@@ -58,6 +60,7 @@ func newTestBackend(t *testing.T) (*node.Node, []*types.Block) {
 	if err != nil {
 		t.Fatalf("can't create new ethereum service: %v", err)
 	}
+	n.RegisterAPIs(tracers.APIs(albaservice.APIBackend))
 	// Import the test chain.
 	if err := n.Start(); err != nil {
 		t.Fatalf("can't start test node: %v", err)
@@ -117,6 +120,12 @@ func TestGethClient(t *testing.T) {
 		}, {
 			"TestGetNodeInfo",
 			func(t *testing.T) { testGetNodeInfo(t, client) },
+		}, {
+			"TestTraceCall",
+			func(t *testing.T) { testTraceCall(t, client) },
+		}, {
+			"TestTraceBlock",
+			func(t *testing.T) { testTraceBlock(t, client) },
 		}, {
 			"TestSetHead",
 			func(t *testing.T) { testSetHead(t, client) },
@@ -306,3 +315,79 @@ func testCallContract(t *testing.T, client *rpc.Client) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func testTraceCall(t *testing.T, client *rpc.Client) {
+	ec := New(client)
+	// Deploy code which stores a value and reverts
+	msg := alba.CallMsg{
+		From:     testAddr,
+		Gas:      100000,
+		GasPrice: big.NewInt(1000000000),
+		Data:     common.FromHex("0x608060806080608155fd"),
+	}
+	// Trace with the struct logger
+	result, err := ec.TraceCall(context.Background(), msg, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.StructLogs == nil || result.CallFrame != nil {
+		t.Fatalf("unexpected tracer output: %+v", result)
+	}
+	logs := result.StructLogs
+	if !logs.Failed {
+		t.Fatal("expected failed execution")
+	}
+	if len(logs.StructLogs) != 6 {
+		t.Fatalf("unexpected number of steps: have %d, want %d", len(logs.StructLogs), 6)
+	}
+	if op := logs.StructLogs[0].Op; op != "PUSH1" {
+		t.Fatalf("unexpected first op: %v", op)
+	}
+	if stack := logs.StructLogs[1].Stack; len(stack) != 1 || stack[0].Cmp(big.NewInt(0x80)) != 0 {
+		t.Fatalf("unexpected stack: %v", stack)
+	}
+	if storage := logs.StructLogs[4].Storage; storage[common.HexToHash("0x81")] != common.HexToHash("0x80") {
+		t.Fatalf("unexpected storage: %v", storage)
+	}
+	// Trace with the call tracer, overriding the balance of the sender
+	overrides := map[common.Address]OverrideAccount{
+		testAddr: {Balance: big.NewInt(1e18)},
+	}
+	msg.Value = big.NewInt(1e17)
+	result, err = ec.TraceCall(context.Background(), msg, nil, &TraceConfig{Tracer: CallTracer}, &overrides)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frame := result.CallFrame
+	if frame == nil || result.StructLogs != nil {
+		t.Fatalf("unexpected tracer output: %+v", result)
+	}
+	if frame.Type != "CREATE" || frame.From != testAddr || frame.Error == "" {
+		t.Fatalf("unexpected call frame: %+v", frame)
+	}
+	if frame.Value.Cmp(msg.Value) != 0 {
+		t.Fatalf("unexpected value: have %v, want %v", frame.Value, msg.Value)
+	}
+	if !bytes.Equal(frame.Input, msg.Data) {
+		t.Fatalf("unexpected input: %x", frame.Input)
+	}
+	if frame.Gas == 0 || frame.GasUsed == 0 {
+		t.Fatalf("unexpected gas: %d %d", frame.Gas, frame.GasUsed)
+	}
+}
+
+func testTraceBlock(t *testing.T, client *rpc.Client) {
+	ec := New(client)
+	// The test chain contains a single empty block
+	results, err := ec.TraceBlockByNumber(context.Background(), big.NewInt(1), &TraceConfig{Tracer: CallTracer})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("unexpected number of traces: %d", len(results))
+	}
+	// The genesis block can't be traced
+	if _, err := ec.TraceBlockByNumber(context.Background(), big.NewInt(0), nil); err == nil {
+		t.Fatal("expected error tracing the genesis block")
+	}
+}
@@ -0,0 +1,382 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package palbaclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/pictor01/ALBA"
+	"github.com/pictor01/ALBA/common"
+	"github.com/pictor01/ALBA/common/hexutil"
+)
+
+// CallTracer is the name of the native tracer producing a tree of call frames.
+const CallTracer = "callTracer"
+
+// TraceConfig specifies how transactions are traced. A nil config traces with the
+// struct logger using the default settings of the node.
+type TraceConfig struct {
+	// Options of the struct logger, ignored by the other tracers.
+	EnableMemory     bool // Capture the memory of every step
+	DisableStack     bool // Don't capture the stack
+	DisableStorage   bool // Don't capture the touched storage slots
+	EnableReturnData bool // Capture the return data of every step
+	Limit            int  // Maximum number of steps captured, zero means unlimited
+
+	Tracer       string        // Name of the tracer, empty selects the struct logger
+	TracerConfig interface{}   // Tracer specific options, encoded as JSON
+	Timeout      time.Duration // Maximum duration of a single trace, zero uses the node default
+	Reexec       *uint64       // Number of blocks re-executed to regenerate missing state
+}
+
+// tracer returns the name of the configured tracer.
+func (config *TraceConfig) tracer() string {
+	if config == nil {
+		return ""
+	}
+	return config.Tracer
+}
+
+// traceConfig is the encoding of the config expected by the debug_trace* methods.
+type traceConfig struct {
+	EnableMemory     bool            `json:"enableMemory,omitempty"`
+	DisableStack     bool            `json:"disableStack,omitempty"`
+	DisableStorage   bool            `json:"disableStorage,omitempty"`
+	EnableReturnData bool            `json:"enableReturnData,omitempty"`
+	Limit            int             `json:"limit,omitempty"`
+	Tracer           *string         `json:"tracer,omitempty"`
+	TracerConfig     json.RawMessage `json:"tracerConfig,omitempty"`
+	Timeout          *string         `json:"timeout,omitempty"`
+	Reexec           *uint64         `json:"reexec,omitempty"`
+	StateOverrides   interface{}     `json:"stateOverrides,omitempty"`
+}
+
+// encode converts the config into the format of the debug_trace* methods.
+func (config *TraceConfig) encode() (*traceConfig, error) {
+	if config == nil {
+		return nil, nil
+	}
+	enc := &traceConfig{
+		EnableMemory:     config.EnableMemory,
+		DisableStack:     config.DisableStack,
+		DisableStorage:   config.DisableStorage,
+		EnableReturnData: config.EnableReturnData,
+		Limit:            config.Limit,
+		Reexec:           config.Reexec,
+	}
+	if config.Tracer != "" {
+		enc.Tracer = &config.Tracer
+	}
+	if config.TracerConfig != nil {
+		blob, err := json.Marshal(config.TracerConfig)
+		if err != nil {
+			return nil, err
+		}
+		enc.TracerConfig = blob
+	}
+	if config.Timeout != 0 {
+		timeout := config.Timeout.String()
+		enc.Timeout = &timeout
+	}
+	return enc, nil
+}
+
+// CallTracerConfig holds the options of the call tracer.
+type CallTracerConfig struct {
+	OnlyTopCall bool `json:"onlyTopCall,omitempty"` // Don't collect the sub-calls
+	WithLog     bool `json:"withLog,omitempty"`     // Collect the emitted event logs
+}
+
+// TraceResult is the outcome of tracing a single transaction. Depending on the
+// tracer, the output is decoded into StructLogs or CallFrame; the raw output is
+// always available for custom tracers.
+type TraceResult struct {
+	StructLogs *ExecutionResult // Output of the struct logger
+	CallFrame  *CallFrame       // Output of the call tracer
+	Raw        json.RawMessage  // Undecoded output of the tracer
+	Error      string           // Tracing failure of a transaction within a block
+}
+
+// decodeTraceResult decodes the output of the given tracer.
+func decodeTraceResult(tracer string, raw json.RawMessage) (*TraceResult, error) {
+	result := &TraceResult{Raw: raw}
+	switch tracer {
+	case "":
+		result.StructLogs = new(ExecutionResult)
+		if err := json.Unmarshal(raw, result.StructLogs); err != nil {
+			return nil, err
+		}
+	case CallTracer:
+		result.CallFrame = new(CallFrame)
+		if err := json.Unmarshal(raw, result.CallFrame); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// ExecutionResult is the output of the struct logger.
+type ExecutionResult struct {
+	Gas         uint64      // Gas used by the transaction
+	Failed      bool        // Whether the transaction failed
+	ReturnValue []byte      // Return data or revert reason of the transaction
+	StructLogs  []StructLog // Captured execution steps
+}
+
+// StructLog is a single execution step captured by the struct logger.
+type StructLog struct {
+	Pc      uint64
+	Op      string
+	Gas     uint64
+	GasCost uint64
+	Depth   int
+	Error   string
+	Stack   []*big.Int                  // Nil if stack capture is disabled
+	Memory  []byte                      // Nil unless memory capture is enabled
+	Storage map[common.Hash]common.Hash // Nil if storage capture is disabled
+}
+
+// UnmarshalJSON decodes the output of the struct logger.
+func (r *ExecutionResult) UnmarshalJSON(input []byte) error {
+	type structLog struct {
+		Pc      uint64             `json:"pc"`
+		Op      string             `json:"op"`
+		Gas     uint64             `json:"gas"`
+		GasCost uint64             `json:"gasCost"`
+		Depth   int                `json:"depth"`
+		Error   string             `json:"error"`
+		Stack   *[]*hexutil.Big    `json:"stack"`
+		Memory  *[]string          `json:"memory"`
+		Storage *map[string]string `json:"storage"`
+	}
+	type executionResult struct {
+		Gas         uint64      `json:"gas"`
+		Failed      bool        `json:"failed"`
+		ReturnValue string      `json:"returnValue"`
+		StructLogs  []structLog `json:"structLogs"`
+	}
+	var dec executionResult
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	r.Gas = dec.Gas
+	r.Failed = dec.Failed
+	r.ReturnValue = common.FromHex(dec.ReturnValue)
+	r.StructLogs = make([]StructLog, len(dec.StructLogs))
+	for i, log := range dec.StructLogs {
+		r.StructLogs[i] = StructLog{
+			Pc:      log.Pc,
+			Op:      log.Op,
+			Gas:     log.Gas,
+			GasCost: log.GasCost,
+			Depth:   log.Depth,
+			Error:   log.Error,
+		}
+		if log.Stack != nil {
+			stack := make([]*big.Int, len(*log.Stack))
+			for j, item := range *log.Stack {
+				stack[j] = item.ToInt()
+			}
+			r.StructLogs[i].Stack = stack
+		}
+		if log.Memory != nil {
+			memory := make([]byte, 0, 32*len(*log.Memory))
+			for _, word := range *log.Memory {
+				memory = append(memory, common.FromHex(word)...)
+			}
+			r.StructLogs[i].Memory = memory
+		}
+		if log.Storage != nil {
+			storage := make(map[common.Hash]common.Hash, len(*log.Storage))
+			for key, value := range *log.Storage {
+				storage[common.HexToHash(key)] = common.HexToHash(value)
+			}
+			r.StructLogs[i].Storage = storage
+		}
+	}
+	return nil
+}
+
+// CallFrame is a single call captured by the call tracer, along with the calls it
+// made in turn.
+type CallFrame struct {
+	Type         string         // Type of the call, e.g. CALL, DELEGATECALL or CREATE
+	From         common.Address // Caller
+	To           common.Address // Callee, or the created contract
+	Value        *big.Int       // Value transferred, nil for calls without value
+	Gas          uint64         // Gas available to the call
+	GasUsed      uint64         // Gas used by the call
+	Input        []byte         // Call data or init code
+	Output       []byte         // Return data or deployed code
+	Error        string         // Error the call failed with, empty on success
+	RevertReason string         // Decoded revert reason, if any
+	Calls        []CallFrame    // Calls made by this call
+	Logs         []CallLog      // Logs emitted by this call, only with WithLog
+}
+
+// CallLog is an event log emitted during a traced call.
+type CallLog struct {
+	Address common.Address `json:"address"`
+	Topics  []common.Hash  `json:"topics"`
+	Data    hexutil.Bytes  `json:"data"`
+}
+
+// UnmarshalJSON decodes the output of the call tracer.
+func (f *CallFrame) UnmarshalJSON(input []byte) error {
+	type callFrame struct {
+		Type         string          `json:"type"`
+		From         common.Address  `json:"from"`
+		To           *common.Address `json:"to"`
+		Value        *hexutil.Big    `json:"value"`
+		Gas          hexutil.Uint64  `json:"gas"`
+		GasUsed      hexutil.Uint64  `json:"gasUsed"`
+		Input        hexutil.Bytes   `json:"input"`
+		Output       hexutil.Bytes   `json:"output"`
+		Error        string          `json:"error"`
+		RevertReason string          `json:"revertReason"`
+		Calls        []CallFrame     `json:"calls"`
+		Logs         []CallLog       `json:"logs"`
+	}
+	var dec callFrame
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*f = CallFrame{
+		Type:         dec.Type,
+		From:         dec.From,
+		Value:        (*big.Int)(dec.Value),
+		Gas:          uint64(dec.Gas),
+		GasUsed:      uint64(dec.GasUsed),
+		Input:        dec.Input,
+		Output:       dec.Output,
+		Error:        dec.Error,
+		RevertReason: dec.RevertReason,
+		Calls:        dec.Calls,
+		Logs:         dec.Logs,
+	}
+	if dec.To != nil {
+		f.To = *dec.To
+	}
+	return nil
+}
+
+// TraceTransaction re-executes the transaction with the given hash and returns the
+// output of the configured tracer.
+func (ec *Client) TraceTransaction(ctx context.Context, hash common.Hash, config *TraceConfig) (*TraceResult, error) {
+	enc, err := config.encode()
+	if err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if err := ec.c.CallContext(ctx, &raw, "debug_traceTransaction", hash, enc); err != nil {
+		return nil, err
+	}
+	return decodeTraceResult(config.tracer(), raw)
+}
+
+// TraceCall executes the message call on top of the given block and returns the
+// output of the configured tracer. The block number can be nil, in which case the
+// call runs on top of the latest known block. overrides optionally replaces the
+// state of accounts before executing the call.
+func (ec *Client) TraceCall(ctx context.Context, msg alba.CallMsg, blockNumber *big.Int, config *TraceConfig, overrides *map[common.Address]OverrideAccount) (*TraceResult, error) {
+	enc, err := config.encode()
+	if err != nil {
+		return nil, err
+	}
+	if overrides != nil {
+		if enc == nil {
+			enc = new(traceConfig)
+		}
+		enc.StateOverrides = toOverrideMap(overrides)
+	}
+	var raw json.RawMessage
+	if err := ec.c.CallContext(ctx, &raw, "debug_traceCall", toCallArg(msg), toBlockNumArg(blockNumber), enc); err != nil {
+		return nil, err
+	}
+	return decodeTraceResult(config.tracer(), raw)
+}
+
+// TraceBlockByNumber re-executes all transactions of the given block of the current
+// canonical chain and returns the output of the configured tracer for each of them.
+// The block number can be nil, in which case the latest known block is traced.
+func (ec *Client) TraceBlockByNumber(ctx context.Context, number *big.Int, config *TraceConfig) ([]*TraceResult, error) {
+	return ec.traceBlock(ctx, "debug_traceBlockByNumber", toBlockNumArg(number), config)
+}
+
+// TraceBlockByHash re-executes all transactions of the block with the given hash and
+// returns the output of the configured tracer for each of them.
+func (ec *Client) TraceBlockByHash(ctx context.Context, hash common.Hash, config *TraceConfig) ([]*TraceResult, error) {
+	return ec.traceBlock(ctx, "debug_traceBlockByHash", hash, config)
+}
+
+// TraceBlock re-executes all transactions of the given RLP encoded block and returns
+// the output of the configured tracer for each of them.
+func (ec *Client) TraceBlock(ctx context.Context, blob []byte, config *TraceConfig) ([]*TraceResult, error) {
+	return ec.traceBlock(ctx, "debug_traceBlock", hexutil.Bytes(blob), config)
+}
+
+// TraceBadBlock re-executes all transactions of the bad block with the given hash,
+// which was rejected by the node, and returns the output of the configured tracer for
+// each of them.
+func (ec *Client) TraceBadBlock(ctx context.Context, hash common.Hash, config *TraceConfig) ([]*TraceResult, error) {
+	return ec.traceBlock(ctx, "debug_traceBadBlock", hash, config)
+}
+
+// traceBlock runs one of the block tracing methods and decodes the results.
+func (ec *Client) traceBlock(ctx context.Context, method string, block interface{}, config *TraceConfig) ([]*TraceResult, error) {
+	type txTraceResult struct {
+		Result json.RawMessage `json:"result"`
+		Error  string          `json:"error"`
+	}
+	enc, err := config.encode()
+	if err != nil {
+		return nil, err
+	}
+	var traces []txTraceResult
+	if err := ec.c.CallContext(ctx, &traces, method, block, enc); err != nil {
+		return nil, err
+	}
+	results := make([]*TraceResult, len(traces))
+	for i, trace := range traces {
+		if trace.Error != "" {
+			results[i] = &TraceResult{Error: trace.Error}
+			continue
+		}
+		result, err := decodeTraceResult(config.tracer(), trace.Result)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace of transaction %d: %v", i, err)
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// IntermediateRoots re-executes the block with the given hash and returns the state
+// root after each of its transactions.
+func (ec *Client) IntermediateRoots(ctx context.Context, hash common.Hash, config *TraceConfig) ([]common.Hash, error) {
+	enc, err := config.encode()
+	if err != nil {
+		return nil, err
+	}
+	var roots []common.Hash
+	err = ec.c.CallContext(ctx, &roots, "debug_intermediateRoots", hash, enc)
+	return roots, err
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package albaclient
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/pictor01/ALBA"
+	"github.com/pictor01/ALBA/common"
+	"github.com/pictor01/ALBA/common/hexutil"
+	"github.com/pictor01/ALBA/core/types"
+	"github.com/pictor01/ALBA/rpc"
+)
+
+// SimulateBlock is a block to be simulated by Simulate. Its calls are executed
+// in order on top of the state left by the previous blocks.
+type SimulateBlock struct {
+	BlockOverrides *SimulateBlockOverrides                    // Header fields replacing the defaults, if set
+	StateOverrides map[common.Address]SimulateAccountOverride // Accounts replaced before the calls execute
+	Calls          []ethereum.CallMsg
+}
+
+// SimulateBlockOverrides specifies the header fields of a simulated block. Nil
+// fields keep the values derived from the parent block.
+type SimulateBlockOverrides struct {
+	Number     *big.Int
+	Difficulty *big.Int
+	Time       *uint64
+	GasLimit   *uint64
+	Coinbase   *common.Address
+	Random     *common.Hash
+	BaseFee    *big.Int
+}
+
+// SimulateAccountOverride specifies the state of an account during simulation.
+// State replaces the whole storage of the account, StateDiff only the given slots.
+type SimulateAccountOverride struct {
+	Nonce     *uint64
+	Code      []byte
+	Balance   *big.Int
+	State     map[common.Hash]common.Hash
+	StateDiff map[common.Hash]common.Hash
+}
+
+// SimulateCallResult is the outcome of a simulated call.
+type SimulateCallResult struct {
+	ReturnData hexutil.Bytes  `json:"returnData"`
+	Logs       []*types.Log   `json:"logs"`
+	GasUsed    hexutil.Uint64 `json:"gasUsed"`
+	Status     hexutil.Uint64 `json:"status"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// SimulateBlockResult is the header and call outcomes of a simulated block.
+type SimulateBlockResult struct {
+	Number     hexutil.Uint64       `json:"number"`
+	Hash       common.Hash          `json:"hash"`
+	ParentHash common.Hash          `json:"parentHash"`
+	Time       hexutil.Uint64       `json:"timestamp"`
+	GasLimit   hexutil.Uint64       `json:"gasLimit"`
+	GasUsed    hexutil.Uint64       `json:"gasUsed"`
+	Coinbase   common.Address       `json:"miner"`
+	BaseFee    *hexutil.Big         `json:"baseFeePerGas,omitempty"`
+	Calls      []SimulateCallResult `json:"calls"`
+}
+
+// Simulate executes the given blocks of calls on top of the block identified by
+// blockNrOrHash, or the latest block if nil, without changing the chain. Failed
+// calls are reported in the results and don't abort the simulation.
+func (ec *Client) Simulate(ctx context.Context, blocks []SimulateBlock, blockNrOrHash *rpc.BlockNumberOrHash) ([]SimulateBlockResult, error) {
+	args := make([]interface{}, len(blocks))
+	for i, block := range blocks {
+		args[i] = toSimulateBlockArg(block)
+	}
+	var result []SimulateBlockResult
+	err := ec.c.CallContext(ctx, &result, "eth_simulate", args, blockNrOrHash)
+	return result, err
+}
+
+func toSimulateBlockArg(block SimulateBlock) interface{} {
+	calls := make([]interface{}, len(block.Calls))
+	for i, call := range block.Calls {
+		calls[i] = toSendTxArg(call)
+	}
+	arg := map[string]interface{}{
+		"calls": calls,
+	}
+	if o := block.BlockOverrides; o != nil {
+		overrides := make(map[string]interface{})
+		if o.Number != nil {
+			overrides["number"] = (*hexutil.Big)(o.Number)
+		}
+		if o.Difficulty != nil {
+			overrides["difficulty"] = (*hexutil.Big)(o.Difficulty)
+		}
+		if o.Time != nil {
+			overrides["time"] = hexutil.Uint64(*o.Time)
+		}
+		if o.GasLimit != nil {
+			overrides["gasLimit"] = hexutil.Uint64(*o.GasLimit)
+		}
+		if o.Coinbase != nil {
+			overrides["coinbase"] = o.Coinbase
+		}
+		if o.Random != nil {
+			overrides["prevRandao"] = o.Random
+		}
+		if o.BaseFee != nil {
+			overrides["baseFee"] = (*hexutil.Big)(o.BaseFee)
+		}
+		arg["blockOverrides"] = overrides
+	}
+	if len(block.StateOverrides) > 0 {
+		overrides := make(map[common.Address]interface{}, len(block.StateOverrides))
+		for addr, account := range block.StateOverrides {
+			override := make(map[string]interface{})
+			if account.Nonce != nil {
+				override["nonce"] = hexutil.Uint64(*account.Nonce)
+			}
+			if account.Code != nil {
+				override["code"] = hexutil.Bytes(account.Code)
+			}
+			if account.Balance != nil {
+				override["balance"] = (*hexutil.Big)(account.Balance)
+			}
+			if account.State != nil {
+				override["state"] = account.State
+			}
+			if account.StateDiff != nil {
+				override["stateDiff"] = account.StateDiff
+			}
+			overrides[addr] = override
+		}
+		arg["stateOverrides"] = overrides
+	}
+	return arg
+}
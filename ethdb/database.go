@@ -88,6 +88,10 @@ type AncientReader interface {
 
 	// AncientSize returns the ancient size of the specified category.
 	AncientSize(kind string) (uint64, error)
+
+	// AncientTail returns the number of the first item retained in the specified
+	// category, i.e. the number of items deleted from its tail.
+	AncientTail(kind string) (uint64, error)
 }
 
 // AncientBatchReader is the interface for 'batched' or 'atomic' reading.
@@ -109,6 +113,13 @@ type AncientWriter interface {
 	// TruncateAncients discards all but the first n ancient data from the ancient store.
 	TruncateAncients(n uint64) error
 
+	// TruncateAncientTail discards the first n ancient data of the specified
+	// category from the ancient store. Implementations may retain some of the
+	// items below n if they can only delete data in larger units; AncientTail
+	// reports the effective tail. The numbering of the retained items is not
+	// affected.
+	TruncateAncientTail(kind string, n uint64) error
+
 	// Sync flushes all in-memory ancient store data to disk.
 	Sync() error
 }
@@ -33,9 +33,11 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
 
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -52,7 +54,7 @@ func TestBuildSchema(t *testing.T) {
 		t.Fatalf("could not create new node: %v", err)
 	}
 	// Make sure the schema can be parsed and matched up to the object model.
-	if err := newHandler(stack, nil, []string{}, []string{}); err != nil {
+	if err := newHandler(stack, nil, false, []string{}, []string{}); err != nil {
 		t.Errorf("Could not construct GraphQL handler: %v", err)
 	}
 }
@@ -197,6 +199,47 @@ func TestGraphQLBlockSerializationEIP2718(t *testing.T) {
 	}
 }
 
+func TestGraphQLBlockReceipts(t *testing.T) {
+	stack := createNode(t, true, true)
+	defer stack.Close()
+	// start node
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+
+	for i, tt := range []struct {
+		body string
+		want string
+		code int
+	}{
+		{
+			body: `{"query": "{block {number receipts { transaction { hash index } status gasUsed cumulativeGasUsed createdContract { address } logs { index }}}}"}`,
+			want: `{"data":{"block":{"number":1,"receipts":[{"transaction":{"hash":"0xd864c9d7d37fade6b70164740540c06dd58bb9c3f6b46101908d6339db6a6a7b","index":0},"status":1,"gasUsed":25204,"cumulativeGasUsed":25204,"createdContract":null,"logs":[]},{"transaction":{"hash":"0x19b35f8187b4e15fb59a9af469dca5dfa3cd363c11d372058c12f6482477b474","index":1},"status":1,"gasUsed":27504,"cumulativeGasUsed":52708,"createdContract":null,"logs":[]}]}}}`,
+			code: 200,
+		},
+		{
+			body: `{"query": "{block(number: 0) {number receipts { status }}}"}`,
+			want: `{"data":{"block":{"number":0,"receipts":[]}}}`,
+			code: 200,
+		},
+	} {
+		resp, err := http.Post(fmt.Sprintf("%s/graphql", stack.HTTPEndpoint()), "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatalf("could not post: %v", err)
+		}
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("could not read from response body: %v", err)
+		}
+		if have := string(bodyBytes); have != tt.want {
+			t.Errorf("testcase %d %s,\nhave:\n%v\nwant:\n%v", i, tt.body, have, tt.want)
+		}
+		if tt.code != resp.StatusCode {
+			t.Errorf("testcase %d %s,\nwrong statuscode, have: %v, want: %v", i, tt.body, resp.StatusCode, tt.code)
+		}
+	}
+}
+
 // Tests that a graphQL request is not handled successfully when graphql is not enabled on the specified endpoint
 func TestGraphQLHTTPOnSamePort_GQLRequest_Unsuccessful(t *testing.T) {
 	stack := createNode(t, false, false)
@@ -213,6 +256,110 @@ func TestGraphQLHTTPOnSamePort_GQLRequest_Unsuccessful(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
 }
 
+// Tests that blocks are streamed to clients subscribed over graphql-ws.
+func TestGraphQLSubscribeNewBlocks(t *testing.T) {
+	stack := createNode(t, false, false)
+	defer stack.Close()
+
+	chain, db := createGQLService(t, stack)
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	url := "ws" + strings.TrimPrefix(stack.HTTPEndpoint(), "http") + "/graphql"
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-ws"}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("could not dial graphql websocket: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	// Subscriptions must be rejected until the connection is initialized
+	start := `{"id":"1","type":"start","payload":{"query":"subscription { newBlocks { number } }"}}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(start)); err != nil {
+		t.Fatalf("could not send start message: %v", err)
+	}
+	if msg := readWSMessage(t, conn); msg.Type != gqlError {
+		t.Fatalf("message type mismatch: have %q, want %q", msg.Type, gqlError)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"connection_init"}`)); err != nil {
+		t.Fatalf("could not send init message: %v", err)
+	}
+	if msg := readWSMessage(t, conn); msg.Type != gqlConnectionAck {
+		t.Fatalf("message type mismatch: have %q, want %q", msg.Type, gqlConnectionAck)
+	}
+	// Plain queries are answered over the socket too
+	query := `{"id":"2","type":"start","payload":{"query":"{ block { number } }"}}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(query)); err != nil {
+		t.Fatalf("could not send query message: %v", err)
+	}
+	if data := readWSData(t, conn, "2"); data != `{"data":{"block":{"number":10}}}` {
+		t.Fatalf("query result mismatch: have %s", data)
+	}
+	if msg := readWSMessage(t, conn); msg.Type != gqlComplete || msg.ID != "2" {
+		t.Fatalf("message mismatch: have %s/%q, want %s/%q", msg.Type, msg.ID, gqlComplete, "2")
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(start)); err != nil {
+		t.Fatalf("could not send start message: %v", err)
+	}
+	// Give the subscription a moment to be installed, then extend the chain
+	time.Sleep(100 * time.Millisecond)
+
+	blocks, _ := core.GenerateChain(params.AllEthashProtocolChanges, chain.CurrentBlock(), ethash.NewFaker(), db, 1, func(i int, gen *core.BlockGen) {})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("could not import blocks: %v", err)
+	}
+	if data := readWSData(t, conn, "1"); data != `{"data":{"newBlocks":{"number":11}}}` {
+		t.Fatalf("subscription result mismatch: have %s", data)
+	}
+}
+
+// Tests that the operation type is detected so requests get routed to the
+// right schema.
+func TestOperationType(t *testing.T) {
+	tests := []struct {
+		query, name, want string
+	}{
+		{`{ block { number } }`, "", "query"},
+		{`query { block { number } }`, "", "query"},
+		{`mutation { sendRawTransaction(data: "0x00") }`, "", "mutation"},
+		{`subscription { newBlocks { number } }`, "", "subscription"},
+		{`# subscription { newBlocks { number } }` + "\n" + `{ block { number } }`, "", "query"},
+		{`fragment F on Block { number } subscription S { newBlocks { ...F } }`, "", "subscription"},
+		{`query Q { block(hash: "}") { number } } subscription S { newBlocks { number } }`, "S", "subscription"},
+		{`query Q { block { number } } subscription S { newBlocks { number } }`, "Q", "query"},
+		{`subscription S($a: [Address!]) { logs(filter: {addresses: $a}) { index } }`, "S", "subscription"},
+	}
+	for i, tt := range tests {
+		if have := operationType(tt.query, tt.name); have != tt.want {
+			t.Errorf("test %d: operation type mismatch: have %q, want %q", i, have, tt.want)
+		}
+	}
+}
+
+// readWSMessage reads the next graphql-ws message, skipping keepalives.
+func readWSMessage(t *testing.T, conn *websocket.Conn) wsMessage {
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("could not read message: %v", err)
+		}
+		if msg.Type != gqlConnectionKeepAlive {
+			return msg
+		}
+	}
+}
+
+// readWSData reads the next graphql-ws message and returns its payload, failing
+// if it is not a result of the given operation.
+func readWSData(t *testing.T, conn *websocket.Conn, id string) string {
+	msg := readWSMessage(t, conn)
+	if msg.Type != gqlData || msg.ID != id {
+		t.Fatalf("message mismatch: have %s/%q, want %s/%q", msg.Type, msg.ID, gqlData, id)
+	}
+	return string(msg.Payload)
+}
+
 func createNode(t *testing.T, gqlEnabled bool, txEnabled bool) *node.Node {
 	stack, err := node.New(&node.Config{
 		HTTPHost: "127.0.0.1",
@@ -234,7 +381,7 @@ func createNode(t *testing.T, gqlEnabled bool, txEnabled bool) *node.Node {
 	return stack
 }
 
-func createGQLService(t *testing.T, stack *node.Node) {
+func createGQLService(t *testing.T, stack *node.Node) (*core.BlockChain, ethdb.Database) {
 	// create backend
 	ethConf := &ethconfig.Config{
 		Genesis: &core.Genesis{
@@ -265,10 +412,11 @@ func createGQLService(t *testing.T, stack *node.Node) {
 		t.Fatalf("could not create import blocks: %v", err)
 	}
 	// create gql service
-	err = New(stack, ethBackend.APIBackend, []string{}, []string{})
+	err = New(stack, ethBackend.APIBackend, false, []string{}, []string{})
 	if err != nil {
 		t.Fatalf("could not create graphql service: %v", err)
 	}
+	return ethBackend.BlockChain(), ethBackend.ChainDb()
 }
 
 func createGQLServiceWithTransactions(t *testing.T, stack *node.Node) {
@@ -350,7 +498,7 @@ func createGQLServiceWithTransactions(t *testing.T, stack *node.Node) {
 		t.Fatalf("could not create import blocks: %v", err)
 	}
 	// create gql service
-	err = New(stack, ethBackend.APIBackend, []string{}, []string{})
+	err = New(stack, ethBackend.APIBackend, false, []string{}, []string{})
 	if err != nil {
 		t.Fatalf("could not create graphql service: %v", err)
 	}
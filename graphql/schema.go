@@ -31,11 +31,6 @@ const schema string = `
     # Long is a 64 bit unsigned integer.
     scalar Long
 
-    schema {
-        query: Query
-        mutation: Mutation
-    }
-
     # Account is an Ethereum account at a particular block.
     type Account {
         # Address is the address owning the account.
@@ -75,6 +70,32 @@ const schema string = `
         storageKeys : [Bytes32!]!
     }
 
+    # Receipt is the result of executing a transaction included in a block.
+    type Receipt {
+        # Transaction is the transaction this receipt belongs to.
+        transaction: Transaction!
+        # Status is the return status of the transaction. This will be 1 if the
+        # transaction succeeded, or 0 if it failed (due to a revert, or due to
+        # running out of gas).
+        status: Long!
+        # GasUsed is the amount of gas that was used processing this transaction.
+        gasUsed: Long!
+        # CumulativeGasUsed is the total gas used in the block up to and including
+        # this transaction.
+        cumulativeGasUsed: Long!
+        # EffectiveGasPrice is actual value per gas deducted from the sender's
+        # account.
+        effectiveGasPrice: BigInt
+        # CreatedContract is the account that was created by a contract creation
+        # transaction. If the transaction was not a contract creation transaction,
+        # this field will be null.
+        createdContract(block: Long): Account
+        # Logs is a list of log entries emitted by this transaction.
+        logs: [Log!]!
+        # LogsBloom is the bloom filter over the logs of this transaction.
+        logsBloom: Bytes!
+    }
+
     # Transaction is an Ethereum transaction.
     type Transaction {
         # Hash is the hash of this transaction.
@@ -222,6 +243,10 @@ const schema string = `
         # transactions are unavailable for this block, or if the index is out of
         # bounds, this field will be null.
         transactionAt(index: Int!): Transaction
+        # Receipts is a list of receipts of the transactions in this block, in
+        # the same order as the transactions. If receipts are unavailable for
+        # this block, this field will be null.
+        receipts: [Receipt!]
         # Logs returns a filtered set of logs from this block.
         logs(filter: BlockFilterCriteria!): [Log!]!
         # Account fetches an Ethereum account at the current block's state.
@@ -343,4 +368,34 @@ const schema string = `
         # SendRawTransaction sends an RLP-encoded transaction to the network.
         sendRawTransaction(data: Bytes!): Bytes32!
     }
+
+    type Subscription {
+        # NewBlocks emits every new block appended to the head of the chain,
+        # including the blocks imported during a reorganisation.
+        newBlocks: Block!
+        # Logs emits the log entries matching the provided filter as they are
+        # included in new blocks.
+        logs(filter: BlockFilterCriteria!): Log!
+        # PendingTransactions emits the transactions entering the pool.
+        pendingTransactions: Transaction!
+    }
+`
+
+// querySchema is the schema answering queries and mutations.
+const querySchema = schema + `
+    schema {
+        query: Query
+        mutation: Mutation
+    }
+`
+
+// subscriptionSchema is the schema answering subscriptions. It is separate from
+// the query schema as all root types share a resolver, and both the Query and
+// the Subscription types have a logs field. GraphQL mandates a query root, so
+// the Subscription type doubles as one, but queries are never routed here.
+const subscriptionSchema = schema + `
+    schema {
+        query: Subscription
+        subscription: Subscription
+    }
 `
@@ -22,6 +22,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/gorilla/websocket"
 	"github.com/graph-gophers/graphql-go"
 )
 
@@ -56,29 +57,54 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // New constructs a new GraphQL service instance.
-func New(stack *node.Node, backend ethapi.Backend, cors, vhosts []string) error {
+func New(stack *node.Node, backend ethapi.Backend, lightMode bool, cors, vhosts []string) error {
 	if backend == nil {
 		panic("missing backend")
 	}
 	// check if http server with given endpoint exists and enable graphQL on it
-	return newHandler(stack, backend, cors, vhosts)
+	return newHandler(stack, backend, lightMode, cors, vhosts)
 }
 
 // newHandler returns a new `http.Handler` that will answer GraphQL queries.
-// It additionally exports an interactive query browser on the / endpoint.
-func newHandler(stack *node.Node, backend ethapi.Backend, cors, vhosts []string) error {
+// It additionally exports an interactive query browser on the / endpoint and
+// serves subscriptions to websocket clients on the query endpoint.
+func newHandler(stack *node.Node, backend ethapi.Backend, lightMode bool, cors, vhosts []string) error {
 	q := Resolver{backend}
 
-	s, err := graphql.ParseSchema(schema, &q)
+	s, err := graphql.ParseSchema(querySchema, &q)
 	if err != nil {
 		return err
 	}
-	h := handler{Schema: s}
-	handler := node.NewHTTPHandlerStack(h, cors, vhosts)
+	sub := SubscriptionResolver{backend: backend, lightMode: lightMode}
 
+	ss, err := graphql.ParseSchema(subscriptionSchema, &sub)
+	if err != nil {
+		return err
+	}
+	h := handler{Schema: s}
+	handler := wsRouter{
+		http: node.NewHTTPHandlerStack(h, cors, vhosts, nil),
+		ws:   node.NewWSHandlerStack(newWSHandler(s, ss, cors), nil),
+	}
 	stack.RegisterHandler("GraphQL UI", "/graphql/ui", GraphiQL{})
 	stack.RegisterHandler("GraphQL", "/graphql", handler)
 	stack.RegisterHandler("GraphQL", "/graphql/", handler)
 
 	return nil
 }
+
+// wsRouter dispatches websocket upgrade requests to the subscription handler
+// and all other requests to the plain HTTP handler. The websocket handler is
+// kept out of the HTTP stack, since its response compression breaks upgrades.
+type wsRouter struct {
+	http http.Handler
+	ws   http.Handler
+}
+
+func (r wsRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if websocket.IsWebSocketUpgrade(req) {
+		r.ws.ServeHTTP(w, req)
+		return
+	}
+	r.http.ServeHTTP(w, req)
+}
@@ -0,0 +1,190 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// maxSubscriptionBacklog is the maximum number of events queued up for a single
+// subscriber. If the subscriber can't keep up, its subscription is terminated
+// instead of stalling the event system.
+const maxSubscriptionBacklog = 10000
+
+// SubscriptionResolver is the root resolver of the Subscription type. The events
+// are sourced from the filter event system, which is created on first use.
+type SubscriptionResolver struct {
+	backend   ethapi.Backend
+	lightMode bool
+
+	events *filters.EventSystem
+	once   sync.Once
+}
+
+// eventSystem returns the filter event system backing the subscriptions.
+func (r *SubscriptionResolver) eventSystem() *filters.EventSystem {
+	r.once.Do(func() {
+		r.events = filters.NewEventSystem(r.backend, r.lightMode)
+	})
+	return r.events
+}
+
+// NewBlocks subscribes to the blocks appended to the head of the chain.
+func (r *SubscriptionResolver) NewBlocks(ctx context.Context) (<-chan *Block, error) {
+	var (
+		headers = make(chan *types.Header)
+		results = make(chan *Block)
+		sub     = r.eventSystem().SubscribeNewHeads(headers)
+	)
+	go func() {
+		defer sub.Unsubscribe()
+		defer close(results)
+
+		var queue []*Block
+		for {
+			var (
+				out  chan<- *Block
+				next *Block
+			)
+			if len(queue) > 0 {
+				out, next = results, queue[0]
+			}
+			select {
+			case header := <-headers:
+				if len(queue) >= maxSubscriptionBacklog {
+					return
+				}
+				numberOrHash := rpc.BlockNumberOrHashWithHash(header.Hash(), false)
+				queue = append(queue, &Block{
+					backend:      r.backend,
+					numberOrHash: &numberOrHash,
+					hash:         header.Hash(),
+					header:       header,
+				})
+			case out <- next:
+				queue = queue[1:]
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, nil
+}
+
+// Logs subscribes to the logs matching the filter criteria.
+func (r *SubscriptionResolver) Logs(ctx context.Context, args struct{ Filter BlockFilterCriteria }) (<-chan *Log, error) {
+	var crit ethereum.FilterQuery
+	if args.Filter.Addresses != nil {
+		crit.Addresses = *args.Filter.Addresses
+	}
+	if args.Filter.Topics != nil {
+		crit.Topics = *args.Filter.Topics
+	}
+	var (
+		logs    = make(chan []*types.Log)
+		results = make(chan *Log)
+	)
+	sub, err := r.eventSystem().SubscribeLogs(crit, logs)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer sub.Unsubscribe()
+		defer close(results)
+
+		var queue []*Log
+		for {
+			var (
+				out  chan<- *Log
+				next *Log
+			)
+			if len(queue) > 0 {
+				out, next = results, queue[0]
+			}
+			select {
+			case batch := <-logs:
+				if len(queue)+len(batch) > maxSubscriptionBacklog {
+					return
+				}
+				for _, log := range batch {
+					queue = append(queue, &Log{
+						backend:     r.backend,
+						transaction: &Transaction{backend: r.backend, hash: log.TxHash},
+						log:         log,
+					})
+				}
+			case out <- next:
+				queue = queue[1:]
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, nil
+}
+
+// PendingTransactions subscribes to the transactions entering the pool.
+func (r *SubscriptionResolver) PendingTransactions(ctx context.Context) (<-chan *Transaction, error) {
+	var (
+		hashes  = make(chan []common.Hash)
+		results = make(chan *Transaction)
+		sub     = r.eventSystem().SubscribePendingTxs(hashes)
+	)
+	go func() {
+		defer sub.Unsubscribe()
+		defer close(results)
+
+		var queue []*Transaction
+		for {
+			var (
+				out  chan<- *Transaction
+				next *Transaction
+			)
+			if len(queue) > 0 {
+				out, next = results, queue[0]
+			}
+			select {
+			case batch := <-hashes:
+				if len(queue)+len(batch) > maxSubscriptionBacklog {
+					return
+				}
+				for _, hash := range batch {
+					queue = append(queue, &Transaction{backend: r.backend, hash: hash})
+				}
+			case out <- next:
+				queue = queue[1:]
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, nil
+}
@@ -0,0 +1,386 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
+)
+
+// The message types of the graphql-ws protocol, as defined by the Apollo
+// subscriptions-transport-ws package.
+const (
+	gqlConnectionInit      = "connection_init"
+	gqlConnectionAck       = "connection_ack"
+	gqlConnectionError     = "connection_error"
+	gqlConnectionKeepAlive = "ka"
+	gqlConnectionTerminate = "connection_terminate"
+	gqlStart               = "start"
+	gqlData                = "data"
+	gqlError               = "error"
+	gqlComplete            = "complete"
+	gqlStop                = "stop"
+)
+
+const (
+	wsSubprotocol       = "graphql-ws"
+	wsReadBuffer        = 1024
+	wsWriteBuffer       = 1024
+	wsMessageSizeLimit  = 15 * 1024 * 1024
+	wsWriteTimeout      = 10 * time.Second
+	wsKeepAliveInterval = 30 * time.Second
+)
+
+var (
+	errConnNotInitialized = errors.New("connection not initialized")
+	errUnknownMessageType = errors.New("unknown message type")
+	errDuplicateOperation = errors.New("duplicate operation id")
+)
+
+// wsMessage is the envelope of all messages exchanged over graphql-ws.
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// wsStartPayload is the payload of a start message.
+type wsStartPayload struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// wsHandler serves GraphQL queries, mutations and subscriptions over websocket
+// connections speaking the graphql-ws protocol.
+type wsHandler struct {
+	query        *graphql.Schema // Schema serving queries and mutations
+	subscription *graphql.Schema // Schema serving subscriptions
+	upgrader     websocket.Upgrader
+}
+
+// newWSHandler creates a graphql-ws handler, only accepting connections from the
+// given origins.
+func newWSHandler(query, subscription *graphql.Schema, allowedOrigins []string) *wsHandler {
+	return &wsHandler{
+		query:        query,
+		subscription: subscription,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  wsReadBuffer,
+			WriteBufferSize: wsWriteBuffer,
+			Subprotocols:    []string{wsSubprotocol},
+			CheckOrigin:     wsOriginChecker(allowedOrigins),
+		},
+	}
+}
+
+// wsOriginChecker returns a function which checks the origin of a websocket
+// request against the allowed origins. Requests without an origin header are
+// always accepted, since they don't come from a browser.
+func wsOriginChecker(allowedOrigins []string) func(*http.Request) bool {
+	origins := make(map[string]struct{})
+	for _, origin := range allowedOrigins {
+		origins[strings.ToLower(origin)] = struct{}{}
+	}
+	return func(r *http.Request) bool {
+		origin := strings.ToLower(r.Header.Get("Origin"))
+		if origin == "" {
+			return true
+		}
+		if _, ok := origins["*"]; ok {
+			return true
+		}
+		if _, ok := origins[origin]; ok {
+			return true
+		}
+		log.Warn("Rejected GraphQL WebSocket connection", "origin", origin)
+		return false
+	}
+}
+
+// ServeHTTP upgrades the request to a websocket connection and serves the
+// graphql-ws protocol over it until the client disconnects.
+func (h *wsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debug("GraphQL WebSocket upgrade failed", "err", err)
+		return
+	}
+	conn.SetReadLimit(wsMessageSizeLimit)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &wsConn{
+		handler: h,
+		conn:    conn,
+		ops:     make(map[string]context.CancelFunc),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	c.serve()
+}
+
+// wsConn tracks the state of a single graphql-ws connection.
+type wsConn struct {
+	handler *wsHandler
+	conn    *websocket.Conn
+
+	writeLock sync.Mutex // Serializes the writes to the connection
+
+	ops    map[string]context.CancelFunc // Running operations, keyed by client id
+	opLock sync.Mutex                    // Protects the running operations
+
+	ctx    context.Context    // Context of the connection, cancelled on close
+	cancel context.CancelFunc // Cancels all running operations
+	wg     sync.WaitGroup     // Tracks the running operations
+}
+
+// serve reads the client messages until the connection is closed or terminated.
+func (c *wsConn) serve() {
+	defer func() {
+		c.cancel()
+		c.wg.Wait()
+		c.conn.Close()
+	}()
+	var initialized bool
+	for {
+		var msg wsMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				c.write(wsMessage{Type: gqlConnectionError, Payload: errorPayload(err)})
+				continue
+			}
+			return
+		}
+		switch msg.Type {
+		case gqlConnectionInit:
+			if !initialized {
+				initialized = true
+				c.write(wsMessage{Type: gqlConnectionAck})
+				c.write(wsMessage{Type: gqlConnectionKeepAlive})
+				c.wg.Add(1)
+				go c.keepAlive()
+			}
+		case gqlStart:
+			if !initialized {
+				c.write(wsMessage{ID: msg.ID, Type: gqlError, Payload: errorPayload(errConnNotInitialized)})
+				continue
+			}
+			var payload wsStartPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				c.write(wsMessage{ID: msg.ID, Type: gqlError, Payload: errorPayload(err)})
+				continue
+			}
+			c.start(msg.ID, payload)
+		case gqlStop:
+			c.stop(msg.ID)
+		case gqlConnectionTerminate:
+			return
+		default:
+			c.write(wsMessage{ID: msg.ID, Type: gqlConnectionError, Payload: errorPayload(errUnknownMessageType)})
+		}
+	}
+}
+
+// start runs a new operation on behalf of the client, sending back the results
+// as they become available.
+func (c *wsConn) start(id string, payload wsStartPayload) {
+	c.opLock.Lock()
+	defer c.opLock.Unlock()
+
+	if _, ok := c.ops[id]; ok {
+		c.write(wsMessage{ID: id, Type: gqlError, Payload: errorPayload(errDuplicateOperation)})
+		return
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.ops[id] = cancel
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer c.stop(id)
+
+		// Queries and mutations are answered by the query schema, subscriptions
+		// by the subscription schema
+		if operationType(payload.Query, payload.OperationName) != "subscription" {
+			response := c.handler.query.Exec(ctx, payload.Query, payload.OperationName, payload.Variables)
+			c.send(id, response)
+			c.write(wsMessage{ID: id, Type: gqlComplete})
+			return
+		}
+		responses, err := c.handler.subscription.Subscribe(ctx, payload.Query, payload.OperationName, payload.Variables)
+		if err != nil {
+			c.write(wsMessage{ID: id, Type: gqlError, Payload: errorPayload(err)})
+			return
+		}
+		for response := range responses {
+			c.send(id, response)
+		}
+		if ctx.Err() == nil {
+			c.write(wsMessage{ID: id, Type: gqlComplete})
+		}
+	}()
+}
+
+// stop cancels a running operation, if it still exists.
+func (c *wsConn) stop(id string) {
+	c.opLock.Lock()
+	defer c.opLock.Unlock()
+
+	if cancel, ok := c.ops[id]; ok {
+		cancel()
+		delete(c.ops, id)
+	}
+}
+
+// send delivers a single operation result to the client.
+func (c *wsConn) send(id string, response interface{}) {
+	blob, err := json.Marshal(response)
+	if err != nil {
+		c.write(wsMessage{ID: id, Type: gqlError, Payload: errorPayload(err)})
+		return
+	}
+	c.write(wsMessage{ID: id, Type: gqlData, Payload: blob})
+}
+
+// keepAlive periodically pings the client until the connection is closed.
+func (c *wsConn) keepAlive() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(wsKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.write(wsMessage{Type: gqlConnectionKeepAlive})
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// write sends a message to the client. Failures tear down the connection.
+func (c *wsConn) write(msg wsMessage) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err := c.conn.WriteJSON(msg); err != nil {
+		log.Debug("Failed to write GraphQL WebSocket message", "err", err)
+		c.cancel()
+		c.conn.Close()
+	}
+}
+
+// errorPayload wraps an error into a graphql-ws error payload.
+func errorPayload(err error) json.RawMessage {
+	blob, _ := json.Marshal(map[string]string{"message": err.Error()})
+	return blob
+}
+
+// operationType returns the type of the named operation in the query document,
+// or of the first one if no name was given. Anonymous shorthand queries and
+// documents that can't be scanned are reported as "query", which leaves it to
+// the schema to produce a proper error.
+func operationType(query string, operationName string) string {
+	var (
+		depth  int
+		tokens []string
+	)
+	for i := 0; i < len(query); {
+		switch ch := query[i]; {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ',':
+			i++
+		case ch == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case ch == '"':
+			// Skip strings, including block strings, they may contain braces
+			if strings.HasPrefix(query[i:], `"""`) {
+				end := strings.Index(query[i+3:], `"""`)
+				if end < 0 {
+					return "query"
+				}
+				i += end + 6
+				continue
+			}
+			for i++; i < len(query) && query[i] != '"'; i++ {
+				if query[i] == '\\' {
+					i++
+				}
+			}
+			i++
+		case ch == '{':
+			if depth == 0 {
+				// A new top-level selection set, check the definition it belongs to
+				if kind, name := definitionHeader(tokens); kind != "fragment" {
+					if operationName == "" || name == operationName {
+						return kind
+					}
+				}
+				tokens = tokens[:0]
+			}
+			depth++
+			i++
+		case ch == '}':
+			depth--
+			i++
+		case isNameStart(ch):
+			start := i
+			for i < len(query) && (isNameStart(query[i]) || (query[i] >= '0' && query[i] <= '9')) {
+				i++
+			}
+			if depth == 0 {
+				tokens = append(tokens, query[start:i])
+			}
+		default:
+			i++
+		}
+	}
+	return "query"
+}
+
+// definitionHeader extracts the kind and name of a definition from the tokens
+// preceding its selection set.
+func definitionHeader(tokens []string) (string, string) {
+	if len(tokens) == 0 {
+		return "query", ""
+	}
+	switch tokens[0] {
+	case "query", "mutation", "subscription", "fragment":
+		if len(tokens) > 1 {
+			return tokens[0], tokens[1]
+		}
+		return tokens[0], ""
+	}
+	return "query", ""
+}
+
+// isNameStart reports whether the character may start a GraphQL name.
+func isNameStart(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
@@ -65,6 +65,36 @@ func (*HandlerT) Vmodule(pattern string) error {
 	return glogger.Vmodule(pattern)
 }
 
+// LogConfig is the logging configuration adjustable at runtime. Fields left
+// unset keep their current value.
+type LogConfig struct {
+	Format    *string `json:"format,omitempty"`    // Log format: json, logfmt or terminal
+	Verbosity *int    `json:"verbosity,omitempty"` // Global verbosity ceiling
+	Vmodule   *string `json:"vmodule,omitempty"`   // Per-module verbosity pattern
+}
+
+// SetLogConfig changes the log format and verbosity. Either all of the settings
+// are applied, or none if any of them is invalid.
+func (*HandlerT) SetLogConfig(config LogConfig) error {
+	if config.Format != nil {
+		if _, err := logFormat(*config.Format, false); err != nil {
+			return err
+		}
+	}
+	if config.Vmodule != nil {
+		if err := glogger.Vmodule(*config.Vmodule); err != nil {
+			return err
+		}
+	}
+	if config.Verbosity != nil {
+		glogger.Verbosity(log.Lvl(*config.Verbosity))
+	}
+	if config.Format != nil {
+		return setLogFormat(*config.Format)
+	}
+	return nil
+}
+
 // BacktraceAt sets the log backtrace location. See package log for details on
 // the pattern syntax.
 func (*HandlerT) BacktraceAt(location string) error {
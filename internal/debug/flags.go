@@ -23,6 +23,7 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"runtime"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -48,7 +49,36 @@ var (
 	}
 	logjsonFlag = cli.BoolFlag{
 		Name:  "log.json",
-		Usage: "Format logs with JSON",
+		Usage: "Format logs with JSON (deprecated, use --log.format=json)",
+	}
+	logFormatFlag = cli.StringFlag{
+		Name:  "log.format",
+		Usage: "Log format to use (json, logfmt, terminal)",
+		Value: "",
+	}
+	logFileFlag = cli.StringFlag{
+		Name:  "log.file",
+		Usage: "Write logs to the given file instead of the terminal",
+		Value: "",
+	}
+	logRotateSizeFlag = cli.IntFlag{
+		Name:  "log.rotate.maxsize",
+		Usage: "Size in megabytes after which the log file is rotated (0 = no limit)",
+		Value: 100,
+	}
+	logRotateAgeFlag = cli.DurationFlag{
+		Name:  "log.rotate.maxage",
+		Usage: "Age after which the log file is rotated (0 = no limit)",
+		Value: 0,
+	}
+	logRotateBackupsFlag = cli.IntFlag{
+		Name:  "log.rotate.maxbackups",
+		Usage: "Number of rotated log files to retain (0 = retain all)",
+		Value: 10,
+	}
+	logRotateCompressFlag = cli.BoolFlag{
+		Name:  "log.rotate.compress",
+		Usage: "Compress rotated log files with gzip",
 	}
 	backtraceAtFlag = cli.StringFlag{
 		Name:  "log.backtrace",
@@ -97,6 +127,12 @@ var Flags = []cli.Flag{
 	verbosityFlag,
 	vmoduleFlag,
 	logjsonFlag,
+	logFormatFlag,
+	logFileFlag,
+	logRotateSizeFlag,
+	logRotateAgeFlag,
+	logRotateBackupsFlag,
+	logRotateCompressFlag,
 	backtraceAtFlag,
 	debugFlag,
 	pprofFlag,
@@ -110,27 +146,93 @@ var Flags = []cli.Flag{
 
 var glogger *log.GlogHandler
 
+// logOutput is the destination of the log records, retained to allow changing
+// the format at runtime.
+var logOutput struct {
+	writer   io.Writer
+	file     *log.RotatingFile // Log file if logging to one, closed on exit
+	usecolor bool
+	lock     sync.Mutex
+}
+
 func init() {
+	logOutput.writer = os.Stderr
 	glogger = log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
 	glogger.Verbosity(log.LvlInfo)
 	log.Root().SetHandler(glogger)
 }
 
+// logFormat returns the log format with the given name.
+func logFormat(name string, usecolor bool) (log.Format, error) {
+	switch name {
+	case "terminal":
+		return log.TerminalFormat(usecolor), nil
+	case "logfmt":
+		return log.LogfmtFormat(), nil
+	case "json":
+		return log.JSONFormat(), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, must be json, logfmt or terminal", name)
+	}
+}
+
+// setLogFormat switches the format of the log records written to the current
+// output.
+func setLogFormat(name string) error {
+	logOutput.lock.Lock()
+	defer logOutput.lock.Unlock()
+
+	format, err := logFormat(name, logOutput.usecolor)
+	if err != nil {
+		return err
+	}
+	glogger.SetHandler(log.StreamHandler(logOutput.writer, format))
+	return nil
+}
+
 // Setup initializes profiling and logging based on the CLI flags.
 // It should be called as early as possible in the program.
 func Setup(ctx *cli.Context) error {
-	var ostream log.Handler
-	output := io.Writer(os.Stderr)
+	format := ctx.GlobalString(logFormatFlag.Name)
 	if ctx.GlobalBool(logjsonFlag.Name) {
-		ostream = log.StreamHandler(output, log.JSONFormat())
+		if format != "" && format != "json" {
+			return fmt.Errorf("--%s conflicts with --%s=%s", logjsonFlag.Name, logFormatFlag.Name, format)
+		}
+		format = "json"
+	}
+	if format == "" {
+		format = "terminal"
+	}
+	var (
+		output   = io.Writer(os.Stderr)
+		file     *log.RotatingFile
+		usecolor bool
+	)
+	if logFile := ctx.GlobalString(logFileFlag.Name); logFile != "" {
+		var err error
+		file, err = log.NewRotatingFile(logFile, log.RotateConfig{
+			MaxSize:    int64(ctx.GlobalInt(logRotateSizeFlag.Name)) * 1024 * 1024,
+			MaxAge:     ctx.GlobalDuration(logRotateAgeFlag.Name),
+			MaxBackups: ctx.GlobalInt(logRotateBackupsFlag.Name),
+			Compress:   ctx.GlobalBool(logRotateCompressFlag.Name),
+		})
+		if err != nil {
+			return err
+		}
+		output = file
 	} else {
-		usecolor := (isatty.IsTerminal(os.Stderr.Fd()) || isatty.IsCygwinTerminal(os.Stderr.Fd())) && os.Getenv("TERM") != "dumb"
+		usecolor = (isatty.IsTerminal(os.Stderr.Fd()) || isatty.IsCygwinTerminal(os.Stderr.Fd())) && os.Getenv("TERM") != "dumb"
 		if usecolor {
 			output = colorable.NewColorableStderr()
 		}
-		ostream = log.StreamHandler(output, log.TerminalFormat(usecolor))
 	}
-	glogger.SetHandler(ostream)
+	logOutput.lock.Lock()
+	logOutput.writer, logOutput.file, logOutput.usecolor = output, file, usecolor
+	logOutput.lock.Unlock()
+
+	if err := setLogFormat(format); err != nil {
+		return err
+	}
 
 	// logging
 	verbosity := ctx.GlobalInt(verbosityFlag.Name)
@@ -204,4 +306,11 @@ func StartPProf(address string, withMetrics bool) {
 func Exit() {
 	Handler.StopCPUProfile()
 	Handler.StopGoTrace()
+
+	logOutput.lock.Lock()
+	defer logOutput.lock.Unlock()
+
+	if logOutput.file != nil {
+		logOutput.file.Close()
+	}
 }
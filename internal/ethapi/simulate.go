@@ -0,0 +1,331 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// maxSimulateBlocks is the maximum number of blocks that can be simulated
+	// in a single request.
+	maxSimulateBlocks = 256
+
+	// simBlockPeriod is the default time difference between two consecutive
+	// simulated blocks, if no timestamp override is given.
+	simBlockPeriod = 12
+)
+
+// SimBlock is a batch of calls to be executed in a single simulated block, on
+// top of the post-state of the previous one.
+type SimBlock struct {
+	BlockOverrides *BlockOverrides   `json:"blockOverrides"`
+	StateOverrides *StateOverride    `json:"stateOverrides"`
+	Calls          []TransactionArgs `json:"calls"`
+}
+
+// SimCallResult is the result of a single simulated call.
+type SimCallResult struct {
+	ReturnValue hexutil.Bytes  `json:"returnData"`
+	Logs        []*types.Log   `json:"logs"`
+	GasUsed     hexutil.Uint64 `json:"gasUsed"`
+	Status      hexutil.Uint64 `json:"status"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// SimBlockResult is the result of a simulated block, containing the final
+// header fields and the results of all calls executed within it.
+type SimBlockResult struct {
+	Number     hexutil.Uint64  `json:"number"`
+	Hash       common.Hash     `json:"hash"`
+	ParentHash common.Hash     `json:"parentHash"`
+	Time       hexutil.Uint64  `json:"timestamp"`
+	GasLimit   hexutil.Uint64  `json:"gasLimit"`
+	GasUsed    hexutil.Uint64  `json:"gasUsed"`
+	Coinbase   common.Address  `json:"miner"`
+	BaseFee    *hexutil.Big    `json:"baseFeePerGas,omitempty"`
+	Calls      []SimCallResult `json:"calls"`
+}
+
+// simChainContext is a core.ChainContext that resolves the headers of already
+// simulated blocks before falling back to the backend, so that BLOCKHASH works
+// across the simulated chain segment.
+type simChainContext struct {
+	ctx     context.Context
+	b       Backend
+	headers map[common.Hash]*types.Header
+}
+
+func (c *simChainContext) Engine() consensus.Engine {
+	return c.b.Engine()
+}
+
+func (c *simChainContext) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header, ok := c.headers[hash]; ok {
+		return header
+	}
+	header, err := c.b.HeaderByHash(c.ctx, hash)
+	if err != nil || header == nil || header.Number.Uint64() != number {
+		return nil
+	}
+	return header
+}
+
+// Simulate executes a series of blocks of calls on top of the given block. Each
+// block can override the header fields and the state before its calls are run,
+// and every call sees the state changes made by all the calls preceding it.
+// Block numbers skipped by an override are filled with empty blocks, which are
+// part of the result.
+//
+// Note, this function doesn't make any changes in the state/blockchain.
+func (s *PublicBlockChainAPI) Simulate(ctx context.Context, blocks []SimBlock, blockNrOrHash *rpc.BlockNumberOrHash) ([]SimBlockResult, error) {
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	return DoSimulate(ctx, s.b, blocks, bNrOrHash, s.b.RPCEVMTimeout(), s.b.RPCGasCap())
+}
+
+// DoSimulate runs the given blocks of calls on top of the state of blockNrOrHash.
+// The timeout applies to the simulation as a whole, whereas globalGasCap limits
+// both the gas of every call and the total gas used across all blocks.
+func DoSimulate(ctx context.Context, b Backend, blocks []SimBlock, blockNrOrHash rpc.BlockNumberOrHash, timeout time.Duration, globalGasCap uint64) ([]SimBlockResult, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM simulation finished", "runtime", time.Since(start)) }(time.Now())
+
+	if len(blocks) == 0 {
+		return nil, errors.New("empty simulation")
+	}
+	if len(blocks) > maxSimulateBlocks {
+		return nil, fmt.Errorf("too many blocks: %d > %d", len(blocks), maxSimulateBlocks)
+	}
+	state, parent, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	if blocks, err = fillSimGaps(parent, blocks); err != nil {
+		return nil, err
+	}
+	// Setup context so it may be cancelled the simulation has completed
+	// or, in case of unmetered gas, setup a context with a timeout.
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	var (
+		chain   = &simChainContext{ctx: ctx, b: b, headers: make(map[common.Hash]*types.Header)}
+		gasUsed uint64
+		results = make([]SimBlockResult, 0, len(blocks))
+	)
+	for i, block := range blocks {
+		header := makeSimHeader(b.ChainConfig(), parent, block.BlockOverrides)
+		if header.Number.Cmp(parent.Number) <= 0 {
+			return nil, fmt.Errorf("block %d: number %v not above parent %v", i, header.Number, parent.Number)
+		}
+		if header.Time <= parent.Time {
+			return nil, fmt.Errorf("block %d: timestamp %d not above parent %d", i, header.Time, parent.Time)
+		}
+		if err := block.StateOverrides.Apply(state); err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		calls, err := simulateCalls(ctx, b, state, header, chain, block.Calls, globalGasCap, &gasUsed)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		// Seal the simulated block so it can be referenced by its descendants
+		header.Root = state.IntermediateRoot(b.ChainConfig().IsEIP158(header.Number))
+		hash := header.Hash()
+		for _, call := range calls {
+			for _, l := range call.Logs {
+				l.BlockNumber = header.Number.Uint64()
+				l.BlockHash = hash
+			}
+		}
+		chain.headers[hash] = header
+
+		result := SimBlockResult{
+			Number:     hexutil.Uint64(header.Number.Uint64()),
+			Hash:       hash,
+			ParentHash: header.ParentHash,
+			Time:       hexutil.Uint64(header.Time),
+			GasLimit:   hexutil.Uint64(header.GasLimit),
+			GasUsed:    hexutil.Uint64(header.GasUsed),
+			Coinbase:   header.Coinbase,
+			Calls:      calls,
+		}
+		if header.BaseFee != nil {
+			result.BaseFee = (*hexutil.Big)(header.BaseFee)
+		}
+		results = append(results, result)
+		parent = header
+	}
+	return results, nil
+}
+
+// fillSimGaps inserts empty blocks wherever a number override skips ahead of
+// the preceding block, so that the simulated chain stays contiguous and BLOCKHASH
+// resolves the ancestors of every simulated block.
+func fillSimGaps(base *types.Header, blocks []SimBlock) ([]SimBlock, error) {
+	var (
+		first  = base.Number.Uint64()
+		prev   = first
+		filled = make([]SimBlock, 0, len(blocks))
+	)
+	for i, block := range blocks {
+		number := prev + 1
+		if block.BlockOverrides != nil && block.BlockOverrides.Number != nil {
+			n := block.BlockOverrides.Number.ToInt()
+			if !n.IsUint64() || n.Uint64() <= prev {
+				return nil, fmt.Errorf("block %d: number %v not above parent %d", i, n, prev)
+			}
+			number = n.Uint64()
+		}
+		if number-first > maxSimulateBlocks {
+			return nil, fmt.Errorf("too many blocks: %d > %d", number-first, maxSimulateBlocks)
+		}
+		for n := prev + 1; n < number; n++ {
+			filled = append(filled, SimBlock{
+				BlockOverrides: &BlockOverrides{Number: (*hexutil.Big)(new(big.Int).SetUint64(n))},
+			})
+		}
+		filled = append(filled, block)
+		prev = number
+	}
+	return filled, nil
+}
+
+// makeSimHeader creates the header of a simulated block on top of parent, with
+// the given overrides applied.
+func makeSimHeader(config *params.ChainConfig, parent *types.Header, overrides *BlockOverrides) *types.Header {
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		UncleHash:  types.EmptyUncleHash,
+		Coinbase:   parent.Coinbase,
+		Difficulty: new(big.Int).Set(parent.Difficulty),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		Time:       parent.Time + simBlockPeriod,
+		MixDigest:  parent.MixDigest,
+	}
+	overrides.Apply(header)
+
+	// Derive the base fee from the parent unless explicitly overridden
+	if header.BaseFee == nil && config.IsLondon(header.Number) {
+		header.BaseFee = misc.CalcBaseFee(config, parent)
+	}
+	return header
+}
+
+// simulateCalls executes the calls of a single simulated block on the given
+// state, updating the header's gas used and the total gas used so far.
+func simulateCalls(ctx context.Context, b Backend, state *state.StateDB, header *types.Header, chain *simChainContext, calls []TransactionArgs, globalGasCap uint64, totalGasUsed *uint64) ([]SimCallResult, error) {
+	var (
+		blockCtx = core.NewEVMBlockContext(header, chain, &header.Coinbase)
+		gp       = new(core.GasPool).AddGas(header.GasLimit)
+		results  = make([]SimCallResult, 0, len(calls))
+	)
+	for i, args := range calls {
+		// Default the gas allowance to whatever is left in the block
+		if args.Gas == nil {
+			gas := hexutil.Uint64(gp.Gas())
+			args.Gas = &gas
+		}
+		msg, err := args.ToMessage(globalGasCap, header.BaseFee)
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		// Calls are not signed transactions, derive a unique hash to tag the
+		// logs with from the message and the current sender nonce.
+		txHash := types.NewTx(&types.LegacyTx{
+			Nonce:    state.GetNonce(msg.From()),
+			To:       msg.To(),
+			Value:    msg.Value(),
+			Gas:      msg.Gas(),
+			GasPrice: msg.GasPrice(),
+			Data:     msg.Data(),
+		}).Hash()
+		state.Prepare(txHash, i)
+
+		evm, vmError, err := b.GetEVM(ctx, msg, state, header, &vm.Config{NoBaseFee: true}, &blockCtx)
+		if err != nil {
+			return nil, err
+		}
+		// Wait for the context to be done and cancel the evm. Even if the
+		// EVM has finished, cancelling may be done (repeatedly)
+		go func() {
+			<-ctx.Done()
+			evm.Cancel()
+		}()
+		result, err := core.ApplyMessage(evm, msg, gp)
+		if err := vmError(); err != nil {
+			return nil, err
+		}
+		// If the timer caused an abort, return an appropriate error message
+		if evm.Cancelled() {
+			return nil, errors.New("execution aborted (timeout)")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %w (supplied gas %d)", i, err, msg.Gas())
+		}
+		state.Finalise(evm.ChainConfig().IsEIP158(header.Number))
+
+		header.GasUsed += result.UsedGas
+		*totalGasUsed += result.UsedGas
+		if globalGasCap != 0 && *totalGasUsed > globalGasCap {
+			return nil, fmt.Errorf("call %d: simulation gas cap %d exceeded", i, globalGasCap)
+		}
+		call := SimCallResult{
+			ReturnValue: result.Return(),
+			Logs:        state.GetLogs(txHash, common.Hash{}),
+			GasUsed:     hexutil.Uint64(result.UsedGas),
+			Status:      hexutil.Uint64(types.ReceiptStatusSuccessful),
+		}
+		if call.Logs == nil {
+			call.Logs = []*types.Log{}
+		}
+		if result.Failed() {
+			call.Status = hexutil.Uint64(types.ReceiptStatusFailed)
+			if len(result.Revert()) > 0 {
+				call.ReturnValue = result.Revert()
+				call.Error = newRevertError(result).Error()
+			} else {
+				call.Error = result.Err.Error()
+			}
+		}
+		results = append(results, call)
+	}
+	return results, nil
+}
@@ -0,0 +1,275 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// simBackend implements the subset of Backend used by DoSimulate, on top of a
+// database holding only the genesis block.
+type simBackend struct {
+	Backend
+	db      ethdb.Database
+	genesis *types.Block
+}
+
+func newSimBackend(alloc core.GenesisAlloc) *simBackend {
+	gspec := &core.Genesis{
+		Config:   params.TestChainConfig,
+		GasLimit: 30_000_000,
+		BaseFee:  big.NewInt(params.InitialBaseFee),
+		Alloc:    alloc,
+	}
+	db := rawdb.NewMemoryDatabase()
+	return &simBackend{db: db, genesis: gspec.MustCommit(db)}
+}
+
+func (b *simBackend) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+func (b *simBackend) Engine() consensus.Engine         { return ethash.NewFaker() }
+func (b *simBackend) RPCGasCap() uint64                { return 50_000_000 }
+func (b *simBackend) RPCEVMTimeout() time.Duration     { return 5 * time.Second }
+
+func (b *simBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	number := rawdb.ReadHeaderNumber(b.db, hash)
+	if number == nil {
+		return nil, nil
+	}
+	return rawdb.ReadHeader(b.db, hash, *number), nil
+}
+
+func (b *simBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	statedb, err := state.New(b.genesis.Root(), state.NewDatabase(b.db), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return statedb, b.genesis.Header(), nil
+}
+
+func (b *simBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config, blockCtx *vm.BlockContext) (*vm.EVM, func() error, error) {
+	return vm.NewEVM(*blockCtx, core.NewEVMTxContext(msg), state, params.TestChainConfig, *vmConfig), func() error { return nil }, nil
+}
+
+var (
+	simSender = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	simRecv   = common.HexToAddress("0x2000000000000000000000000000000000000002")
+	simOther  = common.HexToAddress("0x3000000000000000000000000000000000000003")
+
+	// simHashCode returns blockhash(number - 1).
+	simHashCode = []byte{byte(vm.PUSH1), 1, byte(vm.NUMBER), byte(vm.SUB), byte(vm.BLOCKHASH), byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN)}
+	// simLogCode emits an empty log with topic 0xff.
+	simLogCode = []byte{byte(vm.PUSH1), 0xff, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.LOG1), byte(vm.STOP)}
+	// simRevertCode reverts without data.
+	simRevertCode = []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT)}
+
+	simHashAddr   = common.HexToAddress("0xaaaa")
+	simLogAddr    = common.HexToAddress("0xbbbb")
+	simRevertAddr = common.HexToAddress("0xcccc")
+)
+
+func newSimTestBackend(t *testing.T) *simBackend {
+	return newSimBackend(core.GenesisAlloc{
+		simSender:     {Balance: big.NewInt(params.Ether)},
+		simHashAddr:   {Code: simHashCode, Balance: new(big.Int)},
+		simLogAddr:    {Code: simLogCode, Balance: new(big.Int)},
+		simRevertAddr: {Code: simRevertCode, Balance: new(big.Int)},
+	})
+}
+
+func simulate(b *simBackend, blocks []SimBlock) ([]SimBlockResult, error) {
+	return DoSimulate(context.Background(), b, blocks, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), b.RPCEVMTimeout(), b.RPCGasCap())
+}
+
+func simValue(v int64) *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(v))
+}
+
+func simNumber(n uint64) *hexutil.Big {
+	return (*hexutil.Big)(new(big.Int).SetUint64(n))
+}
+
+// Tests that state changes carry over across calls and blocks, and that the
+// simulated blocks form a chain on top of the base block.
+func TestSimulateMultiBlock(t *testing.T) {
+	b := newSimTestBackend(t)
+	results, err := simulate(b, []SimBlock{
+		{Calls: []TransactionArgs{{From: &simSender, To: &simRecv, Value: simValue(1000)}}},
+		{Calls: []TransactionArgs{
+			{From: &simRecv, To: &simOther, Value: simValue(600)},
+			{From: &simRecv, To: &simOther, Value: simValue(400)},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("wrong number of blocks: have %d, want 2", len(results))
+	}
+	var (
+		parentHash = b.genesis.Hash()
+		parentTime = b.genesis.Time()
+	)
+	for i, res := range results {
+		if uint64(res.Number) != uint64(i+1) {
+			t.Errorf("block %d: wrong number %d", i, res.Number)
+		}
+		if res.ParentHash != parentHash {
+			t.Errorf("block %d: wrong parent hash %x, want %x", i, res.ParentHash, parentHash)
+		}
+		if uint64(res.Time) != parentTime+simBlockPeriod {
+			t.Errorf("block %d: wrong timestamp %d", i, res.Time)
+		}
+		for j, call := range res.Calls {
+			if call.Status != hexutil.Uint64(types.ReceiptStatusSuccessful) {
+				t.Errorf("block %d call %d failed: %s", i, j, call.Error)
+			}
+		}
+		parentHash, parentTime = res.Hash, uint64(res.Time)
+	}
+	if uint64(results[1].GasUsed) != 2*params.TxGas {
+		t.Errorf("wrong gas used in block 1: have %d, want %d", results[1].GasUsed, 2*params.TxGas)
+	}
+}
+
+// Tests that block and state overrides are applied, and that blocks skipped by
+// a number override are filled in so BLOCKHASH resolves through them.
+func TestSimulateOverrides(t *testing.T) {
+	var (
+		b        = newSimTestBackend(t)
+		coinbase = common.HexToAddress("0xc0ffee")
+		time     = hexutil.Uint64(b.genesis.Time() + 1000)
+		gasLimit = hexutil.Uint64(10_000_000)
+		balance  = simValue(params.Ether)
+	)
+	results, err := simulate(b, []SimBlock{{
+		BlockOverrides: &BlockOverrides{
+			Number:   simNumber(3),
+			Time:     &time,
+			GasLimit: &gasLimit,
+			Coinbase: &coinbase,
+		},
+		StateOverrides: &StateOverride{simOther: OverrideAccount{Balance: &balance}},
+		Calls: []TransactionArgs{
+			{From: &simOther, To: &simRecv, Value: simValue(params.GWei)},
+			{From: &simSender, To: &simHashAddr},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("wrong number of blocks: have %d, want 3", len(results))
+	}
+	for i, res := range results[:2] {
+		if uint64(res.Number) != uint64(i+1) || len(res.Calls) != 0 {
+			t.Errorf("filler block %d: number %d, %d calls", i, res.Number, len(res.Calls))
+		}
+	}
+	res := results[2]
+	if res.Number != 3 || res.Time != time || res.GasLimit != gasLimit || res.Coinbase != coinbase {
+		t.Errorf("overrides not applied: number %d, time %d, gas limit %d, coinbase %x", res.Number, res.Time, res.GasLimit, res.Coinbase)
+	}
+	if res.ParentHash != results[1].Hash {
+		t.Errorf("wrong parent hash %x, want %x", res.ParentHash, results[1].Hash)
+	}
+	if status := res.Calls[0].Status; status != hexutil.Uint64(types.ReceiptStatusSuccessful) {
+		t.Errorf("transfer from overridden balance failed: %s", res.Calls[0].Error)
+	}
+	if have := common.BytesToHash(res.Calls[1].ReturnValue); have != results[1].Hash {
+		t.Errorf("wrong parent block hash from the EVM: have %x, want %x", have, results[1].Hash)
+	}
+}
+
+// Tests that logs are reported per call and attributed to their simulated block.
+func TestSimulateLogs(t *testing.T) {
+	b := newSimTestBackend(t)
+	results, err := simulate(b, []SimBlock{
+		{Calls: []TransactionArgs{{From: &simSender, To: &simLogAddr}, {From: &simSender, To: &simRecv}, {From: &simSender, To: &simLogAddr}}},
+		{Calls: []TransactionArgs{{From: &simSender, To: &simLogAddr}}},
+	})
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	for i, res := range results {
+		for j, call := range res.Calls {
+			want := 1
+			if i == 0 && j == 1 {
+				want = 0
+			}
+			if len(call.Logs) != want {
+				t.Fatalf("block %d call %d: have %d logs, want %d", i, j, len(call.Logs), want)
+			}
+			for _, l := range call.Logs {
+				if l.Address != simLogAddr || len(l.Topics) != 1 || l.Topics[0] != common.BigToHash(big.NewInt(0xff)) {
+					t.Errorf("block %d call %d: wrong log %+v", i, j, l)
+				}
+				if l.BlockNumber != uint64(res.Number) || l.BlockHash != res.Hash || l.TxIndex != uint(j) {
+					t.Errorf("block %d call %d: wrong log position: block %d %x, tx %d", i, j, l.BlockNumber, l.BlockHash, l.TxIndex)
+				}
+			}
+		}
+	}
+}
+
+// Tests that invalid simulations are rejected and that reverts are reported per
+// call without failing the simulation.
+func TestSimulateErrors(t *testing.T) {
+	b := newSimTestBackend(t)
+
+	results, err := simulate(b, []SimBlock{{Calls: []TransactionArgs{{From: &simSender, To: &simRevertAddr}}}})
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	if call := results[0].Calls[0]; call.Status != hexutil.Uint64(types.ReceiptStatusFailed) || call.Error != "execution reverted" {
+		t.Errorf("wrong revert result: status %d, error %q", call.Status, call.Error)
+	}
+	past := hexutil.Uint64(b.genesis.Time())
+	tests := []struct {
+		blocks []SimBlock
+		want   string
+	}{
+		{nil, "empty simulation"},
+		{[]SimBlock{{BlockOverrides: &BlockOverrides{Number: simNumber(0)}}}, "not above parent"},
+		{[]SimBlock{{}, {BlockOverrides: &BlockOverrides{Number: simNumber(1)}}}, "not above parent"},
+		{[]SimBlock{{BlockOverrides: &BlockOverrides{Number: simNumber(maxSimulateBlocks + 1)}}}, "too many blocks"},
+		{[]SimBlock{{BlockOverrides: &BlockOverrides{Time: &past}}}, "timestamp"},
+		{[]SimBlock{{Calls: []TransactionArgs{{From: &simRecv, To: &simOther, Value: simValue(1)}}}}, "insufficient funds"},
+	}
+	for i, tt := range tests {
+		_, err := simulate(b, tt.blocks)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("test %d: wrong error %v, want %q", i, err, tt.want)
+		}
+	}
+}
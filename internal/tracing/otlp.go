@@ -0,0 +1,272 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// exportTimeout is the time allowed for a collector to accept a batch of spans.
+const exportTimeout = 10 * time.Second
+
+// Exporter is a span exporter sending batches of spans to a collector using the
+// JSON encoding of the OTLP/HTTP protocol.
+type Exporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewExporter creates an exporter for the OTLP/HTTP traces endpoint of a
+// collector, e.g. http://localhost:4318/v1/traces.
+func NewExporter(endpoint string) *Exporter {
+	return &Exporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: exportTimeout},
+	}
+}
+
+// ExportSpans implements sdktrace.SpanExporter, sending the spans to the collector.
+func (e *Exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(encodeSpans(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector rejected %d spans: %s", len(spans), resp.Status)
+	}
+	return nil
+}
+
+// Shutdown implements sdktrace.SpanExporter. There is nothing to release.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// The types below mirror the JSON encoding of the OTLP trace messages, see
+// https://github.com/open-telemetry/opentelemetry-proto.
+
+type otlpTraces struct {
+	ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource      `json:"resource"`
+	ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope   `json:"scope"`
+	Spans []*otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+// OTLP status codes, which differ from the ones of the OpenTelemetry API.
+const (
+	otlpStatusUnset = 0
+	otlpStatusOk    = 1
+	otlpStatusError = 2
+)
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string     `json:"stringValue,omitempty"`
+	BoolValue   *bool       `json:"boolValue,omitempty"`
+	IntValue    *string     `json:"intValue,omitempty"`
+	DoubleValue *float64    `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpValues `json:"arrayValue,omitempty"`
+}
+
+type otlpValues struct {
+	Values []otlpValue `json:"values"`
+}
+
+// encodeSpans groups the spans by resource and instrumentation scope.
+func encodeSpans(spans []sdktrace.ReadOnlySpan) *otlpTraces {
+	var (
+		traces    = new(otlpTraces)
+		resources = make(map[*resource.Resource]*otlpResourceSpans)
+		scopes    = make(map[*otlpResourceSpans]map[instrumentation.Library]*otlpScopeSpans)
+	)
+	for _, span := range spans {
+		rs, ok := resources[span.Resource()]
+		if !ok {
+			rs = &otlpResourceSpans{Resource: otlpResource{Attributes: encodeAttributes(span.Resource().Attributes())}}
+			resources[span.Resource()] = rs
+			scopes[rs] = make(map[instrumentation.Library]*otlpScopeSpans)
+			traces.ResourceSpans = append(traces.ResourceSpans, rs)
+		}
+		lib := span.InstrumentationLibrary()
+		ss, ok := scopes[rs][lib]
+		if !ok {
+			ss = &otlpScopeSpans{Scope: otlpScope{Name: lib.Name, Version: lib.Version}}
+			scopes[rs][lib] = ss
+			rs.ScopeSpans = append(rs.ScopeSpans, ss)
+		}
+		ss.Spans = append(ss.Spans, encodeSpan(span))
+	}
+	return traces
+}
+
+// encodeSpan converts a finished span into its OTLP form.
+func encodeSpan(span sdktrace.ReadOnlySpan) *otlpSpan {
+	sc := span.SpanContext()
+	enc := &otlpSpan{
+		TraceID:           sc.TraceID().String(),
+		SpanID:            sc.SpanID().String(),
+		Name:              span.Name(),
+		Kind:              int(span.SpanKind()), // API and OTLP kinds share the numbering
+		StartTimeUnixNano: strconv.FormatInt(span.StartTime().UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.EndTime().UnixNano(), 10),
+		Attributes:        encodeAttributes(span.Attributes()),
+	}
+	if parent := span.Parent(); parent.IsValid() {
+		enc.ParentSpanID = parent.SpanID().String()
+	}
+	for _, event := range span.Events() {
+		enc.Events = append(enc.Events, otlpEvent{
+			TimeUnixNano: strconv.FormatInt(event.Time.UnixNano(), 10),
+			Name:         event.Name,
+			Attributes:   encodeAttributes(event.Attributes),
+		})
+	}
+	switch status := span.Status(); status.Code {
+	case codes.Ok:
+		enc.Status = otlpStatus{Code: otlpStatusOk}
+	case codes.Error:
+		enc.Status = otlpStatus{Code: otlpStatusError, Message: status.Description}
+	default:
+		enc.Status = otlpStatus{Code: otlpStatusUnset}
+	}
+	return enc
+}
+
+// encodeAttributes converts a list of attributes into their OTLP form.
+func encodeAttributes(attrs []attribute.KeyValue) []otlpKeyValue {
+	enc := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		enc = append(enc, otlpKeyValue{Key: string(attr.Key), Value: encodeValue(attr.Value)})
+	}
+	return enc
+}
+
+// encodeValue converts an attribute value into its OTLP form.
+func encodeValue(v attribute.Value) otlpValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return otlpValue{BoolValue: &b}
+	case attribute.INT64:
+		i := strconv.FormatInt(v.AsInt64(), 10)
+		return otlpValue{IntValue: &i}
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return otlpValue{DoubleValue: &f}
+	case attribute.BOOLSLICE:
+		var values []otlpValue
+		for _, b := range v.AsBoolSlice() {
+			values = append(values, encodeValue(attribute.BoolValue(b)))
+		}
+		return otlpValue{ArrayValue: &otlpValues{Values: values}}
+	case attribute.INT64SLICE:
+		var values []otlpValue
+		for _, i := range v.AsInt64Slice() {
+			values = append(values, encodeValue(attribute.Int64Value(i)))
+		}
+		return otlpValue{ArrayValue: &otlpValues{Values: values}}
+	case attribute.FLOAT64SLICE:
+		var values []otlpValue
+		for _, f := range v.AsFloat64Slice() {
+			values = append(values, encodeValue(attribute.Float64Value(f)))
+		}
+		return otlpValue{ArrayValue: &otlpValues{Values: values}}
+	case attribute.STRINGSLICE:
+		var values []otlpValue
+		for _, s := range v.AsStringSlice() {
+			values = append(values, encodeValue(attribute.StringValue(s)))
+		}
+		return otlpValue{ArrayValue: &otlpValues{Values: values}}
+	default:
+		s := v.Emit()
+		return otlpValue{StringValue: &s}
+	}
+}
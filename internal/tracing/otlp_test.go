@@ -0,0 +1,142 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// collector is a stub OTLP/HTTP collector recording the spans it receives.
+type collector struct {
+	spans []*otlpSpan
+	lock  sync.Mutex
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	var traces otlpTraces
+	if err := json.NewDecoder(r.Body).Decode(&traces); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, rs := range traces.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+	w.Write([]byte("{}"))
+}
+
+func (c *collector) find(name string) *otlpSpan {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, span := range c.spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func attributeValue(span *otlpSpan, key string) *otlpValue {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return &attr.Value
+		}
+	}
+	return nil
+}
+
+// Tests that spans are exported to the collector with their hierarchy, attributes
+// and status intact.
+func TestExport(t *testing.T) {
+	stub := new(collector)
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(NewExporter(server.URL + "/v1/traces")))
+	tracer := provider.Tracer(instrumentationName)
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	_, child := tracer.Start(ctx, "child")
+	child.SetAttributes(attribute.Int64("number", 42), attribute.String("hash", "0x01"))
+	child.SetAttributes(CacheAttributes("cache", 3, 1)...)
+	End(child, errors.New("failure"))
+	End(parent, nil)
+
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("failed to shut down provider: %v", err)
+	}
+	p, c := stub.find("parent"), stub.find("child")
+	if p == nil || c == nil {
+		t.Fatalf("spans missing from collector: parent %v, child %v", p, c)
+	}
+	if c.TraceID != p.TraceID || c.ParentSpanID != p.SpanID || p.ParentSpanID != "" {
+		t.Errorf("span hierarchy mismatch: parent %s/%s, child %s/%s of %s", p.TraceID, p.SpanID, c.TraceID, c.SpanID, c.ParentSpanID)
+	}
+	if p.Status.Code != otlpStatusUnset || c.Status.Code != otlpStatusError || c.Status.Message != "failure" {
+		t.Errorf("status mismatch: parent %+v, child %+v", p.Status, c.Status)
+	}
+	if v := attributeValue(c, "number"); v == nil || v.IntValue == nil || *v.IntValue != "42" {
+		t.Errorf("integer attribute mismatch: %+v", v)
+	}
+	if v := attributeValue(c, "hash"); v == nil || v.StringValue == nil || *v.StringValue != "0x01" {
+		t.Errorf("string attribute mismatch: %+v", v)
+	}
+	if v := attributeValue(c, "cache.hit_rate"); v == nil || v.DoubleValue == nil || *v.DoubleValue != 0.75 {
+		t.Errorf("hit rate attribute mismatch: %+v", v)
+	}
+	if len(c.Events) != 1 || c.Events[0].Name != "exception" {
+		t.Errorf("error event missing: %+v", c.Events)
+	}
+}
+
+// Tests that a collector refusing the spans surfaces as an export error.
+func TestExportRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var (
+		recorder = tracetest.NewSpanRecorder()
+		provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	)
+	_, span := provider.Tracer(instrumentationName).Start(context.Background(), "span")
+	span.End()
+
+	if err := NewExporter(server.URL).ExportSpans(context.Background(), recorder.Ended()); err == nil {
+		t.Fatal("expected export error")
+	}
+}
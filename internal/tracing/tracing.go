@@ -0,0 +1,134 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracing provides OpenTelemetry tracing of RPC calls, block imports and
+// state commits. Spans are created through the global tracer provider, which
+// discards them unless Setup installed an exporting one.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer creating all spans.
+const instrumentationName = "github.com/ethereum/go-ethereum"
+
+// shutdownTimeout is the time allowed to flush the pending spans on shutdown.
+const shutdownTimeout = 5 * time.Second
+
+var (
+	provider     *sdktrace.TracerProvider
+	providerLock sync.Mutex
+)
+
+// Setup installs a tracer provider exporting the spans to the OTLP/HTTP endpoint
+// of a collector. Only the given ratio of traces is sampled, unless the trace was
+// started and sampled by a remote caller.
+func Setup(endpoint string, ratio float64) error {
+	if ratio < 0 || ratio > 1 {
+		return fmt.Errorf("invalid trace sample rate %v, must be within [0, 1]", ratio)
+	}
+	providerLock.Lock()
+	defer providerLock.Unlock()
+
+	if provider != nil {
+		return fmt.Errorf("tracing already set up")
+	}
+	res := resource.NewSchemaless(
+		attribute.String("service.name", "geth"),
+		attribute.String("service.version", params.VersionWithMeta),
+	)
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(NewExporter(endpoint)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	log.Info("Enabled trace export", "endpoint", endpoint, "samplerate", ratio)
+	return nil
+}
+
+// Shutdown flushes the pending spans of the tracer provider installed by Setup
+// and stops exporting.
+func Shutdown() {
+	providerLock.Lock()
+	defer providerLock.Unlock()
+
+	if provider == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := provider.Shutdown(ctx); err != nil {
+		log.Warn("Failed to flush trace spans", "err", err)
+	}
+	provider = nil
+}
+
+// Start creates a span as the child of the span carried by ctx, if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartServer creates a span for handling a request of a remote caller.
+func StartServer(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...), trace.WithSpanKind(trace.SpanKindServer))
+}
+
+// End ends the span, marking it failed if err is non-nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Extract returns a context carrying the remote span referenced by the trace
+// context headers of an HTTP request.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// CacheAttributes returns the attributes describing the hits and misses of a
+// cache, along with its hit rate.
+func CacheAttributes(prefix string, hits, misses uint64) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.Int64(prefix+".hits", int64(hits)),
+		attribute.Int64(prefix+".misses", int64(misses)),
+	}
+	if total := hits + misses; total > 0 {
+		attrs = append(attrs, attribute.Float64(prefix+".hit_rate", float64(hits)/float64(total)))
+	}
+	return attrs
+}
@@ -58,6 +58,17 @@ web3._extend({
 			call: 'clique_getSignersAtHash',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'getSignerSet',
+			call: 'clique_getSignerSet',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getSignerSetAtHash',
+			call: 'clique_getSignerSetAtHash',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'propose',
 			call: 'clique_propose',
@@ -275,6 +286,11 @@ web3._extend({
 			call: 'debug_vmodule',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'setLogConfig',
+			call: 'debug_setLogConfig',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'backtraceAt',
 			call: 'debug_backtraceAt',
@@ -471,6 +487,18 @@ web3._extend({
 			params: 2,
 			inputFormatter:[web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter],
 		}),
+		new web3._extend.Method({
+			name: 'pruneState',
+			call: 'debug_pruneState',
+		}),
+		new web3._extend.Method({
+			name: 'pruneStateStatus',
+			call: 'debug_pruneStateStatus',
+		}),
+		new web3._extend.Method({
+			name: 'abortPruneState',
+			call: 'debug_abortPruneState',
+		}),
 	],
 	properties: []
 });
@@ -576,6 +604,17 @@ web3._extend({
 			params: 3,
 			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter, null]
 		}),
+		new web3._extend.Method({
+			name: 'simulate',
+			call: 'eth_simulate',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter],
+		}),
+		new web3._extend.Method({
+			name: 'getBlockReceipts',
+			call: 'eth_getBlockReceipts',
+			params: 1,
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -137,11 +137,11 @@ func (t *odrTrie) TryDelete(key []byte) error {
 	})
 }
 
-func (t *odrTrie) Commit(onleaf trie.LeafCallback) (common.Hash, int, error) {
+func (t *odrTrie) CommitNodes(onleaf trie.LeafCallback) (common.Hash, *trie.NodeSet, int, error) {
 	if t.trie == nil {
-		return t.id.Root, 0, nil
+		return t.id.Root, nil, 0, nil
 	}
-	return t.trie.Commit(onleaf)
+	return t.trie.CommitNodes(onleaf)
 }
 
 func (t *odrTrie) Hash() common.Hash {
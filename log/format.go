@@ -269,6 +269,10 @@ func JSONFormatEx(pretty, lineSeparated bool) Format {
 			if !ok {
 				props[errorKey] = fmt.Sprintf("%+v is not a string key", r.Ctx[i])
 			}
+			// Keep the record fields stable, context can't overwrite them
+			if k == r.KeyNames.Time || k == r.KeyNames.Lvl || k == r.KeyNames.Msg {
+				k = "ctx_" + k
+			}
 			props[k] = formatJSONValue(r.Ctx[i+1])
 		}
 
@@ -5,6 +5,7 @@ import (
 	"math/big"
 	"math/rand"
 	"testing"
+	"time"
 )
 
 func TestPrettyInt64(t *testing.T) {
@@ -93,3 +94,17 @@ func BenchmarkPrettyUint64Logfmt(b *testing.B) {
 		sink = FormatLogfmtUint64(rand.Uint64())
 	}
 }
+
+func TestJSONFormatReservedKeys(t *testing.T) {
+	r := &Record{
+		Time:     time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Lvl:      LvlInfo,
+		Msg:      "hello",
+		Ctx:      []interface{}{"msg", "context", "number", 1},
+		KeyNames: RecordKeyNames{Time: timeKey, Msg: msgKey, Lvl: lvlKey, Ctx: ctxKey},
+	}
+	want := `{"ctx_msg":"context","lvl":"info","msg":"hello","number":1,"t":"2022-01-01T00:00:00Z"}` + "\n"
+	if have := string(JSONFormat().Format(r)); have != want {
+		t.Errorf("json mismatch:\nhave %s\nwant %s", have, want)
+	}
+}
@@ -70,6 +70,17 @@ func FileHandler(path string, fmtr Format) (Handler, error) {
 	return closingHandler{f, StreamHandler(f, fmtr)}, nil
 }
 
+// RotatingFileHandler returns a handler which writes log records to the given
+// file using the given format, rotating the file once it exceeds the size or age
+// limits of the config. See RotatingFile for the naming of the old files.
+func RotatingFileHandler(path string, config RotateConfig, fmtr Format) (Handler, error) {
+	f, err := NewRotatingFile(path, config)
+	if err != nil {
+		return nil, err
+	}
+	return closingHandler{f, StreamHandler(f, fmtr)}, nil
+}
+
 // NetHandler opens a socket to the given address and writes records
 // over the connection.
 func NetHandler(network, addr string, fmtr Format) (Handler, error) {
@@ -338,7 +349,8 @@ func DiscardHandler() Handler {
 
 // Must provides the following Handler creation functions
 // which instead of returning an error parameter only return a Handler
-// and panic on failure: FileHandler, NetHandler, SyslogHandler, SyslogNetHandler
+// and panic on failure: FileHandler, RotatingFileHandler, NetHandler, SyslogHandler,
+// SyslogNetHandler
 var Must muster
 
 func must(h Handler, err error) Handler {
@@ -354,6 +366,10 @@ func (m muster) FileHandler(path string, fmtr Format) Handler {
 	return must(FileHandler(path, fmtr))
 }
 
+func (m muster) RotatingFileHandler(path string, config RotateConfig, fmtr Format) Handler {
+	return must(RotatingFileHandler(path, config, fmtr))
+}
+
 func (m muster) NetHandler(network, addr string, fmtr Format) Handler {
 	return must(NetHandler(network, addr, fmtr))
 }
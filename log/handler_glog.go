@@ -37,7 +37,7 @@ var errTraceSyntax = errors.New("expect file.go:234")
 // glog logger: setting global log levels; overriding with callsite pattern
 // matches; and requesting backtraces at certain positions.
 type GlogHandler struct {
-	origin Handler // The origin handler this wraps
+	origin swapHandler // The origin handler this wraps, swappable at runtime
 
 	level     uint32 // Current log level, atomically accessible
 	override  uint32 // Flag whether overrides are used, atomically accessible
@@ -52,14 +52,15 @@ type GlogHandler struct {
 // NewGlogHandler creates a new log handler with filtering functionality similar
 // to Google's glog logger. The returned handler implements Handler.
 func NewGlogHandler(h Handler) *GlogHandler {
-	return &GlogHandler{
-		origin: h,
-	}
+	glog := new(GlogHandler)
+	glog.origin.Swap(h)
+	return glog
 }
 
 // SetHandler updates the handler to write records to the specified sub-handler.
+// It is safe to call while records are being logged.
 func (h *GlogHandler) SetHandler(nh Handler) {
-	h.origin = nh
+	h.origin.Swap(nh)
 }
 
 // pattern contains a filter for the Vmodule option, holding a verbosity level
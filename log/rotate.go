@@ -0,0 +1,284 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp appended to the name of rotated log files.
+// It sorts lexically in chronological order and avoids characters which aren't
+// allowed in file names on some platforms.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// compressSuffix is the extension of compressed log files.
+const compressSuffix = ".gz"
+
+// rotateRetryInterval is the time to wait before retrying a failed rotation,
+// logging continues into the current file in the meantime.
+const rotateRetryInterval = time.Minute
+
+// errRotatingFileClosed is returned when writing to a closed rotating file.
+var errRotatingFileClosed = errors.New("log file closed")
+
+// RotateConfig defines when a log file is rotated and what happens to the old
+// files afterwards.
+type RotateConfig struct {
+	MaxSize    int64         // Size in bytes after which the file is rotated (0 = no limit)
+	MaxAge     time.Duration // Time after which the file is rotated (0 = no limit)
+	MaxBackups int           // Number of rotated files to retain (0 = retain all)
+	Compress   bool          // Whether to gzip the rotated files
+}
+
+// RotatingFile is an io.WriteCloser appending to a log file, which is moved
+// aside and replaced by a new one once it grows too large or too old. Rotated
+// files are named after the original one with the rotation time inserted before
+// the extension, e.g. geth-2006-01-02T15-04-05.000.log, and are optionally
+// compressed and pruned in the background.
+type RotatingFile struct {
+	path   string
+	config RotateConfig
+
+	file   *os.File  // Currently open log file, nil if closed
+	size   int64     // Size of the current log file
+	opened time.Time // Time the current log file was opened
+	failed time.Time // Time the last rotation failed
+
+	millLock sync.Mutex     // Serializes compressing and pruning the backups
+	millWG   sync.WaitGroup // Tracks the running background maintenance
+	lock     sync.Mutex
+	now      func() time.Time
+}
+
+// NewRotatingFile opens the log file at path for appending, creating it (and its
+// directory) if necessary.
+func NewRotatingFile(path string, config RotateConfig) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, config: config, now: time.Now}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the log file, appending to the existing content if any. The age
+// of the file is counted from the time it's opened.
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.opened = file, info.Size(), f.now()
+	return nil
+}
+
+// Write implements io.Writer, rotating the file first if the data would push it
+// over the size limit or the file exceeded its maximum age.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return 0, errRotatingFileClosed
+	}
+	if f.size > 0 && f.expired(len(p)) {
+		if err := f.rotate(); err != nil {
+			if f.file == nil {
+				return 0, err
+			}
+			// Can't log through the logger, it's the one writing this file
+			os.Stderr.WriteString("Failed to rotate log file " + f.path + ": " + err.Error() + "\n")
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// expired reports whether the current file needs to be rotated before writing
+// the given amount of data into it.
+func (f *RotatingFile) expired(size int) bool {
+	if !f.failed.IsZero() && f.now().Sub(f.failed) < rotateRetryInterval {
+		return false
+	}
+	if f.config.MaxSize > 0 && f.size+int64(size) > f.config.MaxSize {
+		return true
+	}
+	if f.config.MaxAge > 0 && f.now().Sub(f.opened) >= f.config.MaxAge {
+		return true
+	}
+	return false
+}
+
+// Rotate closes the current log file, moves it aside and opens a new one.
+func (f *RotatingFile) Rotate() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return errRotatingFileClosed
+	}
+	return f.rotate()
+}
+
+// rotate is the unsynchronized version of Rotate. If the file can't be moved
+// aside or the new one can't be opened, the current file is reopened so that
+// logging goes on, and rotation is retried later.
+func (f *RotatingFile) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+
+	if err == nil {
+		backup := f.backupName(f.now())
+		if err = os.Rename(f.path, backup); err == nil {
+			if err = f.open(); err == nil {
+				f.failed = time.Time{}
+				f.millWG.Add(1)
+				go f.mill(backup)
+				return nil
+			}
+			// Move the old file back to keep appending to it
+			os.Rename(backup, f.path)
+		}
+	}
+	f.failed = f.now()
+	if rerr := f.open(); rerr != nil {
+		return rerr
+	}
+	return err
+}
+
+// backupName returns the name a log file is moved to when rotated at the given
+// time.
+func (f *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	return strings.TrimSuffix(f.path, ext) + "-" + t.Format(backupTimeFormat) + ext
+}
+
+// mill compresses the freshly rotated backup if requested and drops the backups
+// in excess of the retention limit.
+func (f *RotatingFile) mill(backup string) {
+	defer f.millWG.Done()
+
+	f.millLock.Lock()
+	defer f.millLock.Unlock()
+
+	if f.config.Compress {
+		if err := compressFile(backup); err != nil {
+			// Can't log through the logger, it's the one writing this file
+			os.Stderr.WriteString("Failed to compress log file " + backup + ": " + err.Error() + "\n")
+		}
+	}
+	if f.config.MaxBackups > 0 {
+		backups, err := f.backups()
+		if err != nil {
+			return
+		}
+		for len(backups) > f.config.MaxBackups {
+			os.Remove(backups[0])
+			backups = backups[1:]
+		}
+	}
+}
+
+// backups returns the rotated log files, oldest first.
+func (f *RotatingFile) backups() ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return nil, err
+	}
+	var (
+		ext    = filepath.Ext(f.path)
+		prefix = strings.TrimSuffix(filepath.Base(f.path), ext) + "-"
+		files  []string
+	)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name[len(prefix):], compressSuffix), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		files = append(files, filepath.Join(filepath.Dir(f.path), name))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Close closes the log file, waiting for the background maintenance of the
+// rotated files to finish.
+func (f *RotatingFile) Close() error {
+	f.lock.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.lock.Unlock()
+
+	f.millWG.Wait()
+	return err
+}
+
+// compressFile gzips a file, replacing the original.
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+compressSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(path + compressSuffix)
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(path + compressSuffix)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(path + compressSuffix)
+		return err
+	}
+	in.Close()
+	return os.Remove(path)
+}
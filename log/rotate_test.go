@@ -0,0 +1,153 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package log
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that log files are rotated once they would exceed the size limit, and
+// that the oldest backups are dropped.
+func TestRotatingFileSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "geth.log")
+
+	f, err := NewRotatingFile(path, RotateConfig{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("failed to open log file: %v", err)
+	}
+	clock := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("failed to write log: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("failed to close log file: %v", err)
+	}
+	if content, _ := ioutil.ReadFile(path); string(content) != "fourth\n" {
+		t.Errorf("current log content mismatch: have %q, want %q", content, "fourth\n")
+	}
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatalf("failed to list backups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("backup count mismatch: have %d, want 2", len(backups))
+	}
+	for i, want := range []string{"second\n", "third\n"} {
+		if content, _ := ioutil.ReadFile(backups[i]); string(content) != want {
+			t.Errorf("backup %d content mismatch: have %q, want %q", i, content, want)
+		}
+	}
+}
+
+// Tests that log files are rotated once they're too old, and that the old
+// files get compressed if requested.
+func TestRotatingFileAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "geth.log")
+
+	clock := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	f, err := NewRotatingFile(path, RotateConfig{MaxAge: time.Hour, Compress: true})
+	if err != nil {
+		t.Fatalf("failed to open log file: %v", err)
+	}
+	f.now = func() time.Time { return clock }
+	f.opened = clock
+
+	f.Write([]byte("old\n"))
+	clock = clock.Add(30 * time.Minute)
+	f.Write([]byte("older\n"))
+	clock = clock.Add(30 * time.Minute)
+	f.Write([]byte("new\n"))
+	f.Close()
+
+	if content, _ := ioutil.ReadFile(path); string(content) != "new\n" {
+		t.Errorf("current log content mismatch: have %q, want %q", content, "new\n")
+	}
+	backups, _ := f.backups()
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], "geth-2022-01-01T01-00-00.000.log.gz") {
+		t.Fatalf("unexpected backups: %v", backups)
+	}
+	file, err := os.Open(backups[0])
+	if err != nil {
+		t.Fatalf("failed to open backup: %v", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("failed to decompress backup: %v", err)
+	}
+	if content, _ := ioutil.ReadAll(gz); string(content) != "old\nolder\n" {
+		t.Errorf("backup content mismatch: have %q, want %q", content, "old\nolder\n")
+	}
+}
+
+// Tests that a failed rotation doesn't stop logging, and that the rotation is
+// retried later.
+func TestRotatingFileRotateFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "geth.log")
+
+	clock := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	f, err := NewRotatingFile(path, RotateConfig{MaxSize: 10})
+	if err != nil {
+		t.Fatalf("failed to open log file: %v", err)
+	}
+	defer f.Close()
+	f.now = func() time.Time { return clock }
+
+	// Block the backup name with a non-empty directory, so the rename fails
+	backup := f.backupName(clock)
+	if err := os.MkdirAll(filepath.Join(backup, "blocker"), 0755); err != nil {
+		t.Fatalf("failed to block backup name: %v", err)
+	}
+	if err := f.Rotate(); err == nil {
+		t.Fatal("rotation succeeded despite blocked backup name")
+	}
+	for _, line := range []string{"first\n", "second\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("failed to write log after failed rotation: %v", err)
+		}
+	}
+	if content, _ := ioutil.ReadFile(path); string(content) != "first\nsecond\n" {
+		t.Errorf("log content mismatch: have %q, want %q", content, "first\nsecond\n")
+	}
+	// Once the retry interval passed, the file is rotated
+	os.RemoveAll(backup)
+	clock = clock.Add(rotateRetryInterval)
+	if _, err := f.Write([]byte("third\n")); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+	if content, _ := ioutil.ReadFile(path); string(content) != "third\n" {
+		t.Errorf("current log content mismatch: have %q, want %q", content, "third\n")
+	}
+	if content, _ := ioutil.ReadFile(f.backupName(clock)); string(content) != "first\nsecond\n" {
+		t.Errorf("backup content mismatch: have %q, want %q", content, "first\nsecond\n")
+	}
+}
@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultDurationBuckets are the upper bounds of BucketHistogram buckets suitable
+// for durations measured in seconds, spanning from 5ms to 10s.
+var DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// BucketHistograms count values into buckets with fixed upper bounds, the way
+// Prometheus histograms do. Unlike Histograms they don't sample, so the bucket
+// counts of many processes can be aggregated.
+type BucketHistogram interface {
+	Buckets() []float64
+	BucketCounts() []uint64
+	Count() int64
+	Observe(float64)
+	Snapshot() BucketHistogram
+	Sum() float64
+}
+
+// ExponentialBuckets returns count bucket upper bounds, the first being start
+// and each subsequent one factor times the previous.
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// GetOrRegisterBucketHistogram returns an existing BucketHistogram or constructs
+// and registers a new StandardBucketHistogram.
+func GetOrRegisterBucketHistogram(name string, r Registry, buckets []float64) BucketHistogram {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return r.GetOrRegister(name, func() BucketHistogram { return NewBucketHistogram(buckets) }).(BucketHistogram)
+}
+
+// NewBucketHistogram constructs a new StandardBucketHistogram with the given
+// bucket upper bounds.
+func NewBucketHistogram(buckets []float64) BucketHistogram {
+	if !Enabled {
+		return NilBucketHistogram{}
+	}
+	bounds := make([]float64, len(buckets))
+	copy(bounds, buckets)
+	sort.Float64s(bounds)
+
+	return &StandardBucketHistogram{
+		buckets: bounds,
+		counts:  make([]uint64, len(bounds)+1),
+	}
+}
+
+// NewRegisteredBucketHistogram constructs and registers a new
+// StandardBucketHistogram with the given bucket upper bounds.
+func NewRegisteredBucketHistogram(name string, r Registry, buckets []float64) BucketHistogram {
+	c := NewBucketHistogram(buckets)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// BucketHistogramSnapshot is a read-only copy of another BucketHistogram.
+type BucketHistogramSnapshot struct {
+	buckets []float64
+	counts  []uint64
+	count   int64
+	sum     float64
+}
+
+// Buckets returns the upper bounds of the buckets.
+func (h *BucketHistogramSnapshot) Buckets() []float64 { return h.buckets }
+
+// BucketCounts returns the number of values counted into each bucket at the
+// time the snapshot was taken. The last element counts the values above the
+// highest bound.
+func (h *BucketHistogramSnapshot) BucketCounts() []uint64 { return h.counts }
+
+// Count returns the number of values at the time the snapshot was taken.
+func (h *BucketHistogramSnapshot) Count() int64 { return h.count }
+
+// Observe panics.
+func (*BucketHistogramSnapshot) Observe(float64) {
+	panic("Observe called on a BucketHistogramSnapshot")
+}
+
+// Snapshot returns the snapshot.
+func (h *BucketHistogramSnapshot) Snapshot() BucketHistogram { return h }
+
+// Sum returns the sum of the values at the time the snapshot was taken.
+func (h *BucketHistogramSnapshot) Sum() float64 { return h.sum }
+
+// NilBucketHistogram is a no-op BucketHistogram.
+type NilBucketHistogram struct{}
+
+// Buckets is a no-op.
+func (NilBucketHistogram) Buckets() []float64 { return nil }
+
+// BucketCounts is a no-op.
+func (NilBucketHistogram) BucketCounts() []uint64 { return nil }
+
+// Count is a no-op.
+func (NilBucketHistogram) Count() int64 { return 0 }
+
+// Observe is a no-op.
+func (NilBucketHistogram) Observe(float64) {}
+
+// Snapshot is a no-op.
+func (NilBucketHistogram) Snapshot() BucketHistogram { return NilBucketHistogram{} }
+
+// Sum is a no-op.
+func (NilBucketHistogram) Sum() float64 { return 0 }
+
+// StandardBucketHistogram is the standard implementation of a BucketHistogram.
+type StandardBucketHistogram struct {
+	buckets []float64
+	counts  []uint64
+	count   int64
+	sum     float64
+	mutex   sync.Mutex
+}
+
+// Buckets returns the upper bounds of the buckets.
+func (h *StandardBucketHistogram) Buckets() []float64 { return h.buckets }
+
+// BucketCounts returns the number of values counted into each bucket. The last
+// element counts the values above the highest bound.
+func (h *StandardBucketHistogram) BucketCounts() []uint64 {
+	return h.Snapshot().BucketCounts()
+}
+
+// Count returns the number of values counted.
+func (h *StandardBucketHistogram) Count() int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.count
+}
+
+// Observe counts a value into the first bucket whose upper bound isn't below it.
+func (h *StandardBucketHistogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.counts[i]++
+	h.count++
+	h.sum += v
+}
+
+// Snapshot returns a read-only copy of the histogram.
+func (h *StandardBucketHistogram) Snapshot() BucketHistogram {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	counts := make([]uint64, len(h.counts))
+	copy(counts, h.counts)
+	return &BucketHistogramSnapshot{
+		buckets: h.buckets,
+		counts:  counts,
+		count:   h.count,
+		sum:     h.sum,
+	}
+}
+
+// Sum returns the sum of the values counted.
+func (h *StandardBucketHistogram) Sum() float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.sum
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetOrRegisterBucketHistogram(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredBucketHistogram("foo", r, []float64{1}).Observe(0.5)
+	if h := GetOrRegisterBucketHistogram("foo", r, []float64{1}); h.Count() != 1 {
+		t.Fatal(h)
+	}
+}
+
+func TestBucketHistogram(t *testing.T) {
+	h := NewBucketHistogram([]float64{10, 1, 5})
+	for _, v := range []float64{0, 1, 2, 5, 7, 100} {
+		h.Observe(v)
+	}
+	if buckets := h.Buckets(); !reflect.DeepEqual(buckets, []float64{1, 5, 10}) {
+		t.Errorf("h.Buckets(): [1 5 10] != %v\n", buckets)
+	}
+	if counts := h.BucketCounts(); !reflect.DeepEqual(counts, []uint64{2, 2, 1, 1}) {
+		t.Errorf("h.BucketCounts(): [2 2 1 1] != %v\n", counts)
+	}
+	if count := h.Count(); count != 6 {
+		t.Errorf("h.Count(): 6 != %v\n", count)
+	}
+	if sum := h.Sum(); sum != 115 {
+		t.Errorf("h.Sum(): 115 != %v\n", sum)
+	}
+}
+
+func TestBucketHistogramSnapshot(t *testing.T) {
+	h := NewBucketHistogram([]float64{1})
+	h.Observe(2)
+	snapshot := h.Snapshot()
+	h.Observe(0)
+	if count := snapshot.Count(); count != 1 {
+		t.Errorf("snapshot.Count(): 1 != %v\n", count)
+	}
+	if counts := snapshot.BucketCounts(); !reflect.DeepEqual(counts, []uint64{0, 1}) {
+		t.Errorf("snapshot.BucketCounts(): [0 1] != %v\n", counts)
+	}
+}
+
+func TestExponentialBuckets(t *testing.T) {
+	if buckets := ExponentialBuckets(1, 2, 4); !reflect.DeepEqual(buckets, []float64{1, 2, 4, 8}) {
+		t.Errorf("ExponentialBuckets(1, 2, 4): [1 2 4 8] != %v\n", buckets)
+	}
+}
@@ -123,6 +123,12 @@ func (exp *exp) publishHistogram(name string, metric metrics.Histogram) {
 	exp.getFloat(name + ".999-percentile").Set(ps[4])
 }
 
+func (exp *exp) publishBucketHistogram(name string, metric metrics.BucketHistogram) {
+	h := metric.Snapshot()
+	exp.getInt(name + ".count").Set(h.Count())
+	exp.getFloat(name + ".sum").Set(h.Sum())
+}
+
 func (exp *exp) publishMeter(name string, metric metrics.Meter) {
 	m := metric.Snapshot()
 	exp.getInt(name + ".count").Set(m.Count())
@@ -163,7 +169,7 @@ func (exp *exp) publishResettingTimer(name string, metric metrics.ResettingTimer
 }
 
 func (exp *exp) syncToExpvar() {
-	exp.registry.Each(func(name string, i interface{}) {
+	metrics.Flatten(exp.registry, func(name string, i interface{}) {
 		switch i := i.(type) {
 		case metrics.Counter:
 			exp.publishCounter(name, i)
@@ -173,6 +179,8 @@ func (exp *exp) syncToExpvar() {
 			exp.publishGaugeFloat64(name, i)
 		case metrics.Histogram:
 			exp.publishHistogram(name, i)
+		case metrics.BucketHistogram:
+			exp.publishBucketHistogram(name, i)
 		case metrics.Meter:
 			exp.publishMeter(name, i)
 		case metrics.Timer:
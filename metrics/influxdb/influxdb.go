@@ -123,7 +123,7 @@ func (r *reporter) run() {
 func (r *reporter) send() error {
 	var pts []client.Point
 
-	r.reg.Each(func(name string, i interface{}) {
+	metrics.Flatten(r.reg, func(name string, i interface{}) {
 		now := time.Now()
 		namespace := r.namespace
 
@@ -185,6 +185,17 @@ func (r *reporter) send() error {
 					Time: now,
 				})
 			}
+		case metrics.BucketHistogram:
+			ms := metric.Snapshot()
+			pts = append(pts, client.Point{
+				Measurement: fmt.Sprintf("%s%s.histogram", namespace, name),
+				Tags:        r.tags,
+				Fields: map[string]interface{}{
+					"count": ms.Count(),
+					"sum":   ms.Sum(),
+				},
+				Time: now,
+			})
 		case metrics.Meter:
 			ms := metric.Snapshot()
 			pts = append(pts, client.Point{
@@ -85,7 +85,7 @@ func (r *v2Reporter) run() {
 }
 
 func (r *v2Reporter) send() {
-	r.reg.Each(func(name string, i interface{}) {
+	metrics.Flatten(r.reg, func(name string, i interface{}) {
 		now := time.Now()
 		namespace := r.namespace
 
@@ -152,6 +152,18 @@ func (r *v2Reporter) send() {
 				r.write.WritePoint(pt)
 			}
 
+		case metrics.BucketHistogram:
+			ms := metric.Snapshot()
+
+			measurement := fmt.Sprintf("%s%s.histogram", namespace, name)
+			fields := map[string]interface{}{
+				"count": ms.Count(),
+				"sum":   ms.Sum(),
+			}
+
+			pt := influxdb2.NewPoint(measurement, r.tags, fields, now)
+			r.write.WritePoint(pt)
+
 		case metrics.Meter:
 			ms := metric.Snapshot()
 
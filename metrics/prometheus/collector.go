@@ -19,6 +19,7 @@ package prometheus
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
@@ -26,11 +27,14 @@ import (
 )
 
 var (
-	typeGaugeTpl           = "# TYPE %s gauge\n"
-	typeCounterTpl         = "# TYPE %s counter\n"
-	typeSummaryTpl         = "# TYPE %s summary\n"
-	keyValueTpl            = "%s %v\n\n"
-	keyQuantileTagValueTpl = "%s {quantile=\"%s\"} %v\n"
+	typeTpl   = "# TYPE %s %s\n"
+	sampleTpl = "%s%s %v\n"
+
+	// summaryQuantiles are the quantiles reported for sampled histograms and timers.
+	summaryQuantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
+
+	// labelEscaper escapes label values as mandated by the Prometheus text format.
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
 )
 
 // collector is a collection of byte buffers that aggregate Prometheus reports
@@ -47,40 +51,44 @@ func newCollector() *collector {
 }
 
 func (c *collector) addCounter(name string, m metrics.Counter) {
-	c.writeGaugeCounter(name, m.Count())
+	c.writeType(name, "gauge")
+	c.writeCounter(name, nil, m)
+	c.buff.WriteRune('\n')
 }
 
 func (c *collector) addGauge(name string, m metrics.Gauge) {
-	c.writeGaugeCounter(name, m.Value())
+	c.writeType(name, "gauge")
+	c.writeGauge(name, nil, m)
+	c.buff.WriteRune('\n')
 }
 
 func (c *collector) addGaugeFloat64(name string, m metrics.GaugeFloat64) {
-	c.writeGaugeCounter(name, m.Value())
+	c.writeType(name, "gauge")
+	c.writeGaugeFloat64(name, nil, m)
+	c.buff.WriteRune('\n')
 }
 
 func (c *collector) addHistogram(name string, m metrics.Histogram) {
-	pv := []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
-	ps := m.Percentiles(pv)
-	c.writeSummaryCounter(name, m.Count())
-	c.buff.WriteString(fmt.Sprintf(typeSummaryTpl, mutateKey(name)))
-	for i := range pv {
-		c.writeSummaryPercentile(name, strconv.FormatFloat(pv[i], 'f', -1, 64), ps[i])
-	}
+	c.writeType(name, "summary")
+	c.writeHistogram(name, nil, m)
+	c.buff.WriteRune('\n')
+}
+
+func (c *collector) addBucketHistogram(name string, m metrics.BucketHistogram) {
+	c.writeType(name, "histogram")
+	c.writeBucketHistogram(name, nil, m)
 	c.buff.WriteRune('\n')
 }
 
 func (c *collector) addMeter(name string, m metrics.Meter) {
-	c.writeGaugeCounter(name, m.Count())
+	c.writeType(name, "gauge")
+	c.writeMeter(name, nil, m)
+	c.buff.WriteRune('\n')
 }
 
 func (c *collector) addTimer(name string, m metrics.Timer) {
-	pv := []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
-	ps := m.Percentiles(pv)
-	c.writeSummaryCounter(name, m.Count())
-	c.buff.WriteString(fmt.Sprintf(typeSummaryTpl, mutateKey(name)))
-	for i := range pv {
-		c.writeSummaryPercentile(name, strconv.FormatFloat(pv[i], 'f', -1, 64), ps[i])
-	}
+	c.writeType(name, "summary")
+	c.writeTimer(name, nil, m)
 	c.buff.WriteRune('\n')
 }
 
@@ -88,31 +96,146 @@ func (c *collector) addResettingTimer(name string, m metrics.ResettingTimer) {
 	if len(m.Values()) <= 0 {
 		return
 	}
-	ps := m.Percentiles([]float64{50, 95, 99})
-	val := m.Values()
-	c.writeSummaryCounter(name, len(val))
-	c.buff.WriteString(fmt.Sprintf(typeSummaryTpl, mutateKey(name)))
-	c.writeSummaryPercentile(name, "0.50", ps[0])
-	c.writeSummaryPercentile(name, "0.95", ps[1])
-	c.writeSummaryPercentile(name, "0.99", ps[2])
+	c.writeType(name, "summary")
+	c.writeResettingTimer(name, nil, m)
 	c.buff.WriteRune('\n')
 }
 
-func (c *collector) writeGaugeCounter(name string, value interface{}) {
-	name = mutateKey(name)
-	c.buff.WriteString(fmt.Sprintf(typeGaugeTpl, name))
-	c.buff.WriteString(fmt.Sprintf(keyValueTpl, name, value))
+// addVec reports all the members of a metric vector as a single metric family,
+// distinguished by their labels. Empty vectors are omitted.
+func (c *collector) addVec(name string, v metrics.Vec) {
+	var (
+		labels  = v.Labels()
+		written bool
+	)
+	v.Each(func(values []string, i interface{}) {
+		if !written {
+			c.writeType(name, vecMemberType(i))
+			written = true
+		}
+		pairs := make([]string, len(labels))
+		for j := range labels {
+			pairs[j] = labels[j] + "=\"" + labelEscaper.Replace(values[j]) + "\""
+		}
+		switch m := i.(type) {
+		case metrics.Counter:
+			c.writeCounter(name, pairs, m.Snapshot())
+		case metrics.Gauge:
+			c.writeGauge(name, pairs, m.Snapshot())
+		case metrics.GaugeFloat64:
+			c.writeGaugeFloat64(name, pairs, m.Snapshot())
+		case metrics.Histogram:
+			c.writeHistogram(name, pairs, m.Snapshot())
+		case metrics.BucketHistogram:
+			c.writeBucketHistogram(name, pairs, m.Snapshot())
+		case metrics.Meter:
+			// Meters are only reported by count, which the live meter has
+			// up to date, unlike its snapshot
+			c.writeMeter(name, pairs, m)
+		case metrics.Timer:
+			c.writeTimer(name, pairs, m.Snapshot())
+		}
+	})
+	if written {
+		c.buff.WriteRune('\n')
+	}
+}
+
+// vecMemberType returns the Prometheus metric type members of a vector are
+// reported as.
+func vecMemberType(i interface{}) string {
+	switch i.(type) {
+	case metrics.Histogram, metrics.Timer:
+		return "summary"
+	case metrics.BucketHistogram:
+		return "histogram"
+	default:
+		return "gauge"
+	}
+}
+
+func (c *collector) writeCounter(name string, labels []string, m metrics.Counter) {
+	c.writeSample(name, labels, m.Count())
+}
+
+func (c *collector) writeGauge(name string, labels []string, m metrics.Gauge) {
+	c.writeSample(name, labels, m.Value())
 }
 
-func (c *collector) writeSummaryCounter(name string, value interface{}) {
-	name = mutateKey(name + "_count")
-	c.buff.WriteString(fmt.Sprintf(typeCounterTpl, name))
-	c.buff.WriteString(fmt.Sprintf(keyValueTpl, name, value))
+func (c *collector) writeGaugeFloat64(name string, labels []string, m metrics.GaugeFloat64) {
+	c.writeSample(name, labels, m.Value())
 }
 
-func (c *collector) writeSummaryPercentile(name, p string, value interface{}) {
-	name = mutateKey(name)
-	c.buff.WriteString(fmt.Sprintf(keyQuantileTagValueTpl, name, p, value))
+func (c *collector) writeMeter(name string, labels []string, m metrics.Meter) {
+	c.writeSample(name, labels, m.Count())
+}
+
+// writeHistogram reports a sampled histogram as a summary. The sum is estimated
+// from the sample mean, as the sample doesn't hold every value counted.
+func (c *collector) writeHistogram(name string, labels []string, m metrics.Histogram) {
+	c.writeSummary(name, labels, m.Percentiles(summaryQuantiles), m.Mean()*float64(m.Count()), m.Count())
+}
+
+// writeTimer reports a timer as a summary, the same way as histograms.
+func (c *collector) writeTimer(name string, labels []string, m metrics.Timer) {
+	c.writeSummary(name, labels, m.Percentiles(summaryQuantiles), m.Mean()*float64(m.Count()), m.Count())
+}
+
+func (c *collector) writeResettingTimer(name string, labels []string, m metrics.ResettingTimer) {
+	var (
+		val = m.Values()
+		ps  = m.Percentiles([]float64{50, 95, 99})
+		sum int64
+	)
+	for _, v := range val {
+		sum += v
+	}
+	c.writeSample(name, append(labels[:len(labels):len(labels)], `quantile="0.50"`), ps[0])
+	c.writeSample(name, append(labels[:len(labels):len(labels)], `quantile="0.95"`), ps[1])
+	c.writeSample(name, append(labels[:len(labels):len(labels)], `quantile="0.99"`), ps[2])
+	c.writeSample(name+"_sum", labels, sum)
+	c.writeSample(name+"_count", labels, len(val))
+}
+
+func (c *collector) writeSummary(name string, labels []string, ps []float64, sum float64, count int64) {
+	for i, q := range summaryQuantiles {
+		quantile := "quantile=\"" + strconv.FormatFloat(q, 'f', -1, 64) + "\""
+		c.writeSample(name, append(labels[:len(labels):len(labels)], quantile), ps[i])
+	}
+	c.writeSample(name+"_sum", labels, sum)
+	c.writeSample(name+"_count", labels, count)
+}
+
+// writeBucketHistogram reports the cumulative bucket counts of a histogram.
+func (c *collector) writeBucketHistogram(name string, labels []string, m metrics.BucketHistogram) {
+	var (
+		buckets    = m.Buckets()
+		counts     = m.BucketCounts()
+		cumulative uint64
+	)
+	for i, count := range counts {
+		le := math.Inf(+1)
+		if i < len(buckets) {
+			le = buckets[i]
+		}
+		cumulative += count
+		bound := "le=\"" + strconv.FormatFloat(le, 'g', -1, 64) + "\""
+		c.writeSample(name+"_bucket", append(labels[:len(labels):len(labels)], bound), cumulative)
+	}
+	c.writeSample(name+"_sum", labels, m.Sum())
+	c.writeSample(name+"_count", labels, m.Count())
+}
+
+func (c *collector) writeType(name string, kind string) {
+	c.buff.WriteString(fmt.Sprintf(typeTpl, mutateKey(name), kind))
+}
+
+func (c *collector) writeSample(name string, labels []string, value interface{}) {
+	var set string
+	if len(labels) > 0 {
+		set = "{" + strings.Join(labels, ",") + "}"
+	}
+	c.buff.WriteString(fmt.Sprintf(sampleTpl, mutateKey(name), set, value))
 }
 
 func mutateKey(key string) string {
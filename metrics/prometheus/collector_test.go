@@ -67,38 +67,35 @@ test_gauge 23456
 # TYPE test_gauge_float64 gauge
 test_gauge_float64 34567.89
 
-# TYPE test_histogram_count counter
-test_histogram_count 0
-
 # TYPE test_histogram summary
-test_histogram {quantile="0.5"} 0
-test_histogram {quantile="0.75"} 0
-test_histogram {quantile="0.95"} 0
-test_histogram {quantile="0.99"} 0
-test_histogram {quantile="0.999"} 0
-test_histogram {quantile="0.9999"} 0
+test_histogram{quantile="0.5"} 0
+test_histogram{quantile="0.75"} 0
+test_histogram{quantile="0.95"} 0
+test_histogram{quantile="0.99"} 0
+test_histogram{quantile="0.999"} 0
+test_histogram{quantile="0.9999"} 0
+test_histogram_sum 0
+test_histogram_count 0
 
 # TYPE test_meter gauge
 test_meter 9999999
 
-# TYPE test_timer_count counter
-test_timer_count 6
-
 # TYPE test_timer summary
-test_timer {quantile="0.5"} 2.25e+07
-test_timer {quantile="0.75"} 4.8e+07
-test_timer {quantile="0.95"} 1.2e+08
-test_timer {quantile="0.99"} 1.2e+08
-test_timer {quantile="0.999"} 1.2e+08
-test_timer {quantile="0.9999"} 1.2e+08
-
-# TYPE test_resetting_timer_count counter
-test_resetting_timer_count 6
+test_timer{quantile="0.5"} 2.25e+07
+test_timer{quantile="0.75"} 4.8e+07
+test_timer{quantile="0.95"} 1.2e+08
+test_timer{quantile="0.99"} 1.2e+08
+test_timer{quantile="0.999"} 1.2e+08
+test_timer{quantile="0.9999"} 1.2e+08
+test_timer_sum 2.3e+08
+test_timer_count 6
 
 # TYPE test_resetting_timer summary
-test_resetting_timer {quantile="0.50"} 12000000
-test_resetting_timer {quantile="0.95"} 120000000
-test_resetting_timer {quantile="0.99"} 120000000
+test_resetting_timer{quantile="0.50"} 12000000
+test_resetting_timer{quantile="0.95"} 120000000
+test_resetting_timer{quantile="0.99"} 120000000
+test_resetting_timer_sum 180000000
+test_resetting_timer_count 6
 
 `
 	exp := c.buff.String()
@@ -108,3 +105,64 @@ test_resetting_timer {quantile="0.99"} 120000000
 		t.Fatal("unexpected collector output")
 	}
 }
+
+func TestCollectorBucketHistogram(t *testing.T) {
+	c := newCollector()
+
+	histogram := metrics.NewBucketHistogram([]float64{0.1, 1, 10})
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(0.75)
+	histogram.Observe(20)
+	c.addBucketHistogram("test/bucket_histogram", histogram.Snapshot())
+
+	const expectedOutput = `# TYPE test_bucket_histogram histogram
+test_bucket_histogram_bucket{le="0.1"} 1
+test_bucket_histogram_bucket{le="1"} 3
+test_bucket_histogram_bucket{le="10"} 3
+test_bucket_histogram_bucket{le="+Inf"} 4
+test_bucket_histogram_sum 21.3
+test_bucket_histogram_count 4
+
+`
+	if out := c.buff.String(); out != expectedOutput {
+		t.Log("Expected Output:\n", expectedOutput)
+		t.Log("Actual Output:\n", out)
+		t.Fatal("unexpected collector output")
+	}
+}
+
+func TestCollectorVec(t *testing.T) {
+	c := newCollector()
+
+	meters := metrics.NewMeterVec("protocol", "code")
+	defer meters.Stop()
+	meters.With("snap", "0x01").Mark(10)
+	meters.With("eth", "0x02").Mark(20)
+	meters.With("eth", "0x01").Mark(30)
+	c.addVec("test/meter_vec", meters)
+
+	histograms := metrics.NewBucketHistogramVec([]float64{1}, "method")
+	histograms.With(`eth_"call"`).Observe(0.5)
+	c.addVec("test/histogram_vec", histograms)
+
+	c.addVec("test/empty_vec", metrics.NewTimerVec("method"))
+
+	const expectedOutput = `# TYPE test_meter_vec gauge
+test_meter_vec{protocol="eth",code="0x01"} 30
+test_meter_vec{protocol="eth",code="0x02"} 20
+test_meter_vec{protocol="snap",code="0x01"} 10
+
+# TYPE test_histogram_vec histogram
+test_histogram_vec_bucket{method="eth_\"call\"",le="1"} 1
+test_histogram_vec_bucket{method="eth_\"call\"",le="+Inf"} 1
+test_histogram_vec_sum{method="eth_\"call\""} 0.5
+test_histogram_vec_count{method="eth_\"call\""} 1
+
+`
+	if out := c.buff.String(); out != expectedOutput {
+		t.Log("Expected Output:\n", expectedOutput)
+		t.Log("Actual Output:\n", out)
+		t.Fatal("unexpected collector output")
+	}
+}
@@ -57,6 +57,10 @@ func Handler(reg metrics.Registry) http.Handler {
 				c.addTimer(name, m.Snapshot())
 			case metrics.ResettingTimer:
 				c.addResettingTimer(name, m.Snapshot())
+			case metrics.BucketHistogram:
+				c.addBucketHistogram(name, m.Snapshot())
+			case metrics.Vec:
+				c.addVec(name, m)
 			default:
 				log.Warn("Unknown Prometheus metric type", "type", fmt.Sprintf("%T", i))
 			}
@@ -115,7 +115,7 @@ func (r *StandardRegistry) RunHealthchecks() {
 // GetAll metrics in the Registry
 func (r *StandardRegistry) GetAll() map[string]map[string]interface{} {
 	data := make(map[string]map[string]interface{})
-	r.Each(func(name string, i interface{}) {
+	Flatten(r, func(name string, i interface{}) {
 		values := make(map[string]interface{})
 		switch metric := i.(type) {
 		case Counter:
@@ -143,6 +143,10 @@ func (r *StandardRegistry) GetAll() map[string]map[string]interface{} {
 			values["95%"] = ps[2]
 			values["99%"] = ps[3]
 			values["99.9%"] = ps[4]
+		case BucketHistogram:
+			h := metric.Snapshot()
+			values["count"] = h.Count()
+			values["sum"] = h.Sum()
 		case Meter:
 			m := metric.Snapshot()
 			values["count"] = m.Count()
@@ -196,7 +200,7 @@ func (r *StandardRegistry) register(name string, i interface{}) error {
 		return DuplicateMetric(name)
 	}
 	switch i.(type) {
-	case Counter, Gauge, GaugeFloat64, Healthcheck, Histogram, Meter, Timer, ResettingTimer:
+	case Counter, Gauge, GaugeFloat64, Healthcheck, Histogram, Meter, Timer, ResettingTimer, BucketHistogram, Vec:
 		r.metrics[name] = i
 	}
 	return nil
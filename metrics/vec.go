@@ -0,0 +1,238 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Vecs are families of metrics of the same kind, partitioned by the values of a
+// fixed set of labels, e.g. the request durations of every RPC method. Members
+// are created on first use.
+type Vec interface {
+	Each(func(values []string, metric interface{}))
+	Labels() []string
+}
+
+// Flatten calls f for every metric in the registry, expanding each Vec into its
+// members. Members are named after their vector with the label values appended
+// as path elements, e.g. rpc/duration/eth_call/success. It allows exporters without
+// label support to report vectors.
+func Flatten(r Registry, f func(string, interface{})) {
+	r.Each(func(name string, i interface{}) {
+		if v, ok := i.(Vec); ok {
+			v.Each(func(values []string, metric interface{}) {
+				f(name+"/"+strings.Join(values, "/"), metric)
+			})
+			return
+		}
+		f(name, i)
+	})
+}
+
+// vecMember is a metric of a vector along with its label values.
+type vecMember struct {
+	values []string
+	metric interface{}
+}
+
+// vec is the label indexed metric set shared by all the typed vectors.
+type vec struct {
+	labels  []string
+	create  func() interface{}
+	members map[string]*vecMember
+	mutex   sync.RWMutex
+}
+
+func newVec(labels []string, create func() interface{}) *vec {
+	return &vec{
+		labels:  labels,
+		create:  create,
+		members: make(map[string]*vecMember),
+	}
+}
+
+// with returns the member with the given label values, creating it if needed.
+func (v *vec) with(values []string) interface{} {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metric vector with %d labels given %d values", len(v.labels), len(values)))
+	}
+	// Don't bother tracking the no-op metrics
+	if !Enabled {
+		return v.create()
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mutex.RLock()
+	member, ok := v.members[key]
+	v.mutex.RUnlock()
+	if ok {
+		return member.metric
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if member, ok := v.members[key]; ok {
+		return member.metric
+	}
+	member = &vecMember{values: append([]string(nil), values...), metric: v.create()}
+	v.members[key] = member
+	return member.metric
+}
+
+// Each calls f for every member of the vector, ordered by label values.
+func (v *vec) Each(f func(values []string, metric interface{})) {
+	v.mutex.RLock()
+	keys := make([]string, 0, len(v.members))
+	for key := range v.members {
+		keys = append(keys, key)
+	}
+	members := make([]*vecMember, len(keys))
+	sort.Strings(keys)
+	for i, key := range keys {
+		members[i] = v.members[key]
+	}
+	v.mutex.RUnlock()
+
+	for _, member := range members {
+		f(member.values, member.metric)
+	}
+}
+
+// Labels returns the label names of the vector.
+func (v *vec) Labels() []string { return v.labels }
+
+// Stop stops all the members requiring it, to allow for garbage collection.
+func (v *vec) Stop() {
+	v.Each(func(_ []string, metric interface{}) {
+		if s, ok := metric.(Stoppable); ok {
+			s.Stop()
+		}
+	})
+}
+
+// MeterVec is a Vec of Meters.
+type MeterVec struct{ *vec }
+
+// NewMeterVec constructs a new MeterVec with the given label names.
+func NewMeterVec(labels ...string) *MeterVec {
+	return &MeterVec{newVec(labels, func() interface{} { return NewMeter() })}
+}
+
+// GetOrRegisterMeterVec returns an existing MeterVec or constructs and
+// registers a new one.
+func GetOrRegisterMeterVec(name string, r Registry, labels ...string) *MeterVec {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return r.GetOrRegister(name, func() *MeterVec { return NewMeterVec(labels...) }).(*MeterVec)
+}
+
+// NewRegisteredMeterVec constructs and registers a new MeterVec.
+func NewRegisteredMeterVec(name string, r Registry, labels ...string) *MeterVec {
+	c := NewMeterVec(labels...)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// With returns the Meter with the given label values.
+func (v *MeterVec) With(values ...string) Meter { return v.with(values).(Meter) }
+
+// TimerVec is a Vec of Timers.
+type TimerVec struct{ *vec }
+
+// NewTimerVec constructs a new TimerVec with the given label names.
+func NewTimerVec(labels ...string) *TimerVec {
+	return &TimerVec{newVec(labels, func() interface{} { return NewTimer() })}
+}
+
+// GetOrRegisterTimerVec returns an existing TimerVec or constructs and
+// registers a new one.
+func GetOrRegisterTimerVec(name string, r Registry, labels ...string) *TimerVec {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return r.GetOrRegister(name, func() *TimerVec { return NewTimerVec(labels...) }).(*TimerVec)
+}
+
+// NewRegisteredTimerVec constructs and registers a new TimerVec.
+func NewRegisteredTimerVec(name string, r Registry, labels ...string) *TimerVec {
+	c := NewTimerVec(labels...)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// With returns the Timer with the given label values.
+func (v *TimerVec) With(values ...string) Timer { return v.with(values).(Timer) }
+
+// HistogramVec is a Vec of Histograms.
+type HistogramVec struct{ *vec }
+
+// NewHistogramVec constructs a new HistogramVec with the given label names,
+// whose members sample their values into the Samples returned by s.
+func NewHistogramVec(s func() Sample, labels ...string) *HistogramVec {
+	return &HistogramVec{newVec(labels, func() interface{} { return NewHistogram(s()) })}
+}
+
+// GetOrRegisterHistogramVec returns an existing HistogramVec or constructs and
+// registers a new one.
+func GetOrRegisterHistogramVec(name string, r Registry, s func() Sample, labels ...string) *HistogramVec {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return r.GetOrRegister(name, func() *HistogramVec { return NewHistogramVec(s, labels...) }).(*HistogramVec)
+}
+
+// NewRegisteredHistogramVec constructs and registers a new HistogramVec.
+func NewRegisteredHistogramVec(name string, r Registry, s func() Sample, labels ...string) *HistogramVec {
+	c := NewHistogramVec(s, labels...)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// With returns the Histogram with the given label values.
+func (v *HistogramVec) With(values ...string) Histogram { return v.with(values).(Histogram) }
+
+// BucketHistogramVec is a Vec of BucketHistograms.
+type BucketHistogramVec struct{ *vec }
+
+// NewBucketHistogramVec constructs a new BucketHistogramVec with the given label
+// names, whose members share the same bucket upper bounds.
+func NewBucketHistogramVec(buckets []float64, labels ...string) *BucketHistogramVec {
+	return &BucketHistogramVec{newVec(labels, func() interface{} { return NewBucketHistogram(buckets) })}
+}
+
+// GetOrRegisterBucketHistogramVec returns an existing BucketHistogramVec or
+// constructs and registers a new one.
+func GetOrRegisterBucketHistogramVec(name string, r Registry, buckets []float64, labels ...string) *BucketHistogramVec {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return r.GetOrRegister(name, func() *BucketHistogramVec { return NewBucketHistogramVec(buckets, labels...) }).(*BucketHistogramVec)
+}
+
+// NewRegisteredBucketHistogramVec constructs and registers a new
+// BucketHistogramVec.
+func NewRegisteredBucketHistogramVec(name string, r Registry, buckets []float64, labels ...string) *BucketHistogramVec {
+	c := NewBucketHistogramVec(buckets, labels...)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// With returns the BucketHistogram with the given label values.
+func (v *BucketHistogramVec) With(values ...string) BucketHistogram {
+	return v.with(values).(BucketHistogram)
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMeterVec(t *testing.T) {
+	v := NewMeterVec("protocol", "code")
+	defer v.Stop()
+	v.With("eth", "0x01").Mark(1)
+	v.With("eth", "0x01").Mark(2)
+	v.With("snap", "0x01").Mark(4)
+
+	var (
+		values [][]string
+		counts []int64
+	)
+	v.Each(func(vals []string, metric interface{}) {
+		values = append(values, vals)
+		counts = append(counts, metric.(Meter).Count())
+	})
+	if want := [][]string{{"eth", "0x01"}, {"snap", "0x01"}}; !reflect.DeepEqual(values, want) {
+		t.Errorf("label values mismatch: have %v, want %v", values, want)
+	}
+	if want := []int64{3, 4}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("counts mismatch: have %v, want %v", counts, want)
+	}
+}
+
+func TestVecLabelMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic on label count mismatch")
+		}
+	}()
+	NewTimerVec("method", "success").With("eth_call")
+}
+
+func TestGetOrRegisterTimerVec(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredTimerVec("foo", r, "method").With("eth_call").Update(1)
+	if c := GetOrRegisterTimerVec("foo", r, "method").With("eth_call").Count(); c != 1 {
+		t.Fatal(c)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("counter", r).Inc(1)
+	v := NewRegisteredMeterVec("meter", r, "protocol", "version")
+	defer v.Stop()
+	v.With("eth", "66").Mark(1)
+
+	names := make(map[string]bool)
+	Flatten(r, func(name string, i interface{}) {
+		names[name] = true
+	})
+	if want := map[string]bool{"counter": true, "meter/eth/66": true}; !reflect.DeepEqual(names, want) {
+		t.Errorf("flattened names mismatch: have %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// This file contains a miner stress test based on the BFT consensus engine.
+package main
+
+import (
+	"crypto/ecdsa"
+	"io/ioutil"
+	"math/big"
+	"math/rand"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/pictor01/ALBA/accounts/keystore"
+	"github.com/pictor01/ALBA/alba"
+	"github.com/pictor01/ALBA/alba/albaconfig"
+	"github.com/pictor01/ALBA/alba/downloader"
+	"github.com/pictor01/ALBA/common"
+	"github.com/pictor01/ALBA/common/fdlimit"
+	"github.com/pictor01/ALBA/consensus/bft"
+	"github.com/pictor01/ALBA/core"
+	"github.com/pictor01/ALBA/core/types"
+	"github.com/pictor01/ALBA/crypto"
+	"github.com/pictor01/ALBA/log"
+	"github.com/pictor01/ALBA/miner"
+	"github.com/pictor01/ALBA/node"
+	"github.com/pictor01/ALBA/p2p"
+	"github.com/pictor01/ALBA/p2p/enode"
+	"github.com/pictor01/ALBA/params"
+)
+
+func main() {
+	log.Root().SetHandler(log.LvlFilterHandler(log.LvlInfo, log.StreamHandler(os.Stderr, log.TerminalFormat(true))))
+	fdlimit.Raise(2048)
+
+	// Generate a batch of accounts to validate and fund with
+	faucets := make([]*ecdsa.PrivateKey, 128)
+	for i := 0; i < len(faucets); i++ {
+		faucets[i], _ = crypto.GenerateKey()
+	}
+	validators := make([]*ecdsa.PrivateKey, 4)
+	for i := 0; i < len(validators); i++ {
+		validators[i], _ = crypto.GenerateKey()
+	}
+	// Create a BFT network with all the validators in the genesis
+	genesis := makeGenesis(faucets, validators)
+
+	// Handle interrupts.
+	interruptCh := make(chan os.Signal, 5)
+	signal.Notify(interruptCh, os.Interrupt)
+
+	var (
+		stacks []*node.Node
+		nodes  []*alba.Alba
+		enodes []*enode.Node
+	)
+	for _, validator := range validators {
+		// Start the node and wait until it's up
+		stack, albaBackend, err := makeValidator(genesis)
+		if err != nil {
+			panic(err)
+		}
+		defer stack.Close()
+
+		for stack.Server().NodeInfo().Ports.Listener == 0 {
+			time.Sleep(250 * time.Millisecond)
+		}
+		// Connect the node to all the previous ones
+		for _, n := range enodes {
+			stack.Server().AddPeer(n)
+		}
+		// Start tracking the node and its enode
+		stacks = append(stacks, stack)
+		nodes = append(nodes, albaBackend)
+		enodes = append(enodes, stack.Server().Self())
+
+		// Inject the validator key and start proposing with it
+		ks := keystore.NewKeyStore(stack.KeyStoreDir(), keystore.LightScryptN, keystore.LightScryptP)
+		signer, err := ks.ImportECDSA(validator, "")
+		if err != nil {
+			panic(err)
+		}
+		if err := ks.Unlock(signer, ""); err != nil {
+			panic(err)
+		}
+		stack.AccountManager().AddBackend(ks)
+	}
+
+	// Iterate over all the nodes and start validating on them
+	time.Sleep(3 * time.Second)
+	for _, node := range nodes {
+		if err := node.StartMining(1); err != nil {
+			panic(err)
+		}
+	}
+	time.Sleep(3 * time.Second)
+
+	// Start injecting transactions from the faucet like crazy
+	nonces := make([]uint64, len(faucets))
+	for {
+		// Stop when interrupted.
+		select {
+		case <-interruptCh:
+			for _, node := range stacks {
+				node.Close()
+			}
+			return
+		default:
+		}
+
+		// Pick a random validator node
+		index := rand.Intn(len(faucets))
+		backend := nodes[index%len(nodes)]
+
+		// Create a self transaction and inject into the pool
+		tx, err := types.SignTx(types.NewTransaction(nonces[index], crypto.PubkeyToAddress(faucets[index].PublicKey), new(big.Int), 21000, big.NewInt(100000000000), nil), types.HomesteadSigner{}, faucets[index])
+		if err != nil {
+			panic(err)
+		}
+		if err := backend.TxPool().AddLocal(tx); err != nil {
+			panic(err)
+		}
+		nonces[index]++
+
+		// Wait if we're too saturated
+		if pend, _ := backend.TxPool().Stats(); pend > 2048 {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+}
+
+// makeGenesis creates a custom BFT genesis block based on some pre-defined
+// validator and faucet accounts.
+func makeGenesis(faucets []*ecdsa.PrivateKey, validators []*ecdsa.PrivateKey) *core.Genesis {
+	// Create a BFT network with every protocol change enabled
+	config := *params.AllCliqueProtocolChanges
+	config.ChainID = big.NewInt(18)
+	config.Clique = nil
+	config.BFT = &params.BFTConfig{Period: 1, Epoch: 30000, RequestTimeout: 2000}
+
+	genesis := &core.Genesis{
+		Config:   &config,
+		GasLimit: 25000000,
+		BaseFee:  big.NewInt(params.InitialBaseFee),
+		Alloc:    core.GenesisAlloc{},
+	}
+	for _, faucet := range faucets {
+		genesis.Alloc[crypto.PubkeyToAddress(faucet.PublicKey)] = core.GenesisAccount{
+			Balance: new(big.Int).Exp(big.NewInt(2), big.NewInt(128), nil),
+		}
+	}
+	// Embed the validators into the extra-data section
+	addresses := make([]common.Address, len(validators))
+	for i, validator := range validators {
+		addresses[i] = crypto.PubkeyToAddress(validator.PublicKey)
+	}
+	genesis.ExtraData = bft.GenesisExtra(addresses)
+
+	// Return the genesis block for initialization
+	return genesis
+}
+
+func makeValidator(genesis *core.Genesis) (*node.Node, *alba.Alba, error) {
+	// Define the basic configurations for the Ethereum node
+	datadir, _ := ioutil.TempDir("", "")
+
+	config := &node.Config{
+		Name:    "geth",
+		Version: params.Version,
+		DataDir: datadir,
+		P2P: p2p.Config{
+			ListenAddr:  "0.0.0.0:0",
+			NoDiscovery: true,
+			MaxPeers:    25,
+		},
+	}
+	// Start the node and configure a full Ethereum node on it
+	stack, err := node.New(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Create and register the backend
+	albaBackend, err := alba.New(stack, &albaconfig.Config{
+		Genesis:         genesis,
+		NetworkId:       genesis.Config.ChainID.Uint64(),
+		SyncMode:        downloader.FullSync,
+		DatabaseCache:   256,
+		DatabaseHandles: 256,
+		TxPool:          core.DefaultTxPoolConfig,
+		GPO:             albaconfig.Defaults.GPO,
+		Miner: miner.Config{
+			GasCeil:  genesis.GasLimit * 11 / 10,
+			GasPrice: big.NewInt(1),
+			Recommit: time.Second,
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = stack.Start()
+	return stack, albaBackend, err
+}
@@ -40,6 +40,7 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirJWTKey          = "jwtsecret"          // Path within the datadir to the node's jwt secret
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -97,6 +98,15 @@ type Config struct {
 	// SmartCardDaemonPath is the path to the smartcard daemon's socket
 	SmartCardDaemonPath string `toml:",omitempty"`
 
+	// PKCS11Module is the path to the PKCS#11 library of a hardware security
+	// module holding account keys.
+	PKCS11Module string `toml:",omitempty"`
+
+	// DBEngine is the database engine ("leveldb" or "pebble") used to create new
+	// databases. If empty, the engine of an existing database is reused, or
+	// leveldb is picked for fresh ones.
+	DBEngine string `toml:",omitempty"`
+
 	// IPCPath is the requested location to place the IPC endpoint. If the path is
 	// a simple file name, it is placed inside the data directory (or on the root
 	// pipe path on Windows), whereas if it's a resolvable path name (absolute or
@@ -190,6 +200,36 @@ type Config struct {
 
 	// AllowUnprotectedTxs allows non EIP-155 protected transactions to be send over RPC.
 	AllowUnprotectedTxs bool `toml:",omitempty"`
+
+	// BatchRequestLimit is the maximum number of requests in a batch served by the
+	// HTTP and WebSocket RPC endpoints.
+	BatchRequestLimit int `toml:",omitempty"`
+
+	// BatchResponseMaxSize is the maximum number of bytes returned for a batch by
+	// the HTTP and WebSocket RPC endpoints.
+	BatchResponseMaxSize int `toml:",omitempty"`
+
+	// RPCRateLimit is the token-bucket limit on the requests of a single client IP
+	// to the unauthenticated HTTP and WebSocket RPC endpoints.
+	RPCRateLimit rpc.RateLimit `toml:",omitempty"`
+
+	// RPCMethodRateLimits are the token-bucket limits on the calls of a single
+	// client IP to individual methods, applied on top of RPCRateLimit.
+	RPCMethodRateLimits map[string]rpc.RateLimit `toml:",omitempty"`
+
+	// AuthAddr is the listening address on which authenticated APIs are provided.
+	AuthAddr string `toml:",omitempty"`
+
+	// AuthPort is the port number on which authenticated APIs are provided.
+	AuthPort int `toml:",omitempty"`
+
+	// AuthVirtualHosts is the list of virtual hostnames which are allowed on incoming requests
+	// for the authenticated api. This is by default {'localhost'}.
+	AuthVirtualHosts []string `toml:",omitempty"`
+
+	// JWTSecret is the path to the file holding the hex-encoded jwt secret. If the
+	// file doesn't exist, a new secret is generated and stored there.
+	JWTSecret string `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -0,0 +1,149 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// jwtExpiryTimeout is the maximum allowed drift between the 'iat' claim of
+	// a token and the local clock, in either direction.
+	jwtExpiryTimeout = 60 * time.Second
+
+	jwtSigningMethod = "HS256" // The only accepted signing algorithm
+	jwtTokenType     = "JWT"   // The only accepted token type, if one is given
+)
+
+var (
+	errJWTMissing       = errors.New("missing token")
+	errJWTMalformed     = errors.New("malformed token")
+	errJWTAlgorithm     = errors.New("unsupported signing method")
+	errJWTSignature     = errors.New("signature is invalid")
+	errJWTMissingIssued = errors.New("missing issued-at")
+	errJWTStale         = errors.New("stale token")
+	errJWTFuture        = errors.New("future token")
+	errJWTExpired       = errors.New("token is expired")
+)
+
+// jwtHeader is the JOSE header of a JSON web token.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// jwtClaims are the registered claims the handler inspects. Any other claims
+// present in the token are ignored.
+type jwtClaims struct {
+	IssuedAt  *int64 `json:"iat,omitempty"`
+	ExpiresAt *int64 `json:"exp,omitempty"`
+}
+
+type jwtHandler struct {
+	secret []byte
+	next   http.Handler
+}
+
+// newJWTHandler creates a http.Handler with jwt authentication support.
+func newJWTHandler(secret []byte, next http.Handler) http.Handler {
+	return &jwtHandler{secret: secret, next: next}
+}
+
+// ServeHTTP implements http.Handler
+func (handler *jwtHandler) ServeHTTP(out http.ResponseWriter, r *http.Request) {
+	var strToken string
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		strToken = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if len(strToken) == 0 {
+		http.Error(out, errJWTMissing.Error(), http.StatusForbidden)
+		return
+	}
+	if err := verifyJWT(handler.secret, strToken, time.Now()); err != nil {
+		http.Error(out, err.Error(), http.StatusForbidden)
+		return
+	}
+	handler.next.ServeHTTP(out, r)
+}
+
+// verifyJWT checks that token is a HS256 JSON web token signed with secret
+// and that its 'iat' claim is within jwtExpiryTimeout of now.
+func verifyJWT(secret []byte, token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errJWTMalformed
+	}
+	// Only HS256 is accepted, anything else (including 'none') is rejected
+	// before the signature is even looked at.
+	var header jwtHeader
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Alg != jwtSigningMethod {
+		return errJWTAlgorithm
+	}
+	if header.Typ != "" && !strings.EqualFold(header.Typ, jwtTokenType) {
+		return errJWTMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errJWTMalformed
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return errJWTSignature
+	}
+	// Signature is fine, validate the claims.
+	var claims jwtClaims
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return err
+	}
+	if claims.ExpiresAt != nil && now.After(time.Unix(*claims.ExpiresAt, 0)) {
+		return errJWTExpired
+	}
+	if claims.IssuedAt == nil {
+		return errJWTMissingIssued
+	}
+	issued := time.Unix(*claims.IssuedAt, 0)
+	switch {
+	case now.Sub(issued) > jwtExpiryTimeout:
+		return errJWTStale
+	case issued.Sub(now) > jwtExpiryTimeout:
+		return errJWTFuture
+	}
+	return nil
+}
+
+// decodeJWTSegment decodes a base64url encoded JSON segment of a token.
+func decodeJWTSegment(segment string, v interface{}) error {
+	blob, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errJWTMalformed
+	}
+	if err := json.Unmarshal(blob, v); err != nil {
+		return errJWTMalformed
+	}
+	return nil
+}
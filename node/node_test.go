@@ -591,6 +591,63 @@ func (test rpcPrefixTest) check(t *testing.T, node *Node) {
 	}
 }
 
+// authTestService is an RPC service used to check where authenticated APIs are
+// reachable from.
+type authTestService struct{}
+
+func (authTestService) Ping() string { return "pong" }
+
+// Tests that authenticated APIs are not served over IPC and in-process, only on
+// the authenticated port.
+func TestAuthenticatedAPIsNotOnIPC(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "node-auth-")
+	if err != nil {
+		t.Fatalf("failed to create temporary datadir: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	conf := testNodeConfig()
+	conf.DataDir = datadir
+	conf.IPCPath = "test.ipc"
+	conf.AuthAddr = "127.0.0.1"
+	conf.AuthPort = 0
+
+	stack, err := New(conf)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer stack.Close()
+
+	stack.RegisterAPIs([]rpc.API{
+		{Namespace: "engine", Version: "1.0", Service: authTestService{}, Public: true, Authenticated: true},
+		{Namespace: "open", Version: "1.0", Service: authTestService{}, Public: true},
+	})
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	ipc, err := rpc.Dial(stack.IPCEndpoint())
+	if err != nil {
+		t.Fatalf("failed to dial IPC: %v", err)
+	}
+	defer ipc.Close()
+
+	inproc, err := stack.Attach()
+	if err != nil {
+		t.Fatalf("failed to attach: %v", err)
+	}
+	defer inproc.Close()
+
+	for name, client := range map[string]*rpc.Client{"ipc": ipc, "inproc": inproc} {
+		var result string
+		if err := client.Call(&result, "open_ping"); err != nil || result != "pong" {
+			t.Errorf("%s: open API call failed: %q, %v", name, result, err)
+		}
+		if err := client.Call(&result, "engine_ping"); err == nil {
+			t.Errorf("%s: authenticated API reachable", name)
+		}
+	}
+}
+
 func createNode(t *testing.T, httpPort, wsPort int) *Node {
 	conf := &Config{
 		HTTPHost: "127.0.0.1",
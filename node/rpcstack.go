@@ -39,14 +39,32 @@ type httpConfig struct {
 	Modules            []string
 	CorsAllowedOrigins []string
 	Vhosts             []string
-	prefix             string // path prefix on which to mount http handler
+	prefix             string    // path prefix on which to mount http handler
+	jwtSecret          []byte    // optional JWT secret
+	limits             rpcLimits // request limits of the rpc server
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
 type wsConfig struct {
-	Origins []string
-	Modules []string
-	prefix  string // path prefix on which to mount ws handler
+	Origins   []string
+	Modules   []string
+	prefix    string    // path prefix on which to mount ws handler
+	jwtSecret []byte    // optional JWT secret
+	limits    rpcLimits // request limits of the rpc server
+}
+
+// rpcLimits are the request limits enforced by an rpc server.
+type rpcLimits struct {
+	batchItems       int                      // maximum number of requests in a batch
+	batchResponse    int                      // maximum total response size of a batch
+	rateLimit        rpc.RateLimit            // request rate limit per client ip
+	methodRateLimits map[string]rpc.RateLimit // method call rate limits per client ip
+}
+
+// apply configures the limits on the given rpc server.
+func (l rpcLimits) apply(srv *rpc.Server) {
+	srv.SetBatchLimits(l.batchItems, l.batchResponse)
+	srv.SetRateLimits(l.rateLimit, l.methodRateLimits)
 }
 
 type rpcHandler struct {
@@ -186,6 +204,12 @@ func (h *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if ws != nil && isWebsocket(r) {
 		if checkPath(r, h.wsConfig.prefix) {
 			ws.ServeHTTP(w, r)
+			return
+		}
+		// Websocket requests outside of the RPC prefix may still belong to
+		// one of the handlers registered via Node.RegisterHandler.
+		if muxHandler, pattern := h.mux.Handler(r); pattern != "" {
+			muxHandler.ServeHTTP(w, r)
 		}
 		return
 	}
@@ -280,12 +304,13 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	config.limits.apply(srv)
 	if err := RegisterApis(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
 	h.httpConfig = config
 	h.httpHandler.Store(&rpcHandler{
-		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts),
+		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts, config.jwtSecret),
 		server:  srv,
 	})
 	return nil
@@ -312,12 +337,13 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	config.limits.apply(srv)
 	if err := RegisterApis(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
 	h.wsConfig = config
 	h.wsHandler.Store(&rpcHandler{
-		Handler: srv.WebsocketHandler(config.Origins),
+		Handler: NewWSHandlerStack(srv.WebsocketHandler(config.Origins), config.jwtSecret),
 		server:  srv,
 	})
 	return nil
@@ -362,13 +388,24 @@ func isWebsocket(r *http.Request) bool {
 }
 
 // NewHTTPHandlerStack returns wrapped http-related handlers
-func NewHTTPHandlerStack(srv http.Handler, cors []string, vhosts []string) http.Handler {
+func NewHTTPHandlerStack(srv http.Handler, cors []string, vhosts []string, jwtSecret []byte) http.Handler {
 	// Wrap the CORS-handler within a host-handler
 	handler := newCorsHandler(srv, cors)
 	handler = newVHostHandler(vhosts, handler)
+	if len(jwtSecret) != 0 {
+		handler = newJWTHandler(jwtSecret, handler)
+	}
 	return newGzipHandler(handler)
 }
 
+// NewWSHandlerStack returns a wrapped ws-related handler.
+func NewWSHandlerStack(srv http.Handler, jwtSecret []byte) http.Handler {
+	if len(jwtSecret) != 0 {
+		return newJWTHandler(jwtSecret, srv)
+	}
+	return srv
+}
+
 func newCorsHandler(srv http.Handler, allowedOrigins []string) http.Handler {
 	// disable CORS support if user has not specified a custom CORS configuration
 	if len(allowedOrigins) == 0 {
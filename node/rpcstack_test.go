@@ -18,12 +18,16 @@ package node
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/internal/testlog"
 	"github.com/ethereum/go-ethereum/log"
@@ -229,6 +233,70 @@ func Test_checkPath(t *testing.T) {
 	}
 }
 
+// issueToken creates a JSON web token with the given header and claims segments,
+// signed with secret using HMAC-SHA256.
+func issueToken(secret []byte, header, claims string) string {
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(header)) + "." + enc.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+// TestJWT makes sure that requests to an authenticated endpoint are rejected
+// unless they carry a valid, fresh token.
+func TestJWT(t *testing.T) {
+	var (
+		secret  = []byte("secret")
+		header  = `{"alg":"HS256","typ":"JWT"}`
+		claimed = func(iat time.Time) string {
+			return fmt.Sprintf(`{"iat":%d}`, iat.Unix())
+		}
+		now = time.Now()
+	)
+	srv := createAndStartServer(t, &httpConfig{jwtSecret: secret}, true, &wsConfig{Origins: []string{"*"}, jwtSecret: secret})
+	defer srv.stop()
+	httpURL := "http://" + srv.listenAddr()
+	wsURL := "ws://" + srv.listenAddr()
+
+	expOk := []string{
+		issueToken(secret, header, claimed(now)),
+		issueToken(secret, header, claimed(now.Add(-jwtExpiryTimeout/2))),
+		issueToken(secret, header, claimed(now.Add(jwtExpiryTimeout/2))),
+		issueToken(secret, `{"alg":"HS256"}`, fmt.Sprintf(`{"iat":%d,"exp":%d}`, now.Unix(), now.Add(time.Minute).Unix())),
+	}
+	for i, token := range expOk {
+		if err := wsRequest(t, wsURL, "", "Authorization", "Bearer "+token); err != nil {
+			t.Errorf("test %d-ws, token '%v': expected ok, got %v", i, token, err)
+		}
+		if resp := rpcRequest(t, httpURL, "Authorization", "Bearer "+token); resp.StatusCode != http.StatusOK {
+			t.Errorf("test %d-http, token '%v': expected ok, got %v", i, token, resp.StatusCode)
+		}
+	}
+	expFail := []string{
+		"",
+		"Bearer",
+		"Bearer " + issueToken([]byte("other"), header, claimed(now)),
+		"Bearer " + issueToken(secret, `{"alg":"HS512","typ":"JWT"}`, claimed(now)),
+		"Bearer " + issueToken(secret, `{"alg":"none","typ":"JWT"}`, claimed(now)),
+		"Bearer " + issueToken(secret, header, `{}`),
+		"Bearer " + issueToken(secret, header, claimed(now.Add(-2*jwtExpiryTimeout))),
+		"Bearer " + issueToken(secret, header, claimed(now.Add(2*jwtExpiryTimeout))),
+		"Bearer " + issueToken(secret, header, fmt.Sprintf(`{"iat":%d,"exp":%d}`, now.Unix(), now.Add(-time.Second).Unix())),
+		"Bearer " + strings.TrimSuffix(issueToken(secret, header, claimed(now)), "A") + "B",
+		"Bearer not.a.token",
+		issueToken(secret, header, claimed(now)),
+	}
+	for i, auth := range expFail {
+		if err := wsRequest(t, wsURL, "", "Authorization", auth); err == nil {
+			t.Errorf("tc %d-ws, auth '%v': expected fail", i, auth)
+		}
+		if resp := rpcRequest(t, httpURL, "Authorization", auth); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("tc %d-http, auth '%v': expected not to allow, got %v", i, auth, resp.StatusCode)
+		}
+	}
+}
+
 func createAndStartServer(t *testing.T, conf *httpConfig, ws bool, wsConf *wsConfig) *httpServer {
 	t.Helper()
 
@@ -243,7 +311,7 @@ func createAndStartServer(t *testing.T, conf *httpConfig, ws bool, wsConf *wsCon
 }
 
 // wsRequest attempts to open a WebSocket connection to the given URL.
-func wsRequest(t *testing.T, url, browserOrigin string) error {
+func wsRequest(t *testing.T, url, browserOrigin string, extraHeaders ...string) error {
 	t.Helper()
 	t.Logf("checking WebSocket on %s (origin %q)", url, browserOrigin)
 
@@ -251,6 +319,12 @@ func wsRequest(t *testing.T, url, browserOrigin string) error {
 	if browserOrigin != "" {
 		headers.Set("Origin", browserOrigin)
 	}
+	if len(extraHeaders)%2 != 0 {
+		panic("odd extraHeaders length")
+	}
+	for i := 0; i < len(extraHeaders); i += 2 {
+		headers.Set(extraHeaders[i], extraHeaders[i+1])
+	}
 	conn, _, err := websocket.DefaultDialer.Dial(url, headers)
 	if conn != nil {
 		conn.Close()
@@ -19,7 +19,9 @@
 package p2p
 
 import (
+	"fmt"
 	"net"
+	"strconv"
 
 	"github.com/ethereum/go-ethereum/metrics"
 )
@@ -41,8 +43,31 @@ var (
 	egressConnectMeter  = metrics.NewRegisteredMeter("p2p/dials", nil)
 	egressTrafficMeter  = metrics.NewRegisteredMeter(egressMeterName, nil)
 	activePeerGauge     = metrics.NewRegisteredGauge("p2p/peers", nil)
+
+	// Per-message traffic, labelled by protocol name, version and message code.
+	ingressMsgMeters    = metrics.NewRegisteredMeterVec(ingressMeterName+"/msg", nil, "protocol", "version", "code")
+	ingressPacketMeters = metrics.NewRegisteredMeterVec(ingressMeterName+"/msg/packets", nil, "protocol", "version", "code")
+	egressMsgMeters     = metrics.NewRegisteredMeterVec(egressMeterName+"/msg", nil, "protocol", "version", "code")
+	egressPacketMeters  = metrics.NewRegisteredMeterVec(egressMeterName+"/msg/packets", nil, "protocol", "version", "code")
+
+	// HandleHistogram tracks the time in seconds protocol handlers take to serve
+	// messages, labelled by protocol name, version and message code.
+	HandleHistogram = metrics.NewRegisteredBucketHistogramVec(HandleHistName, nil, metrics.ExponentialBuckets(0.0001, 4, 9), "protocol", "version", "code")
 )
 
+// MsgLabels returns the values of the protocol, version and code labels of the
+// per-message metrics.
+func MsgLabels(protocol string, version uint, code uint64) []string {
+	return []string{protocol, strconv.FormatUint(uint64(version), 10), fmt.Sprintf("%#02x", code)}
+}
+
+// markMsg meters a message of the given size in the per-message meters.
+func markMsg(bytes, packets *metrics.MeterVec, protocol string, version uint, code uint64, size uint32) {
+	labels := MsgLabels(protocol, version, code)
+	bytes.With(labels...).Mark(int64(size))
+	packets.With(labels...).Mark(1)
+}
+
 // meteredConn is a wrapper around a net.Conn that meters both the
 // inbound and outbound network traffic.
 type meteredConn struct {
@@ -49,6 +49,30 @@ type Interface interface {
 	String() string
 }
 
+// SocketMapper is implemented by mechanisms which learn the external endpoint of
+// a UDP socket by exchanging packets through it. Unlike ExternalIP, this also
+// reveals the port the NAT assigned to the socket.
+type SocketMapper interface {
+	Interface
+
+	// UseSocket makes the mechanism send its requests from conn. Packets received
+	// on conn must be offered to HandlePacket.
+	UseSocket(conn UDPWriter)
+
+	// HandlePacket consumes the packet if it answers a pending request of the
+	// mechanism, and reports whether it did. The packet data is not retained.
+	HandlePacket(from *net.UDPAddr, data []byte) bool
+
+	// ExternalAddr returns the external address of the socket.
+	ExternalAddr() (*net.UDPAddr, error)
+}
+
+// UDPWriter is the sending side of a UDP socket.
+type UDPWriter interface {
+	WriteToUDP(b []byte, addr *net.UDPAddr) (int, error)
+	LocalAddr() net.Addr
+}
+
 // Parse parses a NAT interface description.
 // The following formats are currently accepted.
 // Note that mechanism names are not case-sensitive.
@@ -59,12 +83,20 @@ type Interface interface {
 //     "upnp"               uses the Universal Plug and Play protocol
 //     "pmp"                uses NAT-PMP with an auto-detected gateway address
 //     "pmp:192.168.0.1"    uses NAT-PMP with the given gateway address
+//     "stun:1.2.3.4:3478"  uses STUN with the given server address
 func Parse(spec string) (Interface, error) {
 	var (
 		parts = strings.SplitN(spec, ":", 2)
 		mech  = strings.ToLower(parts[0])
 		ip    net.IP
 	)
+	// STUN servers are given as host:port, not as an IP address
+	if mech == "stun" {
+		if len(parts) < 2 || parts[1] == "" {
+			return nil, errors.New("missing STUN server address")
+		}
+		return STUN(parts[1]), nil
+	}
 	if len(parts) > 1 {
 		ip = net.ParseIP(parts[1])
 		if ip == nil {
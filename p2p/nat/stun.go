@@ -0,0 +1,333 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	stunDefaultPort = "3478"
+	stunAttempts    = 3                      // number of binding requests sent before giving up
+	stunTimeout     = 500 * time.Millisecond // response timeout of the first request, doubled on retries
+
+	stunHeaderSize   = 20
+	stunMagicCookie  = 0x2112A442
+	stunBindRequest  = 0x0001
+	stunBindResponse = 0x0101
+	stunBindError    = 0x0111
+
+	stunAttrMappedAddr    = 0x0001
+	stunAttrXorMappedAddr = 0x0020
+)
+
+var (
+	errSTUNMismatch  = errors.New("STUN transaction mismatch")
+	errSTUNNoAddress = errors.New("STUN response has no mapped address")
+	errSTUNTimeout   = errors.New("STUN request timed out")
+)
+
+// stun discovers the external address of the local machine by sending STUN
+// binding requests (RFC 5389) to a public STUN server.
+//
+// Once the discovery socket is handed over through UseSocket, the requests are
+// sent from it and the response reveals the external port of that socket as
+// well, which is what other nodes have to contact. Before that, or if discovery
+// is disabled, requests go out from an ephemeral port and only the external IP
+// is meaningful.
+type stun struct {
+	server  string
+	timeout time.Duration
+
+	mu       sync.Mutex
+	conn     UDPWriter                     // shared socket, nil if requests use an ephemeral port
+	pending  map[[12]byte]*stunTransaction // binding requests waiting for a response
+	lastAddr *net.UDPAddr                  // mapped address seen in the last response
+}
+
+// stunTransaction is a pending binding request.
+type stunTransaction struct {
+	server *net.UDPAddr
+	result chan stunResult
+}
+
+type stunResult struct {
+	mapped *net.UDPAddr
+	err    error
+}
+
+// STUN returns a NAT interface that learns the external address through STUN
+// binding requests sent to the given server. The server port defaults to 3478.
+func STUN(server string) Interface {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, stunDefaultPort)
+	}
+	return &stun{
+		server:  server,
+		timeout: stunTimeout,
+		pending: make(map[[12]byte]*stunTransaction),
+	}
+}
+
+func (n *stun) String() string {
+	return fmt.Sprintf("STUN(%s)", n.server)
+}
+
+// ExternalIP sends a binding request and returns the IP address the server saw
+// it coming from.
+func (n *stun) ExternalIP() (net.IP, error) {
+	mapped, err := n.ExternalAddr()
+	if err != nil {
+		return nil, err
+	}
+	return mapped.IP, nil
+}
+
+// ExternalAddr sends a binding request and returns the address the server saw
+// it coming from.
+func (n *stun) ExternalAddr() (*net.UDPAddr, error) {
+	local, mapped, err := n.bind()
+	if err != nil {
+		return nil, err
+	}
+	n.mu.Lock()
+	changed := n.lastAddr == nil || !n.lastAddr.IP.Equal(mapped.IP) || n.lastAddr.Port != mapped.Port
+	n.lastAddr = mapped
+	n.mu.Unlock()
+
+	if changed {
+		log.Debug("Discovered external address through STUN", "server", n.server, "local", local, "mapped", mapped)
+	}
+	return mapped, nil
+}
+
+// UseSocket makes binding requests go out from conn. Responses arrive through
+// HandlePacket.
+func (n *stun) UseSocket(conn UDPWriter) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.conn = conn
+}
+
+// HandlePacket delivers a binding response to the pending request it answers.
+func (n *stun) HandlePacket(from *net.UDPAddr, data []byte) bool {
+	if len(data) < stunHeaderSize || binary.BigEndian.Uint32(data[4:]) != stunMagicCookie {
+		return false
+	}
+	var txid [12]byte
+	copy(txid[:], data[8:20])
+
+	n.mu.Lock()
+	tx := n.pending[txid]
+	if tx == nil || !from.IP.Equal(tx.server.IP) || from.Port != tx.server.Port {
+		n.mu.Unlock()
+		return false
+	}
+	mapped, err := decodeSTUNResponse(data, txid)
+	if err == errSTUNMismatch {
+		n.mu.Unlock()
+		return false
+	}
+	delete(n.pending, txid)
+	n.mu.Unlock()
+
+	tx.result <- stunResult{mapped, err}
+	return true
+}
+
+// These do nothing, STUN can't create port mappings.
+
+func (*stun) AddMapping(string, int, int, string, time.Duration) error { return nil }
+func (*stun) DeleteMapping(string, int, int) error                     { return nil }
+
+// bind performs a binding transaction, retrying with exponential backoff. It
+// returns the local address the request was sent from and the mapped address
+// reported by the server.
+func (n *stun) bind() (local, mapped *net.UDPAddr, err error) {
+	server, err := net.ResolveUDPAddr("udp", n.server)
+	if err != nil {
+		return nil, nil, err
+	}
+	n.mu.Lock()
+	conn := n.conn
+	n.mu.Unlock()
+	if conn == nil {
+		ephemeral, err := net.ListenUDP("udp", nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer ephemeral.Close()
+		go n.readLoop(ephemeral)
+		conn = ephemeral
+	}
+
+	var txid [12]byte
+	if _, err := rand.Read(txid[:]); err != nil {
+		return nil, nil, err
+	}
+	tx := &stunTransaction{server: server, result: make(chan stunResult, 1)}
+	n.mu.Lock()
+	n.pending[txid] = tx
+	n.mu.Unlock()
+	defer func() {
+		n.mu.Lock()
+		delete(n.pending, txid)
+		n.mu.Unlock()
+	}()
+
+	var (
+		req     = encodeSTUNRequest(txid)
+		timeout = n.timeout
+	)
+	for i := 0; i < stunAttempts; i++ {
+		if _, err = conn.WriteToUDP(req, server); err != nil {
+			return nil, nil, err
+		}
+		timer := time.NewTimer(timeout)
+		select {
+		case res := <-tx.result:
+			timer.Stop()
+			return conn.LocalAddr().(*net.UDPAddr), res.mapped, res.err
+		case <-timer.C:
+			err = errSTUNTimeout
+		}
+		timeout *= 2
+	}
+	return nil, nil, fmt.Errorf("no STUN response from %s: %v", n.server, err)
+}
+
+// readLoop passes the packets arriving on an ephemeral request socket to
+// HandlePacket until the socket is closed.
+func (n *stun) readLoop(conn *net.UDPConn) {
+	buf := make([]byte, 1280)
+	for {
+		size, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		n.HandlePacket(from, buf[:size])
+	}
+}
+
+// encodeSTUNRequest creates a binding request without attributes.
+func encodeSTUNRequest(txid [12]byte) []byte {
+	msg := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(msg[0:], stunBindRequest)
+	binary.BigEndian.PutUint16(msg[2:], 0)
+	binary.BigEndian.PutUint32(msg[4:], stunMagicCookie)
+	copy(msg[8:], txid[:])
+	return msg
+}
+
+// decodeSTUNResponse parses a binding response to the transaction and returns
+// the mapped address in it. XOR-MAPPED-ADDRESS is preferred, MAPPED-ADDRESS is
+// accepted from servers predating RFC 5389.
+func decodeSTUNResponse(msg []byte, txid [12]byte) (*net.UDPAddr, error) {
+	if len(msg) < stunHeaderSize {
+		return nil, errSTUNMismatch
+	}
+	var (
+		kind   = binary.BigEndian.Uint16(msg[0:])
+		length = int(binary.BigEndian.Uint16(msg[2:]))
+		cookie = binary.BigEndian.Uint32(msg[4:])
+	)
+	if cookie != stunMagicCookie || string(msg[8:20]) != string(txid[:]) {
+		return nil, errSTUNMismatch
+	}
+	switch kind {
+	case stunBindResponse:
+	case stunBindError:
+		return nil, errors.New("STUN server rejected binding request")
+	default:
+		return nil, errSTUNMismatch
+	}
+	if stunHeaderSize+length > len(msg) {
+		return nil, errors.New("truncated STUN response")
+	}
+	var (
+		attrs  = msg[stunHeaderSize : stunHeaderSize+length]
+		mapped *net.UDPAddr
+	)
+	for len(attrs) >= 4 {
+		var (
+			typ  = binary.BigEndian.Uint16(attrs[0:])
+			size = int(binary.BigEndian.Uint16(attrs[2:]))
+		)
+		if 4+size > len(attrs) {
+			return nil, errors.New("truncated STUN attribute")
+		}
+		value := attrs[4 : 4+size]
+		switch typ {
+		case stunAttrXorMappedAddr:
+			addr, err := decodeSTUNAddress(value, msg[4:20])
+			if err != nil {
+				return nil, err
+			}
+			return addr, nil
+		case stunAttrMappedAddr:
+			addr, err := decodeSTUNAddress(value, nil)
+			if err != nil {
+				return nil, err
+			}
+			mapped = addr
+		}
+		// Attributes are padded to a multiple of four bytes
+		padded := (4 + size + 3) &^ 3
+		if padded > len(attrs) {
+			padded = len(attrs)
+		}
+		attrs = attrs[padded:]
+	}
+	if mapped == nil {
+		return nil, errSTUNNoAddress
+	}
+	return mapped, nil
+}
+
+// decodeSTUNAddress parses the value of an address attribute. If key is given,
+// the address is XOR-ed with it, as is the port with its first two bytes.
+func decodeSTUNAddress(value []byte, key []byte) (*net.UDPAddr, error) {
+	if len(value) < 4 {
+		return nil, errors.New("invalid STUN address")
+	}
+	var ip net.IP
+	switch family := value[1]; {
+	case family == 0x01 && len(value) == 8:
+		ip = make(net.IP, net.IPv4len)
+	case family == 0x02 && len(value) == 20:
+		ip = make(net.IP, net.IPv6len)
+	default:
+		return nil, errors.New("invalid STUN address")
+	}
+	port := binary.BigEndian.Uint16(value[2:])
+	copy(ip, value[4:])
+	if key != nil {
+		port ^= binary.BigEndian.Uint16(key)
+		for i := range ip {
+			ip[i] ^= key[i]
+		}
+	}
+	return &net.UDPAddr{IP: ip, Port: int(port)}, nil
+}
@@ -0,0 +1,232 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stunResponder is an in-process STUN server answering binding requests with a
+// fixed mapped address.
+type stunResponder struct {
+	conn   *net.UDPConn
+	mapped *net.UDPAddr
+	xor    bool // whether to answer with XOR-MAPPED-ADDRESS or MAPPED-ADDRESS
+	drop   int  // number of requests to ignore before answering
+
+	mu   sync.Mutex
+	from *net.UDPAddr // sender of the last request
+}
+
+func startSTUNResponder(t *testing.T, mapped *net.UDPAddr, xor bool, drop int) *stunResponder {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &stunResponder{conn: conn, mapped: mapped, xor: xor, drop: drop}
+	go r.serve()
+	t.Cleanup(func() { conn.Close() })
+	return r
+}
+
+func (r *stunResponder) serve() {
+	buf := make([]byte, 1280)
+	for {
+		n, from, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if n < stunHeaderSize || binary.BigEndian.Uint16(buf) != stunBindRequest {
+			continue
+		}
+		r.mu.Lock()
+		r.from = from
+		r.mu.Unlock()
+		if r.drop > 0 {
+			r.drop--
+			continue
+		}
+		r.conn.WriteToUDP(r.response(buf[8:20]), from)
+	}
+}
+
+func (r *stunResponder) lastFrom() *net.UDPAddr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.from
+}
+
+func (r *stunResponder) response(txid []byte) []byte {
+	var (
+		ip   = r.mapped.IP.To4()
+		port = uint16(r.mapped.Port)
+		typ  = uint16(stunAttrMappedAddr)
+	)
+	addr := make([]byte, 8)
+	if r.xor {
+		typ = stunAttrXorMappedAddr
+		port ^= stunMagicCookie >> 16
+		xip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(xip, binary.BigEndian.Uint32(ip)^stunMagicCookie)
+		ip = xip
+	}
+	addr[1] = 0x01
+	binary.BigEndian.PutUint16(addr[2:], port)
+	copy(addr[4:], ip)
+
+	// Prepend an unknown attribute with padding to exercise attribute skipping
+	attrs := []byte{0x80, 0x22, 0x00, 0x03, 'g', 'e', 't', 0x00}
+	attrs = append(attrs, byte(typ>>8), byte(typ), 0, byte(len(addr)))
+	attrs = append(attrs, addr...)
+
+	msg := make([]byte, stunHeaderSize, stunHeaderSize+len(attrs))
+	binary.BigEndian.PutUint16(msg[0:], stunBindResponse)
+	binary.BigEndian.PutUint16(msg[2:], uint16(len(attrs)))
+	binary.BigEndian.PutUint32(msg[4:], stunMagicCookie)
+	copy(msg[8:], txid)
+	return append(msg, attrs...)
+}
+
+func TestSTUN(t *testing.T) {
+	tests := []struct {
+		name string
+		xor  bool
+		drop int
+	}{
+		{name: "xor-mapped", xor: true},
+		{name: "mapped", xor: false},
+		{name: "retransmit", xor: true, drop: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mapped := &net.UDPAddr{IP: net.IP{33, 44, 55, 66}, Port: 30303}
+			r := startSTUNResponder(t, mapped, test.xor, test.drop)
+
+			n := STUN(r.conn.LocalAddr().String()).(*stun)
+			n.timeout = 50 * time.Millisecond
+			ip, err := n.ExternalIP()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !ip.Equal(mapped.IP) {
+				t.Errorf("got IP %v, want %v", ip, mapped.IP)
+			}
+			if n.lastAddr.Port != mapped.Port {
+				t.Errorf("got mapped port %d, want %d", n.lastAddr.Port, mapped.Port)
+			}
+		})
+	}
+}
+
+func TestSTUNSharedSocket(t *testing.T) {
+	mapped := &net.UDPAddr{IP: net.IP{33, 44, 55, 66}, Port: 40404}
+	r := startSTUNResponder(t, mapped, true, 0)
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	n := STUN(r.conn.LocalAddr().String()).(*stun)
+	n.timeout = 50 * time.Millisecond
+	n.UseSocket(conn)
+
+	// Read the socket like discovery would, offering every packet to STUN first.
+	other := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 1280)
+		for {
+			size, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if !n.HandlePacket(from, buf[:size]) {
+				other <- append([]byte{}, buf[:size]...)
+			}
+		}
+	}()
+
+	addr, err := n.ExternalAddr()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !addr.IP.Equal(mapped.IP) || addr.Port != mapped.Port {
+		t.Errorf("got address %v, want %v", addr, mapped)
+	}
+	if from := r.lastFrom(); from.Port != conn.LocalAddr().(*net.UDPAddr).Port {
+		t.Errorf("request sent from port %d, want shared socket port %d", from.Port, conn.LocalAddr().(*net.UDPAddr).Port)
+	}
+
+	// Packets which are not STUN responses must be left to the socket owner,
+	// including responses to requests which are no longer pending.
+	for _, packet := range [][]byte{[]byte("not a STUN packet"), r.response(make([]byte, 12))} {
+		r.conn.WriteToUDP(packet, conn.LocalAddr().(*net.UDPAddr))
+		select {
+		case got := <-other:
+			if string(got) != string(packet) {
+				t.Errorf("got packet %x, want %x", got, packet)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("packet %x was consumed by STUN", packet)
+		}
+	}
+}
+
+func TestSTUNTimeout(t *testing.T) {
+	r := startSTUNResponder(t, &net.UDPAddr{IP: net.IP{33, 44, 55, 66}}, true, stunAttempts)
+
+	n := STUN(r.conn.LocalAddr().String()).(*stun)
+	n.timeout = 10 * time.Millisecond
+	if ip, err := n.ExternalIP(); err == nil {
+		t.Fatalf("expected error, got IP %v", ip)
+	}
+}
+
+func TestParseSTUN(t *testing.T) {
+	tests := []struct {
+		spec   string
+		server string
+		fail   bool
+	}{
+		{spec: "stun:stun.example.org", server: "stun.example.org:3478"},
+		{spec: "STUN:1.2.3.4:19302", server: "1.2.3.4:19302"},
+		{spec: "stun:[2001:db8::1]:3478", server: "[2001:db8::1]:3478"},
+		{spec: "stun:", fail: true},
+		{spec: "stun", fail: true},
+	}
+	for _, test := range tests {
+		n, err := Parse(test.spec)
+		if test.fail {
+			if err == nil {
+				t.Errorf("%q: expected error", test.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.spec, err)
+			continue
+		}
+		if s, ok := n.(*stun); !ok || s.server != test.server {
+			t.Errorf("%q: got %v, want STUN(%s)", test.spec, n, test.server)
+		}
+	}
+}
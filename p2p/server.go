@@ -62,6 +62,9 @@ const (
 
 	// Maximum amount of time allowed for writing a complete message.
 	frameWriteTimeout = 20 * time.Second
+
+	// Interval at which the external IP is re-queried from the NAT mechanism.
+	natRefreshInterval = 5 * time.Minute
 )
 
 var errServerStopped = errors.New("server stopped")
@@ -184,6 +187,7 @@ type Server struct {
 	DiscV5    *discover.UDPv5
 	discmix   *enode.FairMix
 	dialsched *dialScheduler
+	natConn   *net.UDPConn // discovery socket shared with a nat.SocketMapper
 
 	// Channels into the run loop.
 	quit                    chan struct{}
@@ -431,6 +435,23 @@ func (s *sharedUDPConn) Close() error {
 	return nil
 }
 
+// natUDPConn passes the packets received on the discovery socket to the NAT
+// mechanism sharing it, and returns the ones it didn't consume.
+type natUDPConn struct {
+	*net.UDPConn
+	mapper nat.SocketMapper
+}
+
+// ReadFromUDP implements discover.UDPConn
+func (c *natUDPConn) ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err error) {
+	for {
+		n, addr, err = c.UDPConn.ReadFromUDP(b)
+		if err != nil || !c.mapper.HandlePacket(addr, b[:n]) {
+			return n, addr, err
+		}
+	}
+}
+
 // Start starts running the server.
 // Servers can not be re-used after stopping.
 func (srv *Server) Start() (err error) {
@@ -481,6 +502,13 @@ func (srv *Server) Start() (err error) {
 	if err := srv.setupDiscovery(); err != nil {
 		return err
 	}
+	if _, ok := srv.NAT.(nat.ExtIP); srv.NAT != nil && !ok {
+		// Ask the router about the IP. This takes a while and blocks startup,
+		// do it in the background. It has to wait for discovery to be set up
+		// because STUN sends its requests from the discovery socket.
+		srv.loopWG.Add(1)
+		go srv.natRefreshLoop()
+	}
 	srv.setupDialScheduler()
 
 	srv.loopWG.Add(1)
@@ -511,27 +539,78 @@ func (srv *Server) setupLocalNode() error {
 			srv.localnode.Set(e)
 		}
 	}
-	switch srv.NAT.(type) {
-	case nil:
-		// No NAT interface, do nothing.
-	case nat.ExtIP:
+	if _, ok := srv.NAT.(nat.ExtIP); ok {
 		// ExtIP doesn't block, set the IP right away.
 		ip, _ := srv.NAT.ExternalIP()
 		srv.localnode.SetStaticIP(ip)
-	default:
-		// Ask the router about the IP. This takes a while and blocks startup,
-		// do it in the background.
-		srv.loopWG.Add(1)
-		go func() {
-			defer srv.loopWG.Done()
-			if ip, err := srv.NAT.ExternalIP(); err == nil {
-				srv.localnode.SetStaticIP(ip)
-			}
-		}()
 	}
 	return nil
 }
 
+// natRefreshLoop keeps the endpoint of the local node record in sync with the
+// external address reported by the NAT mechanism, which may change over time.
+func (srv *Server) natRefreshLoop() {
+	defer srv.loopWG.Done()
+
+	var (
+		current *net.UDPAddr
+		refresh = time.NewTimer(0)
+	)
+	defer refresh.Stop()
+	for {
+		select {
+		case <-refresh.C:
+			addr, err := srv.natExternalAddr()
+			switch {
+			case err != nil:
+				srv.log.Debug("Couldn't get external address", "interface", srv.NAT, "err", err)
+			case current == nil || !addr.IP.Equal(current.IP) || addr.Port != current.Port:
+				srv.log.Info("Updated external address", "interface", srv.NAT, "ip", addr.IP, "port", addr.Port)
+				srv.localnode.SetStaticIP(addr.IP)
+				if addr.Port != 0 {
+					srv.setExternalPort(addr.Port)
+				}
+				current = addr
+			}
+			refresh.Reset(natRefreshInterval)
+		case <-srv.quit:
+			return
+		}
+	}
+}
+
+// natExternalAddr queries the NAT mechanism for the external address. The port
+// is only known if the mechanism shares the discovery socket, it is zero
+// otherwise.
+func (srv *Server) natExternalAddr() (*net.UDPAddr, error) {
+	if mapper, ok := srv.NAT.(nat.SocketMapper); ok && srv.natConn != nil {
+		return mapper.ExternalAddr()
+	}
+	ip, err := srv.NAT.ExternalIP()
+	if err != nil {
+		return nil, err
+	}
+	return &net.UDPAddr{IP: ip}, nil
+}
+
+// setExternalPort announces the external port of the discovery socket in the
+// local node record. The NAT mechanism only reports the UDP mapping. If TCP is
+// served on the same local port, as it is by default, the TCP port is assumed
+// to be forwarded alike.
+func (srv *Server) setExternalPort(port int) {
+	// The UDP port in the record is taken from the fallback while a static IP
+	// is set, so that is the one to update.
+	srv.localnode.SetFallbackUDP(port)
+
+	if srv.listener == nil {
+		return
+	}
+	tcp, ok := srv.listener.Addr().(*net.TCPAddr)
+	if ok && tcp.Port == srv.natConn.LocalAddr().(*net.UDPAddr).Port {
+		srv.localnode.Set(enr.TCP(port))
+	}
+}
+
 func (srv *Server) setupDiscovery() error {
 	srv.discmix = enode.NewFairMix(discmixTimeout)
 
@@ -570,6 +649,16 @@ func (srv *Server) setupDiscovery() error {
 	}
 	srv.localnode.SetFallbackUDP(realaddr.Port)
 
+	// Let a NAT mechanism which learns the external port by exchanging packets
+	// use the discovery socket. Its responses are taken out before discovery
+	// reads them.
+	var dconn discover.UDPConn = conn
+	if mapper, ok := srv.NAT.(nat.SocketMapper); ok {
+		mapper.UseSocket(conn)
+		srv.natConn = conn
+		dconn = &natUDPConn{conn, mapper}
+	}
+
 	// Discovery V4
 	var unhandled chan discover.ReadPacket
 	var sconn *sharedUDPConn
@@ -585,7 +674,7 @@ func (srv *Server) setupDiscovery() error {
 			Unhandled:   unhandled,
 			Log:         srv.log,
 		}
-		ntab, err := discover.ListenV4(conn, srv.localnode, cfg)
+		ntab, err := discover.ListenV4(dconn, srv.localnode, cfg)
 		if err != nil {
 			return err
 		}
@@ -605,7 +694,7 @@ func (srv *Server) setupDiscovery() error {
 		if sconn != nil {
 			srv.DiscV5, err = discover.ListenV5(sconn, srv.localnode, cfg)
 		} else {
-			srv.DiscV5, err = discover.ListenV5(conn, srv.localnode, cfg)
+			srv.DiscV5, err = discover.ListenV5(dconn, srv.localnode, cfg)
 		}
 		if err != nil {
 			return err
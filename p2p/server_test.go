@@ -17,13 +17,16 @@
 package p2p
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/sha256"
 	"errors"
+	"fmt"
 	"io"
 	"math/rand"
 	"net"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -32,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/rlpx"
 )
 
@@ -506,6 +510,110 @@ func randomID() (id enode.ID) {
 }
 
 // This test checks that inbound connections are throttled by IP.
+// fakeSocketMapper is a nat.SocketMapper reporting a fixed external address.
+type fakeSocketMapper struct {
+	mapped  *net.UDPAddr
+	marker  []byte        // packets equal to this are consumed
+	handled chan struct{} // receives on every consumed packet
+
+	mu   sync.Mutex
+	conn nat.UDPWriter
+}
+
+func (m *fakeSocketMapper) AddMapping(string, int, int, string, time.Duration) error { return nil }
+func (m *fakeSocketMapper) DeleteMapping(string, int, int) error                     { return nil }
+func (m *fakeSocketMapper) ExternalIP() (net.IP, error)                              { return m.mapped.IP, nil }
+func (m *fakeSocketMapper) String() string                                           { return "fake" }
+
+func (m *fakeSocketMapper) UseSocket(conn nat.UDPWriter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conn = conn
+}
+
+func (m *fakeSocketMapper) HandlePacket(from *net.UDPAddr, data []byte) bool {
+	if !bytes.Equal(data, m.marker) {
+		return false
+	}
+	m.handled <- struct{}{}
+	return true
+}
+
+func (m *fakeSocketMapper) ExternalAddr() (*net.UDPAddr, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conn == nil {
+		return nil, errors.New("no socket")
+	}
+	return m.mapped, nil
+}
+
+// This test checks that a NAT mechanism sharing the discovery socket gets the
+// packets meant for it, and that the external port it reports is announced in
+// the local node record.
+func TestServerNATSocketMapper(t *testing.T) {
+	// Find a port which is free for both TCP and UDP.
+	var port int
+	for i := 0; i < 10 && port == 0; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := l.Addr().(*net.TCPAddr).Port
+		l.Close()
+		if c, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: p}); err == nil {
+			c.Close()
+			port = p
+		}
+	}
+	if port == 0 {
+		t.Skip("no port free for both TCP and UDP")
+	}
+	mapper := &fakeSocketMapper{
+		mapped:  &net.UDPAddr{IP: net.IP{33, 44, 55, 66}, Port: 40404},
+		marker:  []byte("nat response"),
+		handled: make(chan struct{}, 1),
+	}
+	srv := &Server{Config: Config{
+		PrivateKey: newkey(),
+		MaxPeers:   10,
+		ListenAddr: fmt.Sprintf("127.0.0.1:%d", port),
+		NAT:        mapper,
+		Logger:     testlog.Logger(t, log.LvlTrace),
+	}}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	// Packets for the NAT mechanism are taken out of the discovery socket.
+	sender, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	sender.WriteToUDP(mapper.marker, &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: port})
+	select {
+	case <-mapper.handled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("packet wasn't passed to the NAT mechanism")
+	}
+
+	// The record should announce the external address, with the mapped port for
+	// both UDP and TCP.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		n := srv.LocalNode().Node()
+		if n.IP().Equal(mapper.mapped.IP) && n.UDP() == mapper.mapped.Port && n.TCP() == mapper.mapped.Port {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("wrong endpoint in record: ip %v, udp %d, tcp %d", n.IP(), n.UDP(), n.TCP())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func TestServerInboundThrottle(t *testing.T) {
 	const timeout = 5 * time.Second
 	newTransportCalled := make(chan struct{})
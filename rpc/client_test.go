@@ -665,7 +665,90 @@ func TestClientReconnect(t *testing.T) {
 	}
 }
 
+// This test checks that subscriptions are re-established transparently when the
+// client is in reconnecting mode and the connection drops.
+func TestClientResubscribe(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+
+	listener := &trackingListener{}
+	client, hs := httpTestClientWithListener(server, "ws", listener)
+	defer hs.Close()
+	defer client.Close()
+
+	gaps := make(chan SubscriptionGap, 1)
+	if err := client.EnableReconnect(ReconnectConfig{MinBackoff: 10 * time.Millisecond, Gaps: gaps}); err != nil {
+		t.Fatal("can't enable reconnecting mode:", err)
+	}
+	nc := make(chan int)
+	sub, err := client.Subscribe(context.Background(), "nftest", nc, "someSubscription", 2, 5)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	defer sub.Unsubscribe()
+
+	for i := 0; i < 2; i++ {
+		if val := <-nc; val != 5+i {
+			t.Fatalf("value mismatch: got %d, want %d", val, 5+i)
+		}
+	}
+	// Drop the connection, the subscription should be recreated with the same
+	// arguments and a gap reported.
+	listener.killConns()
+
+	select {
+	case gap := <-gaps:
+		if gap.Subscription != sub {
+			t.Fatalf("gap reported for wrong subscription")
+		}
+		if gap.End.Before(gap.Start) {
+			t.Fatalf("invalid gap: %v - %v", gap.Start, gap.End)
+		}
+	case err := <-sub.Err():
+		t.Fatal("subscription failed:", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription not re-established")
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case val := <-nc:
+			if val != 5+i {
+				t.Fatalf("value mismatch after resubscription: got %d, want %d", val, 5+i)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification after resubscription")
+		}
+	}
+}
+
+// This test checks that clients without a way to redial refuse reconnecting mode.
+func TestClientEnableReconnectUnsupported(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+
+	client, hs := httpTestClient(server, "http", nil)
+	defer hs.Close()
+	defer client.Close()
+
+	if err := client.EnableReconnect(ReconnectConfig{}); err != ErrReconnectUnsupported {
+		t.Fatalf("wrong error: got %v, want %v", err, ErrReconnectUnsupported)
+	}
+}
+
 func httpTestClient(srv *Server, transport string, fl *flakeyListener) (*Client, *httptest.Server) {
+	if fl == nil {
+		return httpTestClientWithListener(srv, transport, nil)
+	}
+	return httpTestClientWithListener(srv, transport, fl)
+}
+
+// wrappingListener is a listener which wraps the listener of a test server.
+type wrappingListener interface {
+	net.Listener
+	wrap(net.Listener)
+}
+
+func httpTestClientWithListener(srv *Server, transport string, wl wrappingListener) (*Client, *httptest.Server) {
 	// Create the HTTP server.
 	var hs *httptest.Server
 	switch transport {
@@ -677,9 +760,9 @@ func httpTestClient(srv *Server, transport string, fl *flakeyListener) (*Client,
 		panic("unknown HTTP transport: " + transport)
 	}
 	// Wrap the listener if required.
-	if fl != nil {
-		fl.Listener = hs.Listener
-		hs.Listener = fl
+	if wl != nil {
+		wl.wrap(hs.Listener)
+		hs.Listener = wl
 	}
 	// Connect the client.
 	hs.Start()
@@ -723,6 +806,8 @@ type flakeyListener struct {
 	maxAcceptDelay time.Duration
 }
 
+func (l *flakeyListener) wrap(inner net.Listener) { l.Listener = inner }
+
 func (l *flakeyListener) Accept() (net.Conn, error) {
 	delay := time.Duration(rand.Int63n(int64(l.maxAcceptDelay)))
 	time.Sleep(delay)
@@ -737,3 +822,33 @@ func (l *flakeyListener) Accept() (net.Conn, error) {
 	}
 	return c, err
 }
+
+// trackingListener keeps track of accepted connections, so they can be killed.
+type trackingListener struct {
+	net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func (l *trackingListener) wrap(inner net.Listener) { l.Listener = inner }
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err == nil {
+		l.mu.Lock()
+		l.conns = append(l.conns, c)
+		l.mu.Unlock()
+	}
+	return c, err
+}
+
+// killConns closes all connections accepted so far.
+func (l *trackingListener) killConns() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, c := range l.conns {
+		c.Close()
+	}
+	l.conns = nil
+}
@@ -54,6 +54,9 @@ var (
 	_ Error = new(invalidRequestError)
 	_ Error = new(invalidMessageError)
 	_ Error = new(invalidParamsError)
+	_ Error = new(batchTooLargeError)
+	_ Error = new(responseTooLargeError)
+	_ Error = new(rateLimitError)
 	_ Error = new(CustomError)
 )
 
@@ -103,6 +106,36 @@ func (e *invalidParamsError) ErrorCode() int { return -32602 }
 
 func (e *invalidParamsError) Error() string { return e.message }
 
+// batch contains more requests than the server accepts
+type batchTooLargeError struct{ limit int }
+
+func (e *batchTooLargeError) ErrorCode() int { return -32600 }
+
+func (e *batchTooLargeError) Error() string {
+	return fmt.Sprintf("batch too large, max %d requests", e.limit)
+}
+
+// responses to a batch exceed the size accepted by the server
+type responseTooLargeError struct{ limit int }
+
+func (e *responseTooLargeError) ErrorCode() int { return -32003 }
+
+func (e *responseTooLargeError) Error() string {
+	return fmt.Sprintf("response too large, max %d bytes per batch", e.limit)
+}
+
+// client exceeded the request rate accepted by the server
+type rateLimitError struct{ method string }
+
+func (e *rateLimitError) ErrorCode() int { return -32005 }
+
+func (e *rateLimitError) Error() string {
+	if e.method != "" {
+		return fmt.Sprintf("rate limit exceeded for %s", e.method)
+	}
+	return "rate limit exceeded"
+}
+
 type CustomError struct {
 	Code    int
 	Message string
@@ -25,7 +25,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 // handler handles JSON-RPC messages. There is one handler per connection. Note that
@@ -61,6 +64,8 @@ type handler struct {
 	conn           jsonWriter                     // where responses will be sent
 	log            log.Logger
 	allowSubscribe bool
+	limits         *serverLimits // request limits, nil if unlimited
+	remoteHost     string        // remote host the rate limits are keyed by
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
@@ -71,7 +76,7 @@ type callProc struct {
 	notifiers []*Notifier
 }
 
-func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry) *handler {
+func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry, limits *serverLimits) *handler {
 	rootCtx, cancelRoot := context.WithCancel(connCtx)
 	h := &handler{
 		reg:            reg,
@@ -84,9 +89,11 @@ func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *
 		allowSubscribe: true,
 		serverSubs:     make(map[ID]*Subscription),
 		log:            log.Root(),
+		limits:         limits,
 	}
 	if conn.remoteAddr() != "" {
 		h.log = h.log.New("conn", conn.remoteAddr())
+		h.remoteHost = remoteHost(conn.remoteAddr())
 	}
 	h.unsubscribeCb = newCallback(reflect.Value{}, reflect.ValueOf(h.unsubscribe))
 	return h
@@ -102,6 +109,15 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 		return
 	}
 
+	// Reject the entire batch if it contains too many requests:
+	if h.limits != nil && h.limits.batchItems > 0 && len(msgs) > h.limits.batchItems {
+		batchTooLargeMeter.Mark(1)
+		h.startCallProc(func(cp *callProc) {
+			h.respondWithBatchTooLarge(cp, msgs)
+		})
+		return
+	}
+
 	// Handle non-call messages first:
 	calls := make([]*jsonrpcMessage, 0, len(msgs))
 	for _, msg := range msgs {
@@ -114,10 +130,29 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 	}
 	// Process calls on a goroutine because they may block indefinitely:
 	h.startCallProc(func(cp *callProc) {
-		answers := make([]*jsonrpcMessage, 0, len(msgs))
-		for _, msg := range calls {
+		var (
+			answers = make([]*jsonrpcMessage, 0, len(msgs))
+			size    int
+			limit   int
+		)
+		if h.limits != nil {
+			limit = h.limits.batchResponse
+		}
+		for i, msg := range calls {
+			// Once the responses grew too large, the remaining calls are
+			// not executed anymore, only answered with an error.
+			if limit > 0 && size > limit {
+				for _, msg := range calls[i:] {
+					if msg.isCall() {
+						answers = append(answers, msg.errorResponse(&responseTooLargeError{limit}))
+					}
+				}
+				responseTooLargeMeter.Mark(1)
+				break
+			}
 			if answer := h.handleCallMsg(cp, msg); answer != nil {
 				answers = append(answers, answer)
+				size += len(answer.Result)
 			}
 		}
 		h.addSubscriptions(cp.notifiers)
@@ -130,6 +165,20 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 	})
 }
 
+// respondWithBatchTooLarge answers a batch exceeding the item limit with a single
+// error. The error carries the id of the first call in the batch, as there is no
+// way to report an error for the batch as a whole.
+func (h *handler) respondWithBatchTooLarge(cp *callProc, batch []*jsonrpcMessage) {
+	resp := errorMessage(&batchTooLargeError{h.limits.batchItems})
+	for _, msg := range batch {
+		if msg.isCall() {
+			resp.ID = msg.ID
+			break
+		}
+	}
+	h.conn.writeJSON(cp.ctx, []*jsonrpcMessage{resp})
+}
+
 // handleMsg handles a single message.
 func (h *handler) handleMsg(msg *jsonrpcMessage) {
 	if ok := h.handleImmediate(msg); ok {
@@ -280,9 +329,13 @@ func (h *handler) handleResponse(msg *jsonrpcMessage) {
 		op.err = msg.Error
 		return
 	}
-	if op.err = json.Unmarshal(msg.Result, &op.sub.subid); op.err == nil {
-		go op.sub.run()
-		h.clientSubs[op.sub.subid] = op.sub
+	var subid string
+	if op.err = json.Unmarshal(msg.Result, &subid); op.err == nil {
+		op.sub.setID(subid)
+		if !op.resub {
+			go op.sub.run()
+		}
+		h.clientSubs[subid] = op.sub
 	}
 }
 
@@ -317,6 +370,11 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 
 // handleCall processes method calls.
 func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
+	if h.limits != nil {
+		if err := h.limits.rates.allow(h.remoteHost, msg.Method); err != nil {
+			return msg.errorResponse(err)
+		}
+	}
 	if msg.isSubscribe() {
 		return h.handleSubscribe(cp, msg)
 	}
@@ -334,7 +392,17 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 		return msg.errorResponse(&invalidParamsError{err.Error()})
 	}
 	start := time.Now()
-	answer := h.runMethod(cp.ctx, msg, callb, args)
+	ctx, span := tracing.StartServer(cp.ctx, msg.Method,
+		attribute.String("rpc.system", "jsonrpc"),
+		attribute.String("rpc.method", msg.Method),
+		attribute.String("rpc.jsonrpc.request_id", string(msg.ID)),
+	)
+	answer := h.runMethod(ctx, msg, callb, args)
+	if answer.Error != nil {
+		span.SetAttributes(attribute.Int("rpc.jsonrpc.error_code", answer.Error.Code))
+		span.SetStatus(codes.Error, answer.Error.Message)
+	}
+	span.End()
 
 	// Collect the statistics for RPC calls if metrics is enabled.
 	// We only care about pure rpc call. Filter out subscription.
@@ -29,6 +29,8 @@ import (
 	"net/url"
 	"sync"
 	"time"
+
+	"github.com/ethereum/go-ethereum/internal/tracing"
 )
 
 const (
@@ -249,6 +251,8 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if origin := r.Header.Get("Origin"); origin != "" {
 		ctx = context.WithValue(ctx, "Origin", origin)
 	}
+	// Continue the trace of the caller, if it sent one
+	ctx = tracing.Extract(ctx, r.Header)
 
 	w.Header().Set("content-type", contentType)
 	codec := newHTTPServerConn(r, w)
@@ -21,6 +21,13 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func confirmStatusCode(t *testing.T, got, want int) {
@@ -162,3 +169,52 @@ func TestHTTPErrorResponse(t *testing.T) {
 		t.Error("unexpected error message", errMsg)
 	}
 }
+
+// Tests that every call is traced in a span continuing the trace of the caller.
+func TestHTTPTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	}()
+
+	server := newTestServer()
+	defer server.Stop()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	client, err := DialHTTP(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	client.SetHeader("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	if err := client.Call(nil, "test_noArgsRets"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(nil, "test_returnError"); err == nil {
+		t.Fatal("error was expected")
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("wrong number of spans: have %d, want 2", len(spans))
+	}
+	for i, method := range []string{"test_noArgsRets", "test_returnError"} {
+		span := spans[i]
+		if span.Name() != method || span.SpanKind() != trace.SpanKindServer {
+			t.Errorf("span %d: have %s (%v), want %s server span", i, span.Name(), span.SpanKind(), method)
+		}
+		if parent := span.Parent(); !parent.IsRemote() || parent.TraceID().String() != "0af7651916cd43dd8448eb211c80319c" || parent.SpanID().String() != "b7ad6b7169203331" {
+			t.Errorf("span %d: caller trace not continued, parent %v", i, parent)
+		}
+	}
+	if status := spans[0].Status(); status.Code != codes.Unset {
+		t.Errorf("successful call status mismatch: %v", status)
+	}
+	if status := spans[1].Status(); status.Code != codes.Error || status.Description != "testError" {
+		t.Errorf("failed call status mismatch: %v", status)
+	}
+}
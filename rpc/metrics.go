@@ -17,8 +17,6 @@
 package rpc
 
 import (
-	"fmt"
-
 	"github.com/ethereum/go-ethereum/metrics"
 )
 
@@ -27,6 +25,12 @@ var (
 	successfulRequestGauge = metrics.NewRegisteredGauge("rpc/success", nil)
 	failedReqeustGauge     = metrics.NewRegisteredGauge("rpc/failure", nil)
 	rpcServingTimer        = metrics.NewRegisteredTimer("rpc/duration/all", nil)
+	rpcServingTimers       = metrics.NewRegisteredTimerVec("rpc/duration", nil, "method", "status")
+
+	batchTooLargeMeter    = metrics.NewRegisteredMeter("rpc/limits/batchsize", nil)
+	responseTooLargeMeter = metrics.NewRegisteredMeter("rpc/limits/responsesize", nil)
+	rateLimitedMeter      = metrics.NewRegisteredMeter("rpc/limits/ratelimit/all", nil)
+	rateLimitedMeters     = metrics.NewRegisteredMeterVec("rpc/limits/ratelimit", nil, "method")
 )
 
 func newRPCServingTimer(method string, valid bool) metrics.Timer {
@@ -34,6 +38,9 @@ func newRPCServingTimer(method string, valid bool) metrics.Timer {
 	if !valid {
 		flag = "failure"
 	}
-	m := fmt.Sprintf("rpc/duration/%s/%s", method, flag)
-	return metrics.GetOrRegisterTimer(m, nil)
+	return rpcServingTimers.With(method, flag)
+}
+
+func newRateLimitedMeter(method string) metrics.Meter {
+	return rateLimitedMeters.With(method)
 }
@@ -0,0 +1,174 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimiterCleanupInterval is the interval at which the token buckets of idle
+// clients are dropped.
+const rateLimiterCleanupInterval = time.Minute
+
+// RateLimit configures a token bucket which refills at Rate requests per second
+// and holds at most Burst requests. A zero rate disables the limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// enabled reports whether the limit is active.
+func (l RateLimit) enabled() bool {
+	return l.Rate > 0
+}
+
+// idle returns the time it takes for an empty bucket to fill up again. Buckets
+// unused for longer than this are full and can be dropped.
+func (l RateLimit) idle() time.Duration {
+	return time.Duration(float64(l.burst()) / l.Rate * float64(time.Second))
+}
+
+// burst returns the bucket size, which is at least a single request.
+func (l RateLimit) burst() int {
+	if l.Burst < 1 {
+		return 1
+	}
+	return l.Burst
+}
+
+// rateLimiter tracks the token buckets of the clients of a server, both for all
+// requests of a client and for the individual limited methods.
+type rateLimiter struct {
+	client  RateLimit            // Limit on all requests of a single client
+	methods map[string]RateLimit // Limits on single methods of a single client
+
+	buckets map[rateKey]*rateBucket
+	cleaned time.Time // Last time the idle buckets were dropped
+	lock    sync.Mutex
+}
+
+// rateKey identifies a token bucket. The method is empty for the bucket
+// limiting all requests of the client.
+type rateKey struct {
+	client string
+	method string
+}
+
+// rateBucket is a token bucket along with the time it was last used.
+type rateBucket struct {
+	limiter *rate.Limiter
+	idle    time.Duration
+	used    time.Time
+}
+
+// newRateLimiter creates a rate limiter with the given limits. It returns nil
+// if no limit is enabled.
+func newRateLimiter(client RateLimit, methods map[string]RateLimit) *rateLimiter {
+	enabled := make(map[string]RateLimit)
+	for method, limit := range methods {
+		if limit.enabled() {
+			enabled[method] = limit
+		}
+	}
+	if !client.enabled() && len(enabled) == 0 {
+		return nil
+	}
+	return &rateLimiter{
+		client:  client,
+		methods: enabled,
+		buckets: make(map[rateKey]*rateBucket),
+		cleaned: time.Now(),
+	}
+}
+
+// allow takes a token for the method from the buckets of the client, returning
+// an error if any of them is exhausted. A rejected request takes no tokens, so
+// it doesn't eat into the budget of the bucket that still had some left.
+func (l *rateLimiter) allow(client string, method string) error {
+	if l == nil || client == "" {
+		return nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.cleaned) > rateLimiterCleanupInterval {
+		for key, bucket := range l.buckets {
+			if now.Sub(bucket.used) > bucket.idle {
+				delete(l.buckets, key)
+			}
+		}
+		l.cleaned = now
+	}
+	var taken *rate.Reservation
+	if limit, ok := l.methods[method]; ok {
+		if taken = l.take(rateKey{client, method}, limit, now); taken == nil {
+			rateLimitedMeter.Mark(1)
+			newRateLimitedMeter(method).Mark(1)
+			return &rateLimitError{method: method}
+		}
+	}
+	if l.client.enabled() {
+		if l.take(rateKey{client: client}, l.client, now) == nil {
+			// Give the method token back, the request is not served
+			if taken != nil {
+				taken.CancelAt(now)
+			}
+			rateLimitedMeter.Mark(1)
+			return &rateLimitError{}
+		}
+	}
+	return nil
+}
+
+// take removes a token from the bucket, creating it if it doesn't exist yet. It
+// returns the reservation of the token, which can be cancelled to give it back,
+// or nil if the bucket is exhausted.
+func (l *rateLimiter) take(key rateKey, limit RateLimit, now time.Time) *rate.Reservation {
+	bucket := l.buckets[key]
+	if bucket == nil {
+		bucket = &rateBucket{
+			limiter: rate.NewLimiter(rate.Limit(limit.Rate), limit.burst()),
+			idle:    limit.idle(),
+		}
+		l.buckets[key] = bucket
+	}
+	bucket.used = now
+
+	r := bucket.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return nil
+	}
+	if r.DelayFrom(now) > 0 {
+		r.CancelAt(now)
+		return nil
+	}
+	return r
+}
+
+// remoteHost strips the port from a remote address, so that all connections of
+// a client share the same rate limits.
+func remoteHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
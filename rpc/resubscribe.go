@@ -0,0 +1,218 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ErrReconnectUnsupported is returned when enabling resubscription on a client
+// which is unable to re-dial its server.
+var ErrReconnectUnsupported = errors.New("client cannot reconnect")
+
+const (
+	defaultMinReconnectBackoff = time.Second
+	defaultMaxReconnectBackoff = time.Minute
+)
+
+// ReconnectConfig configures the reconnecting mode of a client.
+type ReconnectConfig struct {
+	// MinBackoff is the delay after the first failed attempt to re-establish the
+	// subscriptions. The delay doubles with every subsequent failure.
+	MinBackoff time.Duration
+
+	// MaxBackoff is the maximum delay between two attempts.
+	MaxBackoff time.Duration
+
+	// Gaps, if non-nil, receives a notification for every subscription which has
+	// been re-established. The channel must be serviced, resubscription blocks
+	// until the notification is delivered.
+	Gaps chan<- SubscriptionGap
+}
+
+// SubscriptionGap reports a period during which a subscription was down because
+// the connection to the server was lost. Notifications the server would have sent
+// between Start and End were missed and may need to be backfilled.
+type SubscriptionGap struct {
+	Subscription *ClientSubscription
+	Start        time.Time // Time the connection loss was detected
+	End          time.Time // Time the subscription was re-established
+}
+
+// EnableReconnect switches the client into reconnecting mode. Whenever the
+// connection is lost, the client re-dials the server with exponential backoff
+// and re-establishes all active subscriptions with their original arguments.
+// The subscriptions stay alive in the meantime: their error channels only fire
+// if the server refuses to recreate them or the client is closed.
+//
+// Reconnecting mode is only available for clients created by Dial, DialWebsocket
+// or DialIPC; other clients return ErrReconnectUnsupported.
+func (c *Client) EnableReconnect(config ReconnectConfig) error {
+	if c.isHTTP() || c.reconnectFunc == nil {
+		return ErrReconnectUnsupported
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = defaultMinReconnectBackoff
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = defaultMaxReconnectBackoff
+		if config.MaxBackoff < config.MinBackoff {
+			config.MaxBackoff = config.MinBackoff
+		}
+	}
+	c.resubLock.Lock()
+	defer c.resubLock.Unlock()
+
+	c.resubConfig = &config
+	return nil
+}
+
+// reconnectConfig returns the reconnecting mode configuration, or nil if the
+// client is not in reconnecting mode.
+func (c *Client) reconnectConfig() *ReconnectConfig {
+	c.resubLock.Lock()
+	defer c.resubLock.Unlock()
+
+	return c.resubConfig
+}
+
+// detachSubscriptions takes the subscriptions away from the handler of a lost
+// connection, so they aren't closed along with it, and starts re-establishing
+// them. It does nothing unless the client is in reconnecting mode.
+//
+// This is called by the dispatch loop.
+func (c *Client) detachSubscriptions(h *handler) {
+	config := c.reconnectConfig()
+	if config == nil || len(h.clientSubs) == 0 {
+		return
+	}
+	subs := make([]*ClientSubscription, 0, len(h.clientSubs))
+	for id, sub := range h.clientSubs {
+		delete(h.clientSubs, id)
+		subs = append(subs, sub)
+	}
+	go c.resubscribe(*config, subs, time.Now())
+}
+
+// resubscribe re-establishes the subscriptions on a new connection, retrying
+// with exponential backoff until it succeeds or the client is closed.
+func (c *Client) resubscribe(config ReconnectConfig, subs []*ClientSubscription, lost time.Time) {
+	backoff := config.MinBackoff
+	for {
+		var failed []*ClientSubscription
+		for _, sub := range subs {
+			// Subscriptions unsubscribed in the meantime are dropped
+			if sub.ended() {
+				continue
+			}
+			err := c.resubscribeOne(sub)
+			switch {
+			case err == nil:
+				log.Debug("RPC subscription re-established", "method", sub.method, "id", sub.id())
+				if !c.reportGap(config, SubscriptionGap{Subscription: sub, Start: lost, End: time.Now()}) {
+					c.closeSubscriptions(subs)
+					return
+				}
+			case err == ErrClientQuit:
+				c.closeSubscriptions(subs)
+				return
+			case isServerError(err):
+				// The server refused the subscription, retrying won't help
+				sub.close(err)
+			default:
+				failed = append(failed, sub)
+			}
+		}
+		if len(failed) == 0 {
+			return
+		}
+		subs = failed
+		log.Debug("RPC resubscription failed, retrying", "subs", len(subs), "backoff", backoff)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-c.closing:
+			timer.Stop()
+			c.closeSubscriptions(subs)
+			return
+		}
+		if backoff *= 2; backoff > config.MaxBackoff {
+			backoff = config.MaxBackoff
+		}
+	}
+}
+
+// resubscribeOne sends the original subscribe request of a subscription. The
+// client reconnects as part of sending the request if the connection is down.
+func (c *Client) resubscribeOne(sub *ClientSubscription) error {
+	ctx, cancel := context.WithTimeout(context.Background(), subscribeTimeout)
+	defer cancel()
+
+	msg := &jsonrpcMessage{Version: vsn, ID: c.nextID(), Method: sub.method, Params: sub.params}
+	op := &requestOp{
+		ids:   []json.RawMessage{msg.ID},
+		resp:  make(chan *jsonrpcMessage),
+		sub:   sub,
+		resub: true,
+	}
+	if err := c.send(ctx, op, msg); err != nil {
+		return err
+	}
+	if _, err := op.wait(ctx, c); err != nil {
+		return err
+	}
+	// If the subscription ended while it was being re-established, drop it on the
+	// server side too.
+	if sub.ended() {
+		sub.requestUnsubscribe()
+	}
+	return nil
+}
+
+// reportGap delivers a gap notification, returning false if the client was
+// closed in the meantime.
+func (c *Client) reportGap(config ReconnectConfig, gap SubscriptionGap) bool {
+	if config.Gaps == nil {
+		return true
+	}
+	select {
+	case config.Gaps <- gap:
+		return true
+	case <-c.closing:
+		return false
+	}
+}
+
+// closeSubscriptions ends the subscriptions because the client was closed.
+func (c *Client) closeSubscriptions(subs []*ClientSubscription) {
+	for _, sub := range subs {
+		sub.close(ErrClientQuit)
+	}
+}
+
+// isServerError reports whether the error was returned by the server, rather
+// than being caused by the connection.
+func isServerError(err error) bool {
+	_, ok := err.(Error)
+	return ok
+}
@@ -46,6 +46,14 @@ type Server struct {
 	idgen    func() ID
 	run      int32
 	codecs   mapset.Set
+	limits   serverLimits
+}
+
+// serverLimits are the limits a server enforces on the requests of its clients.
+type serverLimits struct {
+	batchItems    int          // Maximum number of requests in a batch, 0 = unlimited
+	batchResponse int          // Maximum total size of the responses to a batch, 0 = unlimited
+	rates         *rateLimiter // Per-client and per-method request rates, nil = unlimited
 }
 
 // NewServer creates a new server instance with no registered handlers.
@@ -66,6 +74,26 @@ func (s *Server) RegisterName(name string, receiver interface{}) error {
 	return s.services.registerName(name, receiver)
 }
 
+// SetBatchLimits sets the limits applied to batch requests. The item limit is the
+// maximum number of requests in a batch, the response limit is the maximum total
+// size in bytes of the results returned for a batch. Zero disables a limit.
+//
+// This method should be called before the server starts serving requests.
+func (s *Server) SetBatchLimits(itemLimit, maxResponseSize int) {
+	s.limits.batchItems = itemLimit
+	s.limits.batchResponse = maxResponseSize
+}
+
+// SetRateLimits sets the token-bucket limits applied to the requests of every
+// remote client, identified by its IP address. The client limit applies to all
+// requests, the method limits only to calls of the given methods. Connections
+// without a remote address, such as in-process ones, are never limited.
+//
+// This method should be called before the server starts serving requests.
+func (s *Server) SetRateLimits(client RateLimit, methods map[string]RateLimit) {
+	s.limits.rates = newRateLimiter(client, methods)
+}
+
 // ServeCodec reads incoming requests from codec, calls the appropriate callback and writes
 // the response back using the given codec. It will block until the codec is closed or the
 // server is stopped. In either case the codec is closed.
@@ -83,7 +111,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	c := initClient(codec, s.idgen, &s.services, &s.limits)
 	<-codec.closed()
 	c.Close()
 }
@@ -97,7 +125,7 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 		return
 	}
 
-	h := newHandler(ctx, codec, s.idgen, &s.services)
+	h := newHandler(ctx, codec, s.idgen, &s.services, &s.limits)
 	h.allowSubscribe = false
 	defer h.close(io.EOF, nil)
 
@@ -19,9 +19,12 @@ package rpc
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -150,3 +153,116 @@ func TestServerShortLivedConn(t *testing.T) {
 		}
 	}
 }
+
+// This test checks that batches over the item and response size limits are rejected.
+func TestServerBatchLimits(t *testing.T) {
+	server := newTestServer()
+	server.SetBatchLimits(3, 50)
+	defer server.Stop()
+
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+
+	// A batch with too many items is answered with a single error
+	resps := postBatch(t, httpsrv.URL, `[`+
+		`{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]},`+
+		`{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["x",1]},`+
+		`{"jsonrpc":"2.0","id":3,"method":"test_echo","params":["x",1]},`+
+		`{"jsonrpc":"2.0","id":4,"method":"test_echo","params":["x",1]}]`)
+	if len(resps) != 1 {
+		t.Fatalf("wrong number of responses: got %d, want 1", len(resps))
+	}
+	if resps[0].Error == nil || resps[0].Error.Code != -32600 || string(resps[0].ID) != "1" {
+		t.Fatalf("wrong response to oversized batch: %v", resps[0])
+	}
+	// Calls following a response which exceeds the size limit are not executed
+	resps = postBatch(t, httpsrv.URL, `[`+
+		`{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]},`+
+		`{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["x",1]},`+
+		`{"jsonrpc":"2.0","id":3,"method":"test_echo","params":["x",1]}]`)
+	if len(resps) != 3 {
+		t.Fatalf("wrong number of responses: got %d, want 3", len(resps))
+	}
+	for i, resp := range resps[:2] {
+		if resp.Error != nil {
+			t.Fatalf("response %d: unexpected error: %v", i, resp.Error)
+		}
+	}
+	if resps[2].Error == nil || resps[2].Error.Code != -32003 {
+		t.Fatalf("wrong response to call over the size limit: %v", resps[2])
+	}
+}
+
+// This test checks that clients are throttled according to the rate limits.
+func TestServerRateLimits(t *testing.T) {
+	server := newTestServer()
+	server.SetRateLimits(RateLimit{Rate: 0.001, Burst: 3}, map[string]RateLimit{
+		"test_echo": {Rate: 0.001, Burst: 2},
+	})
+	defer server.Stop()
+
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+
+	var (
+		echo = `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]}`
+		rets = `{"jsonrpc":"2.0","id":1,"method":"test_rets"}`
+	)
+	tests := []struct {
+		body string
+		fail bool
+	}{
+		{echo, false},
+		{echo, false},
+		{echo, true}, // method limit hit
+		{rets, false},
+		{rets, true}, // client limit hit
+	}
+	for i, tt := range tests {
+		resps := postBatch(t, httpsrv.URL, "["+tt.body+"]")
+		switch {
+		case tt.fail && (resps[0].Error == nil || resps[0].Error.Code != -32005):
+			t.Errorf("request %d: expected rate limit error, got %v", i, resps[0])
+		case !tt.fail && resps[0].Error != nil:
+			t.Errorf("request %d: unexpected error: %v", i, resps[0].Error)
+		}
+	}
+}
+
+// This test checks that a request rejected by the client limit doesn't use up a
+// token of the method limit.
+func TestRateLimiterRejectionKeepsTokens(t *testing.T) {
+	l := newRateLimiter(RateLimit{Rate: 0.001, Burst: 1}, map[string]RateLimit{
+		"test_echo": {Rate: 0.001, Burst: 2},
+	})
+	if err := l.allow("client", "test_echo"); err != nil {
+		t.Fatalf("first request rejected: %v", err)
+	}
+	if err := l.allow("client", "test_echo"); err == nil {
+		t.Fatal("request over the client limit allowed")
+	}
+	// Refill the client bucket, the method bucket must still have a token
+	delete(l.buckets, rateKey{client: "client"})
+	if err := l.allow("client", "test_echo"); err != nil {
+		t.Fatalf("method token lost to rejected request: %v", err)
+	}
+	delete(l.buckets, rateKey{client: "client"})
+	if err := l.allow("client", "test_echo"); err == nil {
+		t.Fatal("request over the method limit allowed")
+	}
+}
+
+// postBatch sends a raw batch request to an HTTP server and decodes the responses.
+func postBatch(t *testing.T, url string, body string) []*jsonrpcMessage {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var msgs []*jsonrpcMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msgs); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	return msgs
+}
@@ -208,6 +208,12 @@ type ClientSubscription struct {
 	channel   reflect.Value
 	namespace string
 	subid     string
+	subidLock sync.Mutex // protects subid, which changes on resubscription
+
+	// The subscribe request is retained, so the subscription can be re-established
+	// on a new connection.
+	method string
+	params json.RawMessage
 
 	// The in channel receives notification values from client dispatcher.
 	in chan json.RawMessage
@@ -228,10 +234,12 @@ type ClientSubscription struct {
 // This is the sentinel value sent on sub.quit when Unsubscribe is called.
 var errUnsubscribed = errors.New("unsubscribed")
 
-func newClientSubscription(c *Client, namespace string, channel reflect.Value) *ClientSubscription {
+func newClientSubscription(c *Client, namespace string, channel reflect.Value, msg *jsonrpcMessage) *ClientSubscription {
 	sub := &ClientSubscription{
 		client:      c,
 		namespace:   namespace,
+		method:      msg.Method,
+		params:      msg.Params,
 		etype:       channel.Type().Elem(),
 		channel:     channel,
 		in:          make(chan json.RawMessage),
@@ -371,5 +379,29 @@ func (sub *ClientSubscription) unmarshal(result json.RawMessage) (interface{}, e
 
 func (sub *ClientSubscription) requestUnsubscribe() error {
 	var result interface{}
-	return sub.client.Call(&result, sub.namespace+unsubscribeMethodSuffix, sub.subid)
+	return sub.client.Call(&result, sub.namespace+unsubscribeMethodSuffix, sub.id())
+}
+
+// id returns the current server-side identifier of the subscription.
+func (sub *ClientSubscription) id() string {
+	sub.subidLock.Lock()
+	defer sub.subidLock.Unlock()
+	return sub.subid
+}
+
+// setID updates the server-side identifier of the subscription.
+func (sub *ClientSubscription) setID(id string) {
+	sub.subidLock.Lock()
+	defer sub.subidLock.Unlock()
+	sub.subid = id
+}
+
+// ended reports whether the forwarding loop of the subscription has stopped.
+func (sub *ClientSubscription) ended() bool {
+	select {
+	case <-sub.forwardDone:
+		return true
+	default:
+		return false
+	}
 }
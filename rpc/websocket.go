@@ -251,6 +251,7 @@ func newWebsocketCodec(conn *websocket.Conn) ServerCodec {
 		conn:      conn,
 		pingReset: make(chan struct{}, 1),
 	}
+	wc.jsonCodec.remote = conn.RemoteAddr().String()
 	wc.wg.Add(1)
 	go wc.pingLoop()
 	return wc
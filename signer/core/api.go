@@ -27,6 +27,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/accounts/pkcs11"
 	"github.com/ethereum/go-ethereum/accounts/scwallet"
 	"github.com/ethereum/go-ethereum/accounts/usbwallet"
 	"github.com/ethereum/go-ethereum/common"
@@ -95,6 +96,14 @@ type UIClientAPI interface {
 	RegisterUIServer(api *UIServerAPI)
 }
 
+// SignTxFailureHandler is an optional interface of UIs which need to be told when
+// a transaction they approved failed to get signed, e.g. to release the value
+// reserved for it.
+type SignTxFailureHandler interface {
+	// OnSignTxFailed is invoked with the approved transaction if signing it fails.
+	OnSignTxFailed(tx apitypes.SendTxArgs)
+}
+
 // Validator defines the methods required to validate a transaction against some
 // sanity defaults as well as any underlying 4byte method database.
 //
@@ -127,7 +136,7 @@ type Metadata struct {
 	Origin    string `json:"Origin"`
 }
 
-func StartClefAccountManager(ksLocation string, nousb, lightKDF bool, scpath string) *accounts.Manager {
+func StartClefAccountManager(ksLocation string, nousb, lightKDF bool, scpath string, pkcs11Module string) *accounts.Manager {
 	var (
 		backends []accounts.Backend
 		n, p     = keystore.StandardScryptN, keystore.StandardScryptP
@@ -182,6 +191,16 @@ func StartClefAccountManager(ksLocation string, nousb, lightKDF bool, scpath str
 		}
 	}
 
+	// Start a hub for the tokens of a hardware security module
+	if len(pkcs11Module) > 0 {
+		if hsmhub, err := pkcs11.NewHub(pkcs11Module); err != nil {
+			log.Warn(fmt.Sprintf("Failed to start PKCS#11 hub, disabling: %v", err))
+		} else {
+			backends = append(backends, hsmhub)
+			log.Debug("PKCS#11 support enabled", "module", pkcs11Module)
+		}
+	}
+
 	// Clef doesn't allow insecure http account unlock.
 	return accounts.NewManager(&accounts.Config{InsecureUnlockAllowed: false}, backends...)
 }
@@ -352,6 +371,10 @@ func (api *SignerAPI) derivationLoop(events chan accounts.WalletEvent) {
 		case accounts.WalletOpened:
 			status, _ := event.Wallet.Status()
 			log.Info("New wallet appeared", "url", event.Wallet.URL(), "status", status)
+			if event.Wallet.URL().Scheme == pkcs11.Scheme {
+				// Keys are generated on the token, there's nothing to derive
+				break
+			}
 			var derive = func(limit int, next func() accounts.DerivationPath) {
 				// Derive first N accounts, hardcoded for now
 				for i := 0; i < limit; i++ {
@@ -576,6 +599,14 @@ func (api *SignerAPI) SignTransaction(ctx context.Context, args apitypes.SendTxA
 	}
 	// Log changes made by the UI to the signing-request
 	logDiff(&req, &result)
+
+	// Let the UI know if the approved transaction doesn't make it to signing
+	signed := false
+	defer func() {
+		if handler, ok := api.UI.(SignTxFailureHandler); ok && !signed {
+			handler.OnSignTxFailed(result.Transaction)
+		}
+	}()
 	var (
 		acc    accounts.Account
 		wallet accounts.Wallet
@@ -605,6 +636,7 @@ func (api *SignerAPI) SignTransaction(ctx context.Context, args apitypes.SendTxA
 		return nil, err
 	}
 	response := ethapi.SignTransactionResult{Raw: data, Tx: signedTx}
+	signed = true
 
 	// Finally, send the signed tx to the UI
 	api.UI.OnApprovedTx(response)
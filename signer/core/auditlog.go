@@ -113,6 +113,13 @@ func (l *AuditLogger) Version(ctx context.Context) (string, error) {
 
 }
 
+// PolicyRejection records a transaction which was rejected by the spending policy,
+// along with the rule it broke.
+func (l *AuditLogger) PolicyRejection(meta Metadata, args apitypes.SendTxArgs, rule string, reason string) {
+	l.log.Info("SignTransaction", "type", "rejection", "metadata", meta.String(),
+		"tx", args.String(), "rule", rule, "reason", reason)
+}
+
 func NewAuditLogger(path string, api ExternalAPI) (*AuditLogger, error) {
 	l := log.New("api", "signer")
 	handler, err := log.FileHandler(path, log.LogfmtFormat())
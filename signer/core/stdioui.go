@@ -25,7 +25,7 @@ import (
 )
 
 type StdIOUI struct {
-	client rpc.Client
+	client *rpc.Client
 }
 
 func NewStdIOUI() *StdIOUI {
@@ -33,7 +33,7 @@ func NewStdIOUI() *StdIOUI {
 	if err != nil {
 		log.Crit("Could not create stdio client", "err", err)
 	}
-	ui := &StdIOUI{client: *client}
+	ui := &StdIOUI{client: client}
 	return ui
 }
 
@@ -0,0 +1,243 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package policy implements declarative spending policies for clef.
+//
+// A policy is a JSON document restricting the transactions signed per account:
+//
+//	{
+//	  "accounts": {
+//	    "0x8a8eafb1cf62bfbeb1741769dae1a9dd47996192": {
+//	      "approve":     true,
+//	      "dailyLimit":  "1000000000000000000",
+//	      "weeklyLimit": "5000000000000000000",
+//	      "recipients":  ["0x3de6a81d7bd8f7de0fbcb91f6e9ec07c5d2ddfc1"],
+//	      "methods":     ["transfer(address,uint256)", "approve", "0x095ea7b3"],
+//	      "maxGasPrice": "0x174876e800"
+//	    }
+//	  }
+//	}
+//
+// Transactions breaking any rule of their account are rejected. Compliant ones
+// are approved right away if the account policy says so, or passed on for manual
+// confirmation otherwise. Transactions of accounts without a policy and all other
+// requests are always passed on.
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/ethereum/go-ethereum/signer/fourbyte"
+)
+
+// Names of the rules, as reported in rejections.
+const (
+	RuleRecipients  = "recipients"
+	RuleMethods     = "methods"
+	RuleMaxGasPrice = "maxGasPrice"
+	RuleDailyLimit  = "dailyLimit"
+	RuleWeeklyLimit = "weeklyLimit"
+)
+
+var methodNameRegexp = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*$`)
+
+// Policy is a declarative spending policy.
+type Policy struct {
+	Accounts map[common.Address]*AccountPolicy `json:"accounts"`
+}
+
+// AccountPolicy restricts the transactions signed for an account. Rules which
+// are not set impose no restriction.
+type AccountPolicy struct {
+	Approve     bool                  `json:"approve"`     // Approve compliant transactions without confirmation
+	DailyLimit  *math.HexOrDecimal256 `json:"dailyLimit"`  // Value in wei sent per UTC day
+	WeeklyLimit *math.HexOrDecimal256 `json:"weeklyLimit"` // Value in wei sent per week, starting on Monday 00:00 UTC
+	Recipients  []common.Address      `json:"recipients"`  // Allowed recipients, contract creations are disallowed if set
+	Methods     []string              `json:"methods"`     // Allowed methods of contract calls, by selector, signature or name
+	MaxGasPrice *math.HexOrDecimal256 `json:"maxGasPrice"` // Cap on the gas price and fee cap in wei
+
+	selectors [][]byte // Selectors of the allowed methods
+	names     []string // Names of the allowed methods, resolved through the 4byte database
+}
+
+// Violation describes how a transaction breaks a rule of the policy.
+type Violation struct {
+	Rule   string // Name of the broken rule
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("policy rule %s violated: %s", v.Rule, v.Reason)
+}
+
+// Parse decodes and validates a policy.
+func Parse(blob []byte) (*Policy, error) {
+	dec := json.NewDecoder(bytes.NewReader(blob))
+	dec.DisallowUnknownFields()
+
+	policy := new(Policy)
+	if err := dec.Decode(policy); err != nil {
+		return nil, err
+	}
+	for addr, account := range policy.Accounts {
+		if account == nil {
+			return nil, fmt.Errorf("account %v: missing policy", addr)
+		}
+		if err := account.init(); err != nil {
+			return nil, fmt.Errorf("account %v: %v", addr, err)
+		}
+	}
+	return policy, nil
+}
+
+// init validates the policy and compiles the method allowlist.
+func (a *AccountPolicy) init() error {
+	for name, value := range map[string]*math.HexOrDecimal256{
+		RuleDailyLimit:  a.DailyLimit,
+		RuleWeeklyLimit: a.WeeklyLimit,
+		RuleMaxGasPrice: a.MaxGasPrice,
+	} {
+		if value != nil && (*big.Int)(value).Sign() < 0 {
+			return fmt.Errorf("negative %s", name)
+		}
+	}
+	for _, method := range a.Methods {
+		method = strings.ReplaceAll(method, " ", "")
+		switch {
+		case strings.HasPrefix(method, "0x"):
+			id, err := hexutil.Decode(method)
+			if err != nil || len(id) != 4 {
+				return fmt.Errorf("invalid method selector %q", method)
+			}
+			a.selectors = append(a.selectors, id)
+
+		case strings.Contains(method, "("):
+			if !strings.HasSuffix(method, ")") || !methodNameRegexp.MatchString(method[:strings.Index(method, "(")]) {
+				return fmt.Errorf("invalid method signature %q", method)
+			}
+			a.selectors = append(a.selectors, crypto.Keccak256([]byte(method))[:4])
+
+		default:
+			if !methodNameRegexp.MatchString(method) {
+				return fmt.Errorf("invalid method name %q", method)
+			}
+			a.names = append(a.names, method)
+		}
+	}
+	return nil
+}
+
+// check returns the first rule the transaction breaks, if any. The daily and
+// weekly values are the amounts already sent in the current periods.
+func (a *AccountPolicy) check(args *apitypes.SendTxArgs, db *fourbyte.Database, daily, weekly *big.Int) *Violation {
+	if a.Recipients != nil {
+		if args.To == nil {
+			return &Violation{RuleRecipients, "contract creation not allowed"}
+		}
+		if !containsAddress(a.Recipients, args.To.Address()) {
+			return &Violation{RuleRecipients, fmt.Sprintf("recipient %v not allowed", args.To.Address())}
+		}
+	}
+	if a.Methods != nil && args.To != nil {
+		if data := callData(args); len(data) > 0 {
+			if v := a.checkMethod(data, db); v != nil {
+				return v
+			}
+		}
+	}
+	if a.MaxGasPrice != nil {
+		limit := (*big.Int)(a.MaxGasPrice)
+		for _, price := range []*hexutil.Big{args.GasPrice, args.MaxFeePerGas} {
+			if price != nil && price.ToInt().Cmp(limit) > 0 {
+				return &Violation{RuleMaxGasPrice, fmt.Sprintf("gas price %v exceeds cap %v", price.ToInt(), limit)}
+			}
+		}
+	}
+	value := args.Value.ToInt()
+	if v := checkLimit(RuleDailyLimit, a.DailyLimit, daily, value); v != nil {
+		return v
+	}
+	return checkLimit(RuleWeeklyLimit, a.WeeklyLimit, weekly, value)
+}
+
+// checkMethod checks the method called against the allowlist.
+func (a *AccountPolicy) checkMethod(data []byte, db *fourbyte.Database) *Violation {
+	if len(data) < 4 {
+		return &Violation{RuleMethods, "call data too short for a method selector"}
+	}
+	for _, id := range a.selectors {
+		if bytes.Equal(id, data[:4]) {
+			return nil
+		}
+	}
+	method := hexutil.Encode(data[:4])
+	if db != nil {
+		if signature, err := db.Selector(data[:4]); err == nil {
+			method = signature
+			name := signature
+			if i := strings.Index(signature, "("); i >= 0 {
+				name = signature[:i]
+			}
+			for _, allowed := range a.names {
+				if allowed == name {
+					return nil
+				}
+			}
+		}
+	}
+	return &Violation{RuleMethods, fmt.Sprintf("method %s not allowed", method)}
+}
+
+// checkLimit checks whether sending value keeps the amount sent within limit.
+func checkLimit(rule string, limit *math.HexOrDecimal256, spent, value *big.Int) *Violation {
+	if limit == nil || new(big.Int).Add(spent, value).Cmp((*big.Int)(limit)) <= 0 {
+		return nil
+	}
+	remaining := new(big.Int).Sub((*big.Int)(limit), spent)
+	if remaining.Sign() < 0 {
+		remaining.SetUint64(0)
+	}
+	return &Violation{rule, fmt.Sprintf("value %v exceeds remaining allowance %v", value, remaining)}
+}
+
+func callData(args *apitypes.SendTxArgs) []byte {
+	if args.Input != nil {
+		return *args.Input
+	}
+	if args.Data != nil {
+		return *args.Data
+	}
+	return nil
+}
+
+func containsAddress(list []common.Address, addr common.Address) bool {
+	for _, a := range list {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,362 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package policy
+
+import (
+	"crypto/ecdsa"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/ethereum/go-ethereum/signer/fourbyte"
+	"github.com/ethereum/go-ethereum/signer/storage"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testAllowed = common.HexToAddress("0x3de6a81d7bd8f7de0fbcb91f6e9ec07c5d2ddfc1")
+	testOther   = common.HexToAddress("0x1111111111111111111111111111111111111111")
+)
+
+// testUI is a UI which counts the transactions passed on to it, approving them
+// after applying the optional edit.
+type testUI struct {
+	approve  bool
+	edit     func(*apitypes.SendTxArgs)
+	requests int
+	signed   int
+}
+
+func (ui *testUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse, error) {
+	ui.requests++
+	tx := request.Transaction
+	if ui.edit != nil {
+		ui.edit(&tx)
+	}
+	return core.SignTxResponse{Transaction: tx, Approved: ui.approve}, nil
+}
+
+func (ui *testUI) ApproveSignData(request *core.SignDataRequest) (core.SignDataResponse, error) {
+	return core.SignDataResponse{Approved: false}, nil
+}
+
+func (ui *testUI) ApproveListing(request *core.ListRequest) (core.ListResponse, error) {
+	return core.ListResponse{}, nil
+}
+
+func (ui *testUI) ApproveNewAccount(request *core.NewAccountRequest) (core.NewAccountResponse, error) {
+	return core.NewAccountResponse{Approved: false}, nil
+}
+
+func (ui *testUI) ShowError(message string) {}
+
+func (ui *testUI) ShowInfo(message string) {}
+
+func (ui *testUI) OnApprovedTx(tx ethapi.SignTransactionResult) {
+	ui.signed++
+}
+
+func (ui *testUI) OnSignerStartup(info core.StartupInfo) {}
+
+func (ui *testUI) OnInputRequired(info core.UserInputRequest) (core.UserInputResponse, error) {
+	return core.UserInputResponse{}, nil
+}
+
+func (ui *testUI) RegisterUIServer(api *core.UIServerAPI) {}
+
+func newTestEvaluator(t *testing.T, blob string, ui core.UIClientAPI) *Evaluator {
+	t.Helper()
+
+	policy, err := Parse([]byte(blob))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	db, err := fourbyte.New()
+	if err != nil {
+		t.Fatalf("failed to load 4byte database: %v", err)
+	}
+	if err := db.AddSelector("approve(address,uint256)", common.FromHex("0x095ea7b3")); err != nil {
+		t.Fatalf("failed to add selector: %v", err)
+	}
+	e := NewEvaluator(ui, policy, db, storage.NewEphemeralStorage())
+	e.now = func() time.Time { return time.Date(2022, 6, 15, 12, 0, 0, 0, time.UTC) }
+	return e
+}
+
+func txRequest(from common.Address, to *common.Address, value int64, data string) *core.SignTxRequest {
+	args := apitypes.SendTxArgs{
+		From:     common.NewMixedcaseAddress(from),
+		Gas:      21000,
+		GasPrice: (*hexutil.Big)(big.NewInt(params.GWei)),
+		Value:    hexutil.Big(*big.NewInt(value)),
+	}
+	if to != nil {
+		mixed := common.NewMixedcaseAddress(*to)
+		args.To = &mixed
+	}
+	if data != "" {
+		input := hexutil.Bytes(common.FromHex(data))
+		args.Data = &input
+	}
+	return &core.SignTxRequest{Transaction: args}
+}
+
+// signedTx signs a transfer of the given value, as reported to OnApprovedTx.
+func signedTx(t *testing.T, key *ecdsa.PrivateKey, value int64) ethapi.SignTransactionResult {
+	t.Helper()
+
+	tx := types.NewTransaction(0, testAllowed, big.NewInt(value), 21000, big.NewInt(params.GWei), nil)
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(big.NewInt(1)), key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	return ethapi.SignTransactionResult{Tx: signed}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		blob string
+		err  string
+	}{
+		{blob: `{"accounts": {}}`},
+		{blob: `{"accounts": {"0x8a8eafb1cf62bfbeb1741769dae1a9dd47996192": {"methods": ["0xa9059cbb", "transfer(address, uint256)", "approve"]}}}`},
+		{blob: `{"accounts": {"0x8a8eafb1cf62bfbeb1741769dae1a9dd47996192": {"limit": "1"}}}`, err: "unknown field"},
+		{blob: `{"accounts": {"0x8a8eafb1cf62bfbeb1741769dae1a9dd47996192": null}}`, err: "missing policy"},
+		{blob: `{"accounts": {"0x8a8eafb1cf62bfbeb1741769dae1a9dd47996192": {"dailyLimit": "-1"}}}`, err: "negative dailyLimit"},
+		{blob: `{"accounts": {"0x8a8eafb1cf62bfbeb1741769dae1a9dd47996192": {"methods": ["0xa9059c"]}}}`, err: "invalid method selector"},
+		{blob: `{"accounts": {"0x8a8eafb1cf62bfbeb1741769dae1a9dd47996192": {"methods": ["transfer(address"]}}}`, err: "invalid method signature"},
+		{blob: `{"accounts": {"0x8a8eafb1cf62bfbeb1741769dae1a9dd47996192": {"methods": ["trans-fer"]}}}`, err: "invalid method name"},
+	}
+	for i, test := range tests {
+		_, err := Parse([]byte(test.blob))
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, test.err)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	policy := `{"accounts": {"` + testAddr.Hex() + `": {
+		"approve":     true,
+		"recipients":  ["` + testAllowed.Hex() + `"],
+		"methods":     ["0x70a08231", "transfer(address,uint256)", "approve"],
+		"maxGasPrice": "0x174876e800"
+	}}}`
+	e := newTestEvaluator(t, policy, &testUI{})
+
+	tests := []struct {
+		request *core.SignTxRequest
+		rule    string
+	}{
+		// Plain transfers to allowed and other recipients
+		{request: txRequest(testAddr, &testAllowed, 1, "")},
+		{request: txRequest(testAddr, &testOther, 1, ""), rule: RuleRecipients},
+		{request: txRequest(testAddr, nil, 0, "0x6080"), rule: RuleRecipients},
+		// Methods allowed by selector, signature and name
+		{request: txRequest(testAddr, &testAllowed, 0, "0x70a08231")},
+		{request: txRequest(testAddr, &testAllowed, 0, "0xa9059cbb00")},
+		{request: txRequest(testAddr, &testAllowed, 0, "0x095ea7b300")},
+		{request: txRequest(testAddr, &testAllowed, 0, "0x23b872dd00"), rule: RuleMethods},
+		{request: txRequest(testAddr, &testAllowed, 0, "0x23b8"), rule: RuleMethods},
+	}
+	for i, test := range tests {
+		response, err := e.ApproveTx(test.request)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if response.Approved != (test.rule == "") {
+			t.Errorf("test %d: approval mismatch: have %v, want %v", i, response.Approved, test.rule == "")
+		}
+	}
+	// Gas price and fee cap are both capped
+	request := txRequest(testAddr, &testAllowed, 1, "")
+	request.Transaction.GasPrice = (*hexutil.Big)(big.NewInt(100*params.GWei + 1))
+	if response, _ := e.ApproveTx(request); response.Approved {
+		t.Errorf("transaction above gas price cap approved")
+	}
+	request = txRequest(testAddr, &testAllowed, 1, "")
+	request.Transaction.GasPrice = nil
+	request.Transaction.MaxFeePerGas = (*hexutil.Big)(big.NewInt(100*params.GWei + 1))
+	if response, _ := e.ApproveTx(request); response.Approved {
+		t.Errorf("transaction above fee cap approved")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	policy := `{"accounts": {"` + testAddr.Hex() + `": {
+		"approve":     true,
+		"dailyLimit":  "100",
+		"weeklyLimit": "250"
+	}}}`
+	ui := &testUI{}
+	e := newTestEvaluator(t, policy, ui)
+	now := e.now()
+	e.now = func() time.Time { return now }
+
+	approve := func(value int64) bool {
+		response, err := e.ApproveTx(txRequest(testAddr, &testAllowed, value, ""))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return response.Approved
+	}
+	if !approve(60) {
+		t.Fatalf("transaction within daily limit rejected")
+	}
+	if approve(41) {
+		t.Fatalf("transaction above remaining daily limit approved")
+	}
+	if !approve(40) {
+		t.Fatalf("transaction within remaining daily limit rejected")
+	}
+	// The daily limit resets on the next day, the weekly one keeps counting
+	now = now.Add(24 * time.Hour)
+	if !approve(100) {
+		t.Fatalf("transaction within daily limit rejected on the next day")
+	}
+	now = now.Add(24 * time.Hour)
+	if approve(51) {
+		t.Fatalf("transaction above remaining weekly limit approved")
+	}
+	// Both reset on the next Monday
+	now = time.Date(2022, 6, 20, 0, 0, 0, 0, time.UTC)
+	if !approve(100) {
+		t.Fatalf("transaction within limits rejected in the next week")
+	}
+	// Transactions of other accounts are passed on
+	e.ApproveTx(txRequest(testOther, &testAllowed, 100, ""))
+	if ui.requests != 1 {
+		t.Fatalf("transaction of other account not passed on")
+	}
+	// Signed transactions are passed on
+	e.OnApprovedTx(signedTx(t, testKey, 100))
+	if ui.signed != 1 {
+		t.Errorf("signed transactions not passed on: have %d, want %d", ui.signed, 1)
+	}
+}
+
+// Tests that the value of a transaction which fails to get signed is released.
+func TestLimitsRelease(t *testing.T) {
+	policy := `{"accounts": {"` + testAddr.Hex() + `": {"approve": true, "dailyLimit": "100"}}}`
+	e := newTestEvaluator(t, policy, &testUI{})
+
+	request := txRequest(testAddr, &testAllowed, 100, "")
+	if response, _ := e.ApproveTx(request); !response.Approved {
+		t.Fatalf("transaction within daily limit rejected")
+	}
+	if response, _ := e.ApproveTx(txRequest(testAddr, &testAllowed, 1, "")); response.Approved {
+		t.Fatalf("transaction above daily limit approved")
+	}
+	e.OnSignTxFailed(request.Transaction)
+	if response, _ := e.ApproveTx(txRequest(testAddr, &testAllowed, 100, "")); !response.Approved {
+		t.Fatalf("transaction within released limit rejected")
+	}
+}
+
+// Tests that concurrent approvals can't exceed the limits together.
+func TestLimitsConcurrent(t *testing.T) {
+	policy := `{"accounts": {"` + testAddr.Hex() + `": {"approve": true, "dailyLimit": "100"}}}`
+	e := newTestEvaluator(t, policy, &testUI{})
+
+	var (
+		wg       sync.WaitGroup
+		approved int32
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if response, _ := e.ApproveTx(txRequest(testAddr, &testAllowed, 10, "")); response.Approved {
+				atomic.AddInt32(&approved, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if approved != 10 {
+		t.Fatalf("approved transaction count mismatch: have %d, want %d", approved, 10)
+	}
+}
+
+func TestManualApproval(t *testing.T) {
+	policy := `{"accounts": {"` + testAddr.Hex() + `": {
+		"recipients": ["` + testAllowed.Hex() + `"]
+	}}}`
+	ui := &testUI{approve: true}
+	e := newTestEvaluator(t, policy, ui)
+
+	// Compliant transactions are passed on for confirmation
+	if response, _ := e.ApproveTx(txRequest(testAddr, &testAllowed, 1, "")); !response.Approved || ui.requests != 1 {
+		t.Fatalf("compliant transaction not confirmed manually: approved %v, requests %d", response.Approved, ui.requests)
+	}
+	// Violations are rejected without asking
+	if response, _ := e.ApproveTx(txRequest(testAddr, &testOther, 1, "")); response.Approved || ui.requests != 1 {
+		t.Fatalf("violating transaction not rejected: approved %v, requests %d", response.Approved, ui.requests)
+	}
+	// Transactions edited during confirmation are checked again
+	ui.edit = func(args *apitypes.SendTxArgs) {
+		to := common.NewMixedcaseAddress(testOther)
+		args.To = &to
+	}
+	if response, _ := e.ApproveTx(txRequest(testAddr, &testAllowed, 1, "")); response.Approved {
+		t.Fatalf("transaction edited into a violation approved")
+	}
+	// Accounts without a policy are left to the next handler
+	ui.edit = nil
+	if response, _ := e.ApproveTx(txRequest(testOther, &testOther, 1, "")); !response.Approved || ui.requests != 3 {
+		t.Fatalf("transaction without policy not passed on: approved %v, requests %d", response.Approved, ui.requests)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	policy := `{"accounts": {"` + testAddr.Hex() + `": {"dailyLimit": "100"}}}`
+	e := newTestEvaluator(t, policy, &testUI{})
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := core.NewAuditLogger(path, nil)
+	if err != nil {
+		t.Fatalf("failed to create audit log: %v", err)
+	}
+	e.SetAuditLog(auditLog)
+
+	if response, _ := e.ApproveTx(txRequest(testAddr, &testAllowed, 101, "")); response.Approved {
+		t.Fatalf("transaction above daily limit approved")
+	}
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	if !strings.Contains(string(blob), "type=rejection") || !strings.Contains(string(blob), "rule=dailyLimit") {
+		t.Errorf("rejection missing from audit log:\n%s", blob)
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package policy
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/ethereum/go-ethereum/signer/fourbyte"
+	"github.com/ethereum/go-ethereum/signer/storage"
+)
+
+// spending is the value an account sent within the current periods, as kept in
+// the counter storage.
+type spending struct {
+	Day    uint64       `json:"day"` // Days since the unix epoch
+	Daily  *hexutil.Big `json:"daily"`
+	Week   uint64       `json:"week"` // Weeks since the Monday before the unix epoch
+	Weekly *hexutil.Big `json:"weekly"`
+}
+
+// periods returns the day and week the given time falls into.
+func periods(now time.Time) (day, week uint64) {
+	day = uint64(now.Unix()) / 86400
+	return day, (day + 3) / 7 // The unix epoch was a Thursday
+}
+
+// Evaluator provides an implementation of UIClientAPI that checks transactions
+// against a spending policy, before passing them on to the next handler.
+//
+// The value of an approved transaction is counted against the limits right away,
+// so concurrent requests can't exceed them, and released if it fails to get
+// signed.
+type Evaluator struct {
+	next     core.UIClientAPI   // The next handler, for manual processing
+	policy   *Policy            // The policy to enforce
+	db       *fourbyte.Database // Database to resolve method selectors with
+	counters storage.Storage    // Storage of the spending counters
+	auditLog *core.AuditLogger  // Log to record rejections in, if any
+
+	now  func() time.Time
+	lock sync.Mutex
+}
+
+// NewEvaluator creates a policy evaluator keeping its spending counters in the
+// given storage. The 4byte database may be nil, disabling the allowlisting of
+// methods by name.
+func NewEvaluator(next core.UIClientAPI, policy *Policy, db *fourbyte.Database, counters storage.Storage) *Evaluator {
+	return &Evaluator{
+		next:     next,
+		policy:   policy,
+		db:       db,
+		counters: counters,
+		now:      time.Now,
+	}
+}
+
+// SetAuditLog sets the audit log to record rejected transactions in.
+func (e *Evaluator) SetAuditLog(auditLog *core.AuditLogger) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.auditLog = auditLog
+}
+
+func (e *Evaluator) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse, error) {
+	account := e.policy.Accounts[request.Transaction.From.Address()]
+	if account == nil {
+		return e.next.ApproveTx(request)
+	}
+	if account.Approve {
+		if v := e.check(account, &request.Transaction, true); v != nil {
+			return e.reject(request, request.Transaction, v), nil
+		}
+		log.Info("Transaction approved by policy", "from", request.Transaction.From.Address())
+		return core.SignTxResponse{Transaction: request.Transaction, Approved: true}, nil
+	}
+	if v := e.check(account, &request.Transaction, false); v != nil {
+		return e.reject(request, request.Transaction, v), nil
+	}
+	// The transaction may be modified during manual processing, check it again
+	response, err := e.next.ApproveTx(request)
+	if err != nil || !response.Approved {
+		return response, err
+	}
+	if v := e.check(account, &response.Transaction, true); v != nil {
+		return e.reject(request, response.Transaction, v), nil
+	}
+	return response, nil
+}
+
+// check checks a transaction against the policy of its account. If it complies
+// and reserve is set, its value is added to the spending counters under the same
+// lock.
+func (e *Evaluator) check(account *AccountPolicy, args *apitypes.SendTxArgs, reserve bool) *Violation {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	addr := args.From.Address()
+	spent, err := e.spending(addr)
+	if err != nil {
+		log.Warn("Failed to load spending counters", "account", addr, "err", err)
+		if account.DailyLimit != nil {
+			return &Violation{RuleDailyLimit, "spending counters unavailable"}
+		}
+		if account.WeeklyLimit != nil {
+			return &Violation{RuleWeeklyLimit, "spending counters unavailable"}
+		}
+	}
+	if v := account.check(args, e.db, spent.Daily.ToInt(), spent.Weekly.ToInt()); v != nil {
+		return v
+	}
+	if value := args.Value.ToInt(); reserve && err == nil && value.Sign() > 0 {
+		e.store(addr, spent, value)
+	}
+	return nil
+}
+
+// reject records a rejected transaction.
+func (e *Evaluator) reject(request *core.SignTxRequest, args apitypes.SendTxArgs, v *Violation) core.SignTxResponse {
+	log.Info("Transaction rejected by policy", "from", args.From.Address(), "rule", v.Rule, "reason", v.Reason)
+
+	e.lock.Lock()
+	auditLog := e.auditLog
+	e.lock.Unlock()
+
+	if auditLog != nil {
+		auditLog.PolicyRejection(request.Meta, args, v.Rule, v.Reason)
+	}
+	return core.SignTxResponse{Approved: false}
+}
+
+// spending loads the counters of an account for the current periods.
+func (e *Evaluator) spending(addr common.Address) (spending, error) {
+	day, week := periods(e.now())
+	spent := spending{Day: day, Daily: new(hexutil.Big), Week: week, Weekly: new(hexutil.Big)}
+
+	blob, err := e.counters.Get(spendingKey(addr))
+	if err == storage.ErrNotFound {
+		return spent, nil
+	}
+	if err != nil {
+		return spent, err
+	}
+	var stored spending
+	if err := json.Unmarshal([]byte(blob), &stored); err != nil {
+		return spent, err
+	}
+	if stored.Day == day && stored.Daily != nil {
+		spent.Daily = stored.Daily
+	}
+	if stored.Week == week && stored.Weekly != nil {
+		spent.Weekly = stored.Weekly
+	}
+	return spent, nil
+}
+
+// release subtracts the value of a transaction which failed to get signed from
+// the current counters of an account.
+func (e *Evaluator) release(addr common.Address, value *big.Int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	spent, err := e.spending(addr)
+	if err != nil {
+		log.Warn("Failed to load spending counters", "account", addr, "err", err)
+		return
+	}
+	e.store(addr, spent, new(big.Int).Neg(value))
+}
+
+// store adds a delta to the given counters of an account, without going below
+// zero, and saves them.
+//
+// Note, this method assumes that the evaluator's lock is held!
+func (e *Evaluator) store(addr common.Address, spent spending, delta *big.Int) {
+	add := func(counter *hexutil.Big) *hexutil.Big {
+		sum := new(big.Int).Add(counter.ToInt(), delta)
+		if sum.Sign() < 0 {
+			sum.SetUint64(0)
+		}
+		return (*hexutil.Big)(sum)
+	}
+	spent.Daily, spent.Weekly = add(spent.Daily), add(spent.Weekly)
+
+	blob, err := json.Marshal(spent)
+	if err != nil {
+		log.Warn("Failed to encode spending counters", "account", addr, "err", err)
+		return
+	}
+	e.counters.Put(spendingKey(addr), string(blob))
+}
+
+func spendingKey(addr common.Address) string {
+	return "spending_" + addr.Hex()
+}
+
+func (e *Evaluator) OnApprovedTx(tx ethapi.SignTransactionResult) {
+	e.next.OnApprovedTx(tx)
+}
+
+// OnSignTxFailed releases the value reserved for a transaction approved by the
+// policy, as it failed to get signed.
+func (e *Evaluator) OnSignTxFailed(tx apitypes.SendTxArgs) {
+	if value := tx.Value.ToInt(); value.Sign() > 0 && e.policy.Accounts[tx.From.Address()] != nil {
+		e.release(tx.From.Address(), value)
+	}
+	if handler, ok := e.next.(core.SignTxFailureHandler); ok {
+		handler.OnSignTxFailed(tx)
+	}
+}
+
+func (e *Evaluator) ApproveSignData(request *core.SignDataRequest) (core.SignDataResponse, error) {
+	return e.next.ApproveSignData(request)
+}
+
+func (e *Evaluator) ApproveListing(request *core.ListRequest) (core.ListResponse, error) {
+	return e.next.ApproveListing(request)
+}
+
+func (e *Evaluator) ApproveNewAccount(request *core.NewAccountRequest) (core.NewAccountResponse, error) {
+	return e.next.ApproveNewAccount(request)
+}
+
+func (e *Evaluator) ShowError(message string) {
+	e.next.ShowError(message)
+}
+
+func (e *Evaluator) ShowInfo(message string) {
+	e.next.ShowInfo(message)
+}
+
+func (e *Evaluator) OnSignerStartup(info core.StartupInfo) {
+	e.next.OnSignerStartup(info)
+}
+
+func (e *Evaluator) OnInputRequired(info core.UserInputRequest) (core.UserInputResponse, error) {
+	return e.next.OnInputRequired(info)
+}
+
+func (e *Evaluator) RegisterUIServer(api *core.UIServerAPI) {
+	e.next.RegisterUIServer(api)
+}
@@ -35,6 +35,7 @@ type leaf struct {
 	size int         // size of the rlp data (estimate)
 	hash common.Hash // hash of rlp data
 	node node        // the node to commit
+	path []byte      // the path of the node in the trie
 }
 
 // committer is a type used for the trie Commit operation. A committer has some
@@ -47,6 +48,8 @@ type committer struct {
 	tmp sliceBuffer
 	sha crypto.KeccakState
 
+	owner  common.Hash // Owner of the trie being committed
+	nodes  *NodeSet    // Set collecting the nodes in the path-based scheme, nil if hash based
 	onleaf LeafCallback
 	leafCh chan *leaf
 }
@@ -67,6 +70,8 @@ func newCommitter() *committer {
 }
 
 func returnCommitterToPool(h *committer) {
+	h.owner = common.Hash{}
+	h.nodes = nil
 	h.onleaf = nil
 	h.leafCh = nil
 	committerPool.Put(h)
@@ -77,7 +82,7 @@ func (c *committer) Commit(n node, db *Database) (hashNode, int, error) {
 	if db == nil {
 		return nil, 0, errors.New("no db provided")
 	}
-	h, committed, err := c.commit(n, db)
+	h, committed, err := c.commit(nil, n, db)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -85,7 +90,7 @@ func (c *committer) Commit(n node, db *Database) (hashNode, int, error) {
 }
 
 // commit collapses a node down into a hash node and inserts it into the database
-func (c *committer) commit(n node, db *Database) (node, int, error) {
+func (c *committer) commit(path []byte, n node, db *Database) (node, int, error) {
 	// if this path is clean, use available cached data
 	hash, dirty := n.cache()
 	if hash != nil && !dirty {
@@ -101,7 +106,7 @@ func (c *committer) commit(n node, db *Database) (node, int, error) {
 		// otherwise it can only be hashNode or valueNode.
 		var childCommitted int
 		if _, ok := cn.Val.(*fullNode); ok {
-			childV, committed, err := c.commit(cn.Val, db)
+			childV, committed, err := c.commit(concat(path, cn.Key...), cn.Val, db)
 			if err != nil {
 				return nil, 0, err
 			}
@@ -109,20 +114,20 @@ func (c *committer) commit(n node, db *Database) (node, int, error) {
 		}
 		// The key needs to be copied, since we're delivering it to database
 		collapsed.Key = hexToCompact(cn.Key)
-		hashedNode := c.store(collapsed, db)
+		hashedNode := c.store(path, collapsed, db)
 		if hn, ok := hashedNode.(hashNode); ok {
 			return hn, childCommitted + 1, nil
 		}
 		return collapsed, childCommitted, nil
 	case *fullNode:
-		hashedKids, childCommitted, err := c.commitChildren(cn, db)
+		hashedKids, childCommitted, err := c.commitChildren(path, cn, db)
 		if err != nil {
 			return nil, 0, err
 		}
 		collapsed := cn.copy()
 		collapsed.Children = hashedKids
 
-		hashedNode := c.store(collapsed, db)
+		hashedNode := c.store(path, collapsed, db)
 		if hn, ok := hashedNode.(hashNode); ok {
 			return hn, childCommitted + 1, nil
 		}
@@ -136,7 +141,7 @@ func (c *committer) commit(n node, db *Database) (node, int, error) {
 }
 
 // commitChildren commits the children of the given fullnode
-func (c *committer) commitChildren(n *fullNode, db *Database) ([17]node, int, error) {
+func (c *committer) commitChildren(path []byte, n *fullNode, db *Database) ([17]node, int, error) {
 	var (
 		committed int
 		children  [17]node
@@ -156,7 +161,7 @@ func (c *committer) commitChildren(n *fullNode, db *Database) ([17]node, int, er
 		// Commit the child recursively and store the "hashed" value.
 		// Note the returned node can be some embedded nodes, so it's
 		// possible the type is not hashNode.
-		hashed, childCommitted, err := c.commit(child, db)
+		hashed, childCommitted, err := c.commit(concat(path, byte(i)), child, db)
 		if err != nil {
 			return children, 0, err
 		}
@@ -173,7 +178,7 @@ func (c *committer) commitChildren(n *fullNode, db *Database) ([17]node, int, er
 // store hashes the node n and if we have a storage layer specified, it writes
 // the key/value pair to it and tracks any node->child references as well as any
 // node->external trie references.
-func (c *committer) store(n node, db *Database) node {
+func (c *committer) store(path []byte, n node, db *Database) node {
 	// Larger nodes are replaced by their hash and stored in the database.
 	var (
 		hash, _ = n.cache()
@@ -183,7 +188,11 @@ func (c *committer) store(n node, db *Database) node {
 		// This was not generated - must be a small node stored in the parent.
 		// In theory, we should apply the leafCall here if it's not nil(embedded
 		// node usually contains value). But small value(less than 32bytes) is
-		// not our target.
+		// not our target. In the path-based scheme, a node previously stored
+		// on its own at the same path is superseded by the embedded one.
+		if c.nodes != nil {
+			c.nodes.remove(c.owner, path)
+		}
 		return n
 	} else {
 		// We have the hash already, estimate the RLP encoding-size of the node.
@@ -197,17 +206,28 @@ func (c *committer) store(n node, db *Database) node {
 			size: size,
 			hash: common.BytesToHash(hash),
 			node: n,
+			path: path,
 		}
 	} else if db != nil {
 		// No leaf-callback used, but there's still a database. Do serial
 		// insertion
-		db.lock.Lock()
-		db.insert(common.BytesToHash(hash), size, n)
-		db.lock.Unlock()
+		c.insert(db, path, common.BytesToHash(hash), size, n)
 	}
 	return hash
 }
 
+// insert adds a collapsed trie node to the node set of the commit in the
+// path-based scheme, or to the memory database in the hash-based one.
+func (c *committer) insert(db *Database, path []byte, hash common.Hash, size int, n node) {
+	if c.nodes != nil {
+		c.nodes.insert(c.owner, path, hash, n)
+		return
+	}
+	db.lock.Lock()
+	db.insert(hash, size, n)
+	db.lock.Unlock()
+}
+
 // commitLoop does the actual insert + leaf callback for nodes.
 func (c *committer) commitLoop(db *Database) {
 	for item := range c.leafCh {
@@ -217,9 +237,7 @@ func (c *committer) commitLoop(db *Database) {
 			n    = item.node
 		)
 		// We are pooling the trie nodes into an intermediate memory cache
-		db.lock.Lock()
-		db.insert(hash, size, n)
-		db.lock.Unlock()
+		c.insert(db, item.path, hash, size, n)
 
 		if c.onleaf != nil {
 			switch n := n.(type) {
@@ -17,21 +17,25 @@
 package trie
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"reflect"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/fastcache"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
@@ -67,6 +71,9 @@ var (
 // behind this split design is to provide read access to RPC handlers and sync
 // servers even while the trie is executing expensive garbage collection.
 type Database struct {
+	cleanHits   uint64 // Number of clean cache hits (atomic access, keep 64-bit aligned)
+	cleanMisses uint64 // Number of clean cache misses (atomic access, keep 64-bit aligned)
+
 	diskdb ethdb.KeyValueStore // Persistent storage for matured trie nodes
 
 	cleans  *fastcache.Cache            // GC friendly memory cache of clean node RLPs
@@ -88,6 +95,10 @@ type Database struct {
 	childrenSize  common.StorageSize // Storage size of the external children tracking
 	preimagesSize common.StorageSize // Storage size of the preimages cache
 
+	path *pathDB // Layered node store of the path-based scheme, nil if hash based
+
+	flushHook func(common.Hash) // Callback invoked for every node before it's flushed to disk
+
 	lock sync.RWMutex
 }
 
@@ -274,9 +285,11 @@ func expandNode(hash hashNode, n node) node {
 
 // Config defines all necessary options for database.
 type Config struct {
-	Cache     int    // Memory allowance (MB) to use for caching trie nodes in memory
-	Journal   string // Journal of clean cache to survive node restarts
-	Preimages bool   // Flag whether the preimage of trie key is recorded
+	Cache        int    // Memory allowance (MB) to use for caching trie nodes in memory
+	Journal      string // Journal of clean cache to survive node restarts
+	Preimages    bool   // Flag whether the preimage of trie key is recorded
+	Scheme       string // Storage scheme of the trie nodes, hash based if empty
+	StateHistory uint64 // Number of recent states to keep reverse diffs for in the path scheme (0 = all)
 }
 
 // NewDatabase creates a new trie database to store ephemeral trie content before
@@ -308,6 +321,9 @@ func NewDatabaseWithConfig(diskdb ethdb.KeyValueStore, config *Config) *Database
 	if config == nil || config.Preimages { // TODO(karalabe): Flip to default off in the future
 		db.preimages = make(map[common.Hash][]byte)
 	}
+	if config != nil && config.Scheme == rawdb.PathScheme {
+		db.path = newPathDB(diskdb, config.StateHistory)
+	}
 	return db
 }
 
@@ -316,6 +332,24 @@ func (db *Database) DiskDB() ethdb.KeyValueStore {
 	return db.diskdb
 }
 
+// SetFlushHook installs a callback invoked for every trie node right before it
+// is flushed into the disk database, or removes it if nil. Flushes which are
+// already in progress might not report to a newly installed hook.
+func (db *Database) SetFlushHook(hook func(hash common.Hash)) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.flushHook = hook
+}
+
+// Scheme returns the storage scheme of the trie nodes.
+func (db *Database) Scheme() string {
+	if db.path != nil {
+		return rawdb.PathScheme
+	}
+	return rawdb.HashScheme
+}
+
 // insert inserts a collapsed trie node into the memory database.
 // The blob size must be specified to allow proper size tracking.
 // All nodes inserted by this function will be reference tracked
@@ -374,6 +408,7 @@ func (db *Database) node(hash common.Hash) node {
 	if db.cleans != nil {
 		if enc := db.cleans.Get(nil, hash[:]); enc != nil {
 			memcacheCleanHitMeter.Mark(1)
+			atomic.AddUint64(&db.cleanHits, 1)
 			memcacheCleanReadMeter.Mark(int64(len(enc)))
 			return mustDecodeNode(hash[:], enc)
 		}
@@ -398,6 +433,7 @@ func (db *Database) node(hash common.Hash) node {
 	if db.cleans != nil {
 		db.cleans.Set(hash[:], enc)
 		memcacheCleanMissMeter.Mark(1)
+		atomic.AddUint64(&db.cleanMisses, 1)
 		memcacheCleanWriteMeter.Mark(int64(len(enc)))
 	}
 	return mustDecodeNode(hash[:], enc)
@@ -414,6 +450,7 @@ func (db *Database) Node(hash common.Hash) ([]byte, error) {
 	if db.cleans != nil {
 		if enc := db.cleans.Get(nil, hash[:]); enc != nil {
 			memcacheCleanHitMeter.Mark(1)
+			atomic.AddUint64(&db.cleanHits, 1)
 			memcacheCleanReadMeter.Mark(int64(len(enc)))
 			return enc, nil
 		}
@@ -436,6 +473,7 @@ func (db *Database) Node(hash common.Hash) ([]byte, error) {
 		if db.cleans != nil {
 			db.cleans.Set(hash[:], enc)
 			memcacheCleanMissMeter.Mark(1)
+			atomic.AddUint64(&db.cleanMisses, 1)
 			memcacheCleanWriteMeter.Mark(int64(len(enc)))
 		}
 		return enc, nil
@@ -597,6 +635,10 @@ func (db *Database) Cap(limit common.StorageSize) error {
 	nodes, storage, start := len(db.dirties), db.dirtiesSize, time.Now()
 	batch := db.diskdb.NewBatch()
 
+	db.lock.RLock()
+	hook := db.flushHook
+	db.lock.RUnlock()
+
 	// db.dirtiesSize only contains the useful data in the cache, but when reporting
 	// the total memory consumption, the maintenance metadata is also needed to be
 	// counted.
@@ -624,6 +666,9 @@ func (db *Database) Cap(limit common.StorageSize) error {
 	for size > limit && oldest != (common.Hash{}) {
 		// Fetch the oldest referenced node and push into the batch
 		node := db.dirties[oldest]
+		if hook != nil {
+			hook(oldest)
+		}
 		rawdb.WriteTrieNode(batch, oldest, node.rlp())
 
 		// If we exceeded the ideal batch size, commit and reset
@@ -693,6 +738,19 @@ func (db *Database) Cap(limit common.StorageSize) error {
 // Note, this method is a non-synchronized mutator. It is unsafe to call this
 // concurrently with other mutators.
 func (db *Database) Commit(node common.Hash, report bool, callback func(common.Hash)) error {
+	return db.CommitContext(context.Background(), node, report, callback)
+}
+
+// CommitContext is like Commit, but traces the commit as a child of the span
+// carried by ctx.
+func (db *Database) CommitContext(ctx context.Context, node common.Hash, report bool, callback func(common.Hash)) (err error) {
+	_, span := tracing.Start(ctx, "trie.Commit", attribute.String("trie.root", node.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	// In the path-based scheme, flatten all the layers up to the given state
+	if db.path != nil {
+		return db.commitLayers(node, report)
+	}
 	// Create a database batch to flush persistent data out. It is important that
 	// outside code doesn't see an inconsistent state (referenced data removed from
 	// memory cache during commit but not yet in persistent storage). This is ensured
@@ -713,8 +771,12 @@ func (db *Database) Commit(node common.Hash, report bool, callback func(common.H
 	// Move the trie itself into the batch, flushing if enough data is accumulated
 	nodes, storage := len(db.dirties), db.dirtiesSize
 
+	db.lock.RLock()
+	hook := db.flushHook
+	db.lock.RUnlock()
+
 	uncacher := &cleaner{db}
-	if err := db.commit(node, batch, uncacher, callback); err != nil {
+	if err := db.commit(node, batch, uncacher, hook, callback); err != nil {
 		log.Error("Failed to commit trie from trie database", "err", err)
 		return err
 	}
@@ -737,6 +799,10 @@ func (db *Database) Commit(node common.Hash, report bool, callback func(common.H
 	memcacheCommitTimeTimer.Update(time.Since(start))
 	memcacheCommitSizeMeter.Mark(int64(storage - db.dirtiesSize))
 	memcacheCommitNodesMeter.Mark(int64(nodes - len(db.dirties)))
+	span.SetAttributes(
+		attribute.Int("trie.nodes", nodes-len(db.dirties)),
+		attribute.Int64("trie.size", int64(storage-db.dirtiesSize)),
+	)
 
 	logger := log.Info
 	if !report {
@@ -753,7 +819,7 @@ func (db *Database) Commit(node common.Hash, report bool, callback func(common.H
 }
 
 // commit is the private locked version of Commit.
-func (db *Database) commit(hash common.Hash, batch ethdb.Batch, uncacher *cleaner, callback func(common.Hash)) error {
+func (db *Database) commit(hash common.Hash, batch ethdb.Batch, uncacher *cleaner, hook func(common.Hash), callback func(common.Hash)) error {
 	// If the node does not exist, it's a previously committed node
 	node, ok := db.dirties[hash]
 	if !ok {
@@ -762,13 +828,16 @@ func (db *Database) commit(hash common.Hash, batch ethdb.Batch, uncacher *cleane
 	var err error
 	node.forChilds(func(child common.Hash) {
 		if err == nil {
-			err = db.commit(child, batch, uncacher, callback)
+			err = db.commit(child, batch, uncacher, hook, callback)
 		}
 	})
 	if err != nil {
 		return err
 	}
 	// If we've reached an optimal batch size, commit and start over
+	if hook != nil {
+		hook(hash)
+	}
 	rawdb.WriteTrieNode(batch, hash, node.rlp())
 	if callback != nil {
 		callback(hash)
@@ -840,6 +909,9 @@ func (db *Database) Size() (common.StorageSize, common.StorageSize) {
 	db.lock.RLock()
 	defer db.lock.RUnlock()
 
+	if db.path != nil {
+		return db.path.layersSize, db.preimagesSize
+	}
 	// db.dirtiesSize only contains the useful data in the cache, but when reporting
 	// the total memory consumption, the maintenance metadata is also needed to be
 	// counted.
@@ -848,6 +920,12 @@ func (db *Database) Size() (common.StorageSize, common.StorageSize) {
 	return db.dirtiesSize + db.childrenSize + metadataSize - metarootRefs, db.preimagesSize
 }
 
+// CleanCacheStats returns the number of hits and misses of the clean node cache
+// since the database was created.
+func (db *Database) CleanCacheStats() (hits uint64, misses uint64) {
+	return atomic.LoadUint64(&db.cleanHits), atomic.LoadUint64(&db.cleanMisses)
+}
+
 // saveCache saves clean state cache to given directory path
 // using specified CPU cores.
 func (db *Database) saveCache(dir string, threads int) error {
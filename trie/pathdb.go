@@ -0,0 +1,565 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// errStateNotRecoverable is returned if a state can't be restored, either as it
+// was never persisted or as its reverse diffs were already pruned.
+var errStateNotRecoverable = errors.New("state is not recoverable")
+
+// errPathCommit is returned if a trie of the path-based scheme is committed
+// without collecting its nodes into a set.
+var errPathCommit = errors.New("path-based trie must be committed with CommitNodes")
+
+// In the path-based scheme, trie nodes are stored in the disk keyed by the owner
+// of the trie (the account hash of storage tries, zero for the account trie) and
+// their path within it. As nodes get overwritten in place, only a single state is
+// persisted, stale nodes never accumulating. Since a path is shared by all the
+// versions of a node, lookups always verify the hash of the retrieved one.
+//
+// Recent states are kept in memory as diff layers on top of the persisted one,
+// each holding the nodes committed by a state transition. Layers are flattened
+// into the disk once they fall out of the retention window, recording the node
+// values they overwrite as a reverse diff, which allows reverting the persisted
+// state to its parent for a limited number of steps back.
+//
+// Nodes removed from a trie are tracked as deletion markers, and the storage tries
+// of destructed accounts are wiped as a whole, so that the disk only ever holds
+// the nodes of the persisted state. Both are journaled in the reverse diffs too.
+
+// pathNode is a trie node stored in the path-based scheme, along with its hash.
+// A node with an empty blob marks the removal of the one stored at its path.
+type pathNode struct {
+	hash common.Hash
+	blob []byte
+}
+
+// NodeSet is the set of trie nodes committed by the tries of a state in the
+// path-based scheme, keyed by the owner of the trie and the path of the node.
+// Every commit collects its nodes into a set of its own, so that concurrent
+// commits of different states never mix. The sets of all the tries of a state
+// are merged and sealed into a layer by Database.Update.
+type NodeSet struct {
+	lock  sync.Mutex
+	nodes map[common.Hash]map[string]*pathNode
+	size  common.StorageSize // Approximate memory size of the nodes
+}
+
+// NewNodeSet creates an empty node set.
+func NewNodeSet() *NodeSet {
+	return &NodeSet{nodes: make(map[common.Hash]map[string]*pathNode)}
+}
+
+// insert adds a collapsed trie node to the set.
+func (s *NodeSet) insert(owner common.Hash, path []byte, hash common.Hash, n node) {
+	blob, err := rlp.EncodeToBytes(n)
+	if err != nil {
+		panic(err)
+	}
+	s.set(owner, path, &pathNode{hash: hash, blob: blob})
+}
+
+// remove marks the node at the given path as deleted in the set.
+func (s *NodeSet) remove(owner common.Hash, path []byte) {
+	s.set(owner, path, &pathNode{})
+}
+
+// set inserts a node into the set, replacing any previous one at the same path.
+func (s *NodeSet) set(owner common.Hash, path []byte, n *pathNode) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	subset := s.nodes[owner]
+	if subset == nil {
+		subset = make(map[string]*pathNode)
+		s.nodes[owner] = subset
+	}
+	if prev := subset[string(path)]; prev != nil {
+		s.size -= common.StorageSize(len(path) + len(prev.blob) + common.HashLength)
+	}
+	subset[string(path)] = n
+	s.size += common.StorageSize(len(path) + len(n.blob) + common.HashLength)
+
+	memcacheDirtyWriteMeter.Mark(int64(len(n.blob)))
+}
+
+// Merge adds the nodes of another set, replacing the ones at the same paths. It
+// is a noop if other is nil.
+func (s *NodeSet) Merge(other *NodeSet) {
+	if other == nil {
+		return
+	}
+	other.lock.Lock()
+	defer other.lock.Unlock()
+
+	for owner, subset := range other.nodes {
+		for path, n := range subset {
+			s.set(owner, []byte(path), n)
+		}
+	}
+}
+
+// Size returns the approximate memory size of the nodes in the set.
+func (s *NodeSet) Size() common.StorageSize {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.size
+}
+
+// diffLayer is the set of trie nodes written by a state transition, kept in
+// memory on top of its parent state.
+type diffLayer struct {
+	root      common.Hash                          // Root hash of the state after the transition
+	parent    common.Hash                          // Root hash of the state the layer is built on
+	id        uint64                               // Sequential id of the state, one above its parent
+	destructs map[common.Hash]struct{}             // Accounts whose storage tries got wiped
+	nodes     map[common.Hash]map[string]*pathNode // Trie nodes written, keyed by owner and path
+	size      common.StorageSize                   // Approximate memory size of the nodes
+}
+
+// journalNode is the value of a trie node before it was overwritten in the disk,
+// an empty blob meaning that the node didn't exist.
+type journalNode struct {
+	Owner common.Hash
+	Path  []byte
+	Prev  []byte
+}
+
+// reverseDiff is the set of trie nodes overwritten when persisting a state, which
+// can be applied to revert the disk to the parent state.
+type reverseDiff struct {
+	Parent common.Hash
+	Root   common.Hash
+	Nodes  []journalNode
+}
+
+// pathDB is the layered node store of the path-based scheme.
+type pathDB struct {
+	history  uint64      // Number of recent states to keep reverse diffs for (0 = all)
+	diskRoot common.Hash // Root hash of the persisted state
+	diskID   uint64      // Sequential id of the persisted state
+
+	layers     map[common.Hash]*diffLayer // In-memory states on top of the persisted one
+	layersSize common.StorageSize         // Approximate memory size of the layers
+}
+
+// newPathDB creates the layered node store on top of the state persisted in the
+// given disk database.
+func newPathDB(diskdb ethdb.KeyValueStore, history uint64) *pathDB {
+	root := emptyRoot
+	if blob := rawdb.ReadAccountTrieNode(diskdb, nil); len(blob) > 0 {
+		root = crypto.Keccak256Hash(blob)
+	}
+	return &pathDB{
+		history:  history,
+		diskRoot: root,
+		diskID:   rawdb.ReadPersistentStateID(diskdb),
+		layers:   make(map[common.Hash]*diffLayer),
+	}
+}
+
+// lookup retrieves the node with the given hash at the given path from any of
+// the in-memory layers, or nil if none of them contains it.
+func (p *pathDB) lookup(owner common.Hash, path []byte, hash common.Hash) *pathNode {
+	for _, layer := range p.layers {
+		if n := layer.nodes[owner][string(path)]; n != nil && n.hash == hash {
+			return n
+		}
+	}
+	return nil
+}
+
+// prune discards the layers which don't descend from the persisted state, they
+// were built on forks that can't be reached anymore.
+func (p *pathDB) prune() {
+	reachable := map[common.Hash]bool{p.diskRoot: true}
+
+	var reaches func(root common.Hash) bool
+	reaches = func(root common.Hash) bool {
+		if ok, known := reachable[root]; known {
+			return ok
+		}
+		layer := p.layers[root]
+		ok := layer != nil && reaches(layer.parent)
+		reachable[root] = ok
+		return ok
+	}
+	for root, layer := range p.layers {
+		if !reaches(root) {
+			delete(p.layers, root)
+			p.layersSize -= layer.size
+		}
+	}
+}
+
+// nodeByPath retrieves the trie node with the given hash at the given path of
+// the trie of owner, or returns nil if the node is not available, either in the
+// in-memory layers or in the persisted state.
+func (db *Database) nodeByPath(owner common.Hash, path []byte, hash common.Hash) node {
+	// Retrieve the node from the clean cache if available
+	if db.cleans != nil {
+		if enc := db.cleans.Get(nil, hash[:]); enc != nil {
+			memcacheCleanHitMeter.Mark(1)
+			memcacheCleanReadMeter.Mark(int64(len(enc)))
+			return mustDecodeNode(hash[:], enc)
+		}
+	}
+	// Retrieve the node from the in-memory layers if available
+	db.lock.RLock()
+	dirty := db.path.lookup(owner, path, hash)
+	db.lock.RUnlock()
+
+	if dirty != nil {
+		memcacheDirtyHitMeter.Mark(1)
+		memcacheDirtyReadMeter.Mark(int64(len(dirty.blob)))
+		return mustDecodeNode(hash[:], dirty.blob)
+	}
+	memcacheDirtyMissMeter.Mark(1)
+
+	// Content unavailable in memory, attempt to retrieve from disk. The node at
+	// the path might belong to another state, only accept the requested one.
+	var enc []byte
+	if owner == (common.Hash{}) {
+		enc = rawdb.ReadAccountTrieNode(db.diskdb, path)
+	} else {
+		enc = rawdb.ReadStorageTrieNode(db.diskdb, owner, path)
+	}
+	if len(enc) == 0 || crypto.Keccak256Hash(enc) != hash {
+		return nil
+	}
+	if db.cleans != nil {
+		db.cleans.Set(hash[:], enc)
+		memcacheCleanMissMeter.Mark(1)
+		memcacheCleanWriteMeter.Mark(int64(len(enc)))
+	}
+	return mustDecodeNode(hash[:], enc)
+}
+
+// Update seals the trie nodes committed by the tries of a state into a new
+// in-memory layer for the state with the given root, on top of its parent. The
+// storage tries of the destructed accounts are wiped before the nodes of the
+// layer are applied. The database takes ownership of the node set. It is a noop
+// in the hash-based scheme.
+func (db *Database) Update(root common.Hash, parent common.Hash, nodes *NodeSet, destructs map[common.Hash]struct{}) error {
+	if db.path == nil {
+		return nil
+	}
+	if nodes == nil {
+		nodes = NewNodeSet()
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	p := db.path
+
+	// The empty state is the base of the genesis, which is opened from a zero root
+	if parent == (common.Hash{}) {
+		parent = emptyRoot
+	}
+	// Skip states which are already known (no state transition or a re-import)
+	if root == parent || root == p.diskRoot || p.layers[root] != nil {
+		return nil
+	}
+	var id uint64
+	if parent == p.diskRoot {
+		id = p.diskID + 1
+	} else if layer := p.layers[parent]; layer != nil {
+		id = layer.id + 1
+	} else {
+		return fmt.Errorf("parent state %x not found", parent)
+	}
+	p.layers[root] = &diffLayer{
+		root:      root,
+		parent:    parent,
+		id:        id,
+		destructs: destructs,
+		nodes:     nodes.nodes,
+		size:      nodes.size,
+	}
+	p.layersSize += nodes.size
+	return nil
+}
+
+// Flatten persists the in-memory layers below the state with the given root,
+// retaining at most the given number of the most recent ones. The layers on the
+// other forks of the new persisted state are discarded. It is a noop in the
+// hash-based scheme.
+//
+// Note, this method is a non-synchronized mutator. It is unsafe to call this
+// concurrently with other mutators.
+func (db *Database) Flatten(root common.Hash, retain int) error {
+	if db.path == nil {
+		return nil
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	_, err := db.flatten(root, retain)
+	return err
+}
+
+// commitLayers persists all the in-memory layers up to the state with the given
+// root, along with all the accumulated preimages.
+func (db *Database) commitLayers(root common.Hash, report bool) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	start, size := time.Now(), db.path.layersSize
+	flattened, err := db.flatten(root, 0)
+	if err != nil {
+		log.Error("Failed to commit state from trie database", "err", err)
+		return err
+	}
+	if len(db.preimages) > 0 {
+		batch := db.diskdb.NewBatch()
+		rawdb.WritePreimages(batch, db.preimages)
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		db.preimages, db.preimagesSize = make(map[common.Hash][]byte), 0
+	}
+	logger := log.Info
+	if !report {
+		logger = log.Debug
+	}
+	logger("Persisted state layers from memory database", "root", root, "id", db.path.diskID, "layers", flattened,
+		"size", size-db.path.layersSize, "time", time.Since(start), "livelayers", len(db.path.layers), "livesize", db.path.layersSize)
+	return nil
+}
+
+// flatten is the private locked version of Flatten, returning the number of
+// layers persisted.
+func (db *Database) flatten(root common.Hash, retain int) (int, error) {
+	// Gather the layers between the requested state and the persisted one
+	var chain []*diffLayer
+	for hash := root; hash != db.path.diskRoot; {
+		layer := db.path.layers[hash]
+		if layer == nil {
+			return 0, fmt.Errorf("state %x not found", root)
+		}
+		chain = append(chain, layer)
+		hash = layer.parent
+	}
+	if len(chain) <= retain {
+		return 0, nil
+	}
+	// Persist the layers out of the retention window, bottom-most first
+	for i := len(chain) - 1; i >= retain; i-- {
+		if err := db.persist(chain[i]); err != nil {
+			return 0, err
+		}
+	}
+	db.path.prune()
+	return len(chain) - retain, nil
+}
+
+// persist writes the nodes of a layer directly on top of the persisted state,
+// alongside the reverse diff to undo it.
+//
+// Note, this method assumes that the database's lock is held!
+func (db *Database) persist(layer *diffLayer) error {
+	var (
+		start = time.Now()
+		batch = db.diskdb.NewBatch()
+		diff  = &reverseDiff{Parent: db.path.diskRoot, Root: layer.root}
+	)
+	// Wipe the storage tries of the destructed accounts, the nodes of the layer
+	// are written afterwards and override the deletions of any recreated ones
+	wiped := make(map[common.Hash]map[string][]byte)
+	for owner := range layer.destructs {
+		nodes := rawdb.ReadStorageTrieNodes(db.diskdb, owner)
+		for path, prev := range nodes {
+			rawdb.DeleteStorageTrieNode(batch, owner, []byte(path))
+			diff.Nodes = append(diff.Nodes, journalNode{Owner: owner, Path: []byte(path), Prev: prev})
+		}
+		wiped[owner] = nodes
+	}
+	for owner, subset := range layer.nodes {
+		for path, n := range subset {
+			var prev []byte
+			if owner == (common.Hash{}) {
+				prev = rawdb.ReadAccountTrieNode(db.diskdb, []byte(path))
+				if len(n.blob) == 0 {
+					rawdb.DeleteAccountTrieNode(batch, []byte(path))
+				} else {
+					rawdb.WriteAccountTrieNode(batch, []byte(path), n.blob)
+				}
+			} else {
+				prev = rawdb.ReadStorageTrieNode(db.diskdb, owner, []byte(path))
+				if len(n.blob) == 0 {
+					rawdb.DeleteStorageTrieNode(batch, owner, []byte(path))
+				} else {
+					rawdb.WriteStorageTrieNode(batch, owner, []byte(path), n.blob)
+				}
+			}
+			// Nodes already journaled by a wipe only need to be written
+			if _, ok := wiped[owner][path]; ok {
+				continue
+			}
+			diff.Nodes = append(diff.Nodes, journalNode{Owner: owner, Path: []byte(path), Prev: prev})
+		}
+	}
+	blob, err := rlp.EncodeToBytes(diff)
+	if err != nil {
+		return err
+	}
+	rawdb.WriteReverseDiff(batch, layer.id, blob)
+	rawdb.WriteStateID(batch, layer.root, layer.id)
+	rawdb.WritePersistentStateID(batch, layer.id)
+
+	// Drop the reverse diff falling out of the retained history
+	if db.path.history > 0 && layer.id > db.path.history {
+		db.truncate(batch, layer.id-db.path.history)
+	}
+	if len(db.preimages) > 0 {
+		rawdb.WritePreimages(batch, db.preimages)
+	}
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to write state layer to disk", "err", err)
+		return err
+	}
+	if db.preimages != nil {
+		db.preimages, db.preimagesSize = make(map[common.Hash][]byte), 0
+	}
+	// Move the persisted nodes into the clean cache to prevent insta-reloads
+	if db.cleans != nil {
+		for _, subset := range layer.nodes {
+			for _, n := range subset {
+				if len(n.blob) == 0 {
+					continue
+				}
+				db.cleans.Set(n.hash[:], n.blob)
+				memcacheCleanWriteMeter.Mark(int64(len(n.blob)))
+			}
+		}
+	}
+	delete(db.path.layers, layer.root)
+	db.path.layersSize -= layer.size
+	db.path.diskRoot, db.path.diskID = layer.root, layer.id
+
+	memcacheFlushTimeTimer.Update(time.Since(start))
+	memcacheFlushSizeMeter.Mark(int64(layer.size))
+	memcacheFlushNodesMeter.Mark(int64(len(diff.Nodes)))
+
+	log.Debug("Persisted state layer", "root", layer.root, "id", layer.id, "nodes", len(diff.Nodes), "size", layer.size, "time", time.Since(start))
+	return nil
+}
+
+// truncate deletes the reverse diff with the given id, after which the parent
+// state of it can't be recovered anymore.
+func (db *Database) truncate(batch ethdb.KeyValueWriter, id uint64) {
+	blob := rawdb.ReadReverseDiff(db.diskdb, id)
+	if len(blob) == 0 {
+		return
+	}
+	var diff reverseDiff
+	if err := rlp.DecodeBytes(blob, &diff); err == nil {
+		// The same state might have been reached again later, keep that one
+		if sid := rawdb.ReadStateID(db.diskdb, diff.Parent); sid != nil && *sid == id-1 {
+			rawdb.DeleteStateID(batch, diff.Parent)
+		}
+	}
+	rawdb.DeleteReverseDiff(batch, id)
+}
+
+// Recoverable returns whether the persisted state can be reverted to the state
+// with the given root, by applying the retained reverse diffs. It is always false
+// in the hash-based scheme.
+func (db *Database) Recoverable(root common.Hash) bool {
+	if db.path == nil {
+		return false
+	}
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	id := rawdb.ReadStateID(db.diskdb, root)
+	if id == nil || *id >= db.path.diskID {
+		return false
+	}
+	// Reverse diffs are dropped oldest first, if the one right above the target
+	// state is available, all of them are.
+	return len(rawdb.ReadReverseDiff(db.diskdb, *id+1)) > 0
+}
+
+// Recover reverts the persisted state to the one with the given root, by applying
+// the reverse diffs in between. All the in-memory layers are discarded, as they
+// are descendants of the reverted state.
+//
+// Note, this method is a non-synchronized mutator. It is unsafe to call this
+// concurrently with other mutators.
+func (db *Database) Recover(root common.Hash) error {
+	if !db.Recoverable(root) {
+		return errStateNotRecoverable
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	p := db.path
+	p.layers, p.layersSize = make(map[common.Hash]*diffLayer), 0
+
+	start := time.Now()
+	for p.diskRoot != root {
+		var (
+			id   = p.diskID
+			diff reverseDiff
+		)
+		if err := rlp.DecodeBytes(rawdb.ReadReverseDiff(db.diskdb, id), &diff); err != nil {
+			return fmt.Errorf("invalid reverse diff %d: %v", id, err)
+		}
+		if diff.Root != p.diskRoot {
+			return fmt.Errorf("reverse diff %d mismatch: have %x, want %x", id, diff.Root, p.diskRoot)
+		}
+		batch := db.diskdb.NewBatch()
+		for _, n := range diff.Nodes {
+			switch {
+			case n.Owner == (common.Hash{}) && len(n.Prev) == 0:
+				rawdb.DeleteAccountTrieNode(batch, n.Path)
+			case n.Owner == (common.Hash{}):
+				rawdb.WriteAccountTrieNode(batch, n.Path, n.Prev)
+			case len(n.Prev) == 0:
+				rawdb.DeleteStorageTrieNode(batch, n.Owner, n.Path)
+			default:
+				rawdb.WriteStorageTrieNode(batch, n.Owner, n.Path, n.Prev)
+			}
+		}
+		if sid := rawdb.ReadStateID(db.diskdb, diff.Root); sid != nil && *sid == id {
+			rawdb.DeleteStateID(batch, diff.Root)
+		}
+		rawdb.DeleteReverseDiff(batch, id)
+		rawdb.WritePersistentStateID(batch, id-1)
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		p.diskRoot, p.diskID = diff.Parent, id-1
+	}
+	log.Info("Recovered persisted state", "root", root, "id", p.diskID, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
@@ -0,0 +1,387 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+)
+
+// pathTestState is a state committed into a path-based trie database, along
+// with the key-value pairs it's expected to contain.
+type pathTestState struct {
+	root common.Hash
+	vals map[string]string
+}
+
+// makePathTestStates commits a sequence of states into the database, each one
+// overwriting a few keys of its parent and adding a few new ones.
+func makePathTestStates(t *testing.T, db *Database, n int) []pathTestState {
+	var (
+		states []pathTestState
+		parent common.Hash
+		vals   = make(map[string]string)
+	)
+	for i := 0; i < n; i++ {
+		trie, err := New(parent, db)
+		if err != nil {
+			t.Fatalf("state %d: failed to open parent: %v", i, err)
+		}
+		for j := 0; j < 50; j++ {
+			key, val := fmt.Sprintf("key-%d-%d", i, j), fmt.Sprintf("val-%d-%d", i, j)
+			if j < 10 && i > 0 {
+				key = fmt.Sprintf("key-%d-%d", i-1, j)
+			}
+			trie.Update([]byte(key), []byte(val))
+			vals[key] = val
+		}
+		root, nodes, _, err := trie.CommitNodes(nil)
+		if err != nil {
+			t.Fatalf("state %d: failed to commit: %v", i, err)
+		}
+		if err := db.Update(root, parent, nodes, nil); err != nil {
+			t.Fatalf("state %d: failed to update: %v", i, err)
+		}
+		cpy := make(map[string]string)
+		for k, v := range vals {
+			cpy[k] = v
+		}
+		states = append(states, pathTestState{root: root, vals: cpy})
+		parent = root
+	}
+	return states
+}
+
+// checkPathTestState checks that the state is fully accessible in the database.
+func checkPathTestState(t *testing.T, db *Database, state pathTestState) {
+	t.Helper()
+
+	trie, err := New(state.root, db)
+	if err != nil {
+		t.Fatalf("failed to open state %x: %v", state.root, err)
+	}
+	for k, v := range state.vals {
+		have, err := trie.TryGet([]byte(k))
+		if err != nil {
+			t.Fatalf("state %x: failed to retrieve %q: %v", state.root, k, err)
+		}
+		if !bytes.Equal(have, []byte(v)) {
+			t.Fatalf("state %x: value mismatch for %q: have %q, want %q", state.root, k, have, v)
+		}
+	}
+	it := NewIterator(trie.NodeIterator(nil))
+	count := 0
+	for it.Next() {
+		count++
+	}
+	if it.Err != nil {
+		t.Fatalf("state %x: failed to iterate: %v", state.root, it.Err)
+	}
+	if count != len(state.vals) {
+		t.Fatalf("state %x: item count mismatch: have %d, want %d", state.root, count, len(state.vals))
+	}
+}
+
+// Tests that states stay accessible in the path-based scheme while they are in
+// memory, and that only the persisted one remains after flattening.
+func TestPathDatabaseFlatten(t *testing.T) {
+	diskdb := memorydb.New()
+	db := NewDatabaseWithConfig(diskdb, &Config{Scheme: rawdb.PathScheme})
+	if db.Scheme() != rawdb.PathScheme {
+		t.Fatalf("scheme mismatch: have %s, want %s", db.Scheme(), rawdb.PathScheme)
+	}
+	states := makePathTestStates(t, db, 8)
+	for _, state := range states {
+		checkPathTestState(t, db, state)
+	}
+	// Flatten all but the last two states, which should be kept in memory
+	if err := db.Flatten(states[7].root, 2); err != nil {
+		t.Fatalf("failed to flatten: %v", err)
+	}
+	for _, state := range states[5:] {
+		checkPathTestState(t, db, state)
+	}
+	if _, err := New(states[1].root, db); err == nil {
+		t.Fatalf("stale state %x still accessible", states[1].root)
+	}
+	// Persist everything and reopen the database
+	if err := db.Commit(states[7].root, false, nil); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	db = NewDatabaseWithConfig(diskdb, &Config{Scheme: rawdb.PathScheme})
+	checkPathTestState(t, db, states[7])
+
+	if id := rawdb.ReadPersistentStateID(diskdb); id != 8 {
+		t.Fatalf("persistent state id mismatch: have %d, want %d", id, 8)
+	}
+}
+
+// Tests that the persisted state can be reverted via the reverse diffs, as long
+// as they are retained.
+func TestPathDatabaseRecover(t *testing.T) {
+	diskdb := memorydb.New()
+	db := NewDatabaseWithConfig(diskdb, &Config{Scheme: rawdb.PathScheme, StateHistory: 4})
+	states := makePathTestStates(t, db, 8)
+	if err := db.Commit(states[7].root, false, nil); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	for i, state := range states[:7] {
+		if want := i >= 3; db.Recoverable(state.root) != want {
+			t.Fatalf("state %d: recoverable mismatch: have %v, want %v", i, !want, want)
+		}
+	}
+	if err := db.Recover(states[2].root); err != errStateNotRecoverable {
+		t.Fatalf("pruned state recovery error mismatch: have %v, want %v", err, errStateNotRecoverable)
+	}
+	if err := db.Recover(states[4].root); err != nil {
+		t.Fatalf("failed to recover: %v", err)
+	}
+	checkPathTestState(t, db, states[4])
+
+	// Build on top of the recovered state and persist it again
+	db = NewDatabaseWithConfig(diskdb, &Config{Scheme: rawdb.PathScheme, StateHistory: 4})
+	checkPathTestState(t, db, states[4])
+
+	trie, _ := New(states[4].root, db)
+	trie.Update([]byte("key-fork"), []byte("val-fork"))
+	root, nodes, _, _ := trie.CommitNodes(nil)
+	if err := db.Update(root, states[4].root, nodes, nil); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	if err := db.Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	vals := map[string]string{"key-fork": "val-fork"}
+	for k, v := range states[4].vals {
+		vals[k] = v
+	}
+	checkPathTestState(t, db, pathTestState{root: root, vals: vals})
+	if db.Recoverable(states[5].root) {
+		t.Fatalf("reverted state %x still recoverable", states[5].root)
+	}
+	if !db.Recoverable(states[4].root) {
+		t.Fatalf("parent state %x not recoverable", states[4].root)
+	}
+}
+
+// countPathNodes returns the number of trie nodes of the given owner persisted
+// in the disk, and the number of them reachable from the given root.
+func countPathNodes(t *testing.T, db *Database, owner common.Hash, root common.Hash) (int, int) {
+	t.Helper()
+
+	prefix := rawdb.TrieNodeAccountPrefix
+	if owner != (common.Hash{}) {
+		prefix = append(append([]byte{}, rawdb.TrieNodeStoragePrefix...), owner.Bytes()...)
+	}
+	it := db.DiskDB().NewIterator(prefix, nil)
+	defer it.Release()
+
+	var disk int
+	for it.Next() {
+		disk++
+	}
+	trie, err := NewWithOwner(owner, root, db)
+	if err != nil {
+		t.Fatalf("failed to open state %x: %v", root, err)
+	}
+	var reachable int
+	for nodes := trie.NodeIterator(nil); nodes.Next(true); {
+		if nodes.Hash() != (common.Hash{}) {
+			reachable++
+		}
+	}
+	return disk, reachable
+}
+
+// Tests that the nodes removed from a trie are deleted from the disk once the
+// state is persisted, and restored when it gets reverted.
+func TestPathDatabaseDeletions(t *testing.T) {
+	diskdb := memorydb.New()
+	db := NewDatabaseWithConfig(diskdb, &Config{Scheme: rawdb.PathScheme})
+	states := makePathTestStates(t, db, 2)
+	if err := db.Commit(states[1].root, false, nil); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	// Delete most of the keys, collapsing the trie
+	trie, _ := New(states[1].root, db)
+	vals := make(map[string]string)
+	for k, v := range states[1].vals {
+		if len(vals) < 5 {
+			vals[k] = v
+			continue
+		}
+		trie.Delete([]byte(k))
+	}
+	root, nodes, _, _ := trie.CommitNodes(nil)
+	if err := db.Update(root, states[1].root, nodes, nil); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	if err := db.Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	checkPathTestState(t, db, pathTestState{root: root, vals: vals})
+	if disk, reachable := countPathNodes(t, db, common.Hash{}, root); disk != reachable {
+		t.Fatalf("stale nodes left in disk: have %d, want %d", disk, reachable)
+	}
+	// Revert the deletions and check the original state is intact
+	if err := db.Recover(states[1].root); err != nil {
+		t.Fatalf("failed to recover: %v", err)
+	}
+	checkPathTestState(t, db, states[1])
+	if disk, reachable := countPathNodes(t, db, common.Hash{}, states[1].root); disk != reachable {
+		t.Fatalf("node count mismatch after recovery: have %d, want %d", disk, reachable)
+	}
+}
+
+// Tests that the storage trie of a destructed account is wiped from the disk
+// once the state is persisted, and restored when it gets reverted.
+func TestPathDatabaseDestructs(t *testing.T) {
+	var (
+		diskdb = memorydb.New()
+		db     = NewDatabaseWithConfig(diskdb, &Config{Scheme: rawdb.PathScheme})
+		owner  = common.Hash{0xff}
+	)
+	storage, _ := NewWithOwner(owner, common.Hash{}, db)
+	for i := 0; i < 20; i++ {
+		storage.Update([]byte(fmt.Sprintf("slot-%d", i)), []byte(fmt.Sprintf("val-%d", i)))
+	}
+	sroot, nodes, _, _ := storage.CommitNodes(nil)
+
+	accounts, _ := New(common.Hash{}, db)
+	accounts.Update([]byte("account"), sroot[:])
+	parent, set, _, _ := accounts.CommitNodes(nil)
+	nodes.Merge(set)
+	if err := db.Update(parent, common.Hash{}, nodes, nil); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	// Destruct the account in the next state
+	accounts.Update([]byte("account"), emptyRoot[:])
+	root, nodes, _, _ := accounts.CommitNodes(nil)
+	if err := db.Update(root, parent, nodes, map[common.Hash]struct{}{owner: {}}); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	if err := db.Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if nodes := rawdb.ReadStorageTrieNodes(diskdb, owner); len(nodes) != 0 {
+		t.Fatalf("destructed storage left in disk: %d nodes", len(nodes))
+	}
+	// Revert the destruction and check the storage is intact
+	if err := db.Recover(parent); err != nil {
+		t.Fatalf("failed to recover: %v", err)
+	}
+	if disk, reachable := countPathNodes(t, db, owner, sroot); disk != reachable || disk == 0 {
+		t.Fatalf("storage node count mismatch after recovery: have %d, want %d", disk, reachable)
+	}
+}
+
+// Tests that a node set is left intact if its parent state is unknown, so the
+// update can be retried on the right one.
+func TestPathDatabaseUnknownParent(t *testing.T) {
+	db := NewDatabaseWithConfig(memorydb.New(), &Config{Scheme: rawdb.PathScheme})
+	trie, _ := New(common.Hash{}, db)
+	trie.Update([]byte("key"), []byte("val"))
+	root, nodes, _, _ := trie.CommitNodes(nil)
+
+	if err := db.Update(root, common.Hash{1}, nodes, nil); err == nil {
+		t.Fatalf("update on unknown parent succeeded")
+	}
+	if err := db.Update(root, common.Hash{}, nodes, nil); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	checkPathTestState(t, db, pathTestState{root: root, vals: map[string]string{"key": "val"}})
+}
+
+// Tests that the nodes committed for different states don't mix, even if the
+// states are committed concurrently and sealed in another order.
+func TestPathDatabaseInterleavedCommits(t *testing.T) {
+	db := NewDatabaseWithConfig(memorydb.New(), &Config{Scheme: rawdb.PathScheme})
+	base, _ := New(common.Hash{}, db)
+	base.Update([]byte("key"), []byte("val"))
+	parent, nodes, _, _ := base.CommitNodes(nil)
+	if err := db.Update(parent, common.Hash{}, nodes, nil); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	var (
+		states = make([]pathTestState, 2)
+		sets   = make([]*NodeSet, 2)
+		wg     sync.WaitGroup
+	)
+	for i := range states {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			trie, _ := New(parent, db)
+			vals := map[string]string{"key": "val"}
+			for j := 0; j < 100; j++ {
+				key, val := fmt.Sprintf("key-%d", j), fmt.Sprintf("val-%d-%d", i, j)
+				trie.Update([]byte(key), []byte(val))
+				vals[key] = val
+			}
+			root, set, _, err := trie.CommitNodes(nil)
+			if err != nil {
+				t.Errorf("state %d: failed to commit: %v", i, err)
+			}
+			states[i], sets[i] = pathTestState{root: root, vals: vals}, set
+		}(i)
+	}
+	wg.Wait()
+
+	for i := len(states) - 1; i >= 0; i-- {
+		if err := db.Update(states[i].root, parent, sets[i], nil); err != nil {
+			t.Fatalf("state %d: failed to update: %v", i, err)
+		}
+	}
+	for _, state := range states {
+		checkPathTestState(t, db, state)
+	}
+	// Persisting one of the states must not leak nodes of the other into the disk
+	if err := db.Commit(states[0].root, false, nil); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	checkPathTestState(t, db, states[0])
+	if disk, reachable := countPathNodes(t, db, common.Hash{}, states[0].root); disk != reachable {
+		t.Fatalf("foreign nodes persisted: have %d, want %d", disk, reachable)
+	}
+	// Tries of the path scheme can't be committed without collecting the nodes
+	trie, _ := New(states[0].root, db)
+	trie.Update([]byte("key"), []byte("other"))
+	if _, _, err := trie.Commit(nil); err != errPathCommit {
+		t.Fatalf("commit error mismatch: have %v, want %v", err, errPathCommit)
+	}
+}
+
+// Tests that the hash-based scheme is still the default one.
+func TestDefaultStateScheme(t *testing.T) {
+	db := NewDatabase(memorydb.New())
+	if db.Scheme() != rawdb.HashScheme {
+		t.Fatalf("scheme mismatch: have %s, want %s", db.Scheme(), rawdb.HashScheme)
+	}
+	if err := db.Update(common.Hash{1}, common.Hash{}, nil, nil); err != nil {
+		t.Fatalf("update failed in hash scheme: %v", err)
+	}
+	if db.Recoverable(common.Hash{1}) {
+		t.Fatalf("state recoverable in hash scheme")
+	}
+}
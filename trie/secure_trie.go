@@ -54,10 +54,17 @@ type SecureTrie struct {
 // A new cache generation is created by each call to Commit.
 // cachelimit sets the number of past cache generations to keep.
 func NewSecure(root common.Hash, db *Database) (*SecureTrie, error) {
+	return NewSecureWithOwner(common.Hash{}, root, db)
+}
+
+// NewSecureWithOwner creates a secure trie with an existing root node from a
+// backing database, owned by the account with the given hash. It's meant for
+// opening storage tries, see NewWithOwner.
+func NewSecureWithOwner(owner common.Hash, root common.Hash, db *Database) (*SecureTrie, error) {
 	if db == nil {
 		panic("trie.NewSecure called without a database")
 	}
-	trie, err := New(root, db)
+	trie, err := NewWithOwner(owner, root, db)
 	if err != nil {
 		return nil, err
 	}
@@ -162,6 +169,16 @@ func (t *SecureTrie) GetKey(shaKey []byte) []byte {
 // Committing flushes nodes from memory. Subsequent Get calls will load nodes
 // from the database.
 func (t *SecureTrie) Commit(onleaf LeafCallback) (common.Hash, int, error) {
+	if t.trie.db.path != nil {
+		return common.Hash{}, 0, errPathCommit
+	}
+	root, _, committed, err := t.CommitNodes(onleaf)
+	return root, committed, err
+}
+
+// CommitNodes is like Commit, but returns the committed nodes as a set in the
+// path-based scheme, see Trie.CommitNodes.
+func (t *SecureTrie) CommitNodes(onleaf LeafCallback) (common.Hash, *NodeSet, int, error) {
 	// Write all the pre-images to the actual disk database
 	if len(t.getSecKeyCache()) > 0 {
 		if t.trie.db.preimages != nil { // Ugly direct check but avoids the below write lock
@@ -174,7 +191,7 @@ func (t *SecureTrie) Commit(onleaf LeafCallback) (common.Hash, int, error) {
 		t.secKeyCache = make(map[string][]byte)
 	}
 	// Commit the trie to its intermediate node database
-	return t.trie.Commit(onleaf)
+	return t.trie.CommitNodes(onleaf)
 }
 
 // Hash returns the root hash of SecureTrie. It does not write to the
@@ -186,6 +203,12 @@ func (t *SecureTrie) Hash() common.Hash {
 // Copy returns a copy of SecureTrie.
 func (t *SecureTrie) Copy() *SecureTrie {
 	cpy := *t
+	if t.trie.deleted != nil {
+		cpy.trie.deleted = make(map[string]struct{}, len(t.trie.deleted))
+		for path := range t.trie.deleted {
+			cpy.trie.deleted[path] = struct{}{}
+		}
+	}
 	return &cpy
 }
 
@@ -60,8 +60,12 @@ type LeafCallback func(paths [][]byte, hexpath []byte, leaf []byte, parent commo
 //
 // Trie is not safe for concurrent use.
 type Trie struct {
-	db   *Database
-	root node
+	db    *Database
+	root  node
+	owner common.Hash // Account hash of storage tries, zero for the account trie
+	// Keep track of the paths of the nodes removed since the last commit, so
+	// that the path-based scheme can drop them from the disk too.
+	deleted map[string]struct{}
 	// Keep track of the number leafs which have been inserted since the last
 	// hashing operation. This number will not directly map to the number of
 	// actually unhashed nodes
@@ -73,6 +77,18 @@ func (t *Trie) newFlag() nodeFlag {
 	return nodeFlag{dirty: true}
 }
 
+// onDelete records the removal of the node previously stored at the given path.
+// It is a noop in the hash-based scheme, where nodes are never removed.
+func (t *Trie) onDelete(path []byte) {
+	if t.db == nil || t.db.path == nil {
+		return
+	}
+	if t.deleted == nil {
+		t.deleted = make(map[string]struct{})
+	}
+	t.deleted[string(path)] = struct{}{}
+}
+
 // New creates a trie with an existing root node from db.
 //
 // If root is the zero hash or the sha3 hash of an empty string, the
@@ -80,11 +96,19 @@ func (t *Trie) newFlag() nodeFlag {
 // New will panic if db is nil and returns a MissingNodeError if root does
 // not exist in the database. Accessing the trie loads nodes from db on demand.
 func New(root common.Hash, db *Database) (*Trie, error) {
+	return NewWithOwner(common.Hash{}, root, db)
+}
+
+// NewWithOwner creates a trie with an existing root node from db, owned by the
+// account with the given hash. Storage tries must be opened with their owner,
+// since the path-based scheme keys their nodes by it.
+func NewWithOwner(owner common.Hash, root common.Hash, db *Database) (*Trie, error) {
 	if db == nil {
 		panic("trie.New called without a database")
 	}
 	trie := &Trie{
-		db: db,
+		db:    db,
+		owner: owner,
 	}
 	if root != (common.Hash{}) && root != emptyRoot {
 		rootnode, err := trie.resolveHash(root[:], nil)
@@ -383,6 +407,7 @@ func (t *Trie) delete(n node, prefix, key []byte) (bool, node, error) {
 			return false, n, nil // don't replace n on mismatch
 		}
 		if matchlen == len(key) {
+			t.onDelete(prefix)
 			return true, nil, nil // remove n entirely for whole matches
 		}
 		// The key is longer than n.Key. Remove the remaining suffix
@@ -401,6 +426,7 @@ func (t *Trie) delete(n node, prefix, key []byte) (bool, node, error) {
 			// always creates a new slice) instead of append to
 			// avoid modifying n.Key since it might be shared with
 			// other nodes.
+			t.onDelete(concat(prefix, n.Key...))
 			return true, &shortNode{concat(n.Key, child.Key...), child.Val, t.newFlag()}, nil
 		default:
 			return true, &shortNode{n.Key, child, t.newFlag()}, nil
@@ -451,11 +477,12 @@ func (t *Trie) delete(n node, prefix, key []byte) (bool, node, error) {
 				// shortNode{..., shortNode{...}}.  Since the entry
 				// might not be loaded yet, resolve it just for this
 				// check.
-				cnode, err := t.resolve(n.Children[pos], prefix)
+				cnode, err := t.resolve(n.Children[pos], append(prefix, byte(pos)))
 				if err != nil {
 					return false, nil, err
 				}
 				if cnode, ok := cnode.(*shortNode); ok {
+					t.onDelete(concat(prefix, byte(pos)))
 					k := append([]byte{byte(pos)}, cnode.Key...)
 					return true, &shortNode{k, cnode.Val, t.newFlag()}, nil
 				}
@@ -508,7 +535,11 @@ func (t *Trie) resolve(n node, prefix []byte) (node, error) {
 
 func (t *Trie) resolveHash(n hashNode, prefix []byte) (node, error) {
 	hash := common.BytesToHash(n)
-	if node := t.db.node(hash); node != nil {
+	if t.db.path != nil {
+		if node := t.db.nodeByPath(t.owner, prefix, hash); node != nil {
+			return node, nil
+		}
+	} else if node := t.db.node(hash); node != nil {
 		return node, nil
 	}
 	return nil, &MissingNodeError{NodeHash: hash, Path: prefix}
@@ -523,25 +554,52 @@ func (t *Trie) Hash() common.Hash {
 }
 
 // Commit writes all nodes to the trie's memory database, tracking the internal
-// and external (for account tries) references.
+// and external (for account tries) references. In the path-based scheme, the
+// nodes of a state have to be sealed together with Database.Update, so tries
+// there must be committed with CommitNodes instead.
 func (t *Trie) Commit(onleaf LeafCallback) (common.Hash, int, error) {
+	if t.db != nil && t.db.path != nil {
+		return common.Hash{}, 0, errPathCommit
+	}
+	root, _, committed, err := t.CommitNodes(onleaf)
+	return root, committed, err
+}
+
+// CommitNodes is like Commit, but in the path-based scheme it collects the
+// committed nodes into a set owned by the caller rather than the database. The
+// sets of all the tries of a state are to be passed to Database.Update. The
+// returned set is nil in the hash-based scheme.
+func (t *Trie) CommitNodes(onleaf LeafCallback) (common.Hash, *NodeSet, int, error) {
 	if t.db == nil {
 		panic("commit called on trie with nil database")
 	}
+	var nodes *NodeSet
+	if t.db.path != nil {
+		nodes = NewNodeSet()
+
+		// Drop the removed nodes first, the committed ones might be stored at
+		// the same paths and have to take precedence.
+		for path := range t.deleted {
+			nodes.remove(t.owner, []byte(path))
+		}
+		t.deleted = nil
+	}
 	if t.root == nil {
-		return emptyRoot, 0, nil
+		return emptyRoot, nodes, 0, nil
 	}
 	// Derive the hash for all dirty nodes first. We hold the assumption
 	// in the following procedure that all nodes are hashed.
 	rootHash := t.Hash()
 	h := newCommitter()
+	h.owner = t.owner
+	h.nodes = nodes
 	defer returnCommitterToPool(h)
 
 	// Do a quick check if we really need to commit, before we spin
 	// up goroutines. This can happen e.g. if we load a trie for reading storage
 	// values, but don't write to it.
 	if _, dirty := t.root.cache(); !dirty {
-		return rootHash, 0, nil
+		return rootHash, nodes, 0, nil
 	}
 	var wg sync.WaitGroup
 	if onleaf != nil {
@@ -563,10 +621,10 @@ func (t *Trie) Commit(onleaf LeafCallback) (common.Hash, int, error) {
 		wg.Wait()
 	}
 	if err != nil {
-		return common.Hash{}, 0, err
+		return common.Hash{}, nil, 0, err
 	}
 	t.root = newRoot
-	return rootHash, committed, nil
+	return rootHash, nodes, committed, nil
 }
 
 // hashRoot calculates the root hash of the given trie
@@ -585,5 +643,6 @@ func (t *Trie) hashRoot() (node, node, error) {
 // Reset drops the referenced root node and cleans all internal state.
 func (t *Trie) Reset() {
 	t.root = nil
+	t.deleted = nil
 	t.unhashed = 0
 }